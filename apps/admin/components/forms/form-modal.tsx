@@ -19,7 +19,7 @@ export function FormModal({ resource, item, onClose }: FormModalProps) {
   const handleSubmit = (data: Record<string, unknown>) => {
     if (isEdit) {
       update(
-        { id: Number(item.id), body: data },
+        { id: Number(item.id), body: { ...data, version: item.version } },
         { onSuccess: () => onClose() }
       );
     } else {
@@ -31,7 +31,7 @@ export function FormPage({ resource }: FormPageProps) {
   const handleSubmit = (data: Record<string, unknown>) => {
     if (isEdit && editId) {
       update(
-        { id: Number(editId), body: data },
+        { id: Number(editId), body: { ...data, version: (item?.data as Record<string, unknown> | undefined)?.version } },
         { onSuccess: () => router.push(`/resources/${resource.slug}`) }
       );
     } else {
@@ -99,7 +99,11 @@ export function useUpdateResource(endpoint: string) {
       toast.success("Updated successfully");
     },
     onError: (err: unknown) => {
-      const axiosErr = err as { response?: { data?: { error?: { message?: string } } } };
+      const axiosErr = err as { response?: { status?: number; data?: { error?: { message?: string } } } };
+      if (axiosErr?.response?.status === 409) {
+        // Changed elsewhere: reload so the next edit starts from the current version
+        queryClient.invalidateQueries({ queryKey: [endpoint] });
+      }
       toast.error(axiosErr?.response?.data?.error?.message || "Failed to update");
     },
   });
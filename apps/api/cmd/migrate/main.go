@@ -1,18 +1,25 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 
+	"desis-keep/apps/api/internal/ai"
 	"desis-keep/apps/api/internal/config"
 	"desis-keep/apps/api/internal/database"
 	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/services"
+	"desis-keep/apps/api/internal/storage"
 )
 
 func main() {
 	fresh := flag.Bool("fresh", false, "Drop all tables before migrating")
+	extract := flag.Bool("extract", false, "Extract the text of uploaded files that have none yet after migrating")
+	reindex := flag.Bool("reindex", false, "Rebuild the full-text search index after migrating")
+	reembed := flag.Bool("reembed", false, "Embed changed resources for semantic search after migrating")
 	flag.Parse()
 
 	cfg, err := config.Load()
@@ -39,5 +46,41 @@ func main() {
 	}
 
 	fmt.Println("Migrations completed successfully.")
+
+	if *extract {
+		fmt.Println("Extracting file text...")
+		store, err := storage.New(cfg.Storage)
+		if err != nil {
+			log.Fatalf("Storage unavailable: %v", err)
+		}
+		count, err := services.NewFileTextService(db, store).ExtractAll(context.Background())
+		if err != nil {
+			log.Fatalf("Extraction failed: %v", err)
+		}
+		fmt.Printf("Checked %d file(s).\n", count)
+	}
+
+	if *reindex {
+		fmt.Println("Rebuilding search index...")
+		count, err := services.NewSearchService(db).ReindexAll()
+		if err != nil {
+			log.Fatalf("Reindex failed: %v", err)
+		}
+		fmt.Printf("Indexed %d resource(s).\n", count)
+	}
+
+	if *reembed {
+		fmt.Println("Embedding resources...")
+		embedder, err := ai.NewProvider(cfg.AIEmbeddingConfig())
+		if err != nil {
+			log.Fatalf("Embedding provider unavailable: %v", err)
+		}
+		aiService := ai.New(nil, embedder)
+		count, err := services.NewEmbeddingService(db, aiService).EmbedAll(context.Background())
+		if err != nil {
+			log.Fatalf("Embedding failed: %v", err)
+		}
+		fmt.Printf("Checked %d resource(s) with %s.\n", count, aiService.EmbeddingModel())
+	}
 	os.Exit(0)
 }
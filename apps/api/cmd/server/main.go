@@ -12,12 +12,14 @@ import (
 
 	"desis-keep/apps/api/internal/ai"
 	"desis-keep/apps/api/internal/cache"
+	"desis-keep/apps/api/internal/collab"
 	"desis-keep/apps/api/internal/config"
 	"desis-keep/apps/api/internal/cron"
 	"desis-keep/apps/api/internal/database"
 	"desis-keep/apps/api/internal/jobs"
 	"desis-keep/apps/api/internal/mail"
 	"desis-keep/apps/api/internal/routes"
+	"desis-keep/apps/api/internal/services"
 	"desis-keep/apps/api/internal/storage"
 )
 
@@ -70,10 +72,20 @@ func main() {
 	}
 
 	// AI service
+	// AI service (completions and embeddings can use different providers;
+	// the default local embedder needs no API key)
 	var aiService *ai.AI
-	if cfg.AIAPIKey != "" {
-		aiService = ai.New(cfg.AIProvider, cfg.AIAPIKey, cfg.AIModel)
-		log.Printf("AI service configured (%s)", cfg.AIProvider)
+	completer, err := ai.NewProvider(cfg.AIConfig())
+	if err != nil {
+		log.Printf("Warning: AI completions disabled: %v", err)
+	}
+	embedder, err := ai.NewProvider(cfg.AIEmbeddingConfig())
+	if err != nil {
+		log.Printf("Warning: AI embeddings disabled: %v", err)
+	}
+	if completer != nil || embedder != nil {
+		aiService = ai.New(completer, embedder)
+		log.Printf("AI service configured (%s, embeddings: %s)", cfg.AIProvider, aiService.EmbeddingModel())
 	}
 
 	// Background jobs (asynq)
@@ -95,6 +107,7 @@ func main() {
 		Mailer:  mailer,
 		AI:      aiService,
 		Jobs:    jobClient,
+		Collab:  collab.NewHub(db, cacheService, jobClient),
 	}
 
 	// Setup router
@@ -108,6 +121,13 @@ func main() {
 			Mailer:  mailer,
 			Storage: storageService,
 			Cache:   cacheService,
+			AI:      aiService,
+			Usage:   services.NewUsageService(db, cfg.AIMonthlyTokenLimit),
+			Jobs:    jobClient,
+
+			UpdateRedirectedLinks: cfg.LinkCheckUpdateRedirects,
+			AppName:               cfg.AppName,
+			FrontendURL:           cfg.FrontendURL,
 		})
 		if err != nil {
 			log.Printf("Warning: Background worker failed to start: %v", err)
@@ -165,6 +185,9 @@ func main() {
 
 	log.Println("Shutting down server...")
 
+	// Save and close collaborative editing sessions
+	svc.Collab.Close()
+
 	// Stop cron scheduler
 	if cronScheduler != nil {
 		cronScheduler.Stop()
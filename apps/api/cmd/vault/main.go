@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"desis-keep/apps/api/internal/config"
+	"desis-keep/apps/api/internal/database"
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/services"
+	"desis-keep/apps/api/internal/storage"
+	"desis-keep/apps/api/internal/vault"
+)
+
+func main() {
+	email := flag.String("email", "", "Email of the account whose notes to write (required)")
+	dir := flag.String("dir", "", "Directory to write the vault to (required)")
+	full := flag.Bool("full", false, "Rewrite every note instead of only those changed since the last sync")
+	flag.Parse()
+
+	if *email == "" || *dir == "" {
+		fmt.Fprintln(os.Stderr, "Usage: vault -email you@example.com -dir ./vault [-full]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := database.Connect(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	var user models.User
+	if err := db.Where("email = ?", *email).First(&user).Error; err != nil {
+		log.Fatalf("User %s not found: %v", *email, err)
+	}
+
+	// Images need object storage; without it only notes and links are written
+	var store *storage.Storage
+	if cfg.Storage.Endpoint != "" && cfg.Storage.AccessKey != "" {
+		store, err = storage.New(cfg.Storage)
+		if err != nil {
+			log.Printf("Warning: Storage unavailable: %v (images skipped)", err)
+		}
+	}
+
+	state := vault.NewState(user.ID)
+	if !*full {
+		state, err = vault.LoadState(*dir, user.ID)
+		if err != nil {
+			log.Fatalf("Failed to read sync state: %v", err)
+		}
+	}
+
+	fmt.Printf("Syncing notes of %s to %s...\n", user.Email, *dir)
+	result, err := services.NewVaultService(db, store).Sync(context.Background(), user.ID, vault.DirTarget{Root: *dir}, state)
+	if err != nil {
+		log.Fatalf("Sync failed: %v", err)
+	}
+	if err := state.Save(*dir); err != nil {
+		log.Fatalf("Failed to save sync state: %v", err)
+	}
+
+	fmt.Printf("Wrote %d note(s), %d unchanged, %d removed, %d new attachment(s).\n",
+		result.Written, result.Unchanged, result.Removed, result.Attachments)
+	os.Exit(0)
+}
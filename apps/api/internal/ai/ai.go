@@ -1,10 +1,10 @@
 package ai
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -42,505 +42,255 @@ type Usage struct {
 // StreamHandler is called for each chunk of a streamed response.
 type StreamHandler func(chunk string) error
 
-// AI provides text generation via Claude or OpenAI APIs.
-type AI struct {
-	provider string
-	apiKey   string
-	model    string
-	client   *http.Client
+// ErrNotSupported is returned when a provider does not implement an operation,
+// such as embeddings on Claude or completions on the local embedder.
+var ErrNotSupported = errors.New("not supported by this provider")
+
+// Provider is a text generation and embedding backend.
+// Requests passed to a provider are already normalized by AI: Messages is set
+// and MaxTokens is positive.
+type Provider interface {
+	// Name identifies the provider in usage records, e.g. "claude" or "ollama".
+	Name() string
+	Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error)
+	// Stream calls handler for each chunk and returns the full response,
+	// with usage when the vendor reports it.
+	Stream(ctx context.Context, req CompletionRequest, handler StreamHandler) (*CompletionResponse, error)
+	Embed(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error)
+	// EmbeddingModel names the model behind Embed, stored with each vector.
+	EmbeddingModel() string
 }
 
-// New creates a new AI service instance.
-func New(provider, apiKey, model string) *AI {
-	return &AI{
-		provider: strings.ToLower(provider),
-		apiKey:   apiKey,
-		model:    model,
-		client:   &http.Client{Timeout: 120 * time.Second},
-	}
+// Config selects and configures a provider.
+type Config struct {
+	Provider       string // "claude", "openai", "gemini", "ollama", "local", or "fake"
+	APIKey         string
+	Model          string
+	EmbeddingModel string // empty selects the provider default
+	BaseURL        string // empty selects the vendor's public API
 }
 
-// Complete generates a response from a single prompt.
-func (a *AI) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
-	messages := req.Messages
-	if len(messages) == 0 && req.Prompt != "" {
-		messages = []Message{{Role: "user", Content: req.Prompt}}
-	}
+// NewProvider builds the provider named in cfg.
+func NewProvider(cfg Config) (Provider, error) {
+	client := &http.Client{Timeout: 120 * time.Second}
 
-	if req.MaxTokens == 0 {
-		req.MaxTokens = 1024
-	}
-
-	switch a.provider {
+	switch strings.ToLower(cfg.Provider) {
+	case "claude", "anthropic":
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("claude provider requires an API key")
+		}
+		return &ClaudeProvider{
+			apiKey:  cfg.APIKey,
+			model:   cfg.Model,
+			baseURL: withDefault(cfg.BaseURL, "https://api.anthropic.com/v1"),
+			client:  client,
+		}, nil
 	case "openai":
-		return a.openaiComplete(ctx, messages, req.MaxTokens, req.Temperature)
+		if cfg.APIKey == "" && cfg.BaseURL == "" {
+			return nil, fmt.Errorf("openai provider requires an API key")
+		}
+		return &OpenAIProvider{
+			name:       "OpenAI",
+			apiKey:     cfg.APIKey,
+			model:      cfg.Model,
+			embedModel: withDefault(cfg.EmbeddingModel, "text-embedding-3-small"),
+			baseURL:    withDefault(cfg.BaseURL, "https://api.openai.com/v1"),
+			client:     client,
+		}, nil
+	case "ollama":
+		return &OpenAIProvider{
+			name:       "Ollama",
+			apiKey:     cfg.APIKey,
+			model:      withDefault(cfg.Model, "llama3.2"),
+			embedModel: withDefault(cfg.EmbeddingModel, "nomic-embed-text"),
+			baseURL:    withDefault(cfg.BaseURL, "http://localhost:11434/v1"),
+			client:     client,
+		}, nil
 	case "gemini":
-		return a.geminiComplete(ctx, messages, req.MaxTokens, req.Temperature)
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("gemini provider requires an API key")
+		}
+		return &GeminiProvider{
+			apiKey:     cfg.APIKey,
+			model:      cfg.Model,
+			embedModel: withDefault(cfg.EmbeddingModel, "text-embedding-004"),
+			baseURL:    withDefault(cfg.BaseURL, "https://generativelanguage.googleapis.com/v1beta"),
+			client:     client,
+		}, nil
+	case "local":
+		return NewLocalProvider(), nil
+	case "fake":
+		return NewFakeProvider(), nil
 	default:
-		return a.claudeComplete(ctx, messages, req.MaxTokens, req.Temperature)
+		return nil, fmt.Errorf("unknown AI provider %q", cfg.Provider)
 	}
 }
 
-// Stream generates a streaming response, calling handler for each chunk.
-func (a *AI) Stream(ctx context.Context, req CompletionRequest, handler StreamHandler) error {
-	messages := req.Messages
-	if len(messages) == 0 && req.Prompt != "" {
-		messages = []Message{{Role: "user", Content: req.Prompt}}
-	}
-
-	if req.MaxTokens == 0 {
-		req.MaxTokens = 1024
-	}
-
-	switch a.provider {
-	case "openai":
-		return a.openaiStream(ctx, messages, req.MaxTokens, req.Temperature, handler)
-	case "gemini":
-		return a.geminiStream(ctx, messages, req.MaxTokens, req.Temperature, handler)
-	default:
-		return a.claudeStream(ctx, messages, req.MaxTokens, req.Temperature, handler)
-	}
+// AI provides text generation and embeddings through pluggable providers.
+// Completions and embeddings may come from different providers, e.g. a hosted
+// chat model with local embeddings.
+type AI struct {
+	completer Provider
+	embedder  Provider
 }
 
-// ── Claude (Anthropic) ──────────────────────────────────────────
-
-func (a *AI) claudeComplete(ctx context.Context, messages []Message, maxTokens int, temperature float64) (*CompletionResponse, error) {
-	body := map[string]interface{}{
-		"model":      a.model,
-		"max_tokens": maxTokens,
-		"messages":   messages,
-	}
-	if temperature > 0 {
-		body["temperature"] = temperature
-	}
-
-	data, err := json.Marshal(body)
-	if err != nil {
-		return nil, fmt.Errorf("marshaling request: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.anthropic.com/v1/messages", bytes.NewReader(data))
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
-	}
-
-	req.Header.Set("x-api-key", a.apiKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := a.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("calling Claude API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("Claude API error (%d): %s", resp.StatusCode, string(respBody))
-	}
-
-	var result struct {
-		Content []struct {
-			Text string `json:"text"`
-		} `json:"content"`
-		Model string `json:"model"`
-		Usage struct {
-			InputTokens  int `json:"input_tokens"`
-			OutputTokens int `json:"output_tokens"`
-		} `json:"usage"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("decoding response: %w", err)
-	}
-
-	content := ""
-	if len(result.Content) > 0 {
-		content = result.Content[0].Text
-	}
+// New creates a new AI service. Either provider may be nil to disable that capability.
+func New(completer, embedder Provider) *AI {
+	return &AI{completer: completer, embedder: embedder}
+}
 
-	return &CompletionResponse{
-		Content: content,
-		Model:   result.Model,
-		Usage: &Usage{
-			InputTokens:  result.Usage.InputTokens,
-			OutputTokens: result.Usage.OutputTokens,
-		},
-	}, nil
+// CanComplete reports whether completions are configured. It is safe on a nil AI.
+func (a *AI) CanComplete() bool {
+	return a != nil && a.completer != nil
 }
 
-func (a *AI) claudeStream(ctx context.Context, messages []Message, maxTokens int, temperature float64, handler StreamHandler) error {
-	body := map[string]interface{}{
-		"model":      a.model,
-		"max_tokens": maxTokens,
-		"messages":   messages,
-		"stream":     true,
-	}
-	if temperature > 0 {
-		body["temperature"] = temperature
-	}
+// CanEmbed reports whether embeddings are configured. It is safe on a nil AI.
+func (a *AI) CanEmbed() bool {
+	return a != nil && a.embedder != nil
+}
 
-	data, err := json.Marshal(body)
-	if err != nil {
-		return fmt.Errorf("marshaling request: %w", err)
+// Provider returns the name of the completion provider, or "" when none is configured.
+func (a *AI) Provider() string {
+	if !a.CanComplete() {
+		return ""
 	}
+	return a.completer.Name()
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.anthropic.com/v1/messages", bytes.NewReader(data))
-	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
+// Complete generates a response from a single prompt.
+// Usage is always set, estimated when the provider does not report it.
+func (a *AI) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	if !a.CanComplete() {
+		return nil, fmt.Errorf("no completion provider configured")
 	}
-
-	req.Header.Set("x-api-key", a.apiKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := a.client.Do(req)
+	req = normalizeRequest(req)
+	resp, err := a.completer.Complete(ctx, req)
 	if err != nil {
-		return fmt.Errorf("calling Claude API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("Claude API error (%d): %s", resp.StatusCode, string(respBody))
-	}
-
-	scanner := bufio.NewScanner(resp.Body)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if !strings.HasPrefix(line, "data: ") {
-			continue
-		}
-
-		data := strings.TrimPrefix(line, "data: ")
-		if data == "[DONE]" {
-			break
-		}
-
-		var event struct {
-			Type  string `json:"type"`
-			Delta struct {
-				Text string `json:"text"`
-			} `json:"delta"`
-		}
-
-		if err := json.Unmarshal([]byte(data), &event); err != nil {
-			continue
-		}
-
-		if event.Type == "content_block_delta" && event.Delta.Text != "" {
-			if err := handler(event.Delta.Text); err != nil {
-				return err
-			}
-		}
+		return nil, err
 	}
-
-	return scanner.Err()
+	fillUsage(req, resp)
+	return resp, nil
 }
 
-// ── OpenAI ──────────────────────────────────────────────────────
-
-func (a *AI) openaiComplete(ctx context.Context, messages []Message, maxTokens int, temperature float64) (*CompletionResponse, error) {
-	body := map[string]interface{}{
-		"model":      a.model,
-		"max_tokens": maxTokens,
-		"messages":   messages,
-	}
-	if temperature > 0 {
-		body["temperature"] = temperature
-	}
+// Stream generates a streaming response, calling handler for each chunk.
+// The returned response holds the full content and usage. On error it still
+// carries whatever was streamed before the failure; usage is only estimated
+// when the provider returned some output, and is zero otherwise.
+func (a *AI) Stream(ctx context.Context, req CompletionRequest, handler StreamHandler) (*CompletionResponse, error) {
+	if !a.CanComplete() {
+		return nil, fmt.Errorf("no completion provider configured")
+	}
+	req = normalizeRequest(req)
+	resp, err := a.completer.Stream(ctx, req, handler)
+	if resp == nil {
+		resp = &CompletionResponse{}
+	}
+	if err != nil && resp.Content == "" && !reported(resp.Usage) {
+		resp.Usage = &Usage{}
+		return resp, err
+	}
+	fillUsage(req, resp)
+	return resp, err
+}
 
-	data, err := json.Marshal(body)
-	if err != nil {
-		return nil, fmt.Errorf("marshaling request: %w", err)
+// Embed generates a unit-length embedding vector for each input text.
+func (a *AI) Embed(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error) {
+	if !a.CanEmbed() {
+		return nil, fmt.Errorf("no embedding provider configured")
 	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.openai.com/v1/chat/completions", bytes.NewReader(data))
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+	if len(req.Input) == 0 {
+		return &EmbeddingResponse{Vectors: [][]float32{}, Model: a.embedder.EmbeddingModel()}, nil
 	}
 
-	req.Header.Set("Authorization", "Bearer "+a.apiKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := a.client.Do(req)
+	resp, err := a.embedder.Embed(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("calling OpenAI API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("OpenAI API error (%d): %s", resp.StatusCode, string(respBody))
-	}
-
-	var result struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-		Model string `json:"model"`
-		Usage struct {
-			PromptTokens     int `json:"prompt_tokens"`
-			CompletionTokens int `json:"completion_tokens"`
-		} `json:"usage"`
+		return nil, err
 	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("decoding response: %w", err)
+	if len(resp.Vectors) != len(req.Input) {
+		return nil, fmt.Errorf("provider returned %d embeddings for %d inputs", len(resp.Vectors), len(req.Input))
 	}
-
-	content := ""
-	if len(result.Choices) > 0 {
-		content = result.Choices[0].Message.Content
+	for _, v := range resp.Vectors {
+		normalize(v)
 	}
-
-	return &CompletionResponse{
-		Content: content,
-		Model:   result.Model,
-		Usage: &Usage{
-			InputTokens:  result.Usage.PromptTokens,
-			OutputTokens: result.Usage.CompletionTokens,
-		},
-	}, nil
+	return resp, nil
 }
 
-func (a *AI) openaiStream(ctx context.Context, messages []Message, maxTokens int, temperature float64, handler StreamHandler) error {
-	body := map[string]interface{}{
-		"model":      a.model,
-		"max_tokens": maxTokens,
-		"messages":   messages,
-		"stream":     true,
-	}
-	if temperature > 0 {
-		body["temperature"] = temperature
+// EmbeddingModel returns the model name stored alongside generated vectors.
+func (a *AI) EmbeddingModel() string {
+	if !a.CanEmbed() {
+		return ""
 	}
+	return a.embedder.EmbeddingModel()
+}
 
-	data, err := json.Marshal(body)
-	if err != nil {
-		return fmt.Errorf("marshaling request: %w", err)
+// normalizeRequest turns a bare prompt into messages and applies the default token limit.
+func normalizeRequest(req CompletionRequest) CompletionRequest {
+	if len(req.Messages) == 0 && req.Prompt != "" {
+		req.Messages = []Message{{Role: "user", Content: req.Prompt}}
 	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.openai.com/v1/chat/completions", bytes.NewReader(data))
-	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
+	if req.MaxTokens == 0 {
+		req.MaxTokens = 1024
 	}
+	return req
+}
 
-	req.Header.Set("Authorization", "Bearer "+a.apiKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := a.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("calling OpenAI API: %w", err)
+// fillUsage estimates token usage at roughly four characters per token
+// when the provider did not report it.
+func fillUsage(req CompletionRequest, resp *CompletionResponse) {
+	if reported(resp.Usage) {
+		return
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("OpenAI API error (%d): %s", resp.StatusCode, string(respBody))
+	chars := 0
+	for _, m := range req.Messages {
+		chars += len(m.Content)
 	}
-
-	scanner := bufio.NewScanner(resp.Body)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if !strings.HasPrefix(line, "data: ") {
-			continue
-		}
-
-		data := strings.TrimPrefix(line, "data: ")
-		if data == "[DONE]" {
-			break
-		}
-
-		var event struct {
-			Choices []struct {
-				Delta struct {
-					Content string `json:"content"`
-				} `json:"delta"`
-			} `json:"choices"`
-		}
-
-		if err := json.Unmarshal([]byte(data), &event); err != nil {
-			continue
-		}
-
-		if len(event.Choices) > 0 && event.Choices[0].Delta.Content != "" {
-			if err := handler(event.Choices[0].Delta.Content); err != nil {
-				return err
-			}
-		}
+	resp.Usage = &Usage{
+		InputTokens:  (chars + 3) / 4,
+		OutputTokens: (len(resp.Content) + 3) / 4,
 	}
-
-	return scanner.Err()
 }
 
-// ── Gemini (Google) ──────────────────────────────────────────
-
-func (a *AI) geminiComplete(ctx context.Context, messages []Message, maxTokens int, temperature float64) (*CompletionResponse, error) {
-	// Convert messages to Gemini format
-	contents := make([]map[string]interface{}, 0, len(messages))
-	for _, msg := range messages {
-		role := msg.Role
-		if role == "assistant" {
-			role = "model"
-		}
-		contents = append(contents, map[string]interface{}{
-			"role":  role,
-			"parts": []map[string]string{{"text": msg.Content}},
-		})
-	}
-
-	body := map[string]interface{}{
-		"contents": contents,
-		"generationConfig": map[string]interface{}{
-			"maxOutputTokens": maxTokens,
-		},
-	}
-	if temperature > 0 {
-		body["generationConfig"].(map[string]interface{})["temperature"] = temperature
-	}
+// reported reports whether a provider counted any tokens.
+func reported(usage *Usage) bool {
+	return usage != nil && (usage.InputTokens > 0 || usage.OutputTokens > 0)
+}
 
+// postJSON sends a JSON request and returns the response, or an error naming
+// the vendor when the call fails or the API answers with an error status.
+// The caller must close the response body.
+func postJSON(ctx context.Context, client *http.Client, vendor, url string, headers map[string]string, body interface{}) (*http.Response, error) {
 	data, err := json.Marshal(body)
 	if err != nil {
 		return nil, fmt.Errorf("marshaling request: %w", err)
 	}
 
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s", a.model, a.apiKey)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := a.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("calling Gemini API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("Gemini API error (%d): %s", resp.StatusCode, string(respBody))
-	}
-
-	var result struct {
-		Candidates []struct {
-			Content struct {
-				Parts []struct {
-					Text string `json:"text"`
-				} `json:"parts"`
-			} `json:"content"`
-		} `json:"candidates"`
-		UsageMetadata struct {
-			PromptTokenCount     int `json:"promptTokenCount"`
-			CandidatesTokenCount int `json:"candidatesTokenCount"`
-		} `json:"usageMetadata"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("decoding response: %w", err)
-	}
-
-	content := ""
-	if len(result.Candidates) > 0 && len(result.Candidates[0].Content.Parts) > 0 {
-		content = result.Candidates[0].Content.Parts[0].Text
-	}
-
-	return &CompletionResponse{
-		Content: content,
-		Model:   a.model,
-		Usage: &Usage{
-			InputTokens:  result.UsageMetadata.PromptTokenCount,
-			OutputTokens: result.UsageMetadata.CandidatesTokenCount,
-		},
-	}, nil
-}
-
-func (a *AI) geminiStream(ctx context.Context, messages []Message, maxTokens int, temperature float64, handler StreamHandler) error {
-	// Convert messages to Gemini format
-	contents := make([]map[string]interface{}, 0, len(messages))
-	for _, msg := range messages {
-		role := msg.Role
-		if role == "assistant" {
-			role = "model"
-		}
-		contents = append(contents, map[string]interface{}{
-			"role":  role,
-			"parts": []map[string]string{{"text": msg.Content}},
-		})
-	}
-
-	body := map[string]interface{}{
-		"contents": contents,
-		"generationConfig": map[string]interface{}{
-			"maxOutputTokens": maxTokens,
-		},
+	for k, v := range headers {
+		req.Header.Set(k, v)
 	}
-	if temperature > 0 {
-		body["generationConfig"].(map[string]interface{})["temperature"] = temperature
-	}
-
-	data, err := json.Marshal(body)
-	if err != nil {
-		return fmt.Errorf("marshaling request: %w", err)
-	}
-
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:streamGenerateContent?alt=sse&key=%s", a.model, a.apiKey)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
-	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := a.client.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("calling Gemini API: %w", err)
+		return nil, fmt.Errorf("calling %s API: %w", vendor, err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
 		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("Gemini API error (%d): %s", resp.StatusCode, string(respBody))
+		return nil, fmt.Errorf("%s API error (%d): %s", vendor, resp.StatusCode, string(respBody))
 	}
+	return resp, nil
+}
 
-	scanner := bufio.NewScanner(resp.Body)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if !strings.HasPrefix(line, "data: ") {
-			continue
-		}
-
-		data := strings.TrimPrefix(line, "data: ")
-		if data == "[DONE]" {
-			break
-		}
-
-		var event struct {
-			Candidates []struct {
-				Content struct {
-					Parts []struct {
-						Text string `json:"text"`
-					} `json:"parts"`
-				} `json:"content"`
-			} `json:"candidates"`
-		}
-
-		if err := json.Unmarshal([]byte(data), &event); err != nil {
-			continue
-		}
-
-		if len(event.Candidates) > 0 && len(event.Candidates[0].Content.Parts) > 0 {
-			text := event.Candidates[0].Content.Parts[0].Text
-			if text != "" {
-				if err := handler(text); err != nil {
-					return err
-				}
-			}
-		}
+// withDefault returns value, or fallback when value is empty.
+func withDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
 	}
-
-	return scanner.Err()
+	return value
 }
@@ -0,0 +1,51 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// cutProvider streams Content and then fails without reporting usage, like
+// a connection dropped mid-stream.
+type cutProvider struct {
+	FakeProvider
+	Content string
+}
+
+func (p *cutProvider) Stream(ctx context.Context, req CompletionRequest, handler StreamHandler) (*CompletionResponse, error) {
+	resp := &CompletionResponse{Model: "cut"}
+	if p.Content != "" {
+		resp.Content = p.Content
+		if err := handler(p.Content); err != nil {
+			return resp, err
+		}
+	}
+	return resp, errors.New("connection reset")
+}
+
+func TestStreamUsage(t *testing.T) {
+	req := CompletionRequest{Prompt: "12345678"}
+	tests := []struct {
+		name     string
+		provider Provider
+		wantErr  bool
+		want     Usage
+	}{
+		{"reported by the provider", NewFakeProvider("one two"), false, Usage{InputTokens: 1, OutputTokens: 2}},
+		{"failed before any output", &cutProvider{}, true, Usage{}},
+		{"failed after some output", &cutProvider{Content: "abcde"}, true, Usage{InputTokens: 2, OutputTokens: 2}},
+		{"provider error", &FakeProvider{Err: context.DeadlineExceeded}, true, Usage{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := New(tt.provider, nil).Stream(context.Background(), req, func(string) error { return nil })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Stream error = %v, want error %v", err, tt.wantErr)
+			}
+			if resp.Usage == nil || *resp.Usage != tt.want {
+				t.Errorf("usage = %+v, want %+v", resp.Usage, tt.want)
+			}
+		})
+	}
+}
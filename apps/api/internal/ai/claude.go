@@ -0,0 +1,158 @@
+package ai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ClaudeProvider talks to the Anthropic Messages API.
+type ClaudeProvider struct {
+	apiKey  string
+	model   string
+	baseURL string
+	client  *http.Client
+}
+
+// Name returns "claude".
+func (p *ClaudeProvider) Name() string {
+	return "claude"
+}
+
+func (p *ClaudeProvider) headers() map[string]string {
+	return map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": "2023-06-01",
+	}
+}
+
+func (p *ClaudeProvider) body(req CompletionRequest, stream bool) map[string]interface{} {
+	body := map[string]interface{}{
+		"model":      p.model,
+		"max_tokens": req.MaxTokens,
+		"messages":   req.Messages,
+	}
+	if stream {
+		body["stream"] = true
+	}
+	if req.Temperature > 0 {
+		body["temperature"] = req.Temperature
+	}
+	return body
+}
+
+// Complete generates a response with the Messages API.
+func (p *ClaudeProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	resp, err := postJSON(ctx, p.client, "Claude", p.baseURL+"/messages", p.headers(), p.body(req, false))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+		Model string `json:"model"`
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	content := ""
+	if len(result.Content) > 0 {
+		content = result.Content[0].Text
+	}
+
+	return &CompletionResponse{
+		Content: content,
+		Model:   result.Model,
+		Usage: &Usage{
+			InputTokens:  result.Usage.InputTokens,
+			OutputTokens: result.Usage.OutputTokens,
+		},
+	}, nil
+}
+
+// Stream generates a streaming response with the Messages API.
+func (p *ClaudeProvider) Stream(ctx context.Context, req CompletionRequest, handler StreamHandler) (*CompletionResponse, error) {
+	resp, err := postJSON(ctx, p.client, "Claude", p.baseURL+"/messages", p.headers(), p.body(req, true))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &CompletionResponse{Model: p.model, Usage: &Usage{}}
+	var content strings.Builder
+	defer func() { result.Content = content.String() }()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		data := strings.TrimPrefix(line, "data: ")
+		if data == "[DONE]" {
+			break
+		}
+
+		var event struct {
+			Type    string `json:"type"`
+			Message struct {
+				Model string `json:"model"`
+				Usage struct {
+					InputTokens int `json:"input_tokens"`
+				} `json:"usage"`
+			} `json:"message"`
+			Delta struct {
+				Text string `json:"text"`
+			} `json:"delta"`
+			Usage struct {
+				OutputTokens int `json:"output_tokens"`
+			} `json:"usage"`
+		}
+
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			continue
+		}
+
+		switch event.Type {
+		case "message_start":
+			if event.Message.Model != "" {
+				result.Model = event.Message.Model
+			}
+			result.Usage.InputTokens = event.Message.Usage.InputTokens
+		case "message_delta":
+			result.Usage.OutputTokens = event.Usage.OutputTokens
+		case "content_block_delta":
+			if event.Delta.Text != "" {
+				content.WriteString(event.Delta.Text)
+				if err := handler(event.Delta.Text); err != nil {
+					return result, err
+				}
+			}
+		}
+	}
+
+	return result, scanner.Err()
+}
+
+// Embed is not offered by the Anthropic API.
+func (p *ClaudeProvider) Embed(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error) {
+	return nil, fmt.Errorf("claude embeddings: %w", ErrNotSupported)
+}
+
+// EmbeddingModel returns an empty string because Claude has no embedding model.
+func (p *ClaudeProvider) EmbeddingModel() string {
+	return ""
+}
@@ -0,0 +1,114 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// LocalEmbeddingDimensions is the vector size produced by the local provider.
+const LocalEmbeddingDimensions = 256
+
+// EmbeddingRequest holds the texts to embed.
+type EmbeddingRequest struct {
+	Input []string `json:"input"`
+}
+
+// EmbeddingResponse holds one vector per input text, in input order.
+// Vectors returned by AI.Embed are unit length.
+type EmbeddingResponse struct {
+	Vectors [][]float32 `json:"vectors"`
+	Model   string      `json:"model"`
+	Usage   *Usage      `json:"usage,omitempty"`
+}
+
+// LocalProvider computes deterministic embeddings in-process by hashing words
+// and character trigrams. It needs no network access or API key, which makes
+// semantic search usable in development and tests. It cannot generate text.
+type LocalProvider struct{}
+
+// NewLocalProvider creates a new LocalProvider.
+func NewLocalProvider() *LocalProvider {
+	return &LocalProvider{}
+}
+
+// Name returns "local".
+func (p *LocalProvider) Name() string {
+	return "local"
+}
+
+// Complete is not supported by the local provider.
+func (p *LocalProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	return nil, fmt.Errorf("local completions: %w", ErrNotSupported)
+}
+
+// Stream is not supported by the local provider.
+func (p *LocalProvider) Stream(ctx context.Context, req CompletionRequest, handler StreamHandler) (*CompletionResponse, error) {
+	return nil, fmt.Errorf("local completions: %w", ErrNotSupported)
+}
+
+// Embed hashes each text into a fixed-size vector. Texts sharing vocabulary land close together.
+func (p *LocalProvider) Embed(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error) {
+	return localEmbed(req.Input, p.EmbeddingModel()), nil
+}
+
+// EmbeddingModel returns the local model name.
+func (p *LocalProvider) EmbeddingModel() string {
+	return "local-hash-256"
+}
+
+// localEmbed hashes words and character trigrams into fixed-size vectors.
+func localEmbed(texts []string, model string) *EmbeddingResponse {
+	vectors := make([][]float32, len(texts))
+	tokens := 0
+	for i, text := range texts {
+		v := make([]float32, LocalEmbeddingDimensions)
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, w := range words {
+			addFeature(v, "w:"+w, 1)
+			padded := []rune(" " + w + " ")
+			for j := 0; j+3 <= len(padded); j++ {
+				addFeature(v, "t:"+string(padded[j:j+3]), 0.5)
+			}
+		}
+		tokens += len(words)
+		normalize(v)
+		vectors[i] = v
+	}
+	return &EmbeddingResponse{
+		Vectors: vectors,
+		Model:   model,
+		Usage:   &Usage{InputTokens: tokens},
+	}
+}
+
+// addFeature adds a signed weight to the bucket chosen by the feature's hash.
+func addFeature(v []float32, feature string, weight float32) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+	if sum&1 == 1 {
+		weight = -weight
+	}
+	v[(sum>>1)%uint64(len(v))] += weight
+}
+
+// normalize scales v to unit length in place so dot products are cosine similarities.
+func normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+}
@@ -0,0 +1,97 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+func TestLocalEmbedDeterministic(t *testing.T) {
+	texts := []string{"Quarterly budget review", "", "Ünïcode wörds 123"}
+	first, err := NewLocalProvider().Embed(context.Background(), EmbeddingRequest{Input: texts})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	second, err := NewLocalProvider().Embed(context.Background(), EmbeddingRequest{Input: texts})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if !reflect.DeepEqual(first.Vectors, second.Vectors) {
+		t.Error("embedding the same texts twice gave different vectors")
+	}
+	if first.Model != "local-hash-256" {
+		t.Errorf("Model = %q", first.Model)
+	}
+
+	for i, v := range first.Vectors {
+		if len(v) != LocalEmbeddingDimensions {
+			t.Fatalf("vector %d has %d dimensions, want %d", i, len(v), LocalEmbeddingDimensions)
+		}
+	}
+	if norm := dot(first.Vectors[0], first.Vectors[0]); math.Abs(norm-1) > 1e-5 {
+		t.Errorf("vector length² = %v, want 1", norm)
+	}
+	if norm := dot(first.Vectors[1], first.Vectors[1]); norm != 0 {
+		t.Errorf("empty text gave a vector of length² %v, want 0", norm)
+	}
+
+	// Case and punctuation do not change the words
+	other, _ := NewLocalProvider().Embed(context.Background(), EmbeddingRequest{Input: []string{"QUARTERLY budget, review!"}})
+	if !reflect.DeepEqual(other.Vectors[0], first.Vectors[0]) {
+		t.Error("case and punctuation changed the embedding")
+	}
+}
+
+func TestLocalEmbedSimilarity(t *testing.T) {
+	query := "how to bake sourdough bread"
+	similar := "Sourdough bread recipe: bake the loaf at 250 degrees"
+	unrelated := "Kubernetes cluster autoscaling configuration"
+
+	a := New(nil, NewLocalProvider())
+	resp, err := a.Embed(context.Background(), EmbeddingRequest{Input: []string{query, similar, unrelated}})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	q, s, u := resp.Vectors[0], resp.Vectors[1], resp.Vectors[2]
+	if simScore, unrelatedScore := dot(q, s), dot(q, u); simScore <= unrelatedScore {
+		t.Errorf("similar text scores %v, unrelated %v; want similar higher", simScore, unrelatedScore)
+	}
+	// Shared trigrams bring word variants closer than unrelated words
+	variants, _ := a.Embed(context.Background(), EmbeddingRequest{Input: []string{"baking", "baked", "cluster"}})
+	if dot(variants.Vectors[0], variants.Vectors[1]) <= dot(variants.Vectors[0], variants.Vectors[2]) {
+		t.Error("baking is no closer to baked than to cluster")
+	}
+}
+
+func TestFakeProviderEmbedsLikeLocal(t *testing.T) {
+	input := EmbeddingRequest{Input: []string{"same text"}}
+	fake, err := NewFakeProvider().Embed(context.Background(), input)
+	if err != nil {
+		t.Fatalf("fake Embed: %v", err)
+	}
+	local, _ := NewLocalProvider().Embed(context.Background(), input)
+	if !reflect.DeepEqual(fake.Vectors, local.Vectors) {
+		t.Error("fake and local providers embed the same text differently")
+	}
+}
+
+func TestLocalProviderCannotComplete(t *testing.T) {
+	a := New(nil, NewLocalProvider())
+	if a.CanComplete() || !a.CanEmbed() {
+		t.Errorf("CanComplete = %v, CanEmbed = %v; want embeddings only", a.CanComplete(), a.CanEmbed())
+	}
+	_, err := NewLocalProvider().Complete(context.Background(), CompletionRequest{Prompt: "hi"})
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Complete error = %v, want ErrNotSupported", err)
+	}
+}
@@ -0,0 +1,116 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// FakeProvider is an in-process provider for tests and offline development.
+// Completions return queued Responses in order, then echo the last user
+// message. Embeddings use the deterministic local hashing scheme.
+// Every completion request is recorded in Requests.
+type FakeProvider struct {
+	mu        sync.Mutex
+	Responses []string
+	Err       error
+	Requests  []CompletionRequest
+}
+
+// NewFakeProvider creates a FakeProvider that returns the given responses in order.
+func NewFakeProvider(responses ...string) *FakeProvider {
+	return &FakeProvider{Responses: responses}
+}
+
+// Name returns "fake".
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+// Complete returns the next queued response.
+func (p *FakeProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	content, err := p.next(req)
+	if err != nil {
+		return nil, err
+	}
+	return &CompletionResponse{
+		Content: content,
+		Model:   "fake",
+		Usage: &Usage{
+			InputTokens:  countWords(req.Messages),
+			OutputTokens: len(strings.Fields(content)),
+		},
+	}, nil
+}
+
+// Stream sends the next queued response to handler one word at a time.
+func (p *FakeProvider) Stream(ctx context.Context, req CompletionRequest, handler StreamHandler) (*CompletionResponse, error) {
+	content, err := p.next(req)
+	if err != nil {
+		return nil, err
+	}
+	result := &CompletionResponse{
+		Model: "fake",
+		Usage: &Usage{
+			InputTokens:  countWords(req.Messages),
+			OutputTokens: len(strings.Fields(content)),
+		},
+	}
+	for _, word := range strings.SplitAfter(content, " ") {
+		if word == "" {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		result.Content += word
+		if err := handler(word); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// Embed returns deterministic local embeddings.
+func (p *FakeProvider) Embed(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error) {
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	return localEmbed(req.Input, p.EmbeddingModel()), nil
+}
+
+// EmbeddingModel returns the fake model name.
+func (p *FakeProvider) EmbeddingModel() string {
+	return "fake-embedding"
+}
+
+// next records the request and pops the next response.
+func (p *FakeProvider) next(req CompletionRequest) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Requests = append(p.Requests, req)
+	if p.Err != nil {
+		return "", p.Err
+	}
+	if len(p.Responses) > 0 {
+		content := p.Responses[0]
+		p.Responses = p.Responses[1:]
+		return content, nil
+	}
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			return "Echo: " + req.Messages[i].Content, nil
+		}
+	}
+	return "Echo:", nil
+}
+
+// countWords approximates token usage as the number of words in the messages.
+func countWords(messages []Message) int {
+	n := 0
+	for _, m := range messages {
+		n += len(strings.Fields(m.Content))
+	}
+	return n
+}
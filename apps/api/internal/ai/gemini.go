@@ -0,0 +1,201 @@
+package ai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// GeminiProvider talks to the Google Generative Language API.
+type GeminiProvider struct {
+	apiKey     string
+	model      string
+	embedModel string
+	baseURL    string
+	client     *http.Client
+}
+
+// Name returns "gemini".
+func (p *GeminiProvider) Name() string {
+	return "gemini"
+}
+
+func (p *GeminiProvider) body(req CompletionRequest) map[string]interface{} {
+	// Convert messages to Gemini format
+	contents := make([]map[string]interface{}, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		role := msg.Role
+		if role == "assistant" {
+			role = "model"
+		}
+		contents = append(contents, map[string]interface{}{
+			"role":  role,
+			"parts": []map[string]string{{"text": msg.Content}},
+		})
+	}
+
+	generationConfig := map[string]interface{}{
+		"maxOutputTokens": req.MaxTokens,
+	}
+	if req.Temperature > 0 {
+		generationConfig["temperature"] = req.Temperature
+	}
+
+	return map[string]interface{}{
+		"contents":         contents,
+		"generationConfig": generationConfig,
+	}
+}
+
+// Complete generates a response with generateContent.
+func (p *GeminiProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	url := fmt.Sprintf("%s/models/%s:generateContent?key=%s", p.baseURL, p.model, p.apiKey)
+	resp, err := postJSON(ctx, p.client, "Gemini", url, nil, p.body(req))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+		} `json:"candidates"`
+		UsageMetadata struct {
+			PromptTokenCount     int `json:"promptTokenCount"`
+			CandidatesTokenCount int `json:"candidatesTokenCount"`
+		} `json:"usageMetadata"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	content := ""
+	if len(result.Candidates) > 0 && len(result.Candidates[0].Content.Parts) > 0 {
+		content = result.Candidates[0].Content.Parts[0].Text
+	}
+
+	return &CompletionResponse{
+		Content: content,
+		Model:   p.model,
+		Usage: &Usage{
+			InputTokens:  result.UsageMetadata.PromptTokenCount,
+			OutputTokens: result.UsageMetadata.CandidatesTokenCount,
+		},
+	}, nil
+}
+
+// Stream generates a streaming response with streamGenerateContent over SSE.
+func (p *GeminiProvider) Stream(ctx context.Context, req CompletionRequest, handler StreamHandler) (*CompletionResponse, error) {
+	url := fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse&key=%s", p.baseURL, p.model, p.apiKey)
+	resp, err := postJSON(ctx, p.client, "Gemini", url, nil, p.body(req))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &CompletionResponse{Model: p.model}
+	var content strings.Builder
+	defer func() { result.Content = content.String() }()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		data := strings.TrimPrefix(line, "data: ")
+		if data == "[DONE]" {
+			break
+		}
+
+		var event struct {
+			Candidates []struct {
+				Content struct {
+					Parts []struct {
+						Text string `json:"text"`
+					} `json:"parts"`
+				} `json:"content"`
+			} `json:"candidates"`
+			UsageMetadata *struct {
+				PromptTokenCount     int `json:"promptTokenCount"`
+				CandidatesTokenCount int `json:"candidatesTokenCount"`
+			} `json:"usageMetadata"`
+		}
+
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			continue
+		}
+
+		// Each chunk reports cumulative usage; the last one wins
+		if event.UsageMetadata != nil {
+			result.Usage = &Usage{
+				InputTokens:  event.UsageMetadata.PromptTokenCount,
+				OutputTokens: event.UsageMetadata.CandidatesTokenCount,
+			}
+		}
+
+		if len(event.Candidates) > 0 && len(event.Candidates[0].Content.Parts) > 0 {
+			text := event.Candidates[0].Content.Parts[0].Text
+			if text != "" {
+				content.WriteString(text)
+				if err := handler(text); err != nil {
+					return result, err
+				}
+			}
+		}
+	}
+
+	return result, scanner.Err()
+}
+
+// Embed generates embeddings with batchEmbedContents.
+func (p *GeminiProvider) Embed(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error) {
+	requests := make([]map[string]interface{}, 0, len(req.Input))
+	for _, text := range req.Input {
+		requests = append(requests, map[string]interface{}{
+			"model":   "models/" + p.embedModel,
+			"content": map[string]interface{}{"parts": []map[string]string{{"text": text}}},
+		})
+	}
+
+	url := fmt.Sprintf("%s/models/%s:batchEmbedContents?key=%s", p.baseURL, p.embedModel, p.apiKey)
+	resp, err := postJSON(ctx, p.client, "Gemini", url, nil, map[string]interface{}{"requests": requests})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Embeddings []struct {
+			Values []float32 `json:"values"`
+		} `json:"embeddings"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	vectors := make([][]float32, len(result.Embeddings))
+	for i, e := range result.Embeddings {
+		vectors[i] = e.Values
+	}
+
+	return &EmbeddingResponse{
+		Vectors: vectors,
+		Model:   p.embedModel,
+	}, nil
+}
+
+// EmbeddingModel returns the configured embedding model.
+func (p *GeminiProvider) EmbeddingModel() string {
+	return p.embedModel
+}
@@ -0,0 +1,205 @@
+package ai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OpenAIProvider talks to the OpenAI API or any server exposing the same
+// /chat/completions and /embeddings endpoints, such as Ollama, vLLM or LM Studio.
+type OpenAIProvider struct {
+	name       string // vendor name used in error messages
+	apiKey     string
+	model      string
+	embedModel string
+	baseURL    string
+	client     *http.Client
+}
+
+// Name returns the lowercase vendor name, e.g. "openai" or "ollama".
+func (p *OpenAIProvider) Name() string {
+	return strings.ToLower(p.name)
+}
+
+func (p *OpenAIProvider) headers() map[string]string {
+	if p.apiKey == "" {
+		// Local servers usually run without authentication
+		return nil
+	}
+	return map[string]string{"Authorization": "Bearer " + p.apiKey}
+}
+
+func (p *OpenAIProvider) body(req CompletionRequest, stream bool) map[string]interface{} {
+	body := map[string]interface{}{
+		"model":      p.model,
+		"max_tokens": req.MaxTokens,
+		"messages":   req.Messages,
+	}
+	if stream {
+		body["stream"] = true
+	}
+	if req.Temperature > 0 {
+		body["temperature"] = req.Temperature
+	}
+	return body
+}
+
+// Complete generates a response with the chat completions API.
+func (p *OpenAIProvider) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	resp, err := postJSON(ctx, p.client, p.name, p.baseURL+"/chat/completions", p.headers(), p.body(req, false))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Model string `json:"model"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	content := ""
+	if len(result.Choices) > 0 {
+		content = result.Choices[0].Message.Content
+	}
+
+	return &CompletionResponse{
+		Content: content,
+		Model:   result.Model,
+		Usage: &Usage{
+			InputTokens:  result.Usage.PromptTokens,
+			OutputTokens: result.Usage.CompletionTokens,
+		},
+	}, nil
+}
+
+// Stream generates a streaming response with the chat completions API.
+func (p *OpenAIProvider) Stream(ctx context.Context, req CompletionRequest, handler StreamHandler) (*CompletionResponse, error) {
+	body := p.body(req, true)
+	body["stream_options"] = map[string]bool{"include_usage": true}
+
+	resp, err := postJSON(ctx, p.client, p.name, p.baseURL+"/chat/completions", p.headers(), body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &CompletionResponse{Model: p.model}
+	var content strings.Builder
+	defer func() { result.Content = content.String() }()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		data := strings.TrimPrefix(line, "data: ")
+		if data == "[DONE]" {
+			break
+		}
+
+		var event struct {
+			Model   string `json:"model"`
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Usage *struct {
+				PromptTokens     int `json:"prompt_tokens"`
+				CompletionTokens int `json:"completion_tokens"`
+			} `json:"usage"`
+		}
+
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			continue
+		}
+
+		if event.Model != "" {
+			result.Model = event.Model
+		}
+		// With include_usage the final chunk carries usage and no choices
+		if event.Usage != nil {
+			result.Usage = &Usage{
+				InputTokens:  event.Usage.PromptTokens,
+				OutputTokens: event.Usage.CompletionTokens,
+			}
+		}
+
+		if len(event.Choices) > 0 && event.Choices[0].Delta.Content != "" {
+			content.WriteString(event.Choices[0].Delta.Content)
+			if err := handler(event.Choices[0].Delta.Content); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	return result, scanner.Err()
+}
+
+// Embed generates embeddings with the embeddings API.
+func (p *OpenAIProvider) Embed(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error) {
+	resp, err := postJSON(ctx, p.client, p.name, p.baseURL+"/embeddings", p.headers(), map[string]interface{}{
+		"model": p.embedModel,
+		"input": req.Input,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+		Usage struct {
+			PromptTokens int `json:"prompt_tokens"`
+		} `json:"usage"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	vectors := make([][]float32, len(req.Input))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("%s API returned embedding index %d out of range", p.name, d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("%s API returned no embedding for input %d", p.name, i)
+		}
+	}
+
+	return &EmbeddingResponse{
+		Vectors: vectors,
+		Model:   p.embedModel,
+		Usage:   &Usage{InputTokens: result.Usage.PromptTokens},
+	}, nil
+}
+
+// EmbeddingModel returns the configured embedding model.
+func (p *OpenAIProvider) EmbeddingModel() string {
+	return p.embedModel
+}
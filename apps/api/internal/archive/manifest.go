@@ -0,0 +1,125 @@
+// Package archive defines the portable account archive: a zip holding a
+// manifest.json that describes every label, note, link, image and file of an
+// account, a Markdown file per note and the binary content of images and files.
+//
+// Layout:
+//
+//	manifest.json           Manifest, see below
+//	notes/<id>-<title>.md   note body with YAML front matter
+//	images/<id>-<name>      image content
+//	files/<id>-<name>       file content
+//
+// IDs in the manifest are the IDs the resources had in the exporting account;
+// they only serve to name archive entries and are reassigned on import.
+// Resources refer to labels by slug. Paths are relative to the archive root.
+package archive
+
+import "time"
+
+// FormatVersion is the manifest format written by this package. Readers
+// reject manifests with a newer version.
+const FormatVersion = 1
+
+// ManifestName is the name of the manifest entry in an archive.
+const ManifestName = "manifest.json"
+
+// App is written to Manifest.App to identify archives made by this application.
+const App = "desis-keep"
+
+// Manifest describes the contents of an account archive.
+type Manifest struct {
+	Version    int       `json:"version"`
+	App        string    `json:"app"`
+	ExportedAt time.Time `json:"exported_at"`
+	Account    Account   `json:"account"`
+	Labels     []Label   `json:"labels"`
+	Notes      []Note    `json:"notes"`
+	Links      []Link    `json:"links"`
+	Images     []Image   `json:"images"`
+	Files      []File    `json:"files"`
+}
+
+// Account identifies whose data the archive holds.
+type Account struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// State holds the flags every resource has.
+type State struct {
+	IsPinned   bool `json:"is_pinned"`
+	IsArchived bool `json:"is_archived"`
+	IsTrashed  bool `json:"is_trashed"`
+}
+
+// Label is a label of the account.
+type Label struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Note is a note; its body is the Markdown file at Path, after the front matter.
+// The body of a checklist holds its items as a Markdown task list.
+type Note struct {
+	ID                  uint   `json:"id"`
+	Type                string `json:"type,omitempty"` // missing in archives from before checklists, meaning text
+	Title               string `json:"title"`
+	Color               string `json:"color"`
+	MoveCheckedToBottom bool   `json:"move_checked_to_bottom,omitempty"`
+	State
+	Labels    []string  `json:"labels"`
+	Path      string    `json:"path"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Link is a saved link.
+type Link struct {
+	ID           uint   `json:"id"`
+	URL          string `json:"url"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+	FaviconURL   string `json:"favicon_url,omitempty"`
+	State
+	Labels    []string  `json:"labels"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Image is an uploaded image. Path is empty when its content could not be
+// read at export time.
+type Image struct {
+	ID        uint   `json:"id"`
+	Title     string `json:"title"`
+	MimeType  string `json:"mime_type"`
+	SizeBytes uint   `json:"size_bytes"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Folder    string `json:"folder"`
+	State
+	Labels    []string  `json:"labels"`
+	Path      string    `json:"path,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// File is an uploaded file. Path is empty when its content could not be
+// read at export time.
+type File struct {
+	ID           uint   `json:"id"`
+	Title        string `json:"title"`
+	OriginalName string `json:"original_name"`
+	MimeType     string `json:"mime_type"`
+	SizeBytes    uint   `json:"size_bytes"`
+	Extension    string `json:"extension"`
+	Folder       string `json:"folder"`
+	State
+	Labels    []string  `json:"labels"`
+	Path      string    `json:"path,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
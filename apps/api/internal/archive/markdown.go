@@ -0,0 +1,100 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// maxNameRunes caps the readable part of an entry name.
+	maxNameRunes = 80
+	// maxNoteBytes caps how much of a note file is read.
+	maxNoteBytes = 16 << 20
+)
+
+// WriteNote writes a note as Markdown with YAML front matter holding its
+// title, type, color, state, label names and timestamps, followed by a blank line
+// and the body exactly as stored. Strings are written as JSON strings, which
+// YAML reads as double-quoted scalars.
+func WriteNote(w io.Writer, n Note, labelNames []string, body string) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("---\n")
+	fmt.Fprintf(bw, "title: %s\n", quote(n.Title))
+	if n.Type != "" {
+		fmt.Fprintf(bw, "type: %s\n", quote(n.Type))
+	}
+	fmt.Fprintf(bw, "color: %s\n", quote(n.Color))
+	fmt.Fprintf(bw, "pinned: %t\n", n.IsPinned)
+	fmt.Fprintf(bw, "archived: %t\n", n.IsArchived)
+	fmt.Fprintf(bw, "trashed: %t\n", n.IsTrashed)
+	quoted := make([]string, len(labelNames))
+	for i, name := range labelNames {
+		quoted[i] = quote(name)
+	}
+	fmt.Fprintf(bw, "labels: [%s]\n", strings.Join(quoted, ", "))
+	fmt.Fprintf(bw, "created: %s\n", n.CreatedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(bw, "updated: %s\n", n.UpdatedAt.UTC().Format(time.RFC3339))
+	bw.WriteString("---\n\n")
+	bw.WriteString(body)
+	return bw.Flush()
+}
+
+// ReadNoteBody returns the body of a note file written by WriteNote, without
+// its front matter. Files without front matter are returned whole.
+func ReadNoteBody(r io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxNoteBytes))
+	if err != nil {
+		return "", err
+	}
+	text := string(data)
+	if !strings.HasPrefix(text, "---\n") {
+		return text, nil
+	}
+	end := strings.Index(text[4:], "\n---\n")
+	if end < 0 {
+		return text, nil
+	}
+	return strings.TrimPrefix(text[4+end+5:], "\n"), nil
+}
+
+// EntryName builds an archive entry name such as "notes/12-shopping-list.md"
+// from a folder, an ID and a human-readable name. The ID keeps names unique;
+// the rest is reduced to characters that are safe on every file system.
+func EntryName(folder string, id uint, name, ext string) string {
+	var b strings.Builder
+	dash := false
+	count := 0
+	for _, r := range name {
+		if count == maxNameRunes {
+			break
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '_' {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		} else {
+			continue
+		}
+		count++
+	}
+	clean := strings.Trim(b.String(), "-.")
+	if clean == "" {
+		return fmt.Sprintf("%s/%d%s", folder, id, ext)
+	}
+	return fmt.Sprintf("%s/%d-%s%s", folder, id, clean, ext)
+}
+
+func quote(s string) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
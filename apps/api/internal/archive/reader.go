@@ -0,0 +1,72 @@
+package archive
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxManifestBytes caps how much of a manifest is read.
+const maxManifestBytes = 256 << 20
+
+var (
+	// ErrNotAccountArchive is returned when a zip holds no account manifest.
+	ErrNotAccountArchive = errors.New("not an account archive")
+	// ErrUnsupportedVersion is returned for manifests newer than FormatVersion.
+	ErrUnsupportedVersion = errors.New("account archive was made by a newer version")
+)
+
+// Reader gives access to the manifest and entries of an account archive.
+type Reader struct {
+	Manifest Manifest
+	files    map[string]*zip.File
+}
+
+// Open reads the manifest of an account archive.
+func Open(r *zip.Reader) (*Reader, error) {
+	reader := &Reader{files: map[string]*zip.File{}}
+	for _, f := range r.File {
+		reader.files[f.Name] = f
+	}
+
+	f, ok := reader.files[ManifestName]
+	if !ok {
+		return nil, ErrNotAccountArchive
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("opening manifest: %w", err)
+	}
+	defer rc.Close()
+	if err := json.NewDecoder(io.LimitReader(rc, maxManifestBytes)).Decode(&reader.Manifest); err != nil {
+		return nil, fmt.Errorf("%w: reading manifest: %v", ErrNotAccountArchive, err)
+	}
+	if reader.Manifest.Version < 1 {
+		return nil, fmt.Errorf("%w: manifest has no version", ErrNotAccountArchive)
+	}
+	if reader.Manifest.Version > FormatVersion {
+		return nil, fmt.Errorf("%w (version %d)", ErrUnsupportedVersion, reader.Manifest.Version)
+	}
+	return reader, nil
+}
+
+// Has reports whether the archive contains an entry.
+func (r *Reader) Has(name string) bool {
+	_, ok := r.files[name]
+	return ok
+}
+
+// Open opens an entry, returning it with its uncompressed size.
+func (r *Reader) Open(name string) (io.ReadCloser, int64, error) {
+	f, ok := r.files[name]
+	if !ok {
+		return nil, 0, fmt.Errorf("%s not found in archive", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, 0, fmt.Errorf("opening %s: %w", name, err)
+	}
+	return rc, int64(f.UncompressedSize64), nil
+}
@@ -0,0 +1,256 @@
+// Package bookmarks reads and writes the Netscape bookmark file format that
+// every major browser uses for bookmark export and import.
+package bookmarks
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// ErrNotBookmarkFile is returned when the input is not a Netscape bookmark file.
+var ErrNotBookmarkFile = errors.New("not a Netscape bookmark file")
+
+// Bookmark is one entry of a bookmark file.
+type Bookmark struct {
+	URL          string
+	Title        string
+	Description  string
+	Folders      []string // enclosing folders, outermost first; browser root folders are left out
+	Tags         []string // from the TAGS attribute some browsers and services write
+	AddDate      time.Time
+	LastModified time.Time
+}
+
+// Folder is a named group of bookmarks written by Write.
+type Folder struct {
+	Name      string
+	Bookmarks []Bookmark
+}
+
+// Sniff reports whether head, the start of a file, looks like a Netscape bookmark file.
+func Sniff(head []byte) bool {
+	lower := bytes.ToLower(head)
+	return bytes.Contains(lower, []byte("netscape-bookmark-file")) ||
+		(bytes.Contains(lower, []byte("<dl")) && bytes.Contains(lower, []byte("<dt")))
+}
+
+// folder tracks one <DL> level while parsing.
+type folder struct {
+	name string
+	root bool // a browser's toolbar or "other bookmarks" folder
+}
+
+// Parse reads every bookmark in a Netscape bookmark file. The format is loose
+// HTML (unclosed <DT> and <p> tags), so it is read token by token: an <H3>
+// names the folder opened by the next <DL>, and a <DD> after an <A> holds that
+// bookmark's description.
+func Parse(r io.Reader) ([]Bookmark, error) {
+	reader, err := charset.NewReader(r, "text/html")
+	if err != nil {
+		reader = r
+	}
+
+	var (
+		result      []Bookmark
+		stack       []folder
+		pending     *folder // folder named by the last <H3>, opened by the next <DL>
+		text        strings.Builder
+		inTitle     bool // collecting <H3> text
+		inLink      bool // collecting <A> text
+		inDesc      bool // collecting <DD> text
+		current     = -1 // index of the bookmark a <DD> would describe
+		sawDocument bool
+	)
+
+	finishDesc := func() {
+		if inDesc && current >= 0 {
+			result[current].Description = strings.Join(strings.Fields(text.String()), " ")
+		}
+		inDesc = false
+	}
+
+	z := xhtml.NewTokenizer(reader)
+	for {
+		tt := z.Next()
+		switch tt {
+		case xhtml.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return nil, fmt.Errorf("reading bookmark file: %w", err)
+			}
+			finishDesc()
+			if !sawDocument {
+				return nil, ErrNotBookmarkFile
+			}
+			return result, nil
+
+		case xhtml.TextToken:
+			if inTitle || inLink || inDesc {
+				text.Write(z.Text())
+			}
+
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			attrs := map[string]string{}
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				attrs[string(key)] = string(val)
+			}
+
+			switch string(name) {
+			case "dl":
+				finishDesc()
+				sawDocument = true
+				if pending != nil {
+					stack = append(stack, *pending)
+					pending = nil
+				} else {
+					stack = append(stack, folder{root: true})
+				}
+				current = -1
+			case "h3":
+				finishDesc()
+				inTitle = true
+				text.Reset()
+				current = -1
+				pending = &folder{root: attrs["personal_toolbar_folder"] == "true" || attrs["unfiled_bookmarks_folder"] == "true"}
+			case "a":
+				finishDesc()
+				inLink = true
+				text.Reset()
+				result = append(result, Bookmark{
+					URL:          strings.TrimSpace(attrs["href"]),
+					Folders:      folderNames(stack),
+					Tags:         splitTags(attrs["tags"]),
+					AddDate:      parseTimestamp(attrs["add_date"]),
+					LastModified: parseTimestamp(attrs["last_modified"]),
+				})
+				current = len(result) - 1
+			case "dd":
+				finishDesc()
+				if current >= 0 {
+					inDesc = true
+					text.Reset()
+				}
+			case "dt":
+				finishDesc()
+				current = -1
+			}
+
+		case xhtml.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "h3":
+				if inTitle && pending != nil {
+					pending.name = strings.TrimSpace(text.String())
+				}
+				inTitle = false
+			case "a":
+				if inLink && current >= 0 {
+					result[current].Title = strings.TrimSpace(text.String())
+				}
+				inLink = false
+			case "dl":
+				finishDesc()
+				if len(stack) > 0 {
+					stack = stack[:len(stack)-1]
+				}
+				current = -1
+			}
+		}
+	}
+}
+
+// folderNames returns the names of the non-root folders on the stack.
+func folderNames(stack []folder) []string {
+	var names []string
+	for _, f := range stack {
+		if !f.root && f.name != "" {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// splitTags splits a comma-separated TAGS attribute.
+func splitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// parseTimestamp reads a Unix timestamp. Browsers write seconds, but some
+// tools write milliseconds or microseconds, which are recognized by size.
+func parseTimestamp(value string) time.Time {
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}
+	}
+	switch {
+	case n > 1e14:
+		return time.UnixMicro(n)
+	case n > 1e11:
+		return time.UnixMilli(n)
+	default:
+		return time.Unix(n, 0)
+	}
+}
+
+// Write emits a bookmark file with the loose bookmarks at the top level
+// followed by one folder per entry of folders.
+func Write(w io.Writer, loose []Bookmark, folders []Folder) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("<!DOCTYPE NETSCAPE-Bookmark-file-1>\n")
+	bw.WriteString("<!-- This is an automatically generated file.\n     It will be read and overwritten.\n     DO NOT EDIT! -->\n")
+	bw.WriteString(`<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">` + "\n")
+	bw.WriteString("<TITLE>Bookmarks</TITLE>\n<H1>Bookmarks</H1>\n<DL><p>\n")
+
+	for _, b := range loose {
+		writeBookmark(bw, b, "    ")
+	}
+	for _, f := range folders {
+		fmt.Fprintf(bw, "    <DT><H3>%s</H3>\n    <DL><p>\n", html.EscapeString(f.Name))
+		for _, b := range f.Bookmarks {
+			writeBookmark(bw, b, "        ")
+		}
+		bw.WriteString("    </DL><p>\n")
+	}
+
+	bw.WriteString("</DL><p>\n")
+	return bw.Flush()
+}
+
+func writeBookmark(w *bufio.Writer, b Bookmark, indent string) {
+	fmt.Fprintf(w, `%s<DT><A HREF="%s"`, indent, html.EscapeString(b.URL))
+	if !b.AddDate.IsZero() {
+		fmt.Fprintf(w, ` ADD_DATE="%d"`, b.AddDate.Unix())
+	}
+	if !b.LastModified.IsZero() {
+		fmt.Fprintf(w, ` LAST_MODIFIED="%d"`, b.LastModified.Unix())
+	}
+	if len(b.Tags) > 0 {
+		fmt.Fprintf(w, ` TAGS="%s"`, html.EscapeString(strings.Join(b.Tags, ",")))
+	}
+	title := b.Title
+	if title == "" {
+		title = b.URL
+	}
+	fmt.Fprintf(w, ">%s</A>\n", html.EscapeString(title))
+	if b.Description != "" {
+		fmt.Fprintf(w, "%s<DD>%s\n", indent, html.EscapeString(strings.Join(strings.Fields(b.Description), " ")))
+	}
+}
@@ -92,6 +92,24 @@ func (c *Cache) Flush(ctx context.Context) error {
 	return c.client.FlushDB(ctx).Err()
 }
 
+// Publish sends a value, encoded as JSON, to a pub/sub channel.
+func (c *Cache) Publish(ctx context.Context, channel string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("cache marshal message for %q: %w", channel, err)
+	}
+
+	if err := c.client.Publish(ctx, channel, data).Err(); err != nil {
+		return fmt.Errorf("cache publish %q: %w", channel, err)
+	}
+	return nil
+}
+
+// Subscribe listens to pub/sub channels. Close the subscription when done.
+func (c *Cache) Subscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	return c.client.Subscribe(ctx, channels...)
+}
+
 // Client returns the underlying Redis client for advanced operations.
 func (c *Cache) Client() *redis.Client {
 	return c.client
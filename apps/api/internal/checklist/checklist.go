@@ -0,0 +1,98 @@
+// Package checklist converts between checklist items and the Markdown task
+// list text that checklist notes keep as their body, so search, exports and
+// AI features that read note bodies see the items too.
+package checklist
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MaxIndent is the deepest an item can be nested.
+const MaxIndent = 3
+
+// Item is one line of a checklist.
+type Item struct {
+	Text    string
+	Checked bool
+	Indent  int
+}
+
+var (
+	// bulletPattern matches a list marker at the start of a line.
+	bulletPattern = regexp.MustCompile(`^(?:[-*+]|\d+[.)])\s+`)
+	// boxPattern matches a task list checkbox.
+	boxPattern = regexp.MustCompile(`^\[([ xX]?)\]\s*`)
+)
+
+// Render writes items as a Markdown task list, one item per line, indented
+// two spaces per level.
+func Render(items []Item) string {
+	var b strings.Builder
+	for i, item := range items {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(strings.Repeat("  ", ClampIndent(item.Indent)))
+		if item.Checked {
+			b.WriteString("- [x] ")
+		} else {
+			b.WriteString("- [ ] ")
+		}
+		b.WriteString(CleanText(item.Text))
+	}
+	return b.String()
+}
+
+// Parse turns text into items, one per non-blank line. Task list checkboxes
+// set Checked, list markers are dropped, and leading whitespace sets the
+// indent at two spaces or one tab per level. Render's output parses back to
+// the same items.
+func Parse(text string) []Item {
+	var items []Item
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		width := 0
+		for _, r := range line {
+			if r == ' ' {
+				width++
+			} else if r == '\t' {
+				width += 2
+			} else {
+				break
+			}
+		}
+		line = strings.TrimSpace(line)
+
+		item := Item{Indent: ClampIndent(width / 2)}
+		line = bulletPattern.ReplaceAllString(line, "")
+		if m := boxPattern.FindStringSubmatch(line); m != nil {
+			item.Checked = m[1] == "x" || m[1] == "X"
+			line = line[len(m[0]):]
+		}
+		if item.Text = CleanText(line); item.Text == "" {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// CleanText makes item text a single trimmed line.
+func CleanText(text string) string {
+	text = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(text)
+	return strings.TrimSpace(text)
+}
+
+// ClampIndent limits an indent to 0 through MaxIndent.
+func ClampIndent(indent int) int {
+	if indent < 0 {
+		return 0
+	}
+	if indent > MaxIndent {
+		return MaxIndent
+	}
+	return indent
+}
@@ -0,0 +1,143 @@
+package collab
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// writeWait is how long writing a message to a client may take.
+	writeWait = 10 * time.Second
+	// pongWait is how long a client may stay silent before it is dropped.
+	pongWait = 60 * time.Second
+	// pingPeriod is how often clients are pinged; shorter than pongWait.
+	pingPeriod = pongWait * 9 / 10
+	// maxMessageSize is the largest message a client may send.
+	maxMessageSize = 8 << 20
+	// maxCursorSize is the largest cursor a client may share.
+	maxCursorSize = 1024
+	// sendBuffer is how many messages may wait for a client before it is
+	// dropped as too slow.
+	sendBuffer = 256
+)
+
+// client is one WebSocket connection in a room.
+type client struct {
+	conn *websocket.Conn
+	peer Peer // guarded by the room's mu once the client is in a room
+	out  chan []byte
+	done chan struct{}
+	once sync.Once
+}
+
+func newClient(conn *websocket.Conn, peer Peer) *client {
+	return &client{
+		conn: conn,
+		peer: peer,
+		out:  make(chan []byte, sendBuffer),
+		done: make(chan struct{}),
+	}
+}
+
+// queue sends a message to the client.
+func (c *client) queue(msg Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Warning: encoding message for session %s: %v", c.peer.Session, err)
+		return
+	}
+	c.send(data)
+}
+
+// send sends an encoded message to the client, disconnecting it when too
+// many messages are waiting for it.
+func (c *client) send(data []byte) {
+	select {
+	case <-c.done:
+	case c.out <- data:
+	default:
+		c.close()
+	}
+}
+
+// fail sends the client an error and disconnects it.
+func (c *client) fail(code, message string) {
+	c.queue(errorMessage(code, message))
+	c.close()
+}
+
+// close disconnects the client once the messages waiting for it are written.
+func (c *client) close() {
+	c.once.Do(func() { close(c.done) })
+}
+
+// readPump passes the client's messages to the room until the connection
+// closes.
+func (c *client) readPump(r *room) {
+	defer c.close()
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.queue(errorMessage("VALIDATION_ERROR", "Messages must be JSON objects"))
+			continue
+		}
+		r.receive(c, msg)
+	}
+}
+
+// writePump writes messages to the client and pings it until it is closed.
+func (c *client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case data := <-c.out:
+			if !c.write(websocket.TextMessage, data) {
+				c.close()
+				return
+			}
+		case <-ticker.C:
+			if !c.write(websocket.PingMessage, nil) {
+				c.close()
+				return
+			}
+		case <-c.done:
+			for {
+				select {
+				case data := <-c.out:
+					if !c.write(websocket.TextMessage, data) {
+						return
+					}
+				default:
+					c.write(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+					return
+				}
+			}
+		}
+	}
+}
+
+// write writes one message, reporting whether it went through.
+func (c *client) write(messageType int, data []byte) bool {
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.conn.WriteMessage(messageType, data) == nil
+}
@@ -0,0 +1,211 @@
+// Package collab runs real-time collaborative editing sessions of note bodies
+// over WebSockets. Everyone with a note open joins the note's room, which
+// holds a replica of the body (see package crdt), relays edits and cursors
+// between them and saves the body every few seconds. Rooms of the same note
+// on other API instances exchange edits and presence through Redis pub/sub.
+package collab
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/cache"
+	"desis-keep/apps/api/internal/crdt"
+	"desis-keep/apps/api/internal/jobs"
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/services"
+)
+
+// Message types sent by clients.
+const (
+	TypeOps    = "ops"
+	TypeCursor = "cursor"
+)
+
+// Message types sent to clients. Ops and cursors of other sessions are also
+// passed on as TypeOps and TypePresence.
+const (
+	TypeInit     = "init"
+	TypePresence = "presence"
+	TypeLeave    = "leave"
+	TypeSaved    = "saved"
+	TypeError    = "error"
+)
+
+// Message types exchanged between instances only: a new room asks for the
+// state of the note's rooms elsewhere, and they answer with it.
+const (
+	typeSync  = "sync"
+	typeState = "state"
+)
+
+var errHubClosed = errors.New("collaborative editing is shutting down")
+
+// Peer is a session in a room.
+type Peer struct {
+	Session string          `json:"session"`
+	UserID  uint            `json:"user_id"`
+	Name    string          `json:"name"`
+	CanEdit bool            `json:"can_edit"`
+	Cursor  json.RawMessage `json:"cursor,omitempty"`
+}
+
+// Message is what clients, rooms and instances send each other.
+//
+// A client gets TypeInit when it joins: its Session, which is also the site
+// of the characters it types, the note's Runs and Clock, and the Peers in the
+// room. It sends TypeOps with the Ops it applied locally, and TypeCursor with
+// its Cursor, which the room passes on as the Peer of a TypePresence message
+// without looking into it. TypeSaved says when the body was last saved.
+type Message struct {
+	Type    string          `json:"type"`
+	Session string          `json:"session,omitempty"`
+	Ops     []crdt.Op       `json:"ops,omitempty"`
+	Cursor  json.RawMessage `json:"cursor,omitempty"`
+	Peer    *Peer           `json:"peer,omitempty"`
+	Peers   []Peer          `json:"peers,omitempty"`
+	Runs    []crdt.Run      `json:"runs,omitempty"`
+	Clock   uint64          `json:"clock,omitempty"`
+	SavedAt *time.Time      `json:"saved_at,omitempty"`
+	Code    string          `json:"code,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// envelope is a message published to the other instances.
+type envelope struct {
+	Instance string  `json:"instance"`
+	Message  Message `json:"message"`
+}
+
+// Hub keeps the open rooms of an API instance.
+type Hub struct {
+	Service *services.CollabService
+	Cache   *cache.Cache
+	Jobs    *jobs.Client
+
+	instance string
+	mu       sync.Mutex
+	rooms    map[uint]*room
+	closed   bool
+}
+
+// NewHub creates a new Hub. Without a cache, rooms only reach the sessions
+// on this instance.
+func NewHub(db *gorm.DB, cacheService *cache.Cache, jobClient *jobs.Client) *Hub {
+	return &Hub{
+		Service:  services.NewCollabService(db),
+		Cache:    cacheService,
+		Jobs:     jobClient,
+		instance: randomID(),
+		rooms:    make(map[uint]*room),
+	}
+}
+
+// Join adds a WebSocket connection to a note's room and serves it until it
+// closes. The user's access to the note must have been checked.
+func (h *Hub) Join(conn *websocket.Conn, noteID uint, user *models.User, canEdit bool) {
+	c := newClient(conn, Peer{
+		Session: randomID(),
+		UserID:  user.ID,
+		Name:    user.DisplayName(),
+		CanEdit: canEdit,
+	})
+	go c.writePump()
+
+	r, err := h.enter(noteID, c)
+	if err != nil {
+		log.Printf("Warning: opening note %d for editing: %v", noteID, err)
+		c.fail("UNAVAILABLE", "The note cannot be edited right now")
+		return
+	}
+	c.readPump(r)
+	if r.remove(c) {
+		r.close()
+	}
+}
+
+// Close saves every open room and disconnects its sessions.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	h.closed = true
+	rooms := make([]*room, 0, len(h.rooms))
+	for _, r := range h.rooms {
+		rooms = append(rooms, r)
+	}
+	h.mu.Unlock()
+
+	for _, r := range rooms {
+		<-r.ready
+		if r.err == nil {
+			r.shutdown()
+		}
+	}
+}
+
+// enter adds a client to the room of a note, opening the room when the note
+// has none. A room that is closing is waited for and opened again.
+func (h *Hub) enter(noteID uint, c *client) (*room, error) {
+	for {
+		h.mu.Lock()
+		if h.closed {
+			h.mu.Unlock()
+			return nil, errHubClosed
+		}
+		r, ok := h.rooms[noteID]
+		if !ok {
+			r = newRoom(h, noteID)
+			h.rooms[noteID] = r
+		}
+		h.mu.Unlock()
+
+		if !ok {
+			r.open()
+		}
+		<-r.ready
+		if r.err != nil {
+			return nil, r.err
+		}
+		if r.add(c) {
+			return r, nil
+		}
+		<-r.done
+	}
+}
+
+// forget removes a closed room.
+func (h *Hub) forget(r *room) {
+	h.mu.Lock()
+	if h.rooms[r.noteID] == r {
+		delete(h.rooms, r.noteID)
+	}
+	h.mu.Unlock()
+}
+
+// enqueueIndexing schedules refreshing the embedding and AI summary of a
+// note whose body a room saved.
+func (h *Hub) enqueueIndexing(noteID uint) {
+	if h.Jobs == nil {
+		return
+	}
+	if err := h.Jobs.EnqueueEmbed(models.ResourceTypeNote, noteID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := h.Jobs.EnqueueEnrich(models.ResourceTypeNote, noteID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// randomID returns a random identifier for a session or an instance.
+func randomID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
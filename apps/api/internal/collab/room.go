@@ -0,0 +1,486 @@
+package collab
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/crdt"
+	"desis-keep/apps/api/internal/services"
+)
+
+const (
+	// saveInterval is how often a room saves the edits of its sessions.
+	saveInterval = 5 * time.Second
+	// refreshInterval is how often a room saves even without edits, taking in
+	// changes made outside the session. Every save first checks that the
+	// room's users still have access to the note.
+	refreshInterval = 30 * time.Second
+	// outboxSize is how many messages to other instances may wait to be sent.
+	outboxSize = 1024
+)
+
+// room is the editing session of one note on this instance.
+type room struct {
+	hub     *Hub
+	noteID  uint
+	channel string
+
+	ready chan struct{} // closed once the room is open, or failed to open (err)
+	done  chan struct{} // closed once the room is closed and forgotten
+	err   error
+
+	mu      sync.Mutex
+	doc     *crdt.Doc
+	clients map[*client]bool
+	remote  map[string]Peer // sessions on other instances
+	dirty   bool
+	editor  uint // user of the last edit
+	closing bool
+
+	sub    *redis.PubSub
+	outbox chan Message
+	stop   chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+func newRoom(h *Hub, noteID uint) *room {
+	return &room{
+		hub:     h,
+		noteID:  noteID,
+		channel: fmt.Sprintf("collab:note:%d", noteID),
+		ready:   make(chan struct{}),
+		done:    make(chan struct{}),
+		clients: make(map[*client]bool),
+		remote:  make(map[string]Peer),
+		outbox:  make(chan Message, outboxSize),
+		stop:    make(chan struct{}),
+	}
+}
+
+// open loads the note's state, subscribes to the other instances and asks
+// them for the state of their rooms.
+func (r *room) open() {
+	defer close(r.ready)
+
+	if r.hub.Cache != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		r.sub = r.hub.Cache.Subscribe(ctx, r.channel)
+		if _, err := r.sub.Receive(ctx); err != nil {
+			log.Printf("Warning: subscribing to %s, editing on this instance only: %v", r.channel, err)
+			r.sub.Close()
+			r.sub = nil
+		}
+		cancel()
+	}
+
+	doc, err := r.hub.Service.Load(r.noteID)
+	if err != nil {
+		r.err = err
+		if r.sub != nil {
+			r.sub.Close()
+		}
+		r.hub.forget(r)
+		close(r.done)
+		return
+	}
+	r.doc = doc
+
+	r.wg.Add(2)
+	go r.run()
+	go r.relay()
+	if r.sub != nil {
+		r.wg.Add(1)
+		go r.listen()
+		r.publish(Message{Type: typeSync})
+	}
+}
+
+// add lets a client in and tells everyone. It returns false when the room is
+// closing.
+func (r *room) add(c *client) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closing {
+		return false
+	}
+	r.clients[c] = true
+
+	c.queue(Message{
+		Type:    TypeInit,
+		Session: c.peer.Session,
+		Peer:    &c.peer,
+		Peers:   r.peers(c),
+		Runs:    r.doc.Runs(),
+		Clock:   r.doc.Clock(),
+	})
+	peer := c.peer
+	r.broadcast(Message{Type: TypePresence, Peer: &peer}, c)
+	r.publish(Message{Type: TypePresence, Peer: &peer})
+	return true
+}
+
+// remove lets a client out and tells everyone. It returns true when it was
+// the last one, and the room must be closed.
+func (r *room) remove(c *client) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.clients[c] {
+		return false
+	}
+	delete(r.clients, c)
+
+	r.broadcast(Message{Type: TypeLeave, Session: c.peer.Session}, nil)
+	r.publish(Message{Type: TypeLeave, Session: c.peer.Session})
+	if len(r.clients) > 0 || r.closing {
+		return false
+	}
+	r.closing = true
+	return true
+}
+
+// receive handles a message from a client.
+func (r *room) receive(c *client, msg Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch msg.Type {
+	case TypeOps:
+		if !c.peer.CanEdit {
+			c.queue(errorMessage("FORBIDDEN", "You can only view this note"))
+			return
+		}
+		var applied []crdt.Op
+		for _, op := range msg.Ops {
+			if op.Type == crdt.OpInsert && op.ID.Site != c.peer.Session {
+				c.queue(errorMessage("INVALID_OP", "Inserted characters must have your session as their site"))
+				break
+			}
+			changed, err := r.doc.Apply(op)
+			if err != nil {
+				c.queue(errorMessage("INVALID_OP", err.Error()))
+				break
+			}
+			if changed {
+				applied = append(applied, op)
+			}
+		}
+		if len(applied) == 0 {
+			return
+		}
+		r.dirty = true
+		r.editor = c.peer.UserID
+		out := Message{Type: TypeOps, Session: c.peer.Session, Ops: applied}
+		r.broadcast(out, c)
+		r.publish(out)
+
+	case TypeCursor:
+		if len(msg.Cursor) > maxCursorSize {
+			c.queue(errorMessage("VALIDATION_ERROR", "cursor is too large"))
+			return
+		}
+		c.peer.Cursor = msg.Cursor
+		peer := c.peer
+		r.broadcast(Message{Type: TypePresence, Peer: &peer}, c)
+		r.publish(Message{Type: TypePresence, Peer: &peer})
+
+	default:
+		c.queue(errorMessage("VALIDATION_ERROR", fmt.Sprintf("unknown message type %q", msg.Type)))
+	}
+}
+
+// deliver handles a message from another instance.
+func (r *room) deliver(msg Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch msg.Type {
+	case TypeOps:
+		var applied []crdt.Op
+		for _, op := range msg.Ops {
+			if changed, _ := r.doc.Apply(op); changed {
+				applied = append(applied, op)
+			}
+		}
+		if len(applied) > 0 {
+			if peer, ok := r.remote[msg.Session]; ok {
+				r.editor = peer.UserID
+			}
+			r.broadcast(Message{Type: TypeOps, Session: msg.Session, Ops: applied}, nil)
+		}
+
+	case TypePresence:
+		if msg.Peer == nil {
+			return
+		}
+		r.remote[msg.Peer.Session] = *msg.Peer
+		r.broadcast(msg, nil)
+
+	case TypeLeave:
+		delete(r.remote, msg.Session)
+		r.broadcast(msg, nil)
+
+	case typeSync:
+		r.publish(Message{Type: typeState, Runs: r.doc.Runs(), Peers: r.localPeers()})
+
+	case typeState:
+		ops, err := r.doc.Merge(msg.Runs)
+		if err != nil {
+			log.Printf("Warning: merging state of note %d: %v", r.noteID, err)
+		}
+		if len(ops) > 0 {
+			r.broadcast(Message{Type: TypeOps, Ops: ops}, nil)
+		}
+		for _, peer := range msg.Peers {
+			if _, ok := r.remote[peer.Session]; !ok {
+				peer := peer
+				r.remote[peer.Session] = peer
+				r.broadcast(Message{Type: TypePresence, Peer: &peer}, nil)
+			}
+		}
+	}
+}
+
+// run saves the room's edits and refreshes it until the room closes.
+func (r *room) run() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(saveInterval)
+	defer ticker.Stop()
+	refreshed := time.Now()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			dirty := r.dirty
+			r.mu.Unlock()
+			if time.Since(refreshed) >= refreshInterval {
+				refreshed = time.Now()
+			} else if !dirty {
+				continue
+			}
+			// Edits are only saved once access is checked, so users who lost
+			// it stop editing within one save interval
+			r.checkAccess()
+			r.save()
+		}
+	}
+}
+
+// save writes the room's state back to the note and passes on the changes
+// that were saved from elsewhere.
+func (r *room) save() {
+	r.mu.Lock()
+	runs, editor, dirty := r.doc.Runs(), r.editor, r.dirty
+	r.dirty = false
+	r.mu.Unlock()
+
+	ops, changed, err := r.hub.Service.Save(r.noteID, editor, runs)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, services.ErrNotTextNote) {
+			r.end("NOTE_UNAVAILABLE", "The note was deleted or can no longer be edited together")
+			return
+		}
+		log.Printf("Warning: saving note %d: %v", r.noteID, err)
+		r.mu.Lock()
+		r.dirty = r.dirty || dirty
+		r.mu.Unlock()
+		return
+	}
+
+	r.mu.Lock()
+	var applied []crdt.Op
+	for _, op := range ops {
+		if changed, _ := r.doc.Apply(op); changed {
+			applied = append(applied, op)
+		}
+	}
+	if len(applied) > 0 {
+		out := Message{Type: TypeOps, Ops: applied}
+		r.broadcast(out, nil)
+		r.publish(out)
+	}
+	if changed {
+		now := time.Now()
+		r.broadcast(Message{Type: TypeSaved, SavedAt: &now}, nil)
+	}
+	r.mu.Unlock()
+
+	if changed {
+		r.hub.enqueueIndexing(r.noteID)
+	}
+}
+
+// checkAccess disconnects users who lost access to the note, and lets users
+// whose permission changed know.
+func (r *room) checkAccess() {
+	r.mu.Lock()
+	users := make(map[uint]bool)
+	for c := range r.clients {
+		users[c.peer.UserID] = true
+	}
+	r.mu.Unlock()
+
+	access := make(map[uint]bool, len(users))
+	lost := make(map[uint]bool)
+	for userID := range users {
+		canEdit, err := r.hub.Service.Access(r.noteID, userID)
+		switch {
+		case err == nil:
+			access[userID] = canEdit
+		case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, services.ErrNotTextNote):
+			lost[userID] = true
+		default:
+			log.Printf("Warning: checking access to note %d: %v", r.noteID, err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for c := range r.clients {
+		if lost[c.peer.UserID] {
+			c.fail("ACCESS_REVOKED", "You no longer have access to this note")
+			continue
+		}
+		if canEdit, ok := access[c.peer.UserID]; ok && canEdit != c.peer.CanEdit {
+			c.peer.CanEdit = canEdit
+			peer := c.peer
+			r.broadcast(Message{Type: TypePresence, Peer: &peer}, nil)
+			r.publish(Message{Type: TypePresence, Peer: &peer})
+		}
+	}
+}
+
+// end disconnects everyone with an error; the last one out closes the room.
+func (r *room) end(code, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for c := range r.clients {
+		c.fail(code, message)
+	}
+}
+
+// shutdown disconnects everyone and closes the room.
+func (r *room) shutdown() {
+	r.mu.Lock()
+	r.closing = true
+	for c := range r.clients {
+		c.fail("SHUTTING_DOWN", "The server is restarting; reconnect to keep editing")
+	}
+	r.mu.Unlock()
+	r.close()
+}
+
+// close stops the room, saves it a last time and forgets it.
+func (r *room) close() {
+	r.once.Do(func() {
+		close(r.stop)
+		if r.sub != nil {
+			r.sub.Close()
+		}
+		r.wg.Wait()
+		r.save()
+		r.hub.forget(r)
+		close(r.done)
+	})
+}
+
+// listen passes messages from the other instances to the room.
+func (r *room) listen() {
+	defer r.wg.Done()
+	for m := range r.sub.Channel() {
+		var env envelope
+		if err := json.Unmarshal([]byte(m.Payload), &env); err != nil {
+			log.Printf("Warning: decoding message on %s: %v", r.channel, err)
+			continue
+		}
+		if env.Instance != r.hub.instance {
+			r.deliver(env.Message)
+		}
+	}
+}
+
+// relay publishes the room's messages to the other instances, in order.
+func (r *room) relay() {
+	defer r.wg.Done()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case msg := <-r.outbox:
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			err := r.hub.Cache.Publish(ctx, r.channel, envelope{Instance: r.hub.instance, Message: msg})
+			cancel()
+			if err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+	}
+}
+
+// publish queues a message for the other instances. Call with r.mu held. A
+// message that does not fit is dropped; the other rooms still get its edits
+// from the saved state when they next save.
+func (r *room) publish(msg Message) {
+	if r.sub == nil || r.closing {
+		return
+	}
+	select {
+	case r.outbox <- msg:
+	default:
+		log.Printf("Warning: dropping message on %s, the outbox is full", r.channel)
+	}
+}
+
+// broadcast sends a message to every client but one. Call with r.mu held.
+func (r *room) broadcast(msg Message, except *client) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Warning: encoding message on %s: %v", r.channel, err)
+		return
+	}
+	for c := range r.clients {
+		if c != except {
+			c.send(data)
+		}
+	}
+}
+
+// peers returns the sessions in the room but one client's. Call with r.mu held.
+func (r *room) peers(except *client) []Peer {
+	peers := make([]Peer, 0, len(r.clients)+len(r.remote))
+	for c := range r.clients {
+		if c != except {
+			peers = append(peers, c.peer)
+		}
+	}
+	for _, peer := range r.remote {
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+// localPeers returns the sessions of the room on this instance. Call with
+// r.mu held.
+func (r *room) localPeers() []Peer {
+	peers := make([]Peer, 0, len(r.clients))
+	for c := range r.clients {
+		peers = append(peers, c.peer)
+	}
+	return peers
+}
+
+// errorMessage builds a TypeError message.
+func errorMessage(code, message string) Message {
+	return Message{Type: TypeError, Code: code, Error: message}
+}
@@ -3,10 +3,13 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+
+	"desis-keep/apps/api/internal/ai"
 )
 
 // StorageConfig holds credentials for a single S3-compatible provider.
@@ -26,6 +29,7 @@ type Config struct {
 	AppEnv      string
 	Port        string
 	AppURL      string
+	FrontendURL string // where the admin app is served, for links in emails
 	DatabaseURL string
 
 	JWTSecret        string
@@ -42,15 +46,28 @@ type Config struct {
 	MailFrom     string
 
 	CORSOrigins []string
+	// TrustedProxies may set the client address through X-Forwarded-For; none by default.
+	TrustedProxies []string
 
 	GORMStudioEnabled  bool
 	GORMStudioUsername string
 	GORMStudioPassword string
 
 	// AI
-	AIProvider string // "claude", "openai", or "gemini"
+	AIProvider string // "claude", "openai", "gemini", "ollama", or "fake"
 	AIAPIKey   string
 	AIModel    string
+	AIBaseURL  string // empty uses the vendor's public API; set for self-hosted or proxied endpoints
+
+	AIEmbeddingProvider string // "local", "openai", "gemini", "ollama", or "fake"
+	AIEmbeddingAPIKey   string // defaults to AIAPIKey
+	AIEmbeddingModel    string // empty selects the provider default
+	AIEmbeddingBaseURL  string
+
+	AIMonthlyTokenLimit int64 // default per-user monthly token quota; 0 means unlimited
+
+	// Links
+	LinkCheckUpdateRedirects bool // rewrite link URLs that permanently redirect during health checks
 
 	// Security (Sentinel)
 	SentinelEnabled   bool
@@ -82,6 +99,7 @@ func Load() (*Config, error) {
 		AppEnv:      getEnv("APP_ENV", "development"),
 		Port:        getEnv("APP_PORT", "8080"),
 		AppURL:      getEnv("APP_URL", "http://localhost:8080"),
+		FrontendURL: strings.TrimRight(getEnv("FRONTEND_URL", "http://localhost:3001"), "/"),
 		DatabaseURL: getEnv("DATABASE_URL", ""),
 		JWTSecret:   getEnv("JWT_SECRET", ""),
 		RedisURL:    getEnv("REDIS_URL", "redis://localhost:6379"),
@@ -92,7 +110,8 @@ func Load() (*Config, error) {
 		ResendAPIKey: getEnv("RESEND_API_KEY", ""),
 		MailFrom:     getEnv("MAIL_FROM", "noreply@localhost"),
 
-		CORSOrigins: strings.Split(getEnv("CORS_ORIGINS", "http://localhost:3000,http://localhost:3001"), ","),
+		CORSOrigins:    strings.Split(getEnv("CORS_ORIGINS", "http://localhost:3000,http://localhost:3001"), ","),
+		TrustedProxies: strings.FieldsFunc(getEnv("TRUSTED_PROXIES", ""), func(r rune) bool { return r == ',' || r == ' ' }),
 
 		GORMStudioEnabled:  getEnv("GORM_STUDIO_ENABLED", "true") == "true",
 		GORMStudioUsername: getEnv("GORM_STUDIO_USERNAME", "admin"),
@@ -101,6 +120,14 @@ func Load() (*Config, error) {
 		AIProvider: getEnv("AI_PROVIDER", "claude"),
 		AIAPIKey:   getEnv("AI_API_KEY", ""),
 		AIModel:    getEnv("AI_MODEL", "claude-sonnet-4-5-20250929"),
+		AIBaseURL:  getEnv("AI_BASE_URL", ""),
+
+		AIEmbeddingProvider: getEnv("AI_EMBEDDING_PROVIDER", "local"),
+		AIEmbeddingAPIKey:   getEnv("AI_EMBEDDING_API_KEY", getEnv("AI_API_KEY", "")),
+		AIEmbeddingModel:    getEnv("AI_EMBEDDING_MODEL", ""),
+		AIEmbeddingBaseURL:  getEnv("AI_EMBEDDING_BASE_URL", ""),
+
+		LinkCheckUpdateRedirects: getEnv("LINK_CHECK_UPDATE_REDIRECTS", "false") == "true",
 
 		SentinelEnabled:   getEnv("SENTINEL_ENABLED", "true") == "true",
 		SentinelUsername:  getEnv("SENTINEL_USERNAME", "admin"),
@@ -137,9 +164,35 @@ func Load() (*Config, error) {
 	}
 	cfg.JWTRefreshExpiry = refreshExpiry
 
+	tokenLimit, err := strconv.ParseInt(getEnv("AI_MONTHLY_TOKEN_LIMIT", "0"), 10, 64)
+	if err != nil || tokenLimit < 0 {
+		return nil, fmt.Errorf("invalid AI_MONTHLY_TOKEN_LIMIT: must be a non-negative integer")
+	}
+	cfg.AIMonthlyTokenLimit = tokenLimit
+
 	return cfg, nil
 }
 
+// AIConfig returns the provider settings for text generation.
+func (c *Config) AIConfig() ai.Config {
+	return ai.Config{
+		Provider: c.AIProvider,
+		APIKey:   c.AIAPIKey,
+		Model:    c.AIModel,
+		BaseURL:  c.AIBaseURL,
+	}
+}
+
+// AIEmbeddingConfig returns the provider settings for embeddings.
+func (c *Config) AIEmbeddingConfig() ai.Config {
+	return ai.Config{
+		Provider:       c.AIEmbeddingProvider,
+		APIKey:         c.AIEmbeddingAPIKey,
+		EmbeddingModel: c.AIEmbeddingModel,
+		BaseURL:        c.AIEmbeddingBaseURL,
+	}
+}
+
 // IsDevelopment returns true if the app is running in development mode.
 func (c *Config) IsDevelopment() bool {
 	return c.AppEnv == "development"
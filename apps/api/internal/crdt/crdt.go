@@ -0,0 +1,442 @@
+// Package crdt implements a replicated text for collaborative editing: an RGA
+// (replicated growable array) in which every character has a unique ID and is
+// placed after the character it was typed after. Replicas that apply the same
+// operations, in any order, hold the same text.
+//
+// IDs are Lamport timestamps: a site gives every character it types a clock
+// greater than that of any character it has seen. Concurrent insertions after
+// the same character are ordered by ID, greatest first. Deleted characters
+// stay in the document as tombstones so that later insertions after them can
+// still be placed.
+package crdt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"desis-keep/apps/api/internal/textdiff"
+)
+
+// Operation types.
+const (
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+const (
+	// MaxOpLength is the most characters one operation inserts or deletes.
+	MaxOpLength = 1 << 20
+	// MaxClockGap is how far above the document's clock an operation's clock
+	// may be. Honest sites stay within the characters they have seen, and the
+	// bound keeps a site from pushing the clock towards overflow.
+	MaxClockGap = 1 << 32
+	// maxPending bounds the insertions waiting for the character they follow.
+	maxPending = 10000
+)
+
+// ErrInvalidOp is returned for an operation that cannot be applied.
+var ErrInvalidOp = errors.New("invalid operation")
+
+// ID identifies a character: the Lamport clock of its insertion and the site
+// that typed it.
+type ID struct {
+	Clock uint64 `json:"clock"`
+	Site  string `json:"site"`
+}
+
+// IsZero reports whether the ID is unset, which as an origin means the start
+// of the text.
+func (a ID) IsZero() bool {
+	return a.Clock == 0 && a.Site == ""
+}
+
+// Less orders IDs by clock, then by site.
+func (a ID) Less(b ID) bool {
+	if a.Clock != b.Clock {
+		return a.Clock < b.Clock
+	}
+	return a.Site < b.Site
+}
+
+// next returns the ID of the character typed right after this one.
+func (a ID) next(n int) ID {
+	return ID{Clock: a.Clock + uint64(n), Site: a.Site}
+}
+
+// Op is an edit of a document. An insert puts Text after the character Origin,
+// or at the start when Origin is nil; its characters get IDs with consecutive
+// clocks from ID. A delete removes the Length characters with consecutive
+// clocks from ID.
+type Op struct {
+	Type   string `json:"type"`
+	ID     ID     `json:"id"`
+	Origin *ID    `json:"origin,omitempty"`
+	Text   string `json:"text,omitempty"`
+	Length int    `json:"length,omitempty"`
+}
+
+// Run is a part of a document's state: characters one site typed one after
+// another, all deleted or none. A document's runs, in order, are its state.
+type Run struct {
+	ID      ID     `json:"id"`
+	Origin  *ID    `json:"origin,omitempty"`
+	Text    string `json:"text"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// element is one character of a document.
+type element struct {
+	id      ID
+	origin  ID
+	ch      rune
+	deleted bool
+}
+
+// Doc is a replica of a text. It is not safe for concurrent use.
+type Doc struct {
+	elems   []element
+	known   map[ID]bool
+	pending [][]element
+	deletes map[ID]bool
+	clock   uint64
+	hint    int // position of the last character looked up or inserted
+}
+
+// New returns an empty document.
+func New() *Doc {
+	return &Doc{known: make(map[ID]bool), deletes: make(map[ID]bool)}
+}
+
+// Clock returns the greatest clock in the document.
+func (d *Doc) Clock() uint64 {
+	return d.clock
+}
+
+// Text returns the document's text.
+func (d *Doc) Text() string {
+	var b strings.Builder
+	for _, e := range d.elems {
+		if !e.deleted {
+			b.WriteRune(e.ch)
+		}
+	}
+	return b.String()
+}
+
+// Apply applies an operation and reports whether it changed the document.
+// Applying an operation again changes nothing. An insertion after a character
+// the document does not have yet waits for it, as does a deletion of one.
+// An operation whose clock is more than MaxClockGap ahead of the document's
+// is rejected.
+func (d *Doc) Apply(op Op) (bool, error) {
+	if err := validate(op); err != nil {
+		return false, err
+	}
+	if op.ID.Clock > d.clock+MaxClockGap {
+		return false, fmt.Errorf("%w: clock is more than %d ahead of the document", ErrInvalidOp, MaxClockGap)
+	}
+	if op.Type == OpDelete {
+		changed := false
+		for i := 0; i < op.Length; i++ {
+			changed = d.remove(op.ID.next(i)) || changed
+		}
+		return changed, nil
+	}
+	origin := ID{}
+	if op.Origin != nil {
+		origin = *op.Origin
+	}
+	return d.integrate(chain(op.ID, origin, op.Text)), nil
+}
+
+// Runs returns the document's state, tombstones included.
+func (d *Doc) Runs() []Run {
+	var runs []Run
+	var text strings.Builder
+	for i, e := range d.elems {
+		if i > 0 {
+			prev := d.elems[i-1]
+			if e.origin != prev.id || e.id != prev.id.next(1) || e.deleted != prev.deleted {
+				runs[len(runs)-1].Text = text.String()
+				text.Reset()
+			} else {
+				text.WriteRune(e.ch)
+				continue
+			}
+		}
+		run := Run{ID: e.id, Deleted: e.deleted}
+		if !e.origin.IsZero() {
+			origin := e.origin
+			run.Origin = &origin
+		}
+		runs = append(runs, run)
+		text.WriteRune(e.ch)
+	}
+	if len(runs) > 0 {
+		runs[len(runs)-1].Text = text.String()
+	}
+	return runs
+}
+
+// Merge applies another replica's state and returns the operations that
+// changed this document, to pass on to replicas that have not seen them.
+func (d *Doc) Merge(runs []Run) ([]Op, error) {
+	var ops []Op
+	for _, run := range runs {
+		op := Op{Type: OpInsert, ID: run.ID, Origin: run.Origin, Text: run.Text}
+		changed, err := d.Apply(op)
+		if err != nil {
+			return ops, err
+		}
+		if changed {
+			ops = append(ops, op)
+		}
+		if run.Deleted {
+			del := Op{Type: OpDelete, ID: run.ID, Length: utf8.RuneCountInString(run.Text)}
+			if changed, _ := d.Apply(del); changed {
+				ops = append(ops, del)
+			}
+		}
+	}
+	return ops, nil
+}
+
+// Replace edits the document to hold text, as site, changing only the lines
+// that differ, and returns the operations it applied. Characters other
+// replicas inserted concurrently among the replaced lines are kept. On error
+// the document may hold part of the new text.
+func (d *Doc) Replace(text, site string) ([]Op, error) {
+	var visible []element
+	for _, e := range d.elems {
+		if !e.deleted {
+			visible = append(visible, e)
+		}
+	}
+
+	var ops []Op
+	pos := 0
+	last := ID{}
+	var inserted strings.Builder
+	flush := func() error {
+		rest := inserted.String()
+		inserted.Reset()
+		for rest != "" {
+			// Insertions longer than one operation may hold are split
+			text := rest
+			if utf8.RuneCountInString(text) > MaxOpLength {
+				n := 0
+				for i := range text {
+					if n == MaxOpLength {
+						text = text[:i]
+						break
+					}
+					n++
+				}
+			}
+			rest = rest[len(text):]
+
+			op := Op{Type: OpInsert, ID: ID{Clock: d.clock + 1, Site: site}, Text: text}
+			if !last.IsZero() {
+				origin := last
+				op.Origin = &origin
+			}
+			if _, err := d.Apply(op); err != nil {
+				return err
+			}
+			ops = append(ops, op)
+			last = op.ID.next(utf8.RuneCountInString(op.Text) - 1)
+		}
+		return nil
+	}
+
+	for _, line := range textdiff.CompareLines(splitLines(d.Text()), splitLines(text)).Lines {
+		n := utf8.RuneCountInString(line.Text)
+		switch line.Op {
+		case textdiff.Equal:
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			pos += n
+			last = visible[pos-1].id
+		case textdiff.Delete:
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			for _, e := range visible[pos : pos+n] {
+				if k := len(ops) - 1; k >= 0 && ops[k].Type == OpDelete && ops[k].ID.next(ops[k].Length) == e.id {
+					ops[k].Length++
+				} else {
+					ops = append(ops, Op{Type: OpDelete, ID: e.id, Length: 1})
+				}
+				d.remove(e.id)
+			}
+			pos += n
+		case textdiff.Insert:
+			inserted.WriteString(line.Text)
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+// integrate inserts a chain of characters, each typed after the one before,
+// and then any waiting insertions it makes possible.
+func (d *Doc) integrate(chain []element) bool {
+	changed := d.insert(chain)
+	for changed && len(d.pending) > 0 {
+		waiting := d.pending
+		d.pending = nil
+		progress := false
+		for _, c := range waiting {
+			progress = d.insert(c) || progress
+		}
+		if !progress {
+			break
+		}
+	}
+	return changed
+}
+
+// insert places the characters of a chain the document does not have yet.
+// The rest of a chain whose origin is missing waits.
+func (d *Doc) insert(chain []element) bool {
+	changed := false
+	for i := 0; i < len(chain); {
+		if d.known[chain[i].id] {
+			i++
+			continue
+		}
+		if origin := chain[i].origin; !origin.IsZero() && !d.known[origin] {
+			if len(d.pending) < maxPending {
+				d.pending = append(d.pending, chain[i:])
+			}
+			break
+		}
+
+		// A new character goes after its origin, past the characters there
+		// with greater IDs, which were inserted concurrently and come first.
+		// The characters of a chain after it then follow it directly, as
+		// nothing they could be ordered behind is known yet.
+		p := 0
+		if !chain[i].origin.IsZero() {
+			p = d.indexOf(chain[i].origin) + 1
+		}
+		for p < len(d.elems) && chain[i].id.Less(d.elems[p].id) {
+			p++
+		}
+		j := i + 1
+		for j < len(chain) && !d.known[chain[j].id] {
+			j++
+		}
+		batch := chain[i:j]
+		d.elems = append(d.elems, batch...)
+		copy(d.elems[p+len(batch):], d.elems[p:len(d.elems)-len(batch)])
+		copy(d.elems[p:], batch)
+		d.hint = p + len(batch) - 1
+		for k := p; k < p+len(batch); k++ {
+			e := &d.elems[k]
+			d.known[e.id] = true
+			if d.deletes[e.id] {
+				e.deleted = true
+				delete(d.deletes, e.id)
+			}
+			if e.id.Clock > d.clock {
+				d.clock = e.id.Clock
+			}
+		}
+		changed = true
+		i = j
+	}
+	return changed
+}
+
+// remove deletes a character, or remembers to delete it when it arrives.
+func (d *Doc) remove(id ID) bool {
+	if !d.known[id] {
+		if len(d.deletes) < maxPending {
+			d.deletes[id] = true
+		}
+		return false
+	}
+	i := d.indexOf(id)
+	if d.elems[i].deleted {
+		return false
+	}
+	d.elems[i].deleted = true
+	return true
+}
+
+// indexOf returns the position of a known character, searching outwards from
+// the last one looked up, since edits tend to follow each other.
+func (d *Doc) indexOf(id ID) int {
+	n := len(d.elems)
+	if d.hint >= n {
+		d.hint = n - 1
+	}
+	for k := 0; d.hint+k < n || d.hint-k-1 >= 0; k++ {
+		if i := d.hint + k; i < n && d.elems[i].id == id {
+			d.hint = i
+			return i
+		}
+		if i := d.hint - k - 1; i >= 0 && d.elems[i].id == id {
+			d.hint = i
+			return i
+		}
+	}
+	return -1
+}
+
+// chain returns the characters of text typed one after another from id, the
+// first after origin.
+func chain(id, origin ID, text string) []element {
+	elems := make([]element, 0, utf8.RuneCountInString(text))
+	for _, ch := range text {
+		elems = append(elems, element{id: id, origin: origin, ch: ch})
+		origin, id = id, id.next(1)
+	}
+	return elems
+}
+
+// validate checks that an operation is well formed.
+func validate(op Op) error {
+	if op.ID.Clock == 0 || op.ID.Site == "" {
+		return fmt.Errorf("%w: id needs a clock and a site", ErrInvalidOp)
+	}
+	switch op.Type {
+	case OpInsert:
+		n := utf8.RuneCountInString(op.Text)
+		if n == 0 || n > MaxOpLength || !utf8.ValidString(op.Text) {
+			return fmt.Errorf("%w: insert needs 1 to %d characters of UTF-8 text", ErrInvalidOp, MaxOpLength)
+		}
+		if op.Origin != nil && !op.Origin.IsZero() && op.ID.Clock <= op.Origin.Clock {
+			return fmt.Errorf("%w: insert clock must be greater than its origin's", ErrInvalidOp)
+		}
+		if op.ID.Clock+uint64(n) < op.ID.Clock {
+			return fmt.Errorf("%w: clock overflows", ErrInvalidOp)
+		}
+	case OpDelete:
+		if op.Length < 1 || op.Length > MaxOpLength {
+			return fmt.Errorf("%w: delete needs a length of 1 to %d", ErrInvalidOp, MaxOpLength)
+		}
+		if op.ID.Clock+uint64(op.Length) < op.ID.Clock {
+			return fmt.Errorf("%w: clock overflows", ErrInvalidOp)
+		}
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidOp, op.Type)
+	}
+	return nil
+}
+
+// splitLines splits text into lines that keep their line endings, so that
+// joining them gives the text back.
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
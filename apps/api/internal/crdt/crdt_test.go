@@ -0,0 +1,233 @@
+package crdt
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// base returns a document holding "one\ntwo\n", typed by site "base" with
+// clocks 1 to 8.
+func base(t *testing.T) *Doc {
+	t.Helper()
+	d := New()
+	if _, err := d.Replace("one\ntwo\n", "base"); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	return d
+}
+
+// clone returns a replica holding the same state as d.
+func clone(t *testing.T, d *Doc) *Doc {
+	t.Helper()
+	c := New()
+	if _, err := c.Merge(d.Runs()); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	return c
+}
+
+// concurrentOps returns edits two sites made to base without seeing each
+// other's: both typed after "one", deleted overlapping parts of "two", and
+// site a deleted what it typed.
+func concurrentOps() (a, b []Op) {
+	e := ID{Clock: 3, Site: "base"}
+	a = []Op{
+		{Type: OpInsert, ID: ID{Clock: 9, Site: "a"}, Origin: &e, Text: "X"},
+		{Type: OpDelete, ID: ID{Clock: 5, Site: "base"}, Length: 2},
+		{Type: OpDelete, ID: ID{Clock: 9, Site: "a"}, Length: 1},
+	}
+	y := ID{Clock: 9, Site: "b"}
+	b = []Op{
+		{Type: OpInsert, ID: y, Origin: &e, Text: "Y"},
+		{Type: OpInsert, ID: ID{Clock: 10, Site: "b"}, Origin: &y, Text: "Z"},
+		{Type: OpDelete, ID: ID{Clock: 6, Site: "base"}, Length: 2},
+	}
+	return a, b
+}
+
+// permutations calls fn with every ordering of ops.
+func permutations(ops []Op, fn func([]Op)) {
+	var permute func(int)
+	permute = func(k int) {
+		if k == len(ops) {
+			fn(ops)
+			return
+		}
+		for i := k; i < len(ops); i++ {
+			ops[k], ops[i] = ops[i], ops[k]
+			permute(k + 1)
+			ops[k], ops[i] = ops[i], ops[k]
+		}
+	}
+	permute(0)
+}
+
+func TestConcurrentEditsConverge(t *testing.T) {
+	a, b := concurrentOps()
+	start := base(t)
+	want := "oneYZ\n\n"
+
+	var first []Run
+	permutations(append(append([]Op(nil), a...), b...), func(ops []Op) {
+		d := clone(t, start)
+		for _, op := range ops {
+			if _, err := d.Apply(op); err != nil {
+				t.Fatalf("Apply(%+v): %v", op, err)
+			}
+		}
+		if got := d.Text(); got != want {
+			t.Fatalf("after %+v: text = %q, want %q", ops, got, want)
+		}
+		if first == nil {
+			first = d.Runs()
+		} else if runs := d.Runs(); !reflect.DeepEqual(runs, first) {
+			t.Fatalf("after %+v: runs = %+v, want %+v", ops, runs, first)
+		}
+	})
+}
+
+func TestApplyTwice(t *testing.T) {
+	a, b := concurrentOps()
+	d := base(t)
+	for _, op := range append(a, b...) {
+		if changed, err := d.Apply(op); err != nil || !changed {
+			t.Fatalf("first Apply(%+v) = %v, %v; want a change", op, changed, err)
+		}
+		text := d.Text()
+		if changed, err := d.Apply(op); err != nil || changed {
+			t.Fatalf("second Apply(%+v) = %v, %v; want no change", op, changed, err)
+		}
+		if d.Text() != text {
+			t.Fatalf("second Apply(%+v) changed the text to %q", op, d.Text())
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, b := concurrentOps()
+	da, db := base(t), base(t)
+	for _, op := range a {
+		da.Apply(op)
+	}
+	for _, op := range b {
+		db.Apply(op)
+	}
+
+	ab := clone(t, da)
+	toA, err := ab.Merge(db.Runs())
+	if err != nil {
+		t.Fatalf("merging b into a: %v", err)
+	}
+	ba := clone(t, db)
+	if _, err := ba.Merge(da.Runs()); err != nil {
+		t.Fatalf("merging a into b: %v", err)
+	}
+	if ab.Text() != "oneYZ\n\n" || ba.Text() != ab.Text() {
+		t.Errorf("merged texts = %q and %q, want %q", ab.Text(), ba.Text(), "oneYZ\n\n")
+	}
+	if !reflect.DeepEqual(ab.Runs(), ba.Runs()) {
+		t.Errorf("merged runs differ:\n%+v\n%+v", ab.Runs(), ba.Runs())
+	}
+
+	// The operations Merge returns bring a replica of a up to date
+	for _, op := range toA {
+		if _, err := da.Apply(op); err != nil {
+			t.Fatalf("Apply(%+v): %v", op, err)
+		}
+	}
+	if da.Text() != ab.Text() {
+		t.Errorf("a after the merge ops = %q, want %q", da.Text(), ab.Text())
+	}
+	if ops, err := ab.Merge(db.Runs()); err != nil || len(ops) != 0 {
+		t.Errorf("merging again = %+v, %v; want no ops", ops, err)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	d := base(t)
+	other := clone(t, d)
+
+	ops, err := d.Replace("one\nthree\n", "s")
+	if err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	if d.Text() != "one\nthree\n" {
+		t.Fatalf("text = %q", d.Text())
+	}
+	// Only the changed line is deleted and typed again
+	want := []Op{
+		{Type: OpDelete, ID: ID{Clock: 5, Site: "base"}, Length: 4},
+		{Type: OpInsert, ID: ID{Clock: 9, Site: "s"}, Origin: &ID{Clock: 4, Site: "base"}, Text: "three\n"},
+	}
+	if !reflect.DeepEqual(ops, want) {
+		t.Errorf("ops = %+v, want %+v", ops, want)
+	}
+	if ops, err := d.Replace(d.Text(), "s"); err != nil || len(ops) != 0 {
+		t.Errorf("replacing with the same text = %+v, %v; want no ops", ops, err)
+	}
+
+	// A character typed concurrently in the replaced line is kept, and both
+	// replicas agree on where
+	w := ID{Clock: 6, Site: "base"}
+	bang := Op{Type: OpInsert, ID: ID{Clock: 9, Site: "t"}, Origin: &w, Text: "!"}
+	other.Apply(bang)
+	d.Apply(bang)
+	for _, op := range ops {
+		other.Apply(op)
+	}
+	if d.Text() != "one\nthree\n!" || other.Text() != d.Text() {
+		t.Errorf("texts = %q and %q, want %q", d.Text(), other.Text(), "one\nthree\n!")
+	}
+}
+
+func TestReplaceSplitsLongInsertions(t *testing.T) {
+	text := strings.Repeat("é", MaxOpLength+3)
+	d := New()
+	ops, err := d.Replace(text, "s")
+	if err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	if len(ops) != 2 || len(ops[1].Text) != 3*len("é") {
+		t.Errorf("got %d ops, want an insertion of %d characters and one of 3", len(ops), MaxOpLength)
+	}
+	if d.Text() != text {
+		t.Error("text differs from the replacement")
+	}
+}
+
+func TestApplyRejects(t *testing.T) {
+	origin := ID{Clock: 5, Site: "a"}
+	tests := []struct {
+		name string
+		op   Op
+	}{
+		{"no clock", Op{Type: OpInsert, ID: ID{Site: "a"}, Text: "x"}},
+		{"no site", Op{Type: OpInsert, ID: ID{Clock: 1}, Text: "x"}},
+		{"no text", Op{Type: OpInsert, ID: ID{Clock: 1, Site: "a"}}},
+		{"invalid UTF-8", Op{Type: OpInsert, ID: ID{Clock: 1, Site: "a"}, Text: "\xff"}},
+		{"clock not after origin", Op{Type: OpInsert, ID: ID{Clock: 5, Site: "b"}, Origin: &origin, Text: "x"}},
+		{"no length", Op{Type: OpDelete, ID: ID{Clock: 1, Site: "a"}}},
+		{"unknown type", Op{Type: "move", ID: ID{Clock: 1, Site: "a"}}},
+		{"clock overflows", Op{Type: OpDelete, ID: ID{Clock: 1<<64 - 1, Site: "a"}, Length: 2}},
+		{"clock far ahead", Op{Type: OpInsert, ID: ID{Clock: MaxClockGap + 1, Site: "a"}, Text: "x"}},
+		{"deleted clock far ahead", Op{Type: OpDelete, ID: ID{Clock: MaxClockGap + 1, Site: "a"}, Length: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := New()
+			if changed, err := d.Apply(tt.op); !errors.Is(err, ErrInvalidOp) || changed {
+				t.Errorf("Apply = %v, %v; want ErrInvalidOp", changed, err)
+			}
+			if d.Clock() != 0 || d.Text() != "" {
+				t.Errorf("rejected op changed the document")
+			}
+		})
+	}
+
+	d := New()
+	if _, err := d.Apply(Op{Type: OpInsert, ID: ID{Clock: MaxClockGap, Site: "a"}, Text: "x"}); err != nil {
+		t.Errorf("clock MaxClockGap ahead: %v", err)
+	}
+}
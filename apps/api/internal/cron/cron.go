@@ -3,6 +3,7 @@ package cron
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/hibiken/asynq"
 )
@@ -45,6 +46,50 @@ func New(redisURL string) (*Scheduler, error) {
 		Type:     "tokens:cleanup",
 	})
 
+	// Check saved links for rot — every hour, in bounded batches
+	_, err = scheduler.Register("30 * * * *", asynq.NewTask("links:check-health", nil), asynq.Timeout(45*time.Minute))
+	if err != nil {
+		return nil, fmt.Errorf("registering link health check: %w", err)
+	}
+	RegisteredTasks = append(RegisteredTasks, Task{
+		Name:     "Check link health",
+		Schedule: "30 * * * *",
+		Type:     "links:check-health",
+	})
+
+	// Delete account export archives past their download window — daily
+	_, err = scheduler.Register("15 3 * * *", asynq.NewTask("exports:cleanup", nil))
+	if err != nil {
+		return nil, fmt.Errorf("registering export cleanup: %w", err)
+	}
+	RegisteredTasks = append(RegisteredTasks, Task{
+		Name:     "Delete expired exports",
+		Schedule: "15 3 * * *",
+		Type:     "exports:cleanup",
+	})
+
+	// Notify about due reminders and schedule their next occurrence — every minute
+	_, err = scheduler.Register("* * * * *", asynq.NewTask("reminders:dispatch", nil), asynq.Queue("critical"), asynq.Timeout(time.Minute))
+	if err != nil {
+		return nil, fmt.Errorf("registering reminder dispatch: %w", err)
+	}
+	RegisteredTasks = append(RegisteredTasks, Task{
+		Name:     "Dispatch due reminders",
+		Schedule: "* * * * *",
+		Type:     "reminders:dispatch",
+	})
+
+	// Prune note revisions past the retention policy — daily
+	_, err = scheduler.Register("45 3 * * *", asynq.NewTask("revisions:prune", nil), asynq.Timeout(30*time.Minute))
+	if err != nil {
+		return nil, fmt.Errorf("registering revision pruning: %w", err)
+	}
+	RegisteredTasks = append(RegisteredTasks, Task{
+		Name:     "Prune note revisions",
+		Schedule: "45 3 * * *",
+		Type:     "revisions:prune",
+	})
+
 	// grit:cron-tasks
 
 	return &Scheduler{scheduler: scheduler}, nil
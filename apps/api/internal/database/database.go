@@ -7,6 +7,8 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+
+	"desis-keep/apps/api/internal/models"
 )
 
 // Connect establishes a database connection using the provided DSN.
@@ -21,6 +23,10 @@ func Connect(dsn string) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	if err := models.RegisterVersioning(db); err != nil {
+		return nil, fmt.Errorf("failed to register versioning: %w", err)
+	}
+
 	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get underlying sql.DB: %w", err)
@@ -0,0 +1,183 @@
+// Package enex reads Evernote export (.enex) files. Notes are decoded one at
+// a time from the XML stream, so exports of any size can be read with only a
+// single note, including its attachments, held in memory.
+package enex
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// ErrNotENEX is returned when the input is not an Evernote export.
+var ErrNotENEX = errors.New("not an Evernote export file")
+
+// timeLayout is how ENEX writes timestamps.
+const timeLayout = "20060102T150405Z"
+
+// Note is one note of an export. Content is the note body in ENML, the XHTML
+// dialect Evernote stores notes in; see ToText.
+type Note struct {
+	Title     string
+	Content   string
+	Created   time.Time
+	Updated   time.Time
+	Tags      []string
+	SourceURL string
+	Resources []Resource
+}
+
+// Resource is a file attached to a note. Hash is the MD5 of Data in hex, which
+// <en-media> elements in the content use to place it.
+type Resource struct {
+	Data     []byte
+	Mime     string
+	FileName string
+	Width    int
+	Height   int
+	Hash     string
+}
+
+// xmlNote mirrors a <note> element.
+type xmlNote struct {
+	Title      string   `xml:"title"`
+	Content    string   `xml:"content"`
+	Created    string   `xml:"created"`
+	Updated    string   `xml:"updated"`
+	Tags       []string `xml:"tag"`
+	Attributes struct {
+		SourceURL string `xml:"source-url"`
+	} `xml:"note-attributes"`
+	Resources []struct {
+		Data struct {
+			Encoding string `xml:"encoding,attr"`
+			Value    string `xml:",chardata"`
+		} `xml:"data"`
+		Mime       string `xml:"mime"`
+		Width      int    `xml:"width"`
+		Height     int    `xml:"height"`
+		Attributes struct {
+			FileName string `xml:"file-name"`
+		} `xml:"resource-attributes"`
+	} `xml:"resource"`
+}
+
+// Sniff reports whether head, the start of a file, looks like an Evernote export.
+func Sniff(head []byte) bool {
+	return bytes.Contains(head, []byte("<en-export"))
+}
+
+// Reader reads notes from an export one at a time.
+type Reader struct {
+	dec     *xml.Decoder
+	started bool
+}
+
+// NewReader creates a Reader for an export.
+func NewReader(r io.Reader) *Reader {
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = charset.NewReaderLabel
+	dec.Strict = false
+	return &Reader{dec: dec}
+}
+
+// Next returns the next note, or io.EOF after the last one. A file that
+// ends without an <en-export> element returns ErrNotENEX.
+func (r *Reader) Next() (*Note, error) {
+	for {
+		tok, err := r.dec.Token()
+		if err == io.EOF {
+			if !r.started {
+				return nil, ErrNotENEX
+			}
+			return nil, io.EOF
+		}
+		if err != nil {
+			if !r.started {
+				return nil, fmt.Errorf("%w: %v", ErrNotENEX, err)
+			}
+			return nil, fmt.Errorf("reading export: %w", err)
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "en-export":
+			r.started = true
+		case "note":
+			if !r.started {
+				return nil, ErrNotENEX
+			}
+			var raw xmlNote
+			if err := r.dec.DecodeElement(&raw, &start); err != nil {
+				return nil, fmt.Errorf("reading note: %w", err)
+			}
+			return convert(raw)
+		}
+	}
+}
+
+func convert(raw xmlNote) (*Note, error) {
+	note := &Note{
+		Title:     strings.TrimSpace(raw.Title),
+		Content:   raw.Content,
+		Created:   parseTime(raw.Created),
+		Updated:   parseTime(raw.Updated),
+		SourceURL: strings.TrimSpace(raw.Attributes.SourceURL),
+	}
+	for _, tag := range raw.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			note.Tags = append(note.Tags, tag)
+		}
+	}
+	for _, res := range raw.Resources {
+		data := []byte(res.Data.Value)
+		if res.Data.Encoding == "" || strings.EqualFold(res.Data.Encoding, "base64") {
+			decoded, err := base64.StdEncoding.DecodeString(stripSpace(res.Data.Value))
+			if err != nil {
+				return nil, fmt.Errorf("decoding attachment of %q: %w", note.Title, err)
+			}
+			data = decoded
+		}
+		sum := md5.Sum(data)
+		note.Resources = append(note.Resources, Resource{
+			Data:     data,
+			Mime:     strings.TrimSpace(res.Mime),
+			FileName: strings.TrimSpace(res.Attributes.FileName),
+			Width:    res.Width,
+			Height:   res.Height,
+			Hash:     hex.EncodeToString(sum[:]),
+		})
+	}
+	return note, nil
+}
+
+// parseTime reads an ENEX timestamp, returning the zero time when it is missing.
+func parseTime(value string) time.Time {
+	t, err := time.Parse(timeLayout, strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// stripSpace removes the line breaks exporters wrap base64 data with.
+func stripSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+			return -1
+		}
+		return r
+	}, s)
+}
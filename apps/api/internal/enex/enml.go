@@ -0,0 +1,277 @@
+package enex
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// blankLines matches runs of blank lines to fold into one.
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// listLevel tracks one open <ul> or <ol>.
+type listLevel struct {
+	ordered bool
+	count   int
+}
+
+// textWriter accumulates converted text and tracks whether the output is at
+// the start of a line.
+type textWriter struct {
+	b         strings.Builder
+	lineStart bool
+	space     bool // whitespace is pending before the next word
+}
+
+func (w *textWriter) newline() {
+	if w.b.Len() > 0 && !w.lineStart {
+		w.b.WriteByte('\n')
+	}
+	w.lineStart = true
+	w.space = false
+}
+
+func (w *textWriter) blank() {
+	w.newline()
+	if w.b.Len() > 0 && !strings.HasSuffix(w.b.String(), "\n\n") {
+		w.b.WriteByte('\n')
+	}
+}
+
+func (w *textWriter) write(s string) {
+	if s == "" {
+		return
+	}
+	if w.space && !w.lineStart {
+		w.b.WriteByte(' ')
+	}
+	w.b.WriteString(s)
+	w.lineStart = strings.HasSuffix(s, "\n")
+	w.space = false
+}
+
+// ToText converts ENML to the plain text notes store, using Markdown for
+// structure: headings, bullet and numbered lists, checklists as "- [ ]" and
+// "- [x]", links as [text](url) and quotes as "> ". Each <en-media> element is
+// replaced by what media returns for its hash and MIME type. Encrypted
+// sections, which only Evernote can open, become a placeholder.
+func ToText(enml string, media func(hash, mime string) string) string {
+	var (
+		w       = &textWriter{lineStart: true}
+		lists   []listLevel
+		hrefs   []string // hrefs of open <a> elements
+		linkAt  []int    // output length where each open <a> started
+		pre     int      // depth of <pre> elements
+		quote   int      // depth of <blockquote> elements
+		skip    int      // depth of elements whose text is dropped
+		rowCell int      // cells written in the current table row
+	)
+
+	linePrefix := func() {
+		if w.lineStart && quote > 0 {
+			w.b.WriteString(strings.Repeat("> ", quote))
+			w.lineStart = false
+		}
+	}
+
+	z := html.NewTokenizer(strings.NewReader(enml))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			text := blankLines.ReplaceAllString(w.b.String(), "\n\n")
+			return strings.TrimSpace(text)
+
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			text := string(z.Text())
+			if pre > 0 {
+				linePrefix()
+				w.b.WriteString(text)
+				w.lineStart = strings.HasSuffix(text, "\n")
+				continue
+			}
+			leading := len(text) > 0 && isSpace(text[0])
+			trailing := len(text) > 0 && isSpace(text[len(text)-1])
+			words := strings.Fields(text)
+			if len(words) == 0 {
+				if leading {
+					w.space = true
+				}
+				continue
+			}
+			if leading {
+				w.space = true
+			}
+			linePrefix()
+			w.write(strings.Join(words, " "))
+			w.space = trailing
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			attrs := map[string]string{}
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				attrs[string(key)] = string(val)
+			}
+			tag := string(name)
+			if skip > 0 {
+				if tt == html.StartTagToken && !isVoid(tag) {
+					skip++
+				}
+				continue
+			}
+
+			switch tag {
+			case "head", "style", "script", "title":
+				if tt == html.StartTagToken {
+					skip++
+				}
+			case "en-crypt":
+				linePrefix()
+				w.write("[encrypted content]")
+				if tt == html.StartTagToken {
+					skip++
+				}
+			case "br":
+				w.b.WriteByte('\n')
+				w.lineStart = true
+				w.space = false
+			case "hr":
+				w.blank()
+				w.write("---")
+				w.blank()
+			case "p", "div", "table", "en-note":
+				w.newline()
+			case "tr":
+				w.newline()
+				rowCell = 0
+			case "td", "th":
+				if rowCell > 0 {
+					w.write(" |")
+					w.space = true
+				}
+				rowCell++
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				w.blank()
+				w.write(strings.Repeat("#", int(tag[1]-'0')) + " ")
+			case "blockquote":
+				w.newline()
+				quote++
+			case "pre":
+				w.newline()
+				linePrefix()
+				w.write("```\n")
+				pre++
+			case "ul", "ol":
+				w.newline()
+				lists = append(lists, listLevel{ordered: tag == "ol"})
+			case "li":
+				w.newline()
+				linePrefix()
+				indent := ""
+				if len(lists) > 1 {
+					indent = strings.Repeat("  ", len(lists)-1)
+				}
+				if len(lists) > 0 && lists[len(lists)-1].ordered {
+					lists[len(lists)-1].count++
+					w.write(fmt.Sprintf("%s%d. ", indent, lists[len(lists)-1].count))
+				} else {
+					w.write(indent + "- ")
+				}
+			case "en-todo":
+				box := "[ ] "
+				if attrs["checked"] == "true" {
+					box = "[x] "
+				}
+				if w.lineStart {
+					linePrefix()
+					box = "- " + box
+				}
+				w.write(box)
+			case "a":
+				hrefs = append(hrefs, strings.TrimSpace(attrs["href"]))
+				if w.space && !w.lineStart {
+					w.b.WriteByte(' ')
+					w.space = false
+				}
+				linkAt = append(linkAt, w.b.Len())
+			case "en-media":
+				if media == nil {
+					continue
+				}
+				if text := media(strings.ToLower(attrs["hash"]), attrs["type"]); text != "" {
+					linePrefix()
+					w.write(text)
+				}
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			if skip > 0 {
+				skip--
+				continue
+			}
+			switch tag {
+			case "p", "div", "tr", "li", "table":
+				w.newline()
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				w.blank()
+			case "blockquote":
+				if quote > 0 {
+					quote--
+				}
+				w.newline()
+			case "pre":
+				if pre > 0 {
+					pre--
+				}
+				w.newline()
+				w.write("```")
+				w.newline()
+			case "ul", "ol":
+				if len(lists) > 0 {
+					lists = lists[:len(lists)-1]
+				}
+				w.newline()
+			case "a":
+				if len(hrefs) == 0 {
+					continue
+				}
+				href, start := hrefs[len(hrefs)-1], linkAt[len(linkAt)-1]
+				hrefs, linkAt = hrefs[:len(hrefs)-1], linkAt[:len(linkAt)-1]
+				text := w.b.String()[start:]
+				if href == "" || strings.HasPrefix(href, "evernote:") || strings.TrimSpace(text) == href {
+					continue
+				}
+				if strings.TrimSpace(text) == "" {
+					text = href
+				}
+				rest := w.b.String()[:start]
+				w.b.Reset()
+				w.b.WriteString(rest)
+				w.b.WriteString("[" + strings.TrimSpace(text) + "](" + href + ")")
+				w.lineStart = false
+			}
+		}
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t'
+}
+
+// isVoid reports whether an element never has an end tag.
+func isVoid(tag string) bool {
+	switch tag {
+	case "br", "hr", "img", "en-media", "en-todo", "input", "meta", "link":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+// Package filetext extracts plain text from uploaded files so that search and
+// question answering can match their contents. Plain text and Markdown files
+// are read as they are; PDFs go through a small reader that follows the page
+// tree and decodes the text drawn on each page.
+package filetext
+
+import (
+	"bytes"
+	"errors"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var (
+	// ErrUnsupported is returned for file types whose text is not extracted.
+	ErrUnsupported = errors.New("unsupported file type")
+	// ErrInvalidPDF is returned for data that is not a readable PDF.
+	ErrInvalidPDF = errors.New("not a readable PDF")
+	// ErrEncrypted is returned for encrypted PDFs, whose text cannot be read without the key.
+	ErrEncrypted = errors.New("PDF is encrypted")
+)
+
+// MaxChars caps the extracted text of a single file.
+const MaxChars = 200000
+
+const (
+	kindText = "text"
+	kindPDF  = "pdf"
+)
+
+// textExtensions are the file name extensions read as plain text.
+var textExtensions = map[string]bool{
+	".txt":      true,
+	".text":     true,
+	".md":       true,
+	".markdown": true,
+}
+
+// Supported reports whether text can be extracted from a file with the given
+// MIME type or name.
+func Supported(mimeType, name string) bool {
+	return kind(mimeType, name) != ""
+}
+
+// Extract returns the text of a file, capped at MaxChars characters.
+func Extract(data []byte, mimeType, name string) (string, error) {
+	switch kind(mimeType, name) {
+	case kindText:
+		return limit(decodeText(data)), nil
+	case kindPDF:
+		text, err := extractPDF(data)
+		if err != nil {
+			return "", err
+		}
+		return limit(clean(text)), nil
+	}
+	return "", ErrUnsupported
+}
+
+// kind classifies a file by its MIME type, then by its extension.
+func kind(mimeType, name string) string {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	switch strings.ToLower(strings.TrimSpace(mimeType)) {
+	case "application/pdf":
+		return kindPDF
+	case "text/plain", "text/markdown", "text/x-markdown":
+		return kindText
+	}
+	ext := strings.ToLower(path.Ext(name))
+	if ext == ".pdf" {
+		return kindPDF
+	}
+	if textExtensions[ext] {
+		return kindText
+	}
+	return ""
+}
+
+// decodeText reads a text file as UTF-8, or as UTF-16 when it starts with a
+// byte order mark, dropping invalid sequences and NUL bytes.
+func decodeText(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		data = data[3:]
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return strings.ReplaceAll(decodeUTF16(data[2:], true), "\x00", "")
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return strings.ReplaceAll(decodeUTF16(data[2:], false), "\x00", "")
+	}
+	return strings.ReplaceAll(strings.ToValidUTF8(string(data), ""), "\x00", "")
+}
+
+// decodeUTF16 decodes UTF-16 text, ignoring a trailing odd byte.
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// clean drops control characters, collapses runs of spaces, trims every line
+// and keeps at most one blank line between paragraphs.
+func clean(text string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.ToValidUTF8(text, ""), "\n") {
+		line = strings.Join(strings.FieldsFunc(line, func(r rune) bool {
+			return unicode.IsSpace(r) || unicode.IsControl(r)
+		}), " ")
+		if line == "" {
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n\n") {
+				b.WriteByte('\n')
+			}
+			continue
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// limit cuts text to MaxChars characters.
+func limit(text string) string {
+	if utf8.RuneCountInString(text) <= MaxChars {
+		return text
+	}
+	return string([]rune(text)[:MaxChars])
+}
@@ -0,0 +1,178 @@
+package filetext
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSupported(t *testing.T) {
+	tests := []struct {
+		mimeType, name string
+		want           bool
+	}{
+		{"application/pdf", "scan", true},
+		{"", "Report.PDF", true},
+		{"text/plain; charset=utf-8", "notes", true},
+		{"text/markdown", "", true},
+		{"application/octet-stream", "README.md", true},
+		{"", "todo.txt", true},
+		{"text/html", "page.html", false},
+		{"image/png", "photo.png", false},
+		{"application/zip", "archive.zip", false},
+	}
+	for _, tt := range tests {
+		if got := Supported(tt.mimeType, tt.name); got != tt.want {
+			t.Errorf("Supported(%q, %q) = %v, want %v", tt.mimeType, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExtractText(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"plain.txt", []byte("Meeting notes\n\n- budget\n"), "Meeting notes\n\n- budget\n"},
+		{"bom.md", []byte("\xEF\xBB\xBF# Title"), "# Title"},
+		{"invalid.txt", []byte("caf\xE9 ok\x00"), "caf ok"},
+		{"utf16.txt", []byte{0xFF, 0xFE, 'h', 0, 'i', 0, 0xE9, 0}, "hié"},
+	}
+	for _, tt := range tests {
+		got, err := Extract(tt.data, "", tt.name)
+		if err != nil || got != tt.want {
+			t.Errorf("Extract(%s) = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	long := strings.Repeat("é", MaxChars+10)
+	if got, _ := Extract([]byte(long), "text/plain", ""); len([]rune(got)) != MaxChars {
+		t.Errorf("long text cut to %d characters, want %d", len([]rune(got)), MaxChars)
+	}
+	if _, err := Extract([]byte("PK"), "application/zip", "a.zip"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("zip error = %v, want ErrUnsupported", err)
+	}
+}
+
+// buildPDF lays out numbered objects as a PDF file. The reader does not need
+// the cross-reference table, so none is written.
+func buildPDF(objects map[int]string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.7\n%\xE2\xE3\xCF\xD3\n")
+	nums := make([]int, 0, len(objects))
+	for num := range objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", num, objects[num])
+	}
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+// stream writes a stream object, compressed when flate is set.
+func stream(dict, data string, flate bool) string {
+	if flate {
+		var z bytes.Buffer
+		w := zlib.NewWriter(&z)
+		w.Write([]byte(data))
+		w.Close()
+		data = z.String()
+		dict += " /Filter /FlateDecode"
+	}
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func TestExtractPDF(t *testing.T) {
+	toUnicode := `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+1 begincodespacerange
+<0000> <FFFF>
+endcodespacerange
+2 beginbfchar
+<0001> <0048>
+<0002> <00E9>
+endbfchar
+1 beginbfrange
+<0010> <0012> <0061>
+endbfrange
+endcmap
+end end`
+
+	data := buildPDF(map[int]string{
+		1: "<< /Type /Catalog /Pages 2 0 R >>",
+		// Page order follows Kids, not object numbers
+		2: "<< /Type /Pages /Kids [6 0 R 3 0 R] /Count 2 /Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> >>",
+		3: "<< /Type /Page /Parent 2 0 R /Contents 7 0 R >>",
+		4: "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		5: "<< /Type /Font /Subtype /Type0 /BaseFont /Custom /ToUnicode 8 0 R >>",
+		6: "<< /Type /Page /Parent 2 0 R /Contents [9 0 R 10 0 R] >>",
+		7: stream("", `BT /F2 12 Tf 72 700 Td <000100020010 0011 0012> Tj ET
+BT /F1 12 Tf 72 680 Td (caf\351 \(d\351j\340 vu\)) Tj ET`, true),
+		8: stream("", toUnicode, true),
+		9: stream("", `BT /F1 12 Tf 72 700 Td (Quarterly budget) Tj 0 -14 Td [(re) 20 (view) -400 (notes)] TJ ET`, false),
+		10: stream("", `BT /F1 12 Tf 1 0 0 1 72 600 Tm (Action items:) Tj T* (ship it) Tj ET
+BI /W 2 /H 2 /BPC 8 /CS /G ID `+"\x00(\xFF)"+` EI`, true),
+	})
+
+	got, err := Extract(data, "application/pdf", "report.pdf")
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	want := "Quarterly budget\nreview notes\nAction items:\nship it\nHéabc\ncafé (déjà vu)"
+	if got != want {
+		t.Errorf("Extract = %q, want %q", got, want)
+	}
+}
+
+func TestExtractPDFObjectStream(t *testing.T) {
+	// The page and font live in a compressed object stream
+	packed := "<< /Type /Page /Parent 2 0 R /Contents 5 0 R /Resources << /Font << /F1 4 0 R >> >> >> " +
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>"
+	second := strings.Index(packed, ">> >> >> ") + len(">> >> >> ")
+	header := fmt.Sprintf("3 0 4 %d ", second)
+	data := buildPDF(map[int]string{
+		1: "<< /Type /Catalog /Pages 2 0 R >>",
+		2: "<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		5: stream("", "BT /F1 10 Tf 10 10 Td (packed page) Tj ET", true),
+		6: stream(fmt.Sprintf("/Type /ObjStm /N 2 /First %d", len(header)), header+packed, true),
+	})
+
+	got, err := Extract(data, "application/pdf", "")
+	if err != nil || got != "packed page" {
+		t.Errorf("Extract = %q, %v; want the text of the packed page", got, err)
+	}
+}
+
+func TestExtractPDFErrors(t *testing.T) {
+	if _, err := Extract([]byte("<html>not a pdf</html>"), "application/pdf", "fake.pdf"); !errors.Is(err, ErrInvalidPDF) {
+		t.Errorf("non-PDF error = %v, want ErrInvalidPDF", err)
+	}
+	encrypted := buildPDF(map[int]string{
+		1: "<< /Type /Catalog /Pages 2 0 R >>",
+		2: "<< /Type /Pages /Kids [] /Count 0 >>",
+		3: "<< /Filter /Standard /V 2 /R 3 >>",
+	})
+	encrypted = append(encrypted, "trailer << /Root 1 0 R /Encrypt 3 0 R >>"...)
+	if _, err := Extract(encrypted, "application/pdf", ""); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("encrypted PDF error = %v, want ErrEncrypted", err)
+	}
+	// A truncated file still yields the text of what arrived
+	full := buildPDF(map[int]string{
+		1: "<< /Type /Catalog /Pages 2 0 R >>",
+		2: "<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		3: "<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+		4: stream("", "BT 10 10 Td (kept) Tj ET", false),
+	})
+	cut := full[:bytes.Index(full, []byte("endstream"))]
+	if got, err := Extract(cut, "application/pdf", ""); err != nil || got != "kept" {
+		t.Errorf("truncated PDF: %q, %v", got, err)
+	}
+}
@@ -0,0 +1,876 @@
+package filetext
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// pdfMaxStream caps the decompressed size of a single PDF stream.
+	pdfMaxStream = 32 << 20
+	// pdfMaxDepth caps reference chains, page tree depth and nested forms.
+	pdfMaxDepth = 16
+	// pdfMaxRange caps the codes a single CMap range maps.
+	pdfMaxRange = 1 << 16
+	// pdfWordGap is the TJ adjustment, in thousandths of a text unit, read as a space.
+	pdfWordGap = 200
+)
+
+// pdfObjPattern matches the header of an indirect object ("12 0 obj").
+var pdfObjPattern = regexp.MustCompile(`(\d+)[\x00\t\n\f\r ]+\d+[\x00\t\n\f\r ]+obj\b`)
+
+// PDF values as read by pdfLexer: numbers are float64, strings []byte.
+type (
+	pdfName    string
+	pdfKeyword string
+	pdfRef     int
+	pdfDict    map[pdfName]any
+	pdfStream  struct {
+		Dict pdfDict
+		Raw  []byte
+	}
+)
+
+// pdfDoc holds the objects of a PDF by object number.
+type pdfDoc struct {
+	objects map[int]any
+	cmaps   map[*pdfStream]*pdfCMap
+}
+
+// extractPDF returns the text of every page of a PDF, in page order.
+func extractPDF(data []byte) (string, error) {
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	if !bytes.Contains(head, []byte("%PDF-")) {
+		return "", ErrInvalidPDF
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return "", ErrEncrypted
+	}
+
+	doc := parsePDF(data)
+	pages := doc.pages()
+	if len(pages) == 0 {
+		return "", ErrInvalidPDF
+	}
+
+	t := &pdfText{doc: doc, forms: map[*pdfStream]bool{}}
+	for _, page := range pages {
+		resources := doc.resources(page)
+		var content []byte
+		switch contents := doc.resolve(page["Contents"]).(type) {
+		case *pdfStream:
+			content = doc.decode(contents)
+		case []any:
+			for _, part := range contents {
+				if s, ok := doc.resolve(part).(*pdfStream); ok {
+					content = append(content, doc.decode(s)...)
+					content = append(content, '\n')
+				}
+			}
+		}
+		t.run(content, resources, 0)
+		t.newline()
+		if t.full() {
+			break
+		}
+	}
+	return t.out.String(), nil
+}
+
+// parsePDF reads every indirect object, including those packed in object
+// streams. When an object is defined more than once, as after incremental
+// updates, the last definition wins.
+func parsePDF(data []byte) *pdfDoc {
+	doc := &pdfDoc{objects: map[int]any{}, cmaps: map[*pdfStream]*pdfCMap{}}
+	for _, m := range pdfObjPattern.FindAllSubmatchIndex(data, -1) {
+		num, err := strconv.Atoi(string(data[m[2]:m[3]]))
+		if err != nil {
+			continue
+		}
+		l := &pdfLexer{data: data, pos: m[1]}
+		v, ok := l.value()
+		if !ok {
+			continue
+		}
+		if dict, isDict := v.(pdfDict); isDict {
+			if raw, ok := l.streamData(dict); ok {
+				v = &pdfStream{Dict: dict, Raw: raw}
+			}
+		}
+		doc.objects[num] = v
+	}
+
+	var objectStreams []*pdfStream
+	for _, num := range doc.numbers() {
+		if s, ok := doc.objects[num].(*pdfStream); ok && s.Dict["Type"] == pdfName("ObjStm") {
+			objectStreams = append(objectStreams, s)
+		}
+	}
+	for _, s := range objectStreams {
+		doc.loadObjectStream(s)
+	}
+	return doc
+}
+
+// numbers returns the object numbers in ascending order.
+func (d *pdfDoc) numbers() []int {
+	nums := make([]int, 0, len(d.objects))
+	for num := range d.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+// loadObjectStream adds the objects packed in an object stream that are not
+// defined directly.
+func (d *pdfDoc) loadObjectStream(s *pdfStream) {
+	data := d.decode(s)
+	n, _ := s.Dict["N"].(float64)
+	first, _ := s.Dict["First"].(float64)
+	if data == nil || first < 0 || int(first) > len(data) {
+		return
+	}
+
+	header := &pdfLexer{data: data[:int(first)]}
+	for i := 0; i < int(n); i++ {
+		num, ok := header.next()
+		if !ok {
+			return
+		}
+		offset, ok := header.next()
+		if !ok {
+			return
+		}
+		objNum, isNum := num.(float64)
+		objOffset, isOffset := offset.(float64)
+		if !isNum || !isOffset || objOffset < 0 || int(first+objOffset) > len(data) {
+			continue
+		}
+		if _, defined := d.objects[int(objNum)]; defined {
+			continue
+		}
+		l := &pdfLexer{data: data, pos: int(first + objOffset)}
+		if v, ok := l.value(); ok {
+			d.objects[int(objNum)] = v
+		}
+	}
+}
+
+// resolve follows references to the object they point to.
+func (d *pdfDoc) resolve(v any) any {
+	for i := 0; i < pdfMaxDepth; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = d.objects[int(ref)]
+	}
+	return nil
+}
+
+// dict resolves v to a dictionary, or a stream's dictionary.
+func (d *pdfDoc) dict(v any) pdfDict {
+	switch t := d.resolve(v).(type) {
+	case pdfDict:
+		return t
+	case *pdfStream:
+		return t.Dict
+	}
+	return nil
+}
+
+// decode returns the decompressed data of a stream, or nil when it uses a
+// filter other than FlateDecode. Data cut short is returned as far as it goes.
+func (d *pdfDoc) decode(s *pdfStream) []byte {
+	var filters []any
+	switch f := d.resolve(s.Dict["Filter"]).(type) {
+	case pdfName:
+		filters = []any{f}
+	case []any:
+		filters = f
+	}
+
+	data := s.Raw
+	for _, f := range filters {
+		if d.resolve(f) != pdfName("FlateDecode") {
+			return nil
+		}
+		r, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil
+		}
+		data, _ = io.ReadAll(io.LimitReader(r, pdfMaxStream))
+	}
+	return data
+}
+
+// pages returns the pages of the document in order.
+func (d *pdfDoc) pages() []pdfDict {
+	var pages []pdfDict
+	visited := map[pdfRef]bool{}
+	for _, num := range d.numbers() {
+		root := d.dict(pdfRef(num))
+		if root["Type"] == pdfName("Pages") && root["Parent"] == nil {
+			d.walk(pdfRef(num), 0, visited, &pages)
+		}
+	}
+	return pages
+}
+
+// walk appends the pages under a page tree node.
+func (d *pdfDoc) walk(node any, depth int, visited map[pdfRef]bool, pages *[]pdfDict) {
+	if ref, ok := node.(pdfRef); ok {
+		if visited[ref] {
+			return
+		}
+		visited[ref] = true
+	}
+	dict := d.dict(node)
+	if dict == nil || depth > pdfMaxDepth {
+		return
+	}
+	switch dict["Type"] {
+	case pdfName("Page"):
+		*pages = append(*pages, dict)
+	case pdfName("Pages"):
+		kids, _ := d.resolve(dict["Kids"]).([]any)
+		for _, kid := range kids {
+			d.walk(kid, depth+1, visited, pages)
+		}
+	}
+}
+
+// resources returns a page's resources, which it may inherit from the page tree.
+func (d *pdfDoc) resources(page pdfDict) pdfDict {
+	for i := 0; page != nil && i < pdfMaxDepth; i++ {
+		if res := d.dict(page["Resources"]); res != nil {
+			return res
+		}
+		page = d.dict(page["Parent"])
+	}
+	return nil
+}
+
+// font loads the font a resource dictionary names.
+func (d *pdfDoc) font(resources pdfDict, name pdfName) *pdfFont {
+	dict := d.dict(d.dict(resources["Font"])[name])
+	if dict == nil {
+		return nil
+	}
+	f := &pdfFont{composite: dict["Subtype"] == pdfName("Type0")}
+	if s, ok := d.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+		if _, loaded := d.cmaps[s]; !loaded {
+			d.cmaps[s] = parseCMap(d.decode(s))
+		}
+		f.cmap = d.cmaps[s]
+	}
+	return f
+}
+
+// pdfFont decodes the strings drawn with a font.
+type pdfFont struct {
+	cmap      *pdfCMap
+	composite bool // multi-byte codes, unreadable without a ToUnicode map
+}
+
+// decode maps the codes in a shown string to text. Simple fonts without a
+// ToUnicode map are read as Windows-1252, which covers the standard encodings
+// for ASCII and most Latin text.
+func (f *pdfFont) decode(s []byte) string {
+	if f != nil && f.cmap != nil {
+		return f.cmap.decode(s)
+	}
+	if f != nil && f.composite {
+		return ""
+	}
+	var b strings.Builder
+	for _, c := range s {
+		if r, ok := cp1252[c]; ok {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
+
+// cp1252 maps the Windows-1252 bytes that differ from Latin-1.
+var cp1252 = map[byte]rune{
+	0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡',
+	0x88: 'ˆ', 0x89: '‰', 0x8A: 'Š', 0x8B: '‹', 0x8C: 'Œ', 0x8E: 'Ž',
+	0x91: '‘', 0x92: '’', 0x93: '“', 0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—',
+	0x98: '˜', 0x99: '™', 0x9A: 'š', 0x9B: '›', 0x9C: 'œ', 0x9E: 'ž', 0x9F: 'Ÿ',
+}
+
+// pdfText runs content streams and collects the text they draw.
+type pdfText struct {
+	doc   *pdfDoc
+	out   strings.Builder
+	forms map[*pdfStream]bool // forms being run, which may not draw themselves
+}
+
+// full reports whether enough text was collected.
+func (t *pdfText) full() bool {
+	return t.out.Len() > 4*MaxChars
+}
+
+func (t *pdfText) write(s string) {
+	t.out.WriteString(s)
+}
+
+// space separates words unless the text already ends in whitespace.
+func (t *pdfText) space() {
+	if s := t.out.String(); s != "" && !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+		t.out.WriteByte(' ')
+	}
+}
+
+// newline ends a line unless the text already ends one.
+func (t *pdfText) newline() {
+	if s := t.out.String(); s != "" && !strings.HasSuffix(s, "\n") {
+		t.out.WriteByte('\n')
+	}
+}
+
+// run interprets a content stream, following the text operators and the form
+// XObjects it draws.
+func (t *pdfText) run(content []byte, resources pdfDict, depth int) {
+	l := &pdfLexer{data: content}
+	var font *pdfFont
+	var operands []any
+	lastY := 0.0
+	for !t.full() {
+		tok, ok := l.next()
+		if !ok {
+			return
+		}
+		op, isOp := tok.(pdfKeyword)
+		if !isOp || op == "[" || op == "<<" {
+			operands = append(operands, l.complete(tok))
+			continue
+		}
+
+		switch op {
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[len(operands)-2].(pdfName); ok {
+					font = t.doc.font(resources, name)
+				}
+			}
+		case "Tj", "'", `"`:
+			if op != "Tj" {
+				t.newline()
+			}
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].([]byte); ok {
+					t.write(font.decode(s))
+				}
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				parts, _ := operands[len(operands)-1].([]any)
+				for _, part := range parts {
+					switch p := part.(type) {
+					case []byte:
+						t.write(font.decode(p))
+					case float64:
+						if p < -pdfWordGap {
+							t.space()
+						}
+					}
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, _ := operands[len(operands)-1].(float64); ty != 0 {
+					t.newline()
+				} else {
+					t.space()
+				}
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				y, _ := operands[len(operands)-1].(float64)
+				if y != lastY {
+					t.newline()
+				} else {
+					t.space()
+				}
+				lastY = y
+			}
+		case "T*":
+			t.newline()
+		case "ET":
+			t.space()
+		case "ID":
+			l.skipInlineImage()
+		case "Do":
+			if len(operands) > 0 && depth < pdfMaxDepth {
+				name, _ := operands[len(operands)-1].(pdfName)
+				form, ok := t.doc.resolve(t.doc.dict(resources["XObject"])[name]).(*pdfStream)
+				if ok && form.Dict["Subtype"] == pdfName("Form") && !t.forms[form] {
+					formResources := t.doc.dict(form.Dict["Resources"])
+					if formResources == nil {
+						formResources = resources
+					}
+					t.forms[form] = true
+					t.run(t.doc.decode(form), formResources, depth+1)
+					delete(t.forms, form)
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+// pdfCMap is a ToUnicode map from character codes to text.
+type pdfCMap struct {
+	widths []int // code lengths in bytes, longest first
+	chars  map[string]string
+}
+
+// parseCMap reads the codespace ranges and the bfchar and bfrange mappings of
+// a ToUnicode CMap.
+func parseCMap(data []byte) *pdfCMap {
+	m := &pdfCMap{chars: map[string]string{}}
+	widths := map[int]bool{}
+	l := &pdfLexer{data: data}
+	var operands []any
+	for {
+		tok, ok := l.next()
+		if !ok {
+			break
+		}
+		op, isOp := tok.(pdfKeyword)
+		if !isOp || op == "[" || op == "<<" {
+			operands = append(operands, l.complete(tok))
+			continue
+		}
+
+		switch op {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				if lo, ok := operands[i].([]byte); ok && len(lo) > 0 {
+					widths[len(lo)] = true
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, okSrc := operands[i].([]byte)
+				dst, okDst := operands[i+1].([]byte)
+				if okSrc && okDst && len(src) > 0 {
+					m.chars[string(src)] = decodeUTF16(dst, true)
+					widths[len(src)] = true
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				m.addRange(operands[i], operands[i+1], operands[i+2])
+			}
+			for i := 0; i+2 < len(operands); i += 3 {
+				if lo, ok := operands[i].([]byte); ok && len(lo) > 0 {
+					widths[len(lo)] = true
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+
+	for w := range widths {
+		m.widths = append(m.widths, w)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(m.widths)))
+	return m
+}
+
+// addRange maps the codes lo through hi, either to consecutive characters
+// starting at dst or to the strings of a dst array.
+func (m *pdfCMap) addRange(loValue, hiValue, dst any) {
+	lo, okLo := loValue.([]byte)
+	hi, okHi := hiValue.([]byte)
+	if !okLo || !okHi || len(lo) == 0 || len(lo) != len(hi) || len(lo) > 4 {
+		return
+	}
+	first, last := codeValue(lo), codeValue(hi)
+	for code := first; code <= last && code-first < pdfMaxRange; code++ {
+		key := string(codeBytes(code, len(lo)))
+		offset := int(code - first)
+		switch d := dst.(type) {
+		case []byte:
+			runes := []rune(decodeUTF16(d, true))
+			if len(runes) == 0 {
+				return
+			}
+			runes[len(runes)-1] += rune(offset)
+			m.chars[key] = string(runes)
+		case []any:
+			if offset >= len(d) {
+				return
+			}
+			if s, ok := d[offset].([]byte); ok {
+				m.chars[key] = decodeUTF16(s, true)
+			}
+		}
+	}
+}
+
+// decode maps a shown string through the CMap, trying the longest code
+// length first. Codes it does not map are dropped.
+func (m *pdfCMap) decode(s []byte) string {
+	widths := m.widths
+	if len(widths) == 0 {
+		widths = []int{1}
+	}
+	var b strings.Builder
+	for len(s) > 0 {
+		step := widths[len(widths)-1]
+		for _, w := range widths {
+			if w > len(s) {
+				continue
+			}
+			if text, ok := m.chars[string(s[:w])]; ok {
+				b.WriteString(text)
+				step = w
+				break
+			}
+		}
+		if step > len(s) {
+			break
+		}
+		s = s[step:]
+	}
+	return b.String()
+}
+
+// codeValue reads a big-endian character code.
+func codeValue(b []byte) uint32 {
+	var v uint32
+	for _, c := range b {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
+
+// codeBytes writes a character code in n big-endian bytes.
+func codeBytes(v uint32, n int) []byte {
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return b
+}
+
+// pdfLexer reads the tokens and objects of PDF syntax.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(b byte) bool {
+	return b == 0 || b == '\t' || b == '\n' || b == '\f' || b == '\r' || b == ' '
+}
+
+func isPDFDelim(b byte) bool {
+	return strings.IndexByte("()<>[]{}/%", b) >= 0
+}
+
+// skipSpace skips whitespace and comments.
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		switch b := l.data[l.pos]; {
+		case isPDFSpace(b):
+			l.pos++
+		case b == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// next reads one token: a number, name, string, keyword or delimiter.
+func (l *pdfLexer) next() (any, bool) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, false
+	}
+	switch b := l.data[l.pos]; b {
+	case '/':
+		l.pos++
+		return pdfName(unescapeName(l.word())), true
+	case '(':
+		return l.literalString(), true
+	case '<':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+			l.pos += 2
+			return pdfKeyword("<<"), true
+		}
+		return l.hexString(), true
+	case '>':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '>' {
+			l.pos += 2
+			return pdfKeyword(">>"), true
+		}
+		l.pos++
+		return pdfKeyword(">"), true
+	case '[', ']', '{', '}', ')':
+		l.pos++
+		return pdfKeyword(string(b)), true
+	}
+
+	word := l.word()
+	if c := word[0]; (c >= '0' && c <= '9') || c == '-' || c == '+' || c == '.' {
+		if n, err := strconv.ParseFloat(string(word), 64); err == nil {
+			return n, true
+		}
+	}
+	return pdfKeyword(word), true
+}
+
+// word reads bytes up to the next whitespace or delimiter.
+func (l *pdfLexer) word() []byte {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelim(l.data[l.pos]) {
+		l.pos++
+	}
+	return l.data[start:l.pos]
+}
+
+// value reads the next complete object.
+func (l *pdfLexer) value() (any, bool) {
+	tok, ok := l.next()
+	if !ok {
+		return nil, false
+	}
+	return l.complete(tok), true
+}
+
+// complete finishes the object a token starts: arrays and dictionaries are
+// read whole, and "12 0 R" becomes a reference.
+func (l *pdfLexer) complete(tok any) any {
+	switch t := tok.(type) {
+	case pdfKeyword:
+		switch t {
+		case "[":
+			arr := []any{}
+			for {
+				v, ok := l.next()
+				if !ok || v == pdfKeyword("]") {
+					return arr
+				}
+				arr = append(arr, l.complete(v))
+			}
+		case "<<":
+			dict := pdfDict{}
+			for {
+				k, ok := l.next()
+				if !ok || k == pdfKeyword(">>") {
+					return dict
+				}
+				name, isName := k.(pdfName)
+				if !isName {
+					continue
+				}
+				v, ok := l.next()
+				if !ok || v == pdfKeyword(">>") {
+					return dict
+				}
+				dict[name] = l.complete(v)
+			}
+		}
+	case float64:
+		save := l.pos
+		if gen, ok := l.next(); ok {
+			if _, isNum := gen.(float64); isNum {
+				if r, ok := l.next(); ok && r == pdfKeyword("R") {
+					return pdfRef(t)
+				}
+			}
+		}
+		l.pos = save
+	}
+	return tok
+}
+
+// literalString reads a (string) with its escapes and balanced parentheses.
+func (l *pdfLexer) literalString() []byte {
+	l.pos++
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		b := l.data[l.pos]
+		l.pos++
+		switch b {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				b = '\n'
+			case 'r':
+				b = '\r'
+			case 't':
+				b = '\t'
+			case 'b':
+				b = '\b'
+			case 'f':
+				b = '\f'
+			case '\r':
+				// A line continuation
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				b = e
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					b = byte(n)
+				}
+			}
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// hexString reads a <hex string>; an odd final digit is padded with 0.
+func (l *pdfLexer) hexString() []byte {
+	l.pos++
+	var out []byte
+	var high byte
+	half := false
+	for l.pos < len(l.data) {
+		b := l.data[l.pos]
+		l.pos++
+		if b == '>' {
+			break
+		}
+		v, ok := hexDigit(b)
+		if !ok {
+			continue
+		}
+		if half {
+			out = append(out, high<<4|v)
+		} else {
+			high = v
+		}
+		half = !half
+	}
+	if half {
+		out = append(out, high<<4)
+	}
+	return out
+}
+
+// streamData returns the raw data of the stream that follows a dictionary,
+// if one does.
+func (l *pdfLexer) streamData(dict pdfDict) ([]byte, bool) {
+	save := l.pos
+	if tok, ok := l.next(); !ok || tok != pdfKeyword("stream") {
+		l.pos = save
+		return nil, false
+	}
+	// The keyword ends with CRLF or LF
+	if l.pos < len(l.data) && l.data[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+		l.pos++
+	}
+	start := l.pos
+
+	if n, ok := dict["Length"].(float64); ok && n >= 0 && start+int(n) <= len(l.data) {
+		end := start + int(n)
+		after := bytes.TrimLeft(l.data[end:min(end+32, len(l.data))], "\x00\t\n\f\r ")
+		if bytes.HasPrefix(after, []byte("endstream")) {
+			return l.data[start:end], true
+		}
+	}
+	// The length is indirect or wrong: the data ends at the endstream keyword
+	end := bytes.Index(l.data[start:], []byte("endstream"))
+	if end < 0 {
+		return l.data[start:], true
+	}
+	raw := l.data[start : start+end]
+	switch {
+	case bytes.HasSuffix(raw, []byte("\r\n")):
+		raw = raw[:len(raw)-2]
+	case bytes.HasSuffix(raw, []byte("\n")), bytes.HasSuffix(raw, []byte("\r")):
+		raw = raw[:len(raw)-1]
+	}
+	return raw, true
+}
+
+// skipInlineImage skips the data of an inline image, up to its EI operator.
+func (l *pdfLexer) skipInlineImage() {
+	for i := l.pos + 1; i+1 < len(l.data); i++ {
+		if l.data[i] == 'E' && l.data[i+1] == 'I' && isPDFSpace(l.data[i-1]) &&
+			(i+2 == len(l.data) || isPDFSpace(l.data[i+2])) {
+			l.pos = i + 2
+			return
+		}
+	}
+	l.pos = len(l.data)
+}
+
+// unescapeName decodes the #xx escapes in a name.
+func unescapeName(b []byte) string {
+	if bytes.IndexByte(b, '#') < 0 {
+		return string(b)
+	}
+	var out []byte
+	for i := 0; i < len(b); i++ {
+		if b[i] == '#' && i+2 < len(b) {
+			hi, okHi := hexDigit(b[i+1])
+			lo, okLo := hexDigit(b[i+2])
+			if okHi && okLo {
+				out = append(out, hi<<4|lo)
+				i += 2
+				continue
+			}
+		}
+		out = append(out, b[i])
+	}
+	return string(out)
+}
+
+func hexDigit(b byte) (byte, bool) {
+	switch {
+	case b >= '0' && b <= '9':
+		return b - '0', true
+	case b >= 'a' && b <= 'f':
+		return b - 'a' + 10, true
+	case b >= 'A' && b <= 'F':
+		return b - 'A' + 10, true
+	}
+	return 0, false
+}
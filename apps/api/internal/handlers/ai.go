@@ -1,17 +1,32 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 
 	"desis-keep/apps/api/internal/ai"
+	"desis-keep/apps/api/internal/services"
 )
 
 // AIHandler handles AI completion endpoints.
+// When Usage is set, every call is metered and checked against the user's monthly quota.
 type AIHandler struct {
-	AI *ai.AI
+	AI         *ai.AI
+	AskService Asker
+	Usage      *services.UsageService
+}
+
+// Asker finds the context for answering a question from a user's resources
+// and builds the prompt. *services.AskService is the implementation.
+type Asker interface {
+	Retrieve(ctx context.Context, userID uint, question string, limit int) ([]services.AskSource, error)
+	BuildMessages(question string, history []ai.Message, sources []services.AskSource) []ai.Message
+	Cited(answer string, sources []services.AskSource) []services.AskSource
 }
 
 type completionRequest struct {
@@ -26,9 +41,17 @@ type chatRequest struct {
 	Temperature float64      `json:"temperature"`
 }
 
+type askRequest struct {
+	Question    string       `json:"question" binding:"required"`
+	History     []ai.Message `json:"history"`
+	MaxSources  int          `json:"max_sources"`
+	MaxTokens   int          `json:"max_tokens"`
+	Temperature float64      `json:"temperature"`
+}
+
 // Complete handles a single prompt completion.
 func (h *AIHandler) Complete(c *gin.Context) {
-	if h.AI == nil {
+	if !h.AI.CanComplete() {
 		c.JSON(http.StatusServiceUnavailable, gin.H{
 			"error": gin.H{
 				"code":    "AI_UNAVAILABLE",
@@ -48,6 +71,9 @@ func (h *AIHandler) Complete(c *gin.Context) {
 		})
 		return
 	}
+	if !h.checkQuota(c) {
+		return
+	}
 
 	resp, err := h.AI.Complete(c.Request.Context(), ai.CompletionRequest{
 		Prompt:      req.Prompt,
@@ -63,6 +89,7 @@ func (h *AIHandler) Complete(c *gin.Context) {
 		})
 		return
 	}
+	h.recordUsage(c, resp, nil)
 
 	c.JSON(http.StatusOK, gin.H{
 		"data": resp,
@@ -71,7 +98,7 @@ func (h *AIHandler) Complete(c *gin.Context) {
 
 // Chat handles a multi-turn conversation.
 func (h *AIHandler) Chat(c *gin.Context) {
-	if h.AI == nil {
+	if !h.AI.CanComplete() {
 		c.JSON(http.StatusServiceUnavailable, gin.H{
 			"error": gin.H{
 				"code":    "AI_UNAVAILABLE",
@@ -91,6 +118,9 @@ func (h *AIHandler) Chat(c *gin.Context) {
 		})
 		return
 	}
+	if !h.checkQuota(c) {
+		return
+	}
 
 	resp, err := h.AI.Complete(c.Request.Context(), ai.CompletionRequest{
 		Messages:    req.Messages,
@@ -106,6 +136,7 @@ func (h *AIHandler) Chat(c *gin.Context) {
 		})
 		return
 	}
+	h.recordUsage(c, resp, nil)
 
 	c.JSON(http.StatusOK, gin.H{
 		"data": resp,
@@ -114,7 +145,7 @@ func (h *AIHandler) Chat(c *gin.Context) {
 
 // Stream handles a streaming completion via SSE.
 func (h *AIHandler) Stream(c *gin.Context) {
-	if h.AI == nil {
+	if !h.AI.CanComplete() {
 		c.JSON(http.StatusServiceUnavailable, gin.H{
 			"error": gin.H{
 				"code":    "AI_UNAVAILABLE",
@@ -134,12 +165,15 @@ func (h *AIHandler) Stream(c *gin.Context) {
 		})
 		return
 	}
+	if !h.checkQuota(c) {
+		return
+	}
 
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
 
-	err := h.AI.Stream(c.Request.Context(), ai.CompletionRequest{
+	resp, err := h.AI.Stream(c.Request.Context(), ai.CompletionRequest{
 		Messages:    req.Messages,
 		MaxTokens:   req.MaxTokens,
 		Temperature: req.Temperature,
@@ -148,6 +182,8 @@ func (h *AIHandler) Stream(c *gin.Context) {
 		c.Writer.Flush()
 		return nil
 	})
+	// Partial streams are billed too
+	h.recordUsage(c, resp, err)
 
 	if err != nil {
 		c.SSEvent("error", fmt.Sprintf("Stream error: %v", err))
@@ -157,3 +193,136 @@ func (h *AIHandler) Stream(c *gin.Context) {
 	c.SSEvent("done", "[DONE]")
 	c.Writer.Flush()
 }
+
+// Ask answers a question from the user's own notes, links and files via SSE.
+// Events: "sources" (context resources, sent first), "message" (answer chunks),
+// "citations" (sources the answer cites), then "done".
+func (h *AIHandler) Ask(c *gin.Context) {
+	if !h.AI.CanComplete() || h.AskService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": gin.H{
+				"code":    "AI_UNAVAILABLE",
+				"message": "AI service is not configured",
+			},
+		})
+		return
+	}
+
+	var req askRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+	if !h.checkQuota(c) {
+		return
+	}
+	if req.MaxSources < 1 || req.MaxSources > 10 {
+		req.MaxSources = 6
+	}
+
+	userID := c.GetUint("user_id")
+	sources, err := h.AskService.Retrieve(c.Request.Context(), userID, req.Question, req.MaxSources)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to retrieve context",
+			},
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	c.SSEvent("sources", sources)
+	c.Writer.Flush()
+
+	resp, err := h.AI.Stream(c.Request.Context(), ai.CompletionRequest{
+		Messages:    h.AskService.BuildMessages(req.Question, req.History, sources),
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+	}, func(chunk string) error {
+		c.SSEvent("message", chunk)
+		c.Writer.Flush()
+		return nil
+	})
+	h.recordUsage(c, resp, err)
+
+	if err != nil {
+		c.SSEvent("error", fmt.Sprintf("Stream error: %v", err))
+		c.Writer.Flush()
+	}
+
+	c.SSEvent("citations", h.AskService.Cited(resp.Content, sources))
+	c.SSEvent("done", "[DONE]")
+	c.Writer.Flush()
+}
+
+// GetUsage returns the authenticated user's AI token usage for the current month.
+func (h *AIHandler) GetUsage(c *gin.Context) {
+	if h.Usage == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"data": services.QuotaStatus{},
+		})
+		return
+	}
+
+	status, err := h.Usage.Check(c.GetUint("user_id"))
+	if err != nil && !errors.Is(err, services.ErrQuotaExceeded) {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to fetch AI usage",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": status,
+	})
+}
+
+// checkQuota writes a 429 response and returns false when the user has used up
+// their monthly token allowance. A failed lookup does not block the request.
+func (h *AIHandler) checkQuota(c *gin.Context) bool {
+	if h.Usage == nil {
+		return true
+	}
+
+	status, err := h.Usage.Check(c.GetUint("user_id"))
+	if errors.Is(err, services.ErrQuotaExceeded) {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": gin.H{
+				"code":      "QUOTA_EXCEEDED",
+				"message":   "Monthly AI token quota exceeded",
+				"limit":     status.Limit,
+				"used":      status.Used,
+				"resets_at": status.ResetsAt,
+			},
+		})
+		return false
+	}
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return true
+}
+
+// recordUsage meters a call against the requesting user, marking it as an
+// error when callErr is set.
+func (h *AIHandler) recordUsage(c *gin.Context, resp *ai.CompletionResponse, callErr error) {
+	if h.Usage == nil {
+		return
+	}
+	if err := h.Usage.Record(c.GetUint("user_id"), c.FullPath(), h.AI.Provider(), resp, callErr); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"desis-keep/apps/api/internal/ai"
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/services"
+)
+
+const testUserID uint = 1
+
+// testDB opens an empty in-memory database holding the given models.
+func testDB(t *testing.T, tables ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	// Every connection to ":memory:" is a database of its own
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	return db
+}
+
+// fakeAsker serves fixed sources instead of searching the database.
+type fakeAsker struct {
+	*services.AskService
+	sources []services.AskSource
+}
+
+func (a fakeAsker) Retrieve(ctx context.Context, userID uint, question string, limit int) ([]services.AskSource, error) {
+	return a.sources, nil
+}
+
+// aiRouter mounts the AI endpoints as the routes do, for an authenticated user.
+func aiRouter(h *AIHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", testUserID)
+	})
+	r.POST("/api/ai/complete", h.Complete)
+	r.POST("/api/ai/chat", h.Chat)
+	r.POST("/api/ai/stream", h.Stream)
+	r.POST("/api/ai/ask", h.Ask)
+	return r
+}
+
+func post(r *gin.Engine, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+type sseEvent struct {
+	Name string
+	Data string
+}
+
+// readEvents splits a server-sent event stream into its events.
+func readEvents(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	for _, frame := range strings.Split(body, "\n\n") {
+		if frame == "" {
+			continue
+		}
+		var ev sseEvent
+		var data []string
+		for _, line := range strings.Split(frame, "\n") {
+			switch {
+			case strings.HasPrefix(line, "event:"):
+				ev.Name = strings.TrimPrefix(line, "event:")
+			case strings.HasPrefix(line, "data:"):
+				data = append(data, strings.TrimPrefix(line, "data:"))
+			default:
+				t.Fatalf("unexpected line %q in event stream", line)
+			}
+		}
+		ev.Data = strings.Join(data, "\n")
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestAIComplete(t *testing.T) {
+	db := testDB(t, &models.AIUsage{}, &models.AIQuota{})
+	provider := ai.NewFakeProvider("Hello there")
+	r := aiRouter(&AIHandler{AI: ai.New(provider, nil), Usage: services.NewUsageService(db, 0)})
+
+	w := post(r, "/api/ai/complete", `{"prompt": "Say hello", "max_tokens": 50}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Data ai.CompletionResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if body.Data.Content != "Hello there" || body.Data.Model != "fake" {
+		t.Errorf("data = %+v, want the fake's response", body.Data)
+	}
+
+	if len(provider.Requests) != 1 {
+		t.Fatalf("provider got %d requests, want 1", len(provider.Requests))
+	}
+	got := provider.Requests[0]
+	if got.MaxTokens != 50 || len(got.Messages) != 1 || got.Messages[0].Content != "Say hello" {
+		t.Errorf("provider request = %+v, want the prompt as one message", got)
+	}
+
+	var usage []models.AIUsage
+	db.Find(&usage)
+	if len(usage) != 1 {
+		t.Fatalf("recorded %d usage rows, want 1", len(usage))
+	}
+	if u := usage[0]; u.UserID != testUserID || u.Endpoint != "/api/ai/complete" || u.Provider != "fake" || u.OutputTokens != 2 {
+		t.Errorf("usage = %+v", u)
+	}
+}
+
+func TestAIChat(t *testing.T) {
+	provider := ai.NewFakeProvider()
+	r := aiRouter(&AIHandler{AI: ai.New(provider, nil)})
+
+	w := post(r, "/api/ai/chat", `{"messages": [
+		{"role": "user", "content": "first"},
+		{"role": "assistant", "content": "reply"},
+		{"role": "user", "content": "second"}
+	]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Data ai.CompletionResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if body.Data.Content != "Echo: second" {
+		t.Errorf("content = %q, want the last user message echoed", body.Data.Content)
+	}
+	if len(provider.Requests) != 1 || len(provider.Requests[0].Messages) != 3 {
+		t.Errorf("provider requests = %+v, want the whole conversation", provider.Requests)
+	}
+
+	if w := post(r, "/api/ai/chat", `{}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("chat without messages: status = %d, want 422", w.Code)
+	}
+}
+
+func TestAIStream(t *testing.T) {
+	r := aiRouter(&AIHandler{AI: ai.New(ai.NewFakeProvider("one two three"), nil)})
+
+	w := post(r, "/api/ai/stream", `{"messages": [{"role": "user", "content": "count"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+	want := []sseEvent{
+		{"message", "one "},
+		{"message", "two "},
+		{"message", "three"},
+		{"done", "[DONE]"},
+	}
+	got := readEvents(t, w.Body.String())
+	if len(got) != len(want) {
+		t.Fatalf("events = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestAIStreamError(t *testing.T) {
+	provider := ai.NewFakeProvider()
+	provider.Err = context.DeadlineExceeded
+	db := testDB(t, &models.AIUsage{}, &models.AIQuota{})
+	r := aiRouter(&AIHandler{AI: ai.New(provider, nil), Usage: services.NewUsageService(db, 0)})
+
+	w := post(r, "/api/ai/stream", `{"messages": [{"role": "user", "content": "count"}]}`)
+	got := readEvents(t, w.Body.String())
+	if len(got) != 2 || got[0].Name != "error" || got[1] != (sseEvent{"done", "[DONE]"}) {
+		t.Errorf("events = %q, want an error then done", got)
+	}
+
+	// Nothing came back, so nothing is charged
+	var usage []models.AIUsage
+	db.Find(&usage)
+	if len(usage) != 1 {
+		t.Fatalf("recorded %d usage rows, want 1", len(usage))
+	}
+	if u := usage[0]; u.InputTokens != 0 || u.OutputTokens != 0 || !u.IsError {
+		t.Errorf("usage = %+v, want an error with no tokens", u)
+	}
+}
+
+func TestAIAsk(t *testing.T) {
+	provider := ai.NewFakeProvider("Pack light [2], as noted.")
+	asker := fakeAsker{sources: []services.AskSource{
+		{Index: 1, Type: "note", ID: 7, Title: "Groceries", Text: "milk, eggs"},
+		{Index: 2, Type: "link", ID: 9, Title: "Travel tips", URL: "https://example.com/tips", Text: "pack light"},
+	}}
+	r := aiRouter(&AIHandler{AI: ai.New(provider, nil), AskService: asker})
+
+	w := post(r, "/api/ai/ask", `{"question": "How should I pack?"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	events := readEvents(t, w.Body.String())
+	if len(events) < 4 {
+		t.Fatalf("events = %q, want sources, messages, citations and done", events)
+	}
+
+	first, last := events[0], events[len(events)-1]
+	if first.Name != "sources" {
+		t.Fatalf("first event = %q, want sources", first.Name)
+	}
+	var sources []services.AskSource
+	if err := json.Unmarshal([]byte(first.Data), &sources); err != nil {
+		t.Fatalf("decoding sources: %v", err)
+	}
+	if len(sources) != 2 || sources[0].ID != 7 || sources[1].URL != "https://example.com/tips" {
+		t.Errorf("sources = %+v", sources)
+	}
+	if strings.Contains(first.Data, "milk") {
+		t.Errorf("sources event leaks source text: %s", first.Data)
+	}
+
+	var answer strings.Builder
+	for _, ev := range events[1 : len(events)-2] {
+		if ev.Name != "message" {
+			t.Fatalf("event %q between sources and citations, want message", ev.Name)
+		}
+		answer.WriteString(ev.Data)
+	}
+	if answer.String() != "Pack light [2], as noted." {
+		t.Errorf("answer = %q", answer.String())
+	}
+
+	citations := events[len(events)-2]
+	if citations.Name != "citations" {
+		t.Fatalf("event = %q, want citations", citations.Name)
+	}
+	var cited []services.AskSource
+	if err := json.Unmarshal([]byte(citations.Data), &cited); err != nil {
+		t.Fatalf("decoding citations: %v", err)
+	}
+	if len(cited) != 1 || cited[0].Index != 2 {
+		t.Errorf("citations = %+v, want source 2", cited)
+	}
+	if last != (sseEvent{"done", "[DONE]"}) {
+		t.Errorf("last event = %q, want done", last)
+	}
+
+	prompt := provider.Requests[0].Messages[0].Content
+	if !strings.Contains(prompt, "[2] link: Travel tips") || !strings.Contains(prompt, "How should I pack?") {
+		t.Errorf("prompt lacks the sources or question:\n%s", prompt)
+	}
+}
+
+func TestAIQuotaExceeded(t *testing.T) {
+	db := testDB(t, &models.AIUsage{}, &models.AIQuota{})
+	db.Create(&models.AIUsage{
+		UserID:       testUserID,
+		Provider:     "fake",
+		Endpoint:     "/api/ai/chat",
+		InputTokens:  60,
+		OutputTokens: 40,
+		CreatedAt:    time.Now().UTC(),
+	})
+	provider := ai.NewFakeProvider()
+	r := aiRouter(&AIHandler{
+		AI:         ai.New(provider, nil),
+		AskService: fakeAsker{},
+		Usage:      services.NewUsageService(db, 100),
+	})
+
+	for path, body := range map[string]string{
+		"/api/ai/complete": `{"prompt": "hi"}`,
+		"/api/ai/chat":     `{"messages": [{"role": "user", "content": "hi"}]}`,
+		"/api/ai/stream":   `{"messages": [{"role": "user", "content": "hi"}]}`,
+		"/api/ai/ask":      `{"question": "hi"}`,
+	} {
+		w := post(r, path, body)
+		if w.Code != http.StatusTooManyRequests {
+			t.Errorf("%s: status = %d, want 429", path, w.Code)
+			continue
+		}
+		var resp struct {
+			Error struct {
+				Code  string `json:"code"`
+				Limit int64  `json:"limit"`
+				Used  int64  `json:"used"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: decoding response: %v", path, err)
+		}
+		if resp.Error.Code != "QUOTA_EXCEEDED" || resp.Error.Limit != 100 || resp.Error.Used != 100 {
+			t.Errorf("%s: error = %+v", path, resp.Error)
+		}
+	}
+	if len(provider.Requests) != 0 {
+		t.Errorf("provider got %d requests over quota, want none", len(provider.Requests))
+	}
+
+	// A higher limit for the user lets them through again
+	limit := int64(1000)
+	if err := services.NewUsageService(db, 100).SetLimit(testUserID, &limit); err != nil {
+		t.Fatalf("raising limit: %v", err)
+	}
+	if w := post(r, "/api/ai/complete", `{"prompt": "hi"}`); w.Code != http.StatusOK {
+		t.Errorf("after raising the limit: status = %d, want 200", w.Code)
+	}
+}
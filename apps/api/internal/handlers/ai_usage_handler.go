@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/services"
+)
+
+// AIUsageHandler handles admin endpoints for AI usage and quotas.
+type AIUsageHandler struct {
+	DB    *gorm.DB
+	Usage *services.UsageService
+}
+
+// NewAIUsageHandler creates a new AIUsageHandler instance.
+func NewAIUsageHandler(db *gorm.DB, usage *services.UsageService) *AIUsageHandler {
+	return &AIUsageHandler{DB: db, Usage: usage}
+}
+
+// Summary returns per-user token totals for a month.
+// Query params: month (YYYY-MM, defaults to the current month).
+func (h *AIUsageHandler) Summary(c *gin.Context) {
+	month, ok := parseMonth(c)
+	if !ok {
+		return
+	}
+
+	rows, err := h.Usage.Summary(month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to fetch AI usage",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": rows,
+		"meta": gin.H{
+			"month":         month.Format("2006-01"),
+			"default_limit": h.Usage.DefaultLimit,
+		},
+	})
+}
+
+// UserUsage returns one user's token totals per endpoint, provider and model for a month.
+// Query params: month (YYYY-MM, defaults to the current month).
+func (h *AIUsageHandler) UserUsage(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+	month, ok := parseMonth(c)
+	if !ok {
+		return
+	}
+
+	rows, err := h.Usage.Breakdown(user.ID, month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to fetch AI usage",
+			},
+		})
+		return
+	}
+	limit, err := h.Usage.Limit(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to fetch AI quota",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": rows,
+		"meta": gin.H{
+			"user_id": user.ID,
+			"month":   month.Format("2006-01"),
+			"limit":   limit,
+		},
+	})
+}
+
+// SetQuota overrides a user's monthly token limit.
+// A null monthly_token_limit removes the override; 0 means unlimited.
+func (h *AIUsageHandler) SetQuota(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		MonthlyTokenLimit *int64 `json:"monthly_token_limit"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+	if req.MonthlyTokenLimit != nil && *req.MonthlyTokenLimit < 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "monthly_token_limit must not be negative",
+			},
+		})
+		return
+	}
+
+	if err := h.Usage.SetLimit(user.ID, req.MonthlyTokenLimit); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to update AI quota",
+			},
+		})
+		return
+	}
+
+	status, err := h.Usage.Check(user.ID)
+	if err != nil && !errors.Is(err, services.ErrQuotaExceeded) {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to fetch AI quota",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    status,
+		"message": "AI quota updated",
+	})
+}
+
+// findUser loads the user named by the :user_id param, writing an error response when it fails.
+func (h *AIUsageHandler) findUser(c *gin.Context) (*models.User, bool) {
+	id, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid user ID",
+			},
+		})
+		return nil, false
+	}
+
+	var user models.User
+	if err := h.DB.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "User not found",
+			},
+		})
+		return nil, false
+	}
+	return &user, true
+}
+
+// parseMonth reads the month query param, writing an error response when it is malformed.
+func parseMonth(c *gin.Context) (time.Time, bool) {
+	value := c.Query("month")
+	if value == "" {
+		return time.Now().UTC(), true
+	}
+	month, err := time.Parse("2006-01", value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "month must be formatted as YYYY-MM",
+			},
+		})
+		return time.Time{}, false
+	}
+	return month, true
+}
@@ -1,14 +1,18 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
 	"golang.org/x/crypto/bcrypt"
 
+	"desis-keep/apps/api/internal/jobs"
 	"desis-keep/apps/api/internal/models"
 	"desis-keep/apps/api/internal/services"
 )
@@ -17,6 +21,9 @@ import (
 type AuthHandler struct {
 	DB          *gorm.DB
 	AuthService *services.AuthService
+	Jobs        *jobs.Client // sends verification emails; nil skips them
+	AppName     string
+	FrontendURL string // where verification links point
 }
 
 type registerRequest struct {
@@ -44,6 +51,10 @@ type resetPasswordRequest struct {
 	Password string `json:"password" binding:"required,min=8"`
 }
 
+type verifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
 // Register creates a new user account.
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req registerRequest
@@ -87,6 +98,9 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		})
 		return
 	}
+	if err := h.sendVerification(&user); err != nil {
+		log.Printf("Warning: %v", err)
+	}
 
 	tokens, err := h.AuthService.GenerateTokenPair(user.ID, user.Email, user.Role)
 	if err != nil {
@@ -104,7 +118,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 			"user":   user,
 			"tokens": tokens,
 		},
-		"message": "User registered successfully",
+		"message": "User registered successfully; check your email to verify your address",
 	})
 }
 
@@ -313,3 +327,89 @@ func (h *AuthHandler) ResetPassword(c *gin.Context) {
 		"message": "Password reset successfully",
 	})
 }
+
+// VerifyEmail confirms a user's email address with the token from their
+// verification email, which gives them the shares waiting for the address.
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req verifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	user, err := services.NewEmailVerificationService(h.DB).Verify(req.Token)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidVerificationToken) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"code":    "INVALID_TOKEN",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to verify email",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    user,
+		"message": "Email verified successfully",
+	})
+}
+
+// ResendVerification emails the current user a new verification link.
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": gin.H{
+				"code":    "ALREADY_VERIFIED",
+				"message": "Your email address is already verified",
+			},
+		})
+		return
+	}
+
+	if err := h.sendVerification(&user); err != nil {
+		log.Printf("Warning: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to send verification email",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Verification email sent",
+	})
+}
+
+// sendVerification emails a user a link to verify their address.
+func (h *AuthHandler) sendVerification(user *models.User) error {
+	token, err := services.NewEmailVerificationService(h.DB).Issue(user)
+	if err != nil {
+		return err
+	}
+	if h.Jobs == nil {
+		log.Printf("Warning: job queue not configured, verification email for user %d not sent", user.ID)
+		return nil
+	}
+	return h.Jobs.EnqueueSendEmail(user.Email, "Verify your email", "email-verification", map[string]interface{}{
+		"AppName":   h.AppName,
+		"Year":      time.Now().Year(),
+		"VerifyURL": h.FrontendURL + "/verify-email?token=" + url.QueryEscape(token),
+	})
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/collab"
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/services"
+)
+
+// CollabHandler handles real-time collaborative editing of notes.
+type CollabHandler struct {
+	DB       *gorm.DB
+	Hub      *collab.Hub
+	upgrader websocket.Upgrader
+}
+
+// NewCollabHandler creates a new CollabHandler instance. WebSocket handshakes
+// are accepted from the given origins and from the API's own host. Browsers
+// always send an Origin; a handshake without one is only accepted from other
+// clients, which authenticate with the Authorization header.
+func NewCollabHandler(db *gorm.DB, hub *collab.Hub, origins []string) *CollabHandler {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[strings.TrimRight(strings.TrimSpace(origin), "/")] = true
+	}
+	return &CollabHandler{
+		DB:  db,
+		Hub: hub,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				if origin == "" {
+					return r.Header.Get("Authorization") != ""
+				}
+				if allowed[origin] {
+					return true
+				}
+				u, err := url.Parse(origin)
+				return err == nil && strings.EqualFold(u.Host, r.Host)
+			},
+		},
+	}
+}
+
+// Connect opens a WebSocket session for editing a note's body together with
+// everyone else who has it open. Users the note is shared with for viewing
+// follow along without editing.
+func (h *CollabHandler) Connect(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	id, ok := noteParam(c)
+	if !ok {
+		return
+	}
+
+	canEdit, err := h.Hub.Service.Access(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": "Note not found",
+				},
+			})
+		case errors.Is(err, services.ErrNotTextNote):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": gin.H{
+					"code":    "VALIDATION_ERROR",
+					"message": err.Error(),
+				},
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": gin.H{
+					"code":    "INTERNAL_ERROR",
+					"message": "Failed to open note",
+				},
+			})
+		}
+		return
+	}
+
+	if !websocket.IsWebSocketUpgrade(c.Request) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "WEBSOCKET_REQUIRED",
+				"message": "This endpoint only accepts WebSocket connections",
+			},
+		})
+		return
+	}
+
+	// The upgrader answers failed handshakes itself.
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Warning: upgrading collaboration session: %v", err)
+		return
+	}
+	h.Hub.Join(conn, id, &user, canEdit)
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCollabCheckOrigin(t *testing.T) {
+	h := NewCollabHandler(nil, nil, []string{"https://app.example.com/"})
+
+	tests := []struct {
+		name          string
+		origin        string
+		authorization string
+		want          bool
+	}{
+		{"allowed origin", "https://app.example.com", "", true},
+		{"own host", "https://api.example.com", "", true},
+		{"other origin", "https://evil.example", "", false},
+		{"other origin with a token", "https://evil.example", "Bearer token", false},
+		{"no origin", "", "", false},
+		{"no origin with a token", "", "Bearer token", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "https://api.example.com/api/notes/1/collab?token=x", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			if got := h.upgrader.CheckOrigin(r); got != tt.want {
+				t.Errorf("CheckOrigin = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
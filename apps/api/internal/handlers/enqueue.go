@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"log"
+
+	"desis-keep/apps/api/internal/jobs"
+)
+
+// Helpers for background work triggered by resource writes. Enqueue failures are
+// logged so a queue problem never fails the write that triggered it, and a nil
+// client (no Redis) disables the work entirely.
+
+// enqueueEmbed schedules a semantic search embedding refresh for a resource.
+func enqueueEmbed(jobClient *jobs.Client, resourceType string, id uint) {
+	if jobClient == nil {
+		return
+	}
+	if err := jobClient.EnqueueEmbed(resourceType, id); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// enqueueEnrich schedules an AI summary and label suggestions for a resource.
+func enqueueEnrich(jobClient *jobs.Client, resourceType string, id uint) {
+	if jobClient == nil {
+		return
+	}
+	if err := jobClient.EnqueueEnrich(resourceType, id); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// enqueueFileText schedules extracting the text of an uploaded file for search.
+func enqueueFileText(jobClient *jobs.Client, fileID uint) {
+	if jobClient == nil {
+		return
+	}
+	if err := jobClient.EnqueueExtractFileText(fileID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// enqueueLinkMetadata schedules fetching a link's title, description, thumbnail and favicon.
+func enqueueLinkMetadata(jobClient *jobs.Client, linkID uint) {
+	if jobClient == nil {
+		return
+	}
+	if err := jobClient.EnqueueFetchLinkMetadata(linkID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// enqueueLinkSnapshot schedules archiving a readable copy of a link's page.
+func enqueueLinkSnapshot(jobClient *jobs.Client, linkID uint) {
+	if jobClient == nil {
+		return
+	}
+	if err := jobClient.EnqueueSnapshotLink(linkID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// enqueueShareInvite schedules telling someone about a resource shared with them.
+func enqueueShareInvite(jobClient *jobs.Client, shareID uint) {
+	if jobClient == nil {
+		return
+	}
+	if err := jobClient.EnqueueShareInvite(shareID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/jobs"
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/services"
+	"desis-keep/apps/api/internal/storage"
+	"desis-keep/apps/api/internal/vault"
+)
+
+// ExportHandler handles exporting data for use in other tools, and exporting
+// a whole account as a portable archive.
+type ExportHandler struct {
+	DB       *gorm.DB
+	Service  *services.ExportService
+	Accounts *services.AccountExportService
+	Vault    *services.VaultService
+	Jobs     *jobs.Client
+}
+
+// NewExportHandler creates a new ExportHandler instance.
+func NewExportHandler(db *gorm.DB, jobClient *jobs.Client, store *storage.Storage) *ExportHandler {
+	return &ExportHandler{
+		DB:       db,
+		Service:  services.NewExportService(db),
+		Accounts: services.NewAccountExportService(db, store),
+		Vault:    services.NewVaultService(db, store),
+		Jobs:     jobClient,
+	}
+}
+
+// Bookmarks downloads the user's links as a browser bookmark file (Netscape
+// HTML format), with one folder per label.
+func (h *ExportHandler) Bookmarks(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var buf bytes.Buffer
+	if err := h.Service.WriteBookmarks(&buf, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to export bookmarks",
+			},
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("bookmarks-%s.html", time.Now().Format("2006-01-02"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// Obsidian downloads the user's notes as a zip of Markdown files that
+// Obsidian opens as a vault, with labels as tags, images as attachments and
+// a Links.md index of links.
+func (h *ExportHandler) Obsidian(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	// Written to a buffer first so a failure can still be reported as JSON
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	_, err := h.Vault.Sync(c.Request.Context(), userID, vault.ZipTarget{Writer: zw}, vault.NewState(userID))
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		log.Printf("Warning: vault export for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to export notes",
+			},
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("obsidian-vault-%s.zip", time.Now().Format("2006-01-02"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// StartAccount starts building a zip archive of everything in the user's
+// account. When it is ready the user is emailed a download link; it can also
+// be polled. A request while an export is still in progress returns that export.
+func (h *ExportHandler) StartAccount(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	if h.Accounts.Storage == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": gin.H{
+				"code":    "STORAGE_UNAVAILABLE",
+				"message": "File storage is not configured",
+			},
+		})
+		return
+	}
+	if h.Jobs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": gin.H{
+				"code":    "JOBS_UNAVAILABLE",
+				"message": "Background jobs are not configured",
+			},
+		})
+		return
+	}
+
+	job, started, err := h.Accounts.Start(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to start export",
+			},
+		})
+		return
+	}
+	if !started {
+		c.JSON(http.StatusAccepted, gin.H{
+			"data":    job,
+			"message": "An export is already in progress",
+		})
+		return
+	}
+
+	if err := h.Jobs.EnqueueExportAccount(job.ID); err != nil {
+		h.DB.Model(job).Updates(map[string]interface{}{
+			"status": models.ExportFailed,
+			"error":  "could not be queued",
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to queue export",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"data":    job,
+		"message": "Export started; we will email you a download link when it is ready",
+	})
+}
+
+// ListAccount returns the user's recent account exports, newest first.
+func (h *ExportHandler) ListAccount(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	exports, err := h.Accounts.List(userID, 20)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to fetch exports",
+			},
+		})
+		return
+	}
+	for i := range exports {
+		h.fillDownloadURL(c, &exports[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": exports,
+	})
+}
+
+// GetAccount returns an account export, with a signed download URL once it
+// has completed and until it expires.
+func (h *ExportHandler) GetAccount(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid export ID",
+			},
+		})
+		return
+	}
+
+	job, err := h.Accounts.Get(uint(id), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "Export not found",
+			},
+		})
+		return
+	}
+	h.fillDownloadURL(c, job)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": job,
+	})
+}
+
+// fillDownloadURL signs a download URL for a completed export.
+func (h *ExportHandler) fillDownloadURL(c *gin.Context, job *models.ExportJob) {
+	url, err := h.Accounts.DownloadURL(c.Request.Context(), job)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	job.DownloadURL = url
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/bookmarks"
+	"desis-keep/apps/api/internal/enex"
+	"desis-keep/apps/api/internal/jobs"
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/services"
+	"desis-keep/apps/api/internal/storage"
+)
+
+// MaxArchiveImportSize is the maximum size of an uploaded export archive (1 GB).
+// Takeout archives include every attachment, so they outgrow MaxUploadSize quickly.
+const MaxArchiveImportSize = 1 << 30
+
+// ImportHandler handles importing data from other tools.
+type ImportHandler struct {
+	DB      *gorm.DB
+	Service *services.ImportService
+	Jobs    *jobs.Client
+}
+
+// NewImportHandler creates a new ImportHandler instance.
+func NewImportHandler(db *gorm.DB, jobClient *jobs.Client, store *storage.Storage) *ImportHandler {
+	return &ImportHandler{
+		DB:      db,
+		Service: services.NewImportService(db, store),
+		Jobs:    jobClient,
+	}
+}
+
+// Bookmarks imports a browser bookmark export (Netscape HTML format) uploaded
+// as the multipart field "file". The import runs in the background; poll the
+// returned import for progress.
+func (h *ImportHandler) Bookmarks(c *gin.Context) {
+	h.start(c, models.ImportKindBookmarks, MaxUploadSize, bookmarks.Sniff, "The file is not a browser bookmark export", services.ImportOptions{})
+}
+
+// GoogleKeep imports a Google Takeout zip containing Keep notes, uploaded as
+// the multipart field "file". Importing the same archive again skips notes
+// that were already imported.
+func (h *ImportHandler) GoogleKeep(c *gin.Context) {
+	h.start(c, models.ImportKindGoogleKeep, MaxArchiveImportSize, isZip, "The file is not a Google Takeout zip archive", services.ImportOptions{})
+}
+
+// Evernote imports an Evernote export (.enex), uploaded as the multipart field
+// "file". The file name, which Evernote sets to the notebook name, becomes a
+// label on every imported note. Importing the same export again skips notes
+// that were already imported.
+func (h *ImportHandler) Evernote(c *gin.Context) {
+	h.start(c, models.ImportKindEvernote, MaxArchiveImportSize, enex.Sniff, "The file is not an Evernote export", services.ImportOptions{})
+}
+
+// Account restores an account export archive, uploaded as the multipart
+// field "file", into the user's account. The form field "mode" is "merge"
+// (the default), which skips entries matching something the user already has,
+// or "copy", which imports them anyway. With "dry_run" set to true nothing is
+// changed and the finished import's report lists what would be created and
+// which entries conflict.
+func (h *ImportHandler) Account(c *gin.Context) {
+	opts := services.ImportOptions{Mode: c.DefaultPostForm("mode", models.ImportModeMerge)}
+	if opts.Mode != models.ImportModeMerge && opts.Mode != models.ImportModeCopy {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "mode must be merge or copy",
+			},
+		})
+		return
+	}
+	if raw := c.PostForm("dry_run"); raw != "" {
+		dryRun, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"code":    "VALIDATION_ERROR",
+					"message": "dry_run must be true or false",
+				},
+			})
+			return
+		}
+		opts.DryRun = dryRun
+	}
+
+	h.start(c, models.ImportKindAccount, MaxArchiveImportSize, isZip, "The file is not an account export archive", opts)
+}
+
+// List returns the user's recent imports, newest first.
+func (h *ImportHandler) List(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	imports, err := h.Service.List(userID, 50)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to fetch imports",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": imports,
+	})
+}
+
+// GetByID returns an import with its progress counters.
+func (h *ImportHandler) GetByID(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid import ID",
+			},
+		})
+		return
+	}
+
+	job, err := h.Service.Get(uint(id), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "Import not found",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": job,
+	})
+}
+
+// start validates the uploaded file with sniff, stores it and queues the import.
+func (h *ImportHandler) start(c *gin.Context, kind string, maxSize int64, sniff func([]byte) bool, invalidMessage string, opts services.ImportOptions) {
+	userID := c.GetUint("user_id")
+
+	if h.Service.Storage == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": gin.H{
+				"code":    "STORAGE_UNAVAILABLE",
+				"message": "File storage is not configured",
+			},
+		})
+		return
+	}
+	if h.Jobs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": gin.H{
+				"code":    "JOBS_UNAVAILABLE",
+				"message": "Background jobs are not configured",
+			},
+		})
+		return
+	}
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_FILE",
+				"message": fmt.Sprintf("No file provided: %v", err),
+			},
+		})
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "FILE_TOO_LARGE",
+				"message": fmt.Sprintf("File size exceeds maximum of %d MB", maxSize/(1<<20)),
+			},
+		})
+		return
+	}
+
+	if !sniffFile(file, sniff) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_FILE_TYPE",
+				"message": invalidMessage,
+			},
+		})
+		return
+	}
+
+	job, err := h.Service.Start(c.Request.Context(), userID, kind, header.Filename, file, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to start import",
+			},
+		})
+		return
+	}
+
+	if err := h.Jobs.EnqueueImport(job.ID); err != nil {
+		h.DB.Model(job).Updates(map[string]interface{}{
+			"status": models.ImportFailed,
+			"error":  "could not be queued",
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to queue import",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"data":    job,
+		"message": "Import started",
+	})
+}
+
+// isZip reports whether head starts like a zip archive.
+func isZip(head []byte) bool {
+	return bytes.HasPrefix(head, []byte("PK\x03\x04"))
+}
+
+// sniffFile checks the start of an uploaded file and rewinds it.
+func sniffFile(file multipart.File, sniff func([]byte) bool) bool {
+	head := make([]byte, 4096)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return false
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return false
+	}
+	return sniff(head[:n])
+}
@@ -1,291 +1,664 @@
-package handlers
-
-import (
-	"net/http"
-	"strconv"
-
-	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
-
-	"desis-keep/apps/api/internal/models"
-	"desis-keep/apps/api/internal/services"
-)
-
-// LinkHandler handles link endpoints.
-type LinkHandler struct {
-	DB      *gorm.DB
-	Service *services.LinkService
-}
-
-// NewLinkHandler creates a new LinkHandler instance.
-func NewLinkHandler(db *gorm.DB) *LinkHandler {
-	return &LinkHandler{
-		DB:      db,
-		Service: services.NewLinkService(db),
-	}
-}
-
-// List returns a paginated list of links for the authenticated user.
-func (h *LinkHandler) List(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
-	search := c.Query("search")
-	sortBy := c.DefaultQuery("sort_by", "created_at")
-	sortOrder := c.DefaultQuery("sort_order", "desc")
-
-	var archived, trashed *bool
-	if c.Query("archived") != "" {
-		val := c.Query("archived") == "true"
-		archived = &val
-	}
-	if c.Query("trashed") != "" {
-		val := c.Query("trashed") == "true"
-		trashed = &val
-	}
-
-	links, total, pages, err := h.Service.List(userID, page, pageSize, search, sortBy, sortOrder, archived, trashed)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"code":    "INTERNAL_ERROR",
-				"message": "Failed to fetch links",
-			},
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"data": links,
-		"meta": gin.H{
-			"total":     total,
-			"page":      page,
-			"page_size": pageSize,
-			"pages":     pages,
-		},
-	})
-}
-
-// Create adds a new link.
-func (h *LinkHandler) Create(c *gin.Context) {
-	userID := c.GetUint("user_id")
-
-	var req struct {
-		URL          string `json:"url" binding:"required,url"`
-		Title        string `json:"title"`
-		Description  string `json:"description"`
-		ThumbnailURL string `json:"thumbnail_url"`
-		FaviconURL   string `json:"favicon_url"`
-		Labels       []uint `json:"labels"`
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"error": gin.H{
-				"code":    "VALIDATION_ERROR",
-				"message": err.Error(),
-			},
-		})
-		return
-	}
-
-	link := models.Link{
-		URL:          req.URL,
-		Title:        req.Title,
-		Description:  req.Description,
-		ThumbnailURL: req.ThumbnailURL,
-		FaviconURL:   req.FaviconURL,
-		UserID:       userID,
-	}
-
-	if err := h.Service.Create(&link); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"code":    "INTERNAL_ERROR",
-				"message": "Failed to create link",
-			},
-		})
-		return
-	}
-
-	// Associate labels if provided
-	if len(req.Labels) > 0 {
-		var labels []models.Label
-		h.DB.Where("id IN ? AND user_id = ?", req.Labels, userID).Find(&labels)
-		h.DB.Model(&link).Association("Labels").Replace(labels)
-	}
-
-	c.JSON(http.StatusCreated, gin.H{
-		"data":    link,
-		"message": "Link created successfully",
-	})
-}
-
-// Update modifies an existing link.
-func (h *LinkHandler) Update(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"code":    "INVALID_ID",
-				"message": "Invalid link ID",
-			},
-		})
-		return
-	}
-
-	var req struct {
-		URL          *string `json:"url"`
-		Title        *string `json:"title"`
-		Description  *string `json:"description"`
-		ThumbnailURL *string `json:"thumbnail_url"`
-		FaviconURL   *string `json:"favicon_url"`
-		IsPinned     *bool   `json:"is_pinned"`
-		IsArchived   *bool   `json:"is_archived"`
-		Labels       []uint  `json:"labels"`
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"error": gin.H{
-				"code":    "VALIDATION_ERROR",
-				"message": err.Error(),
-			},
-		})
-		return
-	}
-
-	updates := map[string]interface{}{}
-	if req.URL != nil {
-		updates["url"] = *req.URL
-	}
-	if req.Title != nil {
-		updates["title"] = *req.Title
-	}
-	if req.Description != nil {
-		updates["description"] = *req.Description
-	}
-	if req.ThumbnailURL != nil {
-		updates["thumbnail_url"] = *req.ThumbnailURL
-	}
-	if req.FaviconURL != nil {
-		updates["favicon_url"] = *req.FaviconURL
-	}
-	if req.IsPinned != nil {
-		updates["is_pinned"] = *req.IsPinned
-	}
-	if req.IsArchived != nil {
-		updates["is_archived"] = *req.IsArchived
-	}
-
-	link, err := h.Service.Update(uint(id), userID, updates)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": gin.H{
-				"code":    "NOT_FOUND",
-				"message": "Link not found",
-			},
-		})
-		return
-	}
-
-	// Update labels if provided
-	if req.Labels != nil {
-		var labels []models.Label
-		h.DB.Where("id IN ? AND user_id = ?", req.Labels, userID).Find(&labels)
-		h.DB.Model(link).Association("Labels").Replace(labels)
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"data":    link,
-		"message": "Link updated successfully",
-	})
-}
-
-// Delete soft-deletes a link.
-func (h *LinkHandler) Delete(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"code":    "INVALID_ID",
-				"message": "Invalid link ID",
-			},
-		})
-		return
-	}
-
-	if err := h.Service.Delete(uint(id), userID); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": gin.H{
-				"code":    "NOT_FOUND",
-				"message": "Link not found",
-			},
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Link moved to trash",
-	})
-}
-
-// Restore restores a trashed link.
-func (h *LinkHandler) Restore(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"code":    "INVALID_ID",
-				"message": "Invalid link ID",
-			},
-		})
-		return
-	}
-
-	if err := h.Service.Restore(uint(id), userID); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": gin.H{
-				"code":    "NOT_FOUND",
-				"message": "Link not found",
-			},
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Link restored successfully",
-	})
-}
-
-// PermanentDelete hard-deletes a link.
-func (h *LinkHandler) PermanentDelete(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"code":    "INVALID_ID",
-				"message": "Invalid link ID",
-			},
-		})
-		return
-	}
-
-	if err := h.Service.PermanentDelete(uint(id), userID); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": gin.H{
-				"code":    "NOT_FOUND",
-				"message": "Link not found",
-			},
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Link permanently deleted",
-	})
-}
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/jobs"
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/querylang"
+	"desis-keep/apps/api/internal/services"
+	"desis-keep/apps/api/internal/storage"
+)
+
+// LinkHandler handles link endpoints.
+type LinkHandler struct {
+	DB        *gorm.DB
+	Service   *services.LinkService
+	Snapshots *services.LinkSnapshotService
+	Health    *services.LinkHealthService
+	Jobs      *jobs.Client
+}
+
+// NewLinkHandler creates a new LinkHandler instance.
+func NewLinkHandler(db *gorm.DB, jobClient *jobs.Client, store *storage.Storage) *LinkHandler {
+	return &LinkHandler{
+		DB:        db,
+		Service:   services.NewLinkService(db),
+		Snapshots: services.NewLinkSnapshotService(db, store),
+		Health:    services.NewLinkHealthService(db, false),
+		Jobs:      jobClient,
+	}
+}
+
+// List returns a paginated list of links for the authenticated user.
+func (h *LinkHandler) List(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	sortBy := c.DefaultQuery("sort_by", "created_at")
+	sortOrder := c.DefaultQuery("sort_order", "desc")
+
+	var archived, trashed *bool
+	if c.Query("archived") != "" {
+		val := c.Query("archived") == "true"
+		archived = &val
+	}
+	if c.Query("trashed") != "" {
+		val := c.Query("trashed") == "true"
+		trashed = &val
+	}
+
+	health := c.Query("health")
+	switch health {
+	case "", models.LinkHealthOK, models.LinkHealthRedirected, models.LinkHealthBroken, "unchecked":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_QUERY",
+				"message": "health must be one of ok, redirected, broken or unchecked",
+			},
+		})
+		return
+	}
+
+	q, err := querylang.Parse(c.Query("search"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_QUERY",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	links, total, pages, err := h.Service.List(userID, page, pageSize, q, sortBy, sortOrder, archived, trashed, health)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to fetch links",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": links,
+		"meta": gin.H{
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+			"pages":     pages,
+		},
+	})
+}
+
+// Create adds a new link.
+func (h *LinkHandler) Create(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req struct {
+		URL          string `json:"url" binding:"required,url"`
+		Title        string `json:"title"`
+		Description  string `json:"description"`
+		ThumbnailURL string `json:"thumbnail_url"`
+		FaviconURL   string `json:"favicon_url"`
+		Labels       []uint `json:"labels"`
+		Snapshot     bool   `json:"snapshot"`
+		// OnDuplicate decides what happens when the URL is already saved:
+		// "error" (default) answers 409, "merge" adds the labels to the existing link.
+		OnDuplicate string `json:"on_duplicate" binding:"omitempty,oneof=error merge"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	link := models.Link{
+		URL:          req.URL,
+		Title:        req.Title,
+		Description:  req.Description,
+		ThumbnailURL: req.ThumbnailURL,
+		FaviconURL:   req.FaviconURL,
+		UserID:       userID,
+	}
+
+	err := h.Service.Create(&link)
+	var duplicate *services.DuplicateLinkError
+	if errors.As(err, &duplicate) {
+		h.handleDuplicate(c, duplicate.Existing, req.OnDuplicate, req.Labels)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to create link",
+			},
+		})
+		return
+	}
+
+	// Associate labels if provided
+	if len(req.Labels) > 0 {
+		h.Service.SetLabels(&link, userID, req.Labels)
+	}
+
+	enqueueLinkMetadata(h.Jobs, link.ID)
+	enqueueEmbed(h.Jobs, models.ResourceTypeLink, link.ID)
+	enqueueEnrich(h.Jobs, models.ResourceTypeLink, link.ID)
+	if req.Snapshot && h.Jobs != nil {
+		if _, err := h.Snapshots.Request(link.ID, userID); err != nil {
+			log.Printf("Warning: %v", err)
+		} else {
+			enqueueLinkSnapshot(h.Jobs, link.ID)
+		}
+	}
+
+	setETag(c, link.Version)
+	c.JSON(http.StatusCreated, gin.H{
+		"data":    link,
+		"message": "Link created successfully",
+	})
+}
+
+// handleDuplicate answers a create request for a URL the user already saved:
+// 409 with the existing link, or with onDuplicate "merge", the existing link
+// with the requested labels added.
+func (h *LinkHandler) handleDuplicate(c *gin.Context, existing *models.Link, onDuplicate string, labelIDs []uint) {
+	userID := c.GetUint("user_id")
+
+	if onDuplicate != "merge" {
+		duplicateLink(c, existing)
+		return
+	}
+
+	if len(labelIDs) > 0 {
+		if err := h.Service.AddLabels(existing, userID, labelIDs); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": gin.H{
+					"code":    "INTERNAL_ERROR",
+					"message": "Failed to merge labels",
+				},
+			})
+			return
+		}
+		existing, _ = h.Service.GetByID(existing.ID, userID)
+		enqueueEmbed(h.Jobs, models.ResourceTypeLink, existing.ID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    existing,
+		"message": "Link already saved; labels merged",
+	})
+}
+
+// duplicateLink answers 409 with the link already saved under the same URL,
+// or without it when existing is nil.
+func duplicateLink(c *gin.Context, existing *models.Link) {
+	body := gin.H{
+		"error": gin.H{
+			"code":    "DUPLICATE_LINK",
+			"message": "This link is already saved",
+		},
+	}
+	if existing != nil {
+		body["data"] = existing
+	}
+	c.JSON(http.StatusConflict, body)
+}
+
+// Update modifies an existing link.
+func (h *LinkHandler) Update(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid link ID",
+			},
+		})
+		return
+	}
+
+	var req struct {
+		URL          *string `json:"url"`
+		Title        *string `json:"title"`
+		Description  *string `json:"description"`
+		ThumbnailURL *string `json:"thumbnail_url"`
+		FaviconURL   *string `json:"favicon_url"`
+		IsPinned     *bool   `json:"is_pinned"`
+		IsArchived   *bool   `json:"is_archived"`
+		Labels       []uint  `json:"labels"`
+		Version      *uint   `json:"version"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	version, ok := ifMatch(c, req.Version)
+	if !ok {
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.URL != nil {
+		updates["url"] = *req.URL
+	}
+	if req.Title != nil {
+		updates["title"] = *req.Title
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.ThumbnailURL != nil {
+		updates["thumbnail_url"] = *req.ThumbnailURL
+	}
+	if req.FaviconURL != nil {
+		updates["favicon_url"] = *req.FaviconURL
+	}
+	if req.IsPinned != nil {
+		updates["is_pinned"] = *req.IsPinned
+	}
+	if req.IsArchived != nil {
+		updates["is_archived"] = *req.IsArchived
+	}
+
+	link, err := h.Service.Update(uint(id), userID, version, updates)
+	if errors.Is(err, services.ErrVersionConflict) {
+		versionConflict(c, link, link.Version)
+		return
+	}
+	var duplicate *services.DuplicateLinkError
+	if errors.As(err, &duplicate) {
+		existing := duplicate.Existing
+		if existing.UserID != userID {
+			// The match is one of the owner's links, which may not be shared
+			// with the caller
+			existing = nil
+		}
+		duplicateLink(c, existing)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "Link not found",
+			},
+		})
+		return
+	}
+
+	// Update labels if provided
+	if req.Labels != nil {
+		h.Service.SetLabels(link, userID, req.Labels)
+	}
+
+	if req.URL != nil {
+		// Only blank fields are filled, so edited titles are kept
+		enqueueLinkMetadata(h.Jobs, link.ID)
+	}
+	enqueueEmbed(h.Jobs, models.ResourceTypeLink, link.ID)
+
+	setETag(c, link.Version)
+	c.JSON(http.StatusOK, gin.H{
+		"data":    link,
+		"message": "Link updated successfully",
+	})
+}
+
+// Delete soft-deletes a link.
+func (h *LinkHandler) Delete(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid link ID",
+			},
+		})
+		return
+	}
+
+	if err := h.Service.Delete(uint(id), userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "Link not found",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Link moved to trash",
+	})
+}
+
+// Restore restores a trashed link.
+func (h *LinkHandler) Restore(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid link ID",
+			},
+		})
+		return
+	}
+
+	if err := h.Service.Restore(uint(id), userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "Link not found",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Link restored successfully",
+	})
+}
+
+// PermanentDelete hard-deletes a link.
+func (h *LinkHandler) PermanentDelete(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid link ID",
+			},
+		})
+		return
+	}
+
+	if err := h.Service.PermanentDelete(uint(id), userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "Link not found",
+			},
+		})
+		return
+	}
+
+	if err := h.Snapshots.Delete(c.Request.Context(), uint(id), userID); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Warning: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Link permanently deleted",
+	})
+}
+
+// GetSnapshot returns a link's archived snapshot. Once the snapshot is ready the
+// response includes the sanitized article HTML; with ?format=html the HTML is
+// served directly as a page.
+func (h *LinkHandler) GetSnapshot(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid link ID",
+			},
+		})
+		return
+	}
+
+	snapshot, err := h.Snapshots.Get(uint(id), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "Snapshot not found",
+			},
+		})
+		return
+	}
+
+	if snapshot.Status != models.SnapshotReady {
+		c.JSON(http.StatusOK, gin.H{
+			"data": snapshot,
+		})
+		return
+	}
+
+	content, err := h.Snapshots.Content(c.Request.Context(), snapshot)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to read snapshot",
+			},
+		})
+		return
+	}
+
+	if c.Query("format") == "html" {
+		// The HTML is sanitized, but a strict CSP keeps any miss inert
+		c.Header("Content-Security-Policy", "default-src 'none'; img-src https: http:; style-src 'unsafe-inline'; sandbox")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(content))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"snapshot": snapshot,
+			"content":  content,
+		},
+	})
+}
+
+// CreateSnapshot archives (or re-archives) a readable copy of a link's page.
+// Capturing runs in the background; poll GET until the status is "ready" or "failed".
+func (h *LinkHandler) CreateSnapshot(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid link ID",
+			},
+		})
+		return
+	}
+	if h.Jobs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": gin.H{
+				"code":    "JOBS_UNAVAILABLE",
+				"message": "Background jobs are not configured",
+			},
+		})
+		return
+	}
+
+	snapshot, err := h.Snapshots.Request(uint(id), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrStorageUnavailable):
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error": gin.H{
+					"code":    "STORAGE_UNAVAILABLE",
+					"message": "File storage is not configured",
+				},
+			})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": "Link not found",
+				},
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": gin.H{
+					"code":    "INTERNAL_ERROR",
+					"message": "Failed to request snapshot",
+				},
+			})
+		}
+		return
+	}
+
+	enqueueLinkSnapshot(h.Jobs, snapshot.LinkID)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"data":    snapshot,
+		"message": "Snapshot requested",
+	})
+}
+
+// DeleteSnapshot removes a link's snapshot and its archived files.
+func (h *LinkHandler) DeleteSnapshot(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid link ID",
+			},
+		})
+		return
+	}
+
+	if err := h.Snapshots.Delete(c.Request.Context(), uint(id), userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": "Snapshot not found",
+				},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to delete snapshot",
+			},
+		})
+		return
+	}
+
+	enqueueEmbed(h.Jobs, models.ResourceTypeLink, uint(id))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Snapshot deleted",
+	})
+}
+
+// HealthReport returns the user's link health counts and their broken and redirected links.
+func (h *LinkHandler) HealthReport(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	report, err := h.Health.Report(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to fetch link health",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": report,
+	})
+}
+
+// ApplyRedirects replaces the URL of each redirected link with the address it redirects to.
+func (h *LinkHandler) ApplyRedirects(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	updated, err := h.Health.ApplyRedirects(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to update redirected links",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    gin.H{"updated": updated},
+		"message": "Redirected links updated",
+	})
+}
+
+// Dedupe enqueues a job that merges duplicate links, for one user when
+// user_id is given and for everyone otherwise.
+func (h *LinkHandler) Dedupe(c *gin.Context) {
+	var req struct {
+		UserID uint `json:"user_id"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": gin.H{
+					"code":    "VALIDATION_ERROR",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+	}
+	if h.Jobs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": gin.H{
+				"code":    "JOBS_UNAVAILABLE",
+				"message": "Background jobs are not configured",
+			},
+		})
+		return
+	}
+
+	if err := h.Jobs.EnqueueDedupeLinks(req.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to enqueue link dedupe",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Duplicate link merge started",
+	})
+}
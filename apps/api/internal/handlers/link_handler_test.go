@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"desis-keep/apps/api/internal/models"
+)
+
+// linkRouter mounts the link update endpoint for the given user.
+func linkRouter(h *LinkHandler, userID uint) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+	})
+	r.PUT("/api/links/:id", h.Update)
+	return r
+}
+
+func TestUpdateLinkDuplicate(t *testing.T) {
+	db := testDB(t, &models.User{}, &models.Label{}, &models.Link{}, &models.Share{})
+	const ownerID, shareeID uint = 1, 2
+	h := NewLinkHandler(db, nil, nil)
+
+	private := models.Link{URL: "https://example.com/private", UserID: ownerID}
+	shared := models.Link{URL: "https://example.com/shared", UserID: ownerID}
+	for _, link := range []*models.Link{&private, &shared} {
+		if err := h.Service.Create(link); err != nil {
+			t.Fatalf("creating link: %v", err)
+		}
+	}
+	grantee := shareeID
+	db.Create(&models.Share{
+		OwnerID:      ownerID,
+		ResourceType: models.ResourceTypeLink,
+		ResourceID:   shared.ID,
+		Email:        "sharee@example.com",
+		UserID:       &grantee,
+		Permission:   models.SharePermissionEdit,
+	})
+
+	put := func(userID uint) (int, map[string]json.RawMessage) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/links/%d", shared.ID),
+			strings.NewReader(`{"url": "https://example.com/private", "version": 1}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		linkRouter(h, userID).ServeHTTP(w, req)
+		var body map[string]json.RawMessage
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		return w.Code, body
+	}
+
+	// The owner sees which link they already saved
+	code, body := put(ownerID)
+	if code != http.StatusConflict {
+		t.Fatalf("owner: status %d, want 409", code)
+	}
+	var existing models.Link
+	if err := json.Unmarshal(body["data"], &existing); err != nil || existing.ID != private.ID {
+		t.Errorf("owner: data = %s, want link %d", body["data"], private.ID)
+	}
+
+	// A sharee with edit access learns only that the URL is taken
+	code, body = put(shareeID)
+	if code != http.StatusConflict {
+		t.Fatalf("sharee: status %d, want 409", code)
+	}
+	if data, ok := body["data"]; ok {
+		t.Errorf("sharee: 409 reveals the owner's link: %s", data)
+	}
+	if !strings.Contains(string(body["error"]), "DUPLICATE_LINK") {
+		t.Errorf("sharee: error = %s", body["error"])
+	}
+}
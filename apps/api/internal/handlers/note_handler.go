@@ -1,279 +1,400 @@
-package handlers
-
-import (
-	"net/http"
-	"strconv"
-
-	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
-
-	"desis-keep/apps/api/internal/models"
-	"desis-keep/apps/api/internal/services"
-)
-
-// NoteHandler handles note endpoints.
-type NoteHandler struct {
-	DB      *gorm.DB
-	Service *services.NoteService
-}
-
-// NewNoteHandler creates a new NoteHandler instance.
-func NewNoteHandler(db *gorm.DB) *NoteHandler {
-	return &NoteHandler{
-		DB:      db,
-		Service: services.NewNoteService(db),
-	}
-}
-
-// List returns a paginated list of notes for the authenticated user.
-func (h *NoteHandler) List(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
-	search := c.Query("search")
-	sortBy := c.DefaultQuery("sort_by", "created_at")
-	sortOrder := c.DefaultQuery("sort_order", "desc")
-
-	var archived, trashed *bool
-	if c.Query("archived") != "" {
-		val := c.Query("archived") == "true"
-		archived = &val
-	}
-	if c.Query("trashed") != "" {
-		val := c.Query("trashed") == "true"
-		trashed = &val
-	}
-
-	notes, total, pages, err := h.Service.List(userID, page, pageSize, search, sortBy, sortOrder, archived, trashed)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"code":    "INTERNAL_ERROR",
-				"message": "Failed to fetch notes",
-			},
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"data": notes,
-		"meta": gin.H{
-			"total":     total,
-			"page":      page,
-			"page_size": pageSize,
-			"pages":     pages,
-		},
-	})
-}
-
-// Create adds a new note.
-func (h *NoteHandler) Create(c *gin.Context) {
-	userID := c.GetUint("user_id")
-
-	var req struct {
-		Title  string `json:"title"`
-		Body   string `json:"body"`
-		Color  string `json:"color"`
-		Labels []uint `json:"labels"`
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"error": gin.H{
-				"code":    "VALIDATION_ERROR",
-				"message": err.Error(),
-			},
-		})
-		return
-	}
-
-	note := models.Note{
-		Title:  req.Title,
-		Body:   req.Body,
-		Color:  req.Color,
-		UserID: userID,
-	}
-
-	if err := h.Service.Create(&note); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"code":    "INTERNAL_ERROR",
-				"message": "Failed to create note",
-			},
-		})
-		return
-	}
-
-	// Associate labels if provided
-	if len(req.Labels) > 0 {
-		var labels []models.Label
-		h.DB.Where("id IN ? AND user_id = ?", req.Labels, userID).Find(&labels)
-		h.DB.Model(&note).Association("Labels").Replace(labels)
-	}
-
-	c.JSON(http.StatusCreated, gin.H{
-		"data":    note,
-		"message": "Note created successfully",
-	})
-}
-
-// Update modifies an existing note.
-func (h *NoteHandler) Update(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"code":    "INVALID_ID",
-				"message": "Invalid note ID",
-			},
-		})
-		return
-	}
-
-	var req struct {
-		Title      *string `json:"title"`
-		Body       *string `json:"body"`
-		Color      *string `json:"color"`
-		IsPinned   *bool   `json:"is_pinned"`
-		IsArchived *bool   `json:"is_archived"`
-		Labels     []uint  `json:"labels"`
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"error": gin.H{
-				"code":    "VALIDATION_ERROR",
-				"message": err.Error(),
-			},
-		})
-		return
-	}
-
-	updates := map[string]interface{}{}
-	if req.Title != nil {
-		updates["title"] = *req.Title
-	}
-	if req.Body != nil {
-		updates["body"] = *req.Body
-	}
-	if req.Color != nil {
-		updates["color"] = *req.Color
-	}
-	if req.IsPinned != nil {
-		updates["is_pinned"] = *req.IsPinned
-	}
-	if req.IsArchived != nil {
-		updates["is_archived"] = *req.IsArchived
-	}
-
-	note, err := h.Service.Update(uint(id), userID, updates)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": gin.H{
-				"code":    "NOT_FOUND",
-				"message": "Note not found",
-			},
-		})
-		return
-	}
-
-	// Update labels if provided
-	if req.Labels != nil {
-		var labels []models.Label
-		h.DB.Where("id IN ? AND user_id = ?", req.Labels, userID).Find(&labels)
-		h.DB.Model(note).Association("Labels").Replace(labels)
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"data":    note,
-		"message": "Note updated successfully",
-	})
-}
-
-// Delete soft-deletes a note (sets is_trashed = true).
-func (h *NoteHandler) Delete(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"code":    "INVALID_ID",
-				"message": "Invalid note ID",
-			},
-		})
-		return
-	}
-
-	if err := h.Service.Delete(uint(id), userID); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": gin.H{
-				"code":    "NOT_FOUND",
-				"message": "Note not found",
-			},
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Note moved to trash",
-	})
-}
-
-// Restore restores a trashed note.
-func (h *NoteHandler) Restore(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"code":    "INVALID_ID",
-				"message": "Invalid note ID",
-			},
-		})
-		return
-	}
-
-	if err := h.Service.Restore(uint(id), userID); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": gin.H{
-				"code":    "NOT_FOUND",
-				"message": "Note not found",
-			},
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Note restored successfully",
-	})
-}
-
-// PermanentDelete hard-deletes a note.
-func (h *NoteHandler) PermanentDelete(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"code":    "INVALID_ID",
-				"message": "Invalid note ID",
-			},
-		})
-		return
-	}
-
-	if err := h.Service.PermanentDelete(uint(id), userID); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": gin.H{
-				"code":    "NOT_FOUND",
-				"message": "Note not found",
-			},
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Note permanently deleted",
-	})
-}
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/checklist"
+	"desis-keep/apps/api/internal/jobs"
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/querylang"
+	"desis-keep/apps/api/internal/services"
+)
+
+// NoteHandler handles note endpoints.
+type NoteHandler struct {
+	DB      *gorm.DB
+	Service *services.NoteService
+	Jobs    *jobs.Client
+}
+
+// NewNoteHandler creates a new NoteHandler instance.
+func NewNoteHandler(db *gorm.DB, jobClient *jobs.Client) *NoteHandler {
+	return &NoteHandler{
+		DB:      db,
+		Service: services.NewNoteService(db),
+		Jobs:    jobClient,
+	}
+}
+
+// List returns a paginated list of notes for the authenticated user.
+func (h *NoteHandler) List(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	sortBy := c.DefaultQuery("sort_by", "created_at")
+	sortOrder := c.DefaultQuery("sort_order", "desc")
+
+	var archived, trashed *bool
+	if c.Query("archived") != "" {
+		val := c.Query("archived") == "true"
+		archived = &val
+	}
+	if c.Query("trashed") != "" {
+		val := c.Query("trashed") == "true"
+		trashed = &val
+	}
+
+	q, err := querylang.Parse(c.Query("search"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_QUERY",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	notes, total, pages, err := h.Service.List(userID, page, pageSize, q, sortBy, sortOrder, archived, trashed)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to fetch notes",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": notes,
+		"meta": gin.H{
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+			"pages":     pages,
+		},
+	})
+}
+
+// Create adds a new note.
+func (h *NoteHandler) Create(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req struct {
+		Type   string        `json:"type"`
+		Title  string        `json:"title"`
+		Body   string        `json:"body"`
+		Color  string        `json:"color"`
+		Items  []noteItemReq `json:"items"`
+		Labels []uint        `json:"labels"`
+
+		MoveCheckedToBottom bool `json:"move_checked_to_bottom"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	if req.Type == "" {
+		req.Type = models.NoteTypeText
+	}
+	if req.Type != models.NoteTypeText && req.Type != models.NoteTypeChecklist {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "type must be text or checklist",
+			},
+		})
+		return
+	}
+
+	note := models.Note{
+		Type:                req.Type,
+		Title:               req.Title,
+		Body:                req.Body,
+		Color:               req.Color,
+		MoveCheckedToBottom: req.MoveCheckedToBottom,
+		UserID:              userID,
+	}
+	if req.Type == models.NoteTypeChecklist {
+		// A checklist sent as text gets one item per line
+		if len(req.Items) == 0 {
+			for _, item := range checklist.Parse(req.Body) {
+				req.Items = append(req.Items, noteItemReq{Text: item.Text, Checked: item.Checked, Indent: item.Indent})
+			}
+		}
+		for _, item := range req.Items {
+			note.Items = append(note.Items, models.NoteItem{Text: item.Text, Checked: item.Checked, Indent: item.Indent})
+		}
+	}
+
+	if err := h.Service.Create(&note); err != nil {
+		if errors.Is(err, services.ErrTooManyItems) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": gin.H{
+					"code":    "VALIDATION_ERROR",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to create note",
+			},
+		})
+		return
+	}
+
+	// Associate labels if provided
+	if len(req.Labels) > 0 {
+		h.Service.SetLabels(&note, userID, req.Labels)
+	}
+
+	enqueueEmbed(h.Jobs, models.ResourceTypeNote, note.ID)
+	enqueueEnrich(h.Jobs, models.ResourceTypeNote, note.ID)
+
+	setETag(c, note.Version)
+	c.JSON(http.StatusCreated, gin.H{
+		"data":    note,
+		"message": "Note created successfully",
+	})
+}
+
+// Update modifies an existing note. With update_references, wiki links in
+// other notes that name the note by an earlier title are changed to its new
+// title.
+func (h *NoteHandler) Update(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid note ID",
+			},
+		})
+		return
+	}
+
+	var req struct {
+		Title      *string `json:"title"`
+		Body       *string `json:"body"`
+		Color      *string `json:"color"`
+		IsPinned   *bool   `json:"is_pinned"`
+		IsArchived *bool   `json:"is_archived"`
+		Labels     []uint  `json:"labels"`
+		Version    *uint   `json:"version"`
+
+		MoveCheckedToBottom *bool `json:"move_checked_to_bottom"`
+		UpdateReferences    bool  `json:"update_references"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	version, ok := ifMatch(c, req.Version)
+	if !ok {
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Title != nil {
+		updates["title"] = *req.Title
+	}
+	if req.Body != nil {
+		updates["body"] = *req.Body
+	}
+	if req.Color != nil {
+		updates["color"] = *req.Color
+	}
+	if req.IsPinned != nil {
+		updates["is_pinned"] = *req.IsPinned
+	}
+	if req.IsArchived != nil {
+		updates["is_archived"] = *req.IsArchived
+	}
+	if req.MoveCheckedToBottom != nil {
+		updates["move_checked_to_bottom"] = *req.MoveCheckedToBottom
+	}
+
+	note, err := h.Service.Update(uint(id), userID, version, updates)
+	if errors.Is(err, services.ErrVersionConflict) {
+		versionConflict(c, note, note.Version)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "Note not found",
+			},
+		})
+		return
+	}
+
+	// Update labels if provided
+	if req.Labels != nil {
+		h.Service.SetLabels(note, userID, req.Labels)
+	}
+
+	if req.UpdateReferences {
+		if _, err := h.Service.RewriteReferences(note.ID, userID); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	enqueueEmbed(h.Jobs, models.ResourceTypeNote, note.ID)
+	enqueueEnrich(h.Jobs, models.ResourceTypeNote, note.ID)
+
+	setETag(c, note.Version)
+	c.JSON(http.StatusOK, gin.H{
+		"data":    note,
+		"message": "Note updated successfully",
+	})
+}
+
+// RewriteReferences changes wiki links in other notes that name a note by an
+// earlier title to its current title.
+func (h *NoteHandler) RewriteReferences(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, ok := noteParam(c)
+	if !ok {
+		return
+	}
+
+	rewritten, err := h.Service.RewriteReferences(id, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": "Note not found",
+				},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to update references",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"rewritten": rewritten,
+		},
+		"message": "References updated successfully",
+	})
+}
+
+// Delete soft-deletes a note (sets is_trashed = true).
+func (h *NoteHandler) Delete(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid note ID",
+			},
+		})
+		return
+	}
+
+	if err := h.Service.Delete(uint(id), userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "Note not found",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Note moved to trash",
+	})
+}
+
+// Restore restores a trashed note.
+func (h *NoteHandler) Restore(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid note ID",
+			},
+		})
+		return
+	}
+
+	if err := h.Service.Restore(uint(id), userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "Note not found",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Note restored successfully",
+	})
+}
+
+// PermanentDelete hard-deletes a note.
+func (h *NoteHandler) PermanentDelete(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid note ID",
+			},
+		})
+		return
+	}
+
+	if err := h.Service.PermanentDelete(uint(id), userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "Note not found",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Note permanently deleted",
+	})
+}
@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/services"
+)
+
+// noteItemReq is a checklist item in a request.
+type noteItemReq struct {
+	Text    string `json:"text"`
+	Checked bool   `json:"checked"`
+	Indent  int    `json:"indent"`
+}
+
+// AddItem adds an item to a checklist note. Without a position the item goes
+// at the end; otherwise it is inserted there and the items below move down.
+func (h *NoteHandler) AddItem(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, ok := noteParam(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		noteItemReq
+		Position *int `json:"position"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	item := models.NoteItem{Text: req.Text, Checked: req.Checked, Indent: req.Indent}
+	note, err := h.Service.AddItem(id, userID, item, req.Position)
+	if err != nil {
+		itemError(c, err)
+		return
+	}
+	enqueueEmbed(h.Jobs, models.ResourceTypeNote, note.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data":    note,
+		"message": "Item added successfully",
+	})
+}
+
+// UpdateItem changes the text, checked state or indent of a checklist item.
+func (h *NoteHandler) UpdateItem(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, ok := noteParam(c)
+	if !ok {
+		return
+	}
+	itemID, ok := itemParam(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Text    *string `json:"text"`
+		Checked *bool   `json:"checked"`
+		Indent  *int    `json:"indent"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Text != nil {
+		updates["text"] = *req.Text
+	}
+	if req.Checked != nil {
+		updates["checked"] = *req.Checked
+	}
+	if req.Indent != nil {
+		updates["indent"] = *req.Indent
+	}
+
+	note, err := h.Service.UpdateItem(id, itemID, userID, updates)
+	if err != nil {
+		itemError(c, err)
+		return
+	}
+	if req.Text != nil {
+		enqueueEmbed(h.Jobs, models.ResourceTypeNote, note.ID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    note,
+		"message": "Item updated successfully",
+	})
+}
+
+// ToggleItem checks an unchecked item or unchecks a checked one.
+func (h *NoteHandler) ToggleItem(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, ok := noteParam(c)
+	if !ok {
+		return
+	}
+	itemID, ok := itemParam(c)
+	if !ok {
+		return
+	}
+
+	note, err := h.Service.ToggleItem(id, itemID, userID)
+	if err != nil {
+		itemError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    note,
+		"message": "Item updated successfully",
+	})
+}
+
+// DeleteItem removes an item from a checklist note.
+func (h *NoteHandler) DeleteItem(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, ok := noteParam(c)
+	if !ok {
+		return
+	}
+	itemID, ok := itemParam(c)
+	if !ok {
+		return
+	}
+
+	note, err := h.Service.DeleteItem(id, itemID, userID)
+	if err != nil {
+		itemError(c, err)
+		return
+	}
+	enqueueEmbed(h.Jobs, models.ResourceTypeNote, note.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    note,
+		"message": "Item deleted successfully",
+	})
+}
+
+// ReorderItems sets the order of a checklist's items. item_ids must list
+// every item of the note exactly once.
+func (h *NoteHandler) ReorderItems(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, ok := noteParam(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		ItemIDs []uint `json:"item_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	note, err := h.Service.ReorderItems(id, userID, req.ItemIDs)
+	if err != nil {
+		itemError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    note,
+		"message": "Items reordered successfully",
+	})
+}
+
+// Convert turns a text note into a checklist with one item per line, or a
+// checklist into a text note holding the items as a Markdown task list.
+func (h *NoteHandler) Convert(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, ok := noteParam(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Type string `json:"type" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	note, err := h.Service.Convert(id, userID, req.Type)
+	if err != nil {
+		itemError(c, err)
+		return
+	}
+	enqueueEmbed(h.Jobs, models.ResourceTypeNote, note.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    note,
+		"message": "Note converted successfully",
+	})
+}
+
+// noteParam reads the note ID from the path, answering 400 when it is invalid.
+func noteParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid note ID",
+			},
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// itemParam reads the item ID from the path, answering 400 when it is invalid.
+func itemParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("item_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid item ID",
+			},
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// itemError answers a failed checklist change.
+func itemError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "Note not found",
+			},
+		})
+	case errors.Is(err, services.ErrItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "Item not found",
+			},
+		})
+	case errors.Is(err, services.ErrNotChecklist):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": gin.H{
+				"code":    "NOT_CHECKLIST",
+				"message": "Note is not a checklist",
+			},
+		})
+	case errors.Is(err, services.ErrTooManyItems), errors.Is(err, services.ErrInvalidItemOrder):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+	case errors.Is(err, services.ErrUnknownNoteType):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "type must be text or checklist",
+			},
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to update checklist",
+			},
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/services"
+)
+
+// Revisions lists a note's revisions, newest first, without their bodies.
+func (h *NoteHandler) Revisions(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, ok := noteParam(c)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	revisions, total, pages, err := h.Service.ListRevisions(id, userID, page, pageSize)
+	if err != nil {
+		revisionError(c, err, "Note not found", "Failed to fetch revisions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": revisions,
+		"meta": gin.H{
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+			"pages":     pages,
+		},
+	})
+}
+
+// Revision returns one revision of a note, with its body.
+func (h *NoteHandler) Revision(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, ok := noteParam(c)
+	if !ok {
+		return
+	}
+	revisionID, ok := revisionParam(c)
+	if !ok {
+		return
+	}
+
+	revision, err := h.Service.GetRevision(id, revisionID, userID)
+	if err != nil {
+		revisionError(c, err, "Revision not found", "Failed to fetch revision")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": revision,
+	})
+}
+
+// DiffRevisions returns a unified diff of the bodies of revision "from" and
+// revision "to", or of "from" and the note as it is now when "to" is left out.
+func (h *NoteHandler) DiffRevisions(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, ok := noteParam(c)
+	if !ok {
+		return
+	}
+	from, err := strconv.ParseUint(c.Query("from"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "from must be a revision ID",
+			},
+		})
+		return
+	}
+	var to uint64
+	if raw := c.Query("to"); raw != "" {
+		if to, err = strconv.ParseUint(raw, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"code":    "INVALID_ID",
+					"message": "to must be a revision ID",
+				},
+			})
+			return
+		}
+	}
+
+	diff, err := h.Service.DiffRevisions(id, userID, uint(from), uint(to))
+	if err != nil {
+		revisionError(c, err, "Revision not found", "Failed to compare revisions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": diff,
+	})
+}
+
+// RestoreRevision sets a note's title and body back to those of a revision.
+func (h *NoteHandler) RestoreRevision(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, ok := noteParam(c)
+	if !ok {
+		return
+	}
+	revisionID, ok := revisionParam(c)
+	if !ok {
+		return
+	}
+
+	note, err := h.Service.RestoreRevision(id, revisionID, userID)
+	if err != nil {
+		revisionError(c, err, "Revision not found", "Failed to restore revision")
+		return
+	}
+	enqueueEmbed(h.Jobs, models.ResourceTypeNote, note.ID)
+	enqueueEnrich(h.Jobs, models.ResourceTypeNote, note.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    note,
+		"message": "Revision restored successfully",
+	})
+}
+
+// revisionParam reads the revision ID from the path, answering 400 when it is invalid.
+func revisionParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("revision_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid revision ID",
+			},
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// revisionError answers a failed revision request.
+func revisionError(c *gin.Context, err error, notFound, failed string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": notFound,
+			},
+		})
+	case errors.Is(err, services.ErrTooManyItems):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": failed,
+			},
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/services"
+)
+
+// NotificationHandler handles in-app notification endpoints.
+type NotificationHandler struct {
+	DB      *gorm.DB
+	Service *services.NotificationService
+}
+
+// NewNotificationHandler creates a new NotificationHandler instance.
+func NewNotificationHandler(db *gorm.DB) *NotificationHandler {
+	return &NotificationHandler{
+		DB:      db,
+		Service: services.NewNotificationService(db),
+	}
+}
+
+// List returns the user's notifications, newest first, with the unread count.
+// Pass unread=true for unread ones only.
+func (h *NotificationHandler) List(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	notifications, unread, err := h.Service.List(userID, c.Query("unread") == "true", limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to fetch notifications",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": notifications,
+		"meta": gin.H{
+			"unread": unread,
+		},
+	})
+}
+
+// MarkRead marks a notification as read.
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid notification ID",
+			},
+		})
+		return
+	}
+
+	if err := h.Service.MarkRead(uint(id), userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": "Notification not found",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Notification marked as read",
+	})
+}
+
+// MarkAllRead marks all of the user's notifications as read.
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	if err := h.Service.MarkAllRead(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to update notifications",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "All notifications marked as read",
+	})
+}
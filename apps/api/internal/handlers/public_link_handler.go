@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/cache"
+	"desis-keep/apps/api/internal/services"
+	"desis-keep/apps/api/internal/storage"
+)
+
+// PublicLinkHandler handles publishing resources through public links, and
+// opening them without an account.
+type PublicLinkHandler struct {
+	DB      *gorm.DB
+	Service *services.PublicLinkService
+}
+
+// NewPublicLinkHandler creates a new PublicLinkHandler instance. Wrong
+// passwords are throttled through cacheService, when it is set.
+func NewPublicLinkHandler(db *gorm.DB, store *storage.Storage, cacheService *cache.Cache) *PublicLinkHandler {
+	return &PublicLinkHandler{
+		DB:      db,
+		Service: services.NewPublicLinkService(db, store, cacheService),
+	}
+}
+
+// Create publishes one of the user's notes, links, images or files.
+func (h *PublicLinkHandler) Create(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req struct {
+		ResourceType string     `json:"resource_type" binding:"required"`
+		ResourceID   uint       `json:"resource_id" binding:"required"`
+		ExpiresAt    *time.Time `json:"expires_at"`
+		Password     string     `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	link, err := h.Service.Create(userID, services.PublicLinkInput{
+		ResourceType: req.ResourceType,
+		ResourceID:   req.ResourceID,
+		ExpiresAt:    req.ExpiresAt,
+		Password:     req.Password,
+	})
+	if err != nil {
+		publicLinkError(c, err, "Resource not found", "Failed to create public link")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data":    link,
+		"message": "Public link created successfully",
+	})
+}
+
+// List returns the user's public links, optionally only those of one
+// resource (resource_type and resource_id).
+func (h *PublicLinkHandler) List(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	resourceID, _ := strconv.ParseUint(c.Query("resource_id"), 10, 64)
+
+	links, err := h.Service.List(userID, c.Query("resource_type"), uint(resourceID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to fetch public links",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": links,
+	})
+}
+
+// Update sets when a public link expires and changes or removes its password.
+func (h *PublicLinkHandler) Update(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, ok := publicLinkParam(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		ExpiresAt *time.Time `json:"expires_at"`
+		Password  *string    `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	link, err := h.Service.Update(id, userID, req.ExpiresAt, req.Password)
+	if err != nil {
+		publicLinkError(c, err, "Public link not found", "Failed to update public link")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    link,
+		"message": "Public link updated successfully",
+	})
+}
+
+// Delete revokes a public link.
+func (h *PublicLinkHandler) Delete(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, ok := publicLinkParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Service.Revoke(id, userID); err != nil {
+		publicLinkError(c, err, "Public link not found", "Failed to revoke public link")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Public link revoked successfully",
+	})
+}
+
+// Open returns the resource a public link publishes (public). A link with a
+// password is opened by POSTing it as {"password": "..."}.
+func (h *PublicLinkHandler) Open(c *gin.Context) {
+	var req struct {
+		Password string `json:"password"`
+	}
+	if c.Request.Method == http.MethodPost {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": gin.H{
+					"code":    "VALIDATION_ERROR",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+	}
+
+	resource, err := h.Service.Open(c.Request.Context(), c.Param("token"), req.Password, c.ClientIP())
+	if err != nil {
+		publicLinkError(c, err, "Link not found", "Failed to open link")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"data": resource,
+	})
+}
+
+// publicLinkParam reads the public link ID from the path, answering 400 when it is invalid.
+func publicLinkParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid public link ID",
+			},
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// publicLinkError answers a failed public link request.
+func publicLinkError(c *gin.Context, err error, notFound, failed string) {
+	var tooMany *services.TooManyAttemptsError
+	switch {
+	case errors.As(err, &tooMany):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(tooMany.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": gin.H{
+				"code":    "TOO_MANY_ATTEMPTS",
+				"message": err.Error(),
+			},
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": notFound,
+			},
+		})
+	case errors.Is(err, services.ErrPasswordRequired):
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{
+				"code":    "PASSWORD_REQUIRED",
+				"message": err.Error(),
+			},
+		})
+	case errors.Is(err, services.ErrPublicLinkExpired):
+		c.JSON(http.StatusGone, gin.H{
+			"error": gin.H{
+				"code":    "LINK_EXPIRED",
+				"message": err.Error(),
+			},
+		})
+	case errors.Is(err, services.ErrUnpublishableType),
+		errors.Is(err, services.ErrExpiryInPast),
+		errors.Is(err, services.ErrPasswordTooLong):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+	case errors.Is(err, services.ErrStorageUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": gin.H{
+				"code":    "STORAGE_UNAVAILABLE",
+				"message": "File storage is not configured",
+			},
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": failed,
+			},
+		})
+	}
+}
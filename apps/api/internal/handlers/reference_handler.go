@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/services"
+)
+
+// ReferenceHandler handles backlink and graph endpoints.
+type ReferenceHandler struct {
+	DB      *gorm.DB
+	Service *services.ReferenceService
+}
+
+// NewReferenceHandler creates a new ReferenceHandler instance.
+func NewReferenceHandler(db *gorm.DB) *ReferenceHandler {
+	return &ReferenceHandler{
+		DB:      db,
+		Service: services.NewReferenceService(db),
+	}
+}
+
+// Backlinks lists the notes that reference a note with a wiki link or a
+// keep:// URI.
+func (h *ReferenceHandler) Backlinks(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, ok := noteParam(c)
+	if !ok {
+		return
+	}
+
+	notes, err := h.Service.Backlinks(id, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": "Note not found",
+				},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to fetch backlinks",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": notes,
+	})
+}
+
+// Graph returns the user's notes and the resources they reference as nodes,
+// and the references as edges. Pass orphans=false to leave out notes
+// without references.
+func (h *ReferenceHandler) Graph(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	graph, err := h.Service.Graph(userID, c.Query("orphans") != "false")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to build graph",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": graph,
+	})
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/rrule"
+	"desis-keep/apps/api/internal/services"
+)
+
+// ReminderHandler handles note reminder endpoints.
+type ReminderHandler struct {
+	DB      *gorm.DB
+	Service *services.ReminderService
+}
+
+// NewReminderHandler creates a new ReminderHandler instance.
+func NewReminderHandler(db *gorm.DB) *ReminderHandler {
+	return &ReminderHandler{
+		DB:      db,
+		Service: services.NewReminderService(db),
+	}
+}
+
+// List returns the user's upcoming reminders, soonest first, each with its
+// note. "before" (RFC 3339) limits them to those firing by then.
+func (h *ReminderHandler) List(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	var before *time.Time
+	if raw := c.Query("before"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"code":    "VALIDATION_ERROR",
+					"message": "before must be an RFC 3339 time",
+				},
+			})
+			return
+		}
+		before = &t
+	}
+
+	reminders, err := h.Service.Upcoming(userID, before, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to fetch reminders",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": reminders,
+	})
+}
+
+// Set gives a note a reminder, replacing the one it had. "rrule" is an
+// optional RFC 5545 recurrence rule, such as "FREQ=WEEKLY;BYDAY=MO", followed
+// in "timezone" (an IANA name, UTC by default).
+func (h *ReminderHandler) Set(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, ok := noteParam(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		DueAt    time.Time `json:"due_at" binding:"required"`
+		RRule    string    `json:"rrule"`
+		Timezone string    `json:"timezone"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	reminder, err := h.Service.Set(id, userID, services.ReminderInput{
+		DueAt:    req.DueAt,
+		RRule:    req.RRule,
+		Timezone: req.Timezone,
+	})
+	if err != nil {
+		reminderError(c, err, "Note not found", "Failed to save reminder")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    reminder,
+		"message": "Reminder set successfully",
+	})
+}
+
+// Delete removes a note's reminder.
+func (h *ReminderHandler) Delete(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, ok := noteParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Service.Remove(id, userID); err != nil {
+		reminderError(c, err, "Reminder not found", "Failed to delete reminder")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Reminder deleted successfully",
+	})
+}
+
+// Snooze fires a reminder again later, either at "until" (RFC 3339) or in
+// "minutes" minutes.
+func (h *ReminderHandler) Snooze(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid reminder ID",
+			},
+		})
+		return
+	}
+
+	var req struct {
+		Until   *time.Time `json:"until"`
+		Minutes int        `json:"minutes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Until == nil && req.Minutes <= 0) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "Send until or a positive number of minutes",
+			},
+		})
+		return
+	}
+	until := time.Now().Add(time.Duration(req.Minutes) * time.Minute)
+	if req.Until != nil {
+		until = *req.Until
+	}
+
+	reminder, err := h.Service.Snooze(uint(id), userID, until)
+	if err != nil {
+		reminderError(c, err, "Reminder not found", "Failed to snooze reminder")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    reminder,
+		"message": "Reminder snoozed",
+	})
+}
+
+// reminderError answers a failed reminder change.
+func reminderError(c *gin.Context, err error, notFound, failed string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": notFound,
+			},
+		})
+	case errors.Is(err, rrule.ErrInvalid), errors.Is(err, services.ErrInvalidTimezone),
+		errors.Is(err, services.ErrReminderInPast), errors.Is(err, services.ErrSnoozeTooLong),
+		errors.Is(err, services.ErrRecurrenceEnded):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": failed,
+			},
+		})
+	}
+}
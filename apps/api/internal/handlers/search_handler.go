@@ -1,122 +1,191 @@
-package handlers
-
-import (
-	"net/http"
-
-	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
-
-	"desis-keep/apps/api/internal/models"
-)
-
-// SearchHandler handles cross-resource search.
-type SearchHandler struct {
-	DB *gorm.DB
-}
-
-// NewSearchHandler creates a new SearchHandler instance.
-func NewSearchHandler(db *gorm.DB) *SearchHandler {
-	return &SearchHandler{DB: db}
-}
-
-// SearchResult represents a unified search result.
-type SearchResult struct {
-	ID        uint   `json:"id"`
-	Type      string `json:"type"` // "note", "link", "image", "file"
-	Title     string `json:"title"`
-	Content   string `json:"content"`
-	URL       string `json:"url,omitempty"`
-	CreatedAt string `json:"created_at"`
-}
-
-// Search performs a cross-resource search.
-func (h *SearchHandler) Search(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	query := c.Query("q")
-
-	if query == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"code":    "VALIDATION_ERROR",
-				"message": "Search query is required",
-			},
-		})
-		return
-	}
-
-	var results []SearchResult
-
-	// Search Notes
-	var notes []models.Note
-	h.DB.Where("user_id = ? AND is_trashed = ? AND (title ILIKE ? OR body ILIKE ?)", 
-		userID, false, "%"+query+"%", "%"+query+"%").
-		Limit(20).Find(&notes)
-	
-	for _, note := range notes {
-		results = append(results, SearchResult{
-			ID:        note.ID,
-			Type:      "note",
-			Title:     note.Title,
-			Content:   note.Body,
-			CreatedAt: note.CreatedAt.Format("2006-01-02T15:04:05Z"),
-		})
-	}
-
-	// Search Links
-	var links []models.Link
-	h.DB.Where("user_id = ? AND is_trashed = ? AND (title ILIKE ? OR url ILIKE ? OR description ILIKE ?)", 
-		userID, false, "%"+query+"%", "%"+query+"%", "%"+query+"%").
-		Limit(20).Find(&links)
-	
-	for _, link := range links {
-		results = append(results, SearchResult{
-			ID:        link.ID,
-			Type:      "link",
-			Title:     link.Title,
-			Content:   link.Description,
-			URL:       link.URL,
-			CreatedAt: link.CreatedAt.Format("2006-01-02T15:04:05Z"),
-		})
-	}
-
-	// Search Images
-	var images []models.Image
-	h.DB.Where("user_id = ? AND is_trashed = ? AND title ILIKE ?", 
-		userID, false, "%"+query+"%").
-		Limit(20).Find(&images)
-	
-	for _, image := range images {
-		results = append(results, SearchResult{
-			ID:        image.ID,
-			Type:      "image",
-			Title:     image.Title,
-			URL:       image.URL,
-			CreatedAt: image.CreatedAt.Format("2006-01-02T15:04:05Z"),
-		})
-	}
-
-	// Search Files
-	var files []models.File
-	h.DB.Where("user_id = ? AND is_trashed = ? AND (title ILIKE ? OR original_name ILIKE ?)", 
-		userID, false, "%"+query+"%", "%"+query+"%").
-		Limit(20).Find(&files)
-	
-	for _, file := range files {
-		results = append(results, SearchResult{
-			ID:        file.ID,
-			Type:      "file",
-			Title:     file.Title,
-			Content:   file.OriginalName,
-			URL:       file.URL,
-			CreatedAt: file.CreatedAt.Format("2006-01-02T15:04:05Z"),
-		})
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"data": results,
-		"meta": gin.H{
-			"query": query,
-			"total": len(results),
-		},
-	})
-}
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/ai"
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/querylang"
+	"desis-keep/apps/api/internal/services"
+)
+
+// SearchHandler handles cross-resource search.
+type SearchHandler struct {
+	DB         *gorm.DB
+	Service    *services.SearchService
+	Embeddings *services.EmbeddingService
+}
+
+// NewSearchHandler creates a new SearchHandler instance.
+// Semantic search is unavailable when aiService has no embedding provider.
+func NewSearchHandler(db *gorm.DB, aiService *ai.AI) *SearchHandler {
+	h := &SearchHandler{
+		DB:      db,
+		Service: services.NewSearchService(db),
+	}
+	if aiService.CanEmbed() {
+		h.Embeddings = services.NewEmbeddingService(db, aiService)
+	}
+	return h
+}
+
+// Search performs a ranked full-text search across notes, links, images and files.
+// Query params: q (query language, see package querylang), type (comma-separated), archived, page, page_size.
+func (h *SearchHandler) Search(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	query := strings.TrimSpace(c.Query("q"))
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	parsed, err := querylang.Parse(query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_QUERY",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+	if parsed.Empty() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "Search query is required",
+			},
+		})
+		return
+	}
+
+	types, ok := parseTypes(c, models.ResourceTypes)
+	if !ok {
+		return
+	}
+
+	result, err := h.Service.Search(userID, services.SearchParams{
+		Query:           parsed,
+		Types:           types,
+		IncludeArchived: c.Query("archived") == "true",
+		Page:            page,
+		PageSize:        pageSize,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to search",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": result.Results,
+		"meta": gin.H{
+			"query":     query,
+			"total":     result.Total,
+			"counts":    result.Counts,
+			"page":      page,
+			"page_size": pageSize,
+			"pages":     result.Pages,
+		},
+	})
+}
+
+// Semantic returns the resources nearest in meaning to a natural-language query.
+// Query params: q, type (comma-separated note, link, file), archived, limit.
+func (h *SearchHandler) Semantic(c *gin.Context) {
+	if h.Embeddings == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": gin.H{
+				"code":    "AI_UNAVAILABLE",
+				"message": "AI service is not configured",
+			},
+		})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	query := strings.TrimSpace(c.Query("q"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "Search query is required",
+			},
+		})
+		return
+	}
+
+	types, ok := parseTypes(c, models.EmbeddedResourceTypes)
+	if !ok {
+		return
+	}
+
+	results, err := h.Embeddings.Search(c.Request.Context(), userID, services.SemanticParams{
+		Query:           query,
+		Types:           types,
+		IncludeArchived: c.Query("archived") == "true",
+		Limit:           limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "AI_ERROR",
+				"message": "Failed to run semantic search",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": results,
+		"meta": gin.H{
+			"query": query,
+			"model": h.Embeddings.AI.EmbeddingModel(),
+			"limit": limit,
+		},
+	})
+}
+
+// parseTypes reads the comma-separated type query param, allowing only the given types.
+// It writes a 400 response and returns false when an unknown type is requested.
+func parseTypes(c *gin.Context, allowed []string) ([]string, bool) {
+	raw := c.Query("type")
+	if raw == "" {
+		return nil, true
+	}
+
+	var types []string
+	for _, t := range strings.Split(raw, ",") {
+		t = strings.TrimSpace(t)
+		if !slices.Contains(allowed, t) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"code":    "VALIDATION_ERROR",
+					"message": "Unknown resource type: " + t,
+				},
+			})
+			return nil, false
+		}
+		types = append(types, t)
+	}
+	return types, true
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/jobs"
+	"desis-keep/apps/api/internal/services"
+)
+
+// ShareHandler handles sharing resources with other users.
+type ShareHandler struct {
+	DB      *gorm.DB
+	Service *services.ShareService
+	Jobs    *jobs.Client
+}
+
+// NewShareHandler creates a new ShareHandler instance.
+func NewShareHandler(db *gorm.DB, jobClient *jobs.Client) *ShareHandler {
+	return &ShareHandler{
+		DB:      db,
+		Service: services.NewShareService(db),
+		Jobs:    jobClient,
+	}
+}
+
+// Create shares one of the user's notes, links, images, files or labels with
+// an email address and emails an invitation. Sharing the same resource with
+// the same address again changes the permission without a new invitation.
+func (h *ShareHandler) Create(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req struct {
+		ResourceType string `json:"resource_type" binding:"required"`
+		ResourceID   uint   `json:"resource_id" binding:"required"`
+		Email        string `json:"email" binding:"required,email"`
+		Permission   string `json:"permission"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	share, created, err := h.Service.Invite(userID, services.ShareInput{
+		ResourceType: req.ResourceType,
+		ResourceID:   req.ResourceID,
+		Email:        req.Email,
+		Permission:   req.Permission,
+	})
+	if err != nil {
+		shareError(c, err, "Resource not found", "Failed to share")
+		return
+	}
+
+	if !created {
+		c.JSON(http.StatusOK, gin.H{
+			"data":    share,
+			"message": "Share updated successfully",
+		})
+		return
+	}
+	enqueueShareInvite(h.Jobs, share.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data":    share,
+		"message": "Invitation sent",
+	})
+}
+
+// List returns the shares the user made, optionally only those of one
+// resource (resource_type and resource_id).
+func (h *ShareHandler) List(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	resourceID, _ := strconv.ParseUint(c.Query("resource_id"), 10, 64)
+
+	shares, err := h.Service.List(userID, c.Query("resource_type"), uint(resourceID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to fetch shares",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": shares,
+	})
+}
+
+// Received returns what other users shared with the user.
+func (h *ShareHandler) Received(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	shares, err := h.Service.Received(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to fetch shares",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": shares,
+	})
+}
+
+// Update changes the permission of one of the user's shares.
+func (h *ShareHandler) Update(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, ok := shareParam(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Permission string `json:"permission" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	share, err := h.Service.SetPermission(id, userID, req.Permission)
+	if err != nil {
+		shareError(c, err, "Share not found", "Failed to update share")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    share,
+		"message": "Share updated successfully",
+	})
+}
+
+// Delete revokes a share the user made, or leaves one made with them.
+func (h *ShareHandler) Delete(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	id, ok := shareParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Service.Revoke(id, userID); err != nil {
+		shareError(c, err, "Share not found", "Failed to revoke share")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Share revoked successfully",
+	})
+}
+
+// shareParam reads the share ID from the path, answering 400 when it is invalid.
+func shareParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid share ID",
+			},
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// shareError answers a failed share request.
+func shareError(c *gin.Context, err error, notFound, failed string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "NOT_FOUND",
+				"message": notFound,
+			},
+		})
+	case errors.Is(err, services.ErrShareSelf),
+		errors.Is(err, services.ErrInvalidPermission),
+		errors.Is(err, services.ErrUnshareableType):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": err.Error(),
+			},
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": failed,
+			},
+		})
+	}
+}
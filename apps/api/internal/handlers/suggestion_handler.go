@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/ai"
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/services"
+)
+
+// SuggestionHandler handles AI label suggestion endpoints.
+type SuggestionHandler struct {
+	DB      *gorm.DB
+	Service *services.SuggestionService
+}
+
+// NewSuggestionHandler creates a new SuggestionHandler instance.
+func NewSuggestionHandler(db *gorm.DB, aiService *ai.AI) *SuggestionHandler {
+	return &SuggestionHandler{
+		DB:      db,
+		Service: services.NewSuggestionService(db, aiService),
+	}
+}
+
+// List returns the authenticated user's label suggestions.
+// Query params: type, resource_id, status (pending by default, "all" for every status).
+func (h *SuggestionHandler) List(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	resourceType := c.Query("type")
+	resourceID, _ := strconv.ParseUint(c.Query("resource_id"), 10, 64)
+	status := c.DefaultQuery("status", models.SuggestionPending)
+	if status == "all" {
+		status = ""
+	}
+
+	if resourceType != "" && !slices.Contains(models.ResourceTypes, resourceType) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "Unknown resource type: " + resourceType,
+			},
+		})
+		return
+	}
+
+	suggestions, err := h.Service.List(userID, resourceType, uint(resourceID), status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to fetch suggestions",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": suggestions,
+	})
+}
+
+// Accept applies a suggested label to its resource.
+func (h *SuggestionHandler) Accept(c *gin.Context) {
+	h.resolve(c, h.Service.Accept, "Suggestion accepted")
+}
+
+// Reject dismisses a suggested label so it is not suggested again.
+func (h *SuggestionHandler) Reject(c *gin.Context) {
+	h.resolve(c, h.Service.Reject, "Suggestion rejected")
+}
+
+// resolve runs an accept or reject action and writes the response.
+func (h *SuggestionHandler) resolve(c *gin.Context, action func(id, userID uint) (*models.LabelSuggestion, error), message string) {
+	userID := c.GetUint("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "INVALID_ID",
+				"message": "Invalid suggestion ID",
+			},
+		})
+		return
+	}
+
+	suggestion, err := action(uint(id), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSuggestionResolved):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": gin.H{
+					"code":    "ALREADY_RESOLVED",
+					"message": "Suggestion was already accepted or rejected",
+				},
+			})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": gin.H{
+					"code":    "NOT_FOUND",
+					"message": "Suggestion not found",
+				},
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": gin.H{
+					"code":    "INTERNAL_ERROR",
+					"message": "Failed to update suggestion",
+				},
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    suggestion,
+		"message": message,
+	})
+}
@@ -215,8 +215,10 @@ func (h *UserHandler) Update(c *gin.Context) {
 	if req.LastName != "" {
 		updates["last_name"] = req.LastName
 	}
-	if req.Email != "" {
+	if req.Email != "" && req.Email != user.Email {
+		// The new address has to be verified before shares sent to it apply
 		updates["email"] = req.Email
+		updates["email_verified_at"] = nil
 	}
 	if req.Password != "" {
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
@@ -358,8 +360,10 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	if req.LastName != "" {
 		updates["last_name"] = req.LastName
 	}
-	if req.Email != "" {
+	if req.Email != "" && req.Email != user.Email {
+		// The new address has to be verified before shares sent to it apply
 		updates["email"] = req.Email
+		updates["email_verified_at"] = nil
 	}
 	if req.Password != "" {
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ifMatch returns the version of a resource an update was based on: the ETag
+// in the If-Match header, or else the request's version field. It answers
+// 428 when there is neither, and 400 when the header holds no ETag of ours.
+// "If-Match: *" returns 0, which updates the resource whatever its version.
+// Weak ETags (W/"3") are accepted as their version too, since proxies that
+// compress responses turn ours into weak ones.
+func ifMatch(c *gin.Context, version *uint) (uint, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "*" {
+		return 0, true
+	}
+	if header != "" {
+		n, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(header, "W/"), `"`), 10, 64)
+		if err != nil || n == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"code":    "INVALID_ETAG",
+					"message": "If-Match must be an ETag returned by the API",
+				},
+			})
+			return 0, false
+		}
+		return uint(n), true
+	}
+	if version != nil && *version != 0 {
+		return *version, true
+	}
+	c.JSON(http.StatusPreconditionRequired, gin.H{
+		"error": gin.H{
+			"code":    "PRECONDITION_REQUIRED",
+			"message": "Send the version you are updating in an If-Match header or a version field",
+		},
+	})
+	return 0, false
+}
+
+// setETag sends a resource's version as its ETag.
+func setETag(c *gin.Context, version uint) {
+	c.Header("ETag", fmt.Sprintf(`"%d"`, version))
+}
+
+// versionConflict answers 409 with the current copy of a resource that was
+// changed since the client read it.
+func versionConflict(c *gin.Context, current interface{}, version uint) {
+	setETag(c, version)
+	c.JSON(http.StatusConflict, gin.H{
+		"error": gin.H{
+			"code":    "VERSION_CONFLICT",
+			"message": "This was changed since you loaded it; review the current version and try again",
+		},
+		"data": current,
+	})
+}
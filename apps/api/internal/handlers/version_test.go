@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIfMatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	three := uint(3)
+
+	tests := []struct {
+		header  string
+		version *uint
+		want    uint
+		status  int
+	}{
+		{`"7"`, nil, 7, 0},
+		{`W/"7"`, nil, 7, 0},
+		{` "7" `, &three, 7, 0},
+		{"*", nil, 0, 0},
+		{"", &three, 3, 0},
+		{"", nil, 0, http.StatusPreconditionRequired},
+		{`"0"`, nil, 0, http.StatusBadRequest},
+		{`"abc"`, nil, 0, http.StatusBadRequest},
+		{`W/"x"`, nil, 0, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPut, "/", nil)
+		if tt.header != "" {
+			c.Request.Header.Set("If-Match", tt.header)
+		}
+
+		got, ok := ifMatch(c, tt.version)
+		if ok != (tt.status == 0) || got != tt.want {
+			t.Errorf("If-Match %q: got %d, %v; want %d, %v", tt.header, got, ok, tt.want, tt.status == 0)
+		}
+		if tt.status != 0 && w.Code != tt.status {
+			t.Errorf("If-Match %q: status %d, want %d", tt.header, w.Code, tt.status)
+		}
+	}
+}
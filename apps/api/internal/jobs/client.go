@@ -2,7 +2,9 @@ package jobs
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/hibiken/asynq"
 )
@@ -11,9 +13,27 @@ import (
 const (
 	TypeEmailSend     = "email:send"
 	TypeImageProcess  = "image:process"
+	TypeFileText      = "file:extract-text"
 	TypeTokensCleanup = "tokens:cleanup"
+	TypeAIEmbed       = "ai:embed"
+	TypeAIEnrich      = "ai:enrich"
+	TypeLinkMetadata  = "link:fetch-metadata"
+	TypeLinkSnapshot  = "link:snapshot"
+	TypeLinksHealth   = "links:check-health"
+	TypeLinksDedupe   = "links:dedupe"
+	TypeImportRun     = "import:run"
+	TypeExportAccount = "export:account"
+	TypeExportCleanup = "exports:cleanup"
+
+	TypeRemindersDispatch = "reminders:dispatch"
+	TypeReminderNotify    = "reminder:notify"
+	TypeRevisionsPrune    = "revisions:prune"
+	TypeShareInvite       = "share:invite"
 )
 
+// enrichDelay debounces AI enrichment so a burst of saves triggers a single completion.
+const enrichDelay = 30 * time.Second
+
 // Client wraps asynq.Client for enqueuing background jobs.
 type Client struct {
 	client *asynq.Client
@@ -50,6 +70,60 @@ type ImagePayload struct {
 	MimeType string `json:"mime_type"`
 }
 
+// FileTextPayload holds the data for a file text extraction job.
+type FileTextPayload struct {
+	FileID uint `json:"file_id"`
+}
+
+// EmbedPayload holds the data for a resource embedding job.
+type EmbedPayload struct {
+	ResourceType string `json:"resource_type"`
+	ResourceID   uint   `json:"resource_id"`
+}
+
+// EnrichPayload holds the data for an AI summary and label suggestion job.
+type EnrichPayload struct {
+	ResourceType string `json:"resource_type"`
+	ResourceID   uint   `json:"resource_id"`
+}
+
+// LinkMetadataPayload holds the data for a link metadata fetch job.
+type LinkMetadataPayload struct {
+	LinkID uint `json:"link_id"`
+}
+
+// LinkSnapshotPayload holds the data for a link snapshot job.
+type LinkSnapshotPayload struct {
+	LinkID uint `json:"link_id"`
+}
+
+// ImportPayload holds the data for an import job.
+type ImportPayload struct {
+	ImportID uint `json:"import_id"`
+}
+
+// ExportPayload holds the data for an account export job.
+type ExportPayload struct {
+	ExportID uint `json:"export_id"`
+}
+
+// ReminderPayload holds the data for a reminder notification job.
+type ReminderPayload struct {
+	ReminderID uint      `json:"reminder_id"`
+	DueAt      time.Time `json:"due_at"`    // the occurrence being notified
+	NotifyAt   time.Time `json:"notify_at"` // when it fired, which differs from DueAt after a snooze
+}
+
+// SharePayload holds the data for a share invitation job.
+type SharePayload struct {
+	ShareID uint `json:"share_id"`
+}
+
+// LinksDedupePayload holds the data for a duplicate link merge job.
+type LinksDedupePayload struct {
+	UserID uint `json:"user_id,omitempty"` // 0 covers every user
+}
+
 // EnqueueSendEmail enqueues an email send job.
 func (c *Client) EnqueueSendEmail(to, subject, template string, data map[string]interface{}) error {
 	payload, err := json.Marshal(EmailPayload{
@@ -89,6 +163,22 @@ func (c *Client) EnqueueProcessImage(uploadID uint, key, mimeType string) error
 	return nil
 }
 
+// EnqueueExtractFileText enqueues a job that extracts the text of an uploaded
+// file for search.
+func (c *Client) EnqueueExtractFileText(fileID uint) error {
+	payload, err := json.Marshal(FileTextPayload{FileID: fileID})
+	if err != nil {
+		return fmt.Errorf("marshaling file text payload: %w", err)
+	}
+
+	task := asynq.NewTask(TypeFileText, payload)
+	_, err = c.client.Enqueue(task, asynq.MaxRetry(3), asynq.Queue("low"), asynq.Timeout(2*time.Minute))
+	if err != nil {
+		return fmt.Errorf("enqueuing file text job: %w", err)
+	}
+	return nil
+}
+
 // EnqueueTokensCleanup enqueues a token cleanup job.
 func (c *Client) EnqueueTokensCleanup() error {
 	task := asynq.NewTask(TypeTokensCleanup, nil)
@@ -98,3 +188,162 @@ func (c *Client) EnqueueTokensCleanup() error {
 	}
 	return nil
 }
+
+// EnqueueEmbed enqueues a job that (re)embeds a resource for semantic search.
+func (c *Client) EnqueueEmbed(resourceType string, resourceID uint) error {
+	payload, err := json.Marshal(EmbedPayload{
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling embed payload: %w", err)
+	}
+
+	task := asynq.NewTask(TypeAIEmbed, payload)
+	_, err = c.client.Enqueue(task, asynq.MaxRetry(3), asynq.Queue("low"))
+	if err != nil {
+		return fmt.Errorf("enqueuing embed job: %w", err)
+	}
+	return nil
+}
+
+// EnqueueEnrich enqueues a delayed job that summarizes a resource and suggests labels.
+// While a job for the resource is still waiting, further calls are no-ops; the job
+// reads the resource when it runs, so it always sees the latest save.
+func (c *Client) EnqueueEnrich(resourceType string, resourceID uint) error {
+	payload, err := json.Marshal(EnrichPayload{
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling enrich payload: %w", err)
+	}
+
+	task := asynq.NewTask(TypeAIEnrich, payload)
+	_, err = c.client.Enqueue(task,
+		asynq.MaxRetry(2),
+		asynq.Queue("low"),
+		asynq.ProcessIn(enrichDelay),
+		asynq.TaskID(fmt.Sprintf("%s:%s:%d", TypeAIEnrich, resourceType, resourceID)),
+	)
+	if err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		return fmt.Errorf("enqueuing enrich job: %w", err)
+	}
+	return nil
+}
+
+// EnqueueFetchLinkMetadata enqueues a job that fills in a link's title,
+// description, thumbnail and favicon from the page it points to.
+func (c *Client) EnqueueFetchLinkMetadata(linkID uint) error {
+	payload, err := json.Marshal(LinkMetadataPayload{LinkID: linkID})
+	if err != nil {
+		return fmt.Errorf("marshaling link metadata payload: %w", err)
+	}
+
+	task := asynq.NewTask(TypeLinkMetadata, payload)
+	_, err = c.client.Enqueue(task, asynq.MaxRetry(3), asynq.Timeout(time.Minute))
+	if err != nil {
+		return fmt.Errorf("enqueuing link metadata job: %w", err)
+	}
+	return nil
+}
+
+// EnqueueSnapshotLink enqueues a job that archives a readable copy of a link's page.
+func (c *Client) EnqueueSnapshotLink(linkID uint) error {
+	payload, err := json.Marshal(LinkSnapshotPayload{LinkID: linkID})
+	if err != nil {
+		return fmt.Errorf("marshaling link snapshot payload: %w", err)
+	}
+
+	task := asynq.NewTask(TypeLinkSnapshot, payload)
+	_, err = c.client.Enqueue(task, asynq.MaxRetry(3), asynq.Queue("low"), asynq.Timeout(3*time.Minute))
+	if err != nil {
+		return fmt.Errorf("enqueuing link snapshot job: %w", err)
+	}
+	return nil
+}
+
+// EnqueueDedupeLinks enqueues a job that merges duplicate links of one user,
+// or of every user when userID is 0.
+func (c *Client) EnqueueDedupeLinks(userID uint) error {
+	payload, err := json.Marshal(LinksDedupePayload{UserID: userID})
+	if err != nil {
+		return fmt.Errorf("marshaling links dedupe payload: %w", err)
+	}
+
+	task := asynq.NewTask(TypeLinksDedupe, payload)
+	_, err = c.client.Enqueue(task, asynq.MaxRetry(1), asynq.Queue("low"), asynq.Timeout(30*time.Minute))
+	if err != nil {
+		return fmt.Errorf("enqueuing links dedupe job: %w", err)
+	}
+	return nil
+}
+
+// EnqueueImport enqueues a job that runs a stored import.
+func (c *Client) EnqueueImport(importID uint) error {
+	payload, err := json.Marshal(ImportPayload{ImportID: importID})
+	if err != nil {
+		return fmt.Errorf("marshaling import payload: %w", err)
+	}
+
+	task := asynq.NewTask(TypeImportRun, payload)
+	_, err = c.client.Enqueue(task, asynq.MaxRetry(2), asynq.Timeout(time.Hour))
+	if err != nil {
+		return fmt.Errorf("enqueuing import job: %w", err)
+	}
+	return nil
+}
+
+// EnqueueExportAccount enqueues a job that builds an account export archive.
+func (c *Client) EnqueueExportAccount(exportID uint) error {
+	payload, err := json.Marshal(ExportPayload{ExportID: exportID})
+	if err != nil {
+		return fmt.Errorf("marshaling export payload: %w", err)
+	}
+
+	task := asynq.NewTask(TypeExportAccount, payload)
+	_, err = c.client.Enqueue(task, asynq.MaxRetry(2), asynq.Queue("low"), asynq.Timeout(2*time.Hour))
+	if err != nil {
+		return fmt.Errorf("enqueuing export job: %w", err)
+	}
+	return nil
+}
+
+// EnqueueReminder enqueues a job that notifies a user about a due reminder.
+// Each firing is enqueued at most once, so a dispatch that is retried after
+// enqueuing does not notify twice.
+func (c *Client) EnqueueReminder(reminderID uint, dueAt, notifyAt time.Time) error {
+	payload, err := json.Marshal(ReminderPayload{ReminderID: reminderID, DueAt: dueAt, NotifyAt: notifyAt})
+	if err != nil {
+		return fmt.Errorf("marshaling reminder payload: %w", err)
+	}
+
+	task := asynq.NewTask(TypeReminderNotify, payload)
+	_, err = c.client.Enqueue(task,
+		asynq.MaxRetry(5),
+		asynq.Queue("critical"),
+		asynq.Timeout(time.Minute),
+		asynq.TaskID(fmt.Sprintf("%s:%d:%d", TypeReminderNotify, reminderID, notifyAt.Unix())),
+		asynq.Retention(24*time.Hour),
+	)
+	if err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		return fmt.Errorf("enqueuing reminder job: %w", err)
+	}
+	return nil
+}
+
+// EnqueueShareInvite enqueues a job that tells someone about a resource
+// shared with them.
+func (c *Client) EnqueueShareInvite(shareID uint) error {
+	payload, err := json.Marshal(SharePayload{ShareID: shareID})
+	if err != nil {
+		return fmt.Errorf("marshaling share payload: %w", err)
+	}
+
+	task := asynq.NewTask(TypeShareInvite, payload)
+	_, err = c.client.Enqueue(task, asynq.MaxRetry(5), asynq.Queue("default"), asynq.Timeout(time.Minute))
+	if err != nil {
+		return fmt.Errorf("enqueuing share invitation job: %w", err)
+	}
+	return nil
+}
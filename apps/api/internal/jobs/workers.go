@@ -4,17 +4,29 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/hibiken/asynq"
 	"gorm.io/gorm"
 
+	"desis-keep/apps/api/internal/ai"
+	"desis-keep/apps/api/internal/archive"
+	"desis-keep/apps/api/internal/bookmarks"
 	"desis-keep/apps/api/internal/cache"
+	"desis-keep/apps/api/internal/enex"
+	"desis-keep/apps/api/internal/filetext"
 	"desis-keep/apps/api/internal/mail"
 	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/readability"
+	"desis-keep/apps/api/internal/services"
 	"desis-keep/apps/api/internal/storage"
+	"desis-keep/apps/api/internal/takeout"
+	"desis-keep/apps/api/internal/webfetch"
 )
 
 // WorkerDeps holds dependencies needed by job handlers.
@@ -23,8 +35,25 @@ type WorkerDeps struct {
 	Mailer  *mail.Mailer
 	Storage *storage.Storage
 	Cache   *cache.Cache
+	AI      *ai.AI
+	Usage   *services.UsageService // meters AI calls made by jobs; nil disables metering
+	// Jobs queues follow-up work from jobs, such as embedding imported resources; nil skips it.
+	Jobs *Client
+	// UpdateRedirectedLinks lets the link health check rewrite permanently redirected URLs.
+	UpdateRedirectedLinks bool
+	// AppName is shown in emails sent by jobs.
+	AppName string
+	// FrontendURL is where the admin app is served, for links in emails.
+	FrontendURL string
 }
 
+const (
+	// linkHealthBatch is how many links the health check loads at a time.
+	linkHealthBatch = 100
+	// linkHealthMaxBatches caps one health check run; remaining links wait for the next run.
+	linkHealthMaxBatches = 10
+)
+
 // StartWorker starts the asynq worker server in a goroutine.
 // Returns a stop function and any startup error.
 func StartWorker(redisURL string, deps WorkerDeps) (func(), error) {
@@ -45,7 +74,21 @@ func StartWorker(redisURL string, deps WorkerDeps) (func(), error) {
 	mux := asynq.NewServeMux()
 	mux.HandleFunc(TypeEmailSend, handleEmailSend(deps))
 	mux.HandleFunc(TypeImageProcess, handleImageProcess(deps))
+	mux.HandleFunc(TypeFileText, handleFileText(deps))
 	mux.HandleFunc(TypeTokensCleanup, handleTokensCleanup(deps))
+	mux.HandleFunc(TypeAIEmbed, handleAIEmbed(deps))
+	mux.HandleFunc(TypeAIEnrich, handleAIEnrich(deps))
+	mux.HandleFunc(TypeLinkMetadata, handleLinkMetadata(deps))
+	mux.HandleFunc(TypeLinkSnapshot, handleLinkSnapshot(deps))
+	mux.HandleFunc(TypeLinksHealth, handleLinksHealth(deps))
+	mux.HandleFunc(TypeLinksDedupe, handleLinksDedupe(deps))
+	mux.HandleFunc(TypeImportRun, handleImportRun(deps))
+	mux.HandleFunc(TypeExportAccount, handleExportAccount(deps))
+	mux.HandleFunc(TypeExportCleanup, handleExportCleanup(deps))
+	mux.HandleFunc(TypeRemindersDispatch, handleRemindersDispatch(deps))
+	mux.HandleFunc(TypeRevisionsPrune, handleRevisionsPrune(deps))
+	mux.HandleFunc(TypeShareInvite, handleShareInvite(deps))
+	mux.HandleFunc(TypeReminderNotify, handleReminderNotify(deps))
 
 	go func() {
 		if err := srv.Run(mux); err != nil {
@@ -141,3 +184,494 @@ func handleTokensCleanup(deps WorkerDeps) func(ctx context.Context, task *asynq.
 		return nil
 	}
 }
+
+func handleFileText(deps WorkerDeps) func(ctx context.Context, task *asynq.Task) error {
+	return func(ctx context.Context, task *asynq.Task) error {
+		if deps.DB == nil {
+			return fmt.Errorf("database not configured")
+		}
+		if deps.Storage == nil {
+			return fmt.Errorf("storage not configured: %w", asynq.SkipRetry)
+		}
+
+		var payload FileTextPayload
+		if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+			return fmt.Errorf("unmarshaling file text payload: %w", err)
+		}
+
+		err := services.NewFileTextService(deps.DB, deps.Storage).Extract(ctx, payload.FileID)
+		if errors.Is(err, filetext.ErrInvalidPDF) || errors.Is(err, filetext.ErrEncrypted) || errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("file %d: %v: %w", payload.FileID, err, asynq.SkipRetry)
+		}
+		if err != nil {
+			return err
+		}
+
+		// The contents feed semantic search too
+		if deps.AI.CanEmbed() {
+			if err := services.NewEmbeddingService(deps.DB, deps.AI).Embed(ctx, models.ResourceTypeFile, payload.FileID); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+		return nil
+	}
+}
+
+func handleAIEmbed(deps WorkerDeps) func(ctx context.Context, task *asynq.Task) error {
+	return func(ctx context.Context, task *asynq.Task) error {
+		if deps.DB == nil {
+			return fmt.Errorf("database not configured")
+		}
+		if !deps.AI.CanEmbed() {
+			return fmt.Errorf("embedding provider not configured")
+		}
+
+		var payload EmbedPayload
+		if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+			return fmt.Errorf("unmarshaling embed payload: %w", err)
+		}
+
+		return services.NewEmbeddingService(deps.DB, deps.AI).Embed(ctx, payload.ResourceType, payload.ResourceID)
+	}
+}
+
+func handleAIEnrich(deps WorkerDeps) func(ctx context.Context, task *asynq.Task) error {
+	return func(ctx context.Context, task *asynq.Task) error {
+		if deps.DB == nil {
+			return fmt.Errorf("database not configured")
+		}
+		if !deps.AI.CanComplete() {
+			// Nothing to retry until a completion provider is configured
+			return fmt.Errorf("completion provider not configured: %w", asynq.SkipRetry)
+		}
+
+		var payload EnrichPayload
+		if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+			return fmt.Errorf("unmarshaling enrich payload: %w", err)
+		}
+
+		suggestions := services.NewSuggestionService(deps.DB, deps.AI)
+		suggestions.Usage = deps.Usage
+		return suggestions.Enrich(ctx, payload.ResourceType, payload.ResourceID)
+	}
+}
+
+func handleLinkMetadata(deps WorkerDeps) func(ctx context.Context, task *asynq.Task) error {
+	return func(ctx context.Context, task *asynq.Task) error {
+		if deps.DB == nil {
+			return fmt.Errorf("database not configured")
+		}
+
+		var payload LinkMetadataPayload
+		if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+			return fmt.Errorf("unmarshaling link metadata payload: %w", err)
+		}
+
+		link, err := services.NewLinkMetadataService(deps.DB, deps.Storage).Fetch(ctx, payload.LinkID)
+		if errors.Is(err, webfetch.ErrBlockedAddress) || errors.Is(err, webfetch.ErrInvalidURL) {
+			// The URL will never be fetchable; retrying only repeats the refusal
+			return fmt.Errorf("link %d: %v: %w", payload.LinkID, err, asynq.SkipRetry)
+		}
+		if err != nil || link == nil {
+			return err
+		}
+
+		// The page title and description feed semantic search
+		if deps.AI.CanEmbed() {
+			if err := services.NewEmbeddingService(deps.DB, deps.AI).Embed(ctx, models.ResourceTypeLink, link.ID); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+		return nil
+	}
+}
+
+func handleLinkSnapshot(deps WorkerDeps) func(ctx context.Context, task *asynq.Task) error {
+	return func(ctx context.Context, task *asynq.Task) error {
+		if deps.DB == nil {
+			return fmt.Errorf("database not configured")
+		}
+		if deps.Storage == nil {
+			return fmt.Errorf("storage not configured: %w", asynq.SkipRetry)
+		}
+
+		var payload LinkSnapshotPayload
+		if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+			return fmt.Errorf("unmarshaling link snapshot payload: %w", err)
+		}
+
+		err := services.NewLinkSnapshotService(deps.DB, deps.Storage).Capture(ctx, payload.LinkID)
+		if errors.Is(err, webfetch.ErrBlockedAddress) || errors.Is(err, webfetch.ErrInvalidURL) || errors.Is(err, readability.ErrNoContent) {
+			return fmt.Errorf("link %d: %v: %w", payload.LinkID, err, asynq.SkipRetry)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Snapshot text feeds semantic search too
+		if deps.AI.CanEmbed() {
+			if err := services.NewEmbeddingService(deps.DB, deps.AI).Embed(ctx, models.ResourceTypeLink, payload.LinkID); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+		return nil
+	}
+}
+
+func handleLinksHealth(deps WorkerDeps) func(ctx context.Context, task *asynq.Task) error {
+	return func(ctx context.Context, task *asynq.Task) error {
+		if deps.DB == nil {
+			return fmt.Errorf("database not configured")
+		}
+
+		log.Println("Checking link health...")
+
+		health := services.NewLinkHealthService(deps.DB, deps.UpdateRedirectedLinks)
+		total := 0
+		for i := 0; i < linkHealthMaxBatches; i++ {
+			checked, err := health.CheckDue(ctx, linkHealthBatch)
+			total += checked
+			if err != nil {
+				return fmt.Errorf("checking link health: %w", err)
+			}
+			if checked < linkHealthBatch {
+				break
+			}
+		}
+
+		log.Printf("Link health check complete, checked %d links", total)
+		return nil
+	}
+}
+
+func handleLinksDedupe(deps WorkerDeps) func(ctx context.Context, task *asynq.Task) error {
+	return func(ctx context.Context, task *asynq.Task) error {
+		if deps.DB == nil {
+			return fmt.Errorf("database not configured")
+		}
+
+		var payload LinksDedupePayload
+		if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+			return fmt.Errorf("unmarshaling links dedupe payload: %w", err)
+		}
+
+		log.Println("Merging duplicate links...")
+
+		result, err := services.NewLinkDedupeService(deps.DB, deps.Storage).Dedupe(ctx, payload.UserID)
+		if err != nil {
+			return fmt.Errorf("merging duplicate links: %w", err)
+		}
+
+		log.Printf("Link dedupe complete, backfilled %d canonical URLs, merged %d links in %d groups",
+			result.Backfilled, result.Removed, result.Groups)
+		return nil
+	}
+}
+
+func handleImportRun(deps WorkerDeps) func(ctx context.Context, task *asynq.Task) error {
+	return func(ctx context.Context, task *asynq.Task) error {
+		if deps.DB == nil {
+			return fmt.Errorf("database not configured")
+		}
+		if deps.Storage == nil {
+			return fmt.Errorf("storage not configured: %w", asynq.SkipRetry)
+		}
+
+		var payload ImportPayload
+		if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+			return fmt.Errorf("unmarshaling import payload: %w", err)
+		}
+
+		importer := services.NewImportService(deps.DB, deps.Storage)
+		if deps.Jobs != nil {
+			importer.AfterCreate = func(resourceType string, id uint, title string) {
+				if err := deps.Jobs.EnqueueEmbed(resourceType, id); err != nil {
+					log.Printf("Warning: %v", err)
+				}
+				if resourceType == models.ResourceTypeFile {
+					if err := deps.Jobs.EnqueueExtractFileText(id); err != nil {
+						log.Printf("Warning: %v", err)
+					}
+				}
+				if resourceType == models.ResourceTypeLink && title == "" {
+					if err := deps.Jobs.EnqueueFetchLinkMetadata(id); err != nil {
+						log.Printf("Warning: %v", err)
+					}
+				}
+			}
+		}
+
+		err := importer.Run(ctx, payload.ImportID)
+		if errors.Is(err, bookmarks.ErrNotBookmarkFile) || errors.Is(err, takeout.ErrNoKeepNotes) ||
+			errors.Is(err, enex.ErrNotENEX) || errors.Is(err, archive.ErrNotAccountArchive) ||
+			errors.Is(err, archive.ErrUnsupportedVersion) || errors.Is(err, services.ErrUnknownImportKind) {
+			// The file will not parse any better next time
+			return fmt.Errorf("import %d: %v: %w", payload.ImportID, err, asynq.SkipRetry)
+		}
+		return err
+	}
+}
+
+func handleExportAccount(deps WorkerDeps) func(ctx context.Context, task *asynq.Task) error {
+	return func(ctx context.Context, task *asynq.Task) error {
+		if deps.DB == nil {
+			return fmt.Errorf("database not configured")
+		}
+		if deps.Storage == nil {
+			return fmt.Errorf("storage not configured: %w", asynq.SkipRetry)
+		}
+
+		var payload ExportPayload
+		if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+			return fmt.Errorf("unmarshaling export payload: %w", err)
+		}
+
+		exporter := services.NewAccountExportService(deps.DB, deps.Storage)
+		job, err := exporter.Run(ctx, payload.ExportID)
+		if err != nil || job == nil {
+			return err
+		}
+
+		log.Printf("Export %d ready: %d bytes", job.ID, job.SizeBytes)
+		if deps.Mailer == nil {
+			log.Printf("Warning: mailer not configured, export %d ready without email", job.ID)
+			return nil
+		}
+
+		var user models.User
+		if err := deps.DB.First(&user, job.UserID).Error; err != nil {
+			return fmt.Errorf("loading user %d: %w", job.UserID, err)
+		}
+		url, err := exporter.DownloadURL(ctx, job)
+		if err != nil {
+			return err
+		}
+		return deps.Mailer.Send(ctx, mail.SendOptions{
+			To:       user.Email,
+			Subject:  "Your data export is ready",
+			Template: "notification",
+			Data: map[string]interface{}{
+				"AppName":    deps.AppName,
+				"Year":       time.Now().Year(),
+				"Title":      "Your data export is ready",
+				"Message":    fmt.Sprintf("Your notes, links, labels, images and files have been packed into a zip archive. The download link works until %s.", job.ExpiresAt.UTC().Format("January 2, 2006 15:04 MST")),
+				"ActionURL":  url,
+				"ActionText": "Download archive",
+			},
+		})
+	}
+}
+
+func handleExportCleanup(deps WorkerDeps) func(ctx context.Context, task *asynq.Task) error {
+	return func(ctx context.Context, task *asynq.Task) error {
+		if deps.DB == nil {
+			return fmt.Errorf("database not configured")
+		}
+		if deps.Storage == nil {
+			return nil
+		}
+
+		expired, err := services.NewAccountExportService(deps.DB, deps.Storage).CleanupExpired(ctx)
+		if expired > 0 {
+			log.Printf("Export cleanup complete, deleted %d archives", expired)
+		}
+		return err
+	}
+}
+
+func handleRevisionsPrune(deps WorkerDeps) func(ctx context.Context, task *asynq.Task) error {
+	return func(ctx context.Context, task *asynq.Task) error {
+		if deps.DB == nil {
+			return fmt.Errorf("database not configured")
+		}
+
+		deleted, err := services.NewNoteService(deps.DB).PruneRevisions()
+		if deleted > 0 {
+			log.Printf("Revision pruning complete, deleted %d revisions", deleted)
+		}
+		return err
+	}
+}
+
+func handleRemindersDispatch(deps WorkerDeps) func(ctx context.Context, task *asynq.Task) error {
+	return func(ctx context.Context, task *asynq.Task) error {
+		if deps.DB == nil {
+			return fmt.Errorf("database not configured")
+		}
+		if deps.Jobs == nil {
+			return fmt.Errorf("job client not configured: %w", asynq.SkipRetry)
+		}
+
+		sent, err := services.NewReminderService(deps.DB).Dispatch(time.Now(), func(r *models.Reminder) error {
+			return deps.Jobs.EnqueueReminder(r.ID, r.DueAt, r.NotifyAt)
+		})
+		if sent > 0 {
+			log.Printf("Reminders dispatched: %d", sent)
+		}
+		return err
+	}
+}
+
+func handleReminderNotify(deps WorkerDeps) func(ctx context.Context, task *asynq.Task) error {
+	return func(ctx context.Context, task *asynq.Task) error {
+		if deps.DB == nil {
+			return fmt.Errorf("database not configured")
+		}
+
+		var payload ReminderPayload
+		if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+			return fmt.Errorf("unmarshaling reminder payload: %w", err)
+		}
+
+		var reminder models.Reminder
+		err := deps.DB.Preload("Note").Where("id = ?", payload.ReminderID).Limit(1).Find(&reminder).Error
+		if err != nil {
+			return fmt.Errorf("loading reminder %d: %w", payload.ReminderID, err)
+		}
+		if reminder.ID == 0 || reminder.Note == nil {
+			// Removed, or its note deleted, since it fired
+			return nil
+		}
+		note := reminder.Note
+
+		loc, err := time.LoadLocation(reminder.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		title := note.Title
+		if title == "" {
+			title = "Untitled note"
+		}
+		message := "Due " + payload.DueAt.In(loc).Format("Monday, January 2, 2006 at 15:04 MST")
+		if excerpt := strings.TrimSpace(note.Body); excerpt != "" {
+			if runes := []rune(excerpt); len(runes) > 300 {
+				excerpt = string(runes[:300]) + "…"
+			}
+			message += "\n\n" + excerpt
+		}
+
+		notification := models.Notification{
+			UserID:       reminder.UserID,
+			Key:          fmt.Sprintf("reminder:%d:%d", reminder.ID, payload.NotifyAt.Unix()),
+			Type:         models.NotificationReminder,
+			Title:        title,
+			Message:      message,
+			ResourceType: models.ResourceTypeNote,
+			ResourceID:   note.ID,
+		}
+		created, err := services.NewNotificationService(deps.DB).Create(&notification)
+		if err != nil {
+			return err
+		}
+		if created {
+			log.Printf("Reminder %d delivered for note %d", reminder.ID, note.ID)
+		}
+
+		if deps.Mailer == nil {
+			return nil
+		}
+		var user models.User
+		if err := deps.DB.First(&user, reminder.UserID).Error; err != nil {
+			return fmt.Errorf("loading user %d: %w", reminder.UserID, err)
+		}
+		data := map[string]interface{}{
+			"AppName": deps.AppName,
+			"Year":    time.Now().Year(),
+			"Title":   title,
+			"Message": message,
+		}
+		if deps.FrontendURL != "" {
+			data["ActionURL"] = deps.FrontendURL + "/board"
+			data["ActionText"] = "Open notes"
+		}
+		return deps.Mailer.Send(ctx, mail.SendOptions{
+			To:       user.Email,
+			Subject:  "Reminder: " + title,
+			Template: "notification",
+			Data:     data,
+		})
+	}
+}
+
+func handleShareInvite(deps WorkerDeps) func(ctx context.Context, task *asynq.Task) error {
+	return func(ctx context.Context, task *asynq.Task) error {
+		if deps.DB == nil {
+			return fmt.Errorf("database not configured")
+		}
+
+		var payload SharePayload
+		if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+			return fmt.Errorf("unmarshaling share payload: %w", err)
+		}
+
+		shares := services.NewShareService(deps.DB)
+		share, err := shares.Get(payload.ShareID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Revoked before the invitation went out
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		details, err := shares.Details(share)
+		if err != nil {
+			return err
+		}
+
+		kind := share.ResourceType
+		access := "view"
+		if share.Permission == models.SharePermissionEdit {
+			access = "view and edit"
+		}
+		title := fmt.Sprintf("%s shared a %s with you", details.OwnerName, kind)
+		message := fmt.Sprintf("You can now %s %s", access, kind)
+		if details.Title != "" {
+			message += fmt.Sprintf(" %q", details.Title)
+		}
+		if share.ResourceType == models.ShareResourceLabel {
+			message += " and everything in it"
+		}
+		message += "."
+
+		if share.UserID != nil {
+			notification := models.Notification{
+				UserID:       *share.UserID,
+				Key:          fmt.Sprintf("share:%d", share.ID),
+				Type:         models.NotificationShare,
+				Title:        title,
+				Message:      message,
+				ResourceType: share.ResourceType,
+				ResourceID:   share.ResourceID,
+			}
+			if _, err := services.NewNotificationService(deps.DB).Create(&notification); err != nil {
+				return err
+			}
+		}
+
+		if deps.Mailer == nil {
+			log.Printf("Warning: mailer not configured, share %d made without email", share.ID)
+			return nil
+		}
+		data := map[string]interface{}{
+			"AppName": deps.AppName,
+			"Year":    time.Now().Year(),
+			"Title":   title,
+			"Message": message,
+		}
+		if deps.FrontendURL != "" {
+			if share.UserID != nil {
+				data["ActionURL"] = deps.FrontendURL + "/shared"
+				data["ActionText"] = "Open"
+			} else {
+				data["ActionURL"] = deps.FrontendURL + "/register?email=" + url.QueryEscape(share.Email)
+				data["ActionText"] = "Create your account"
+			}
+		}
+		return deps.Mailer.Send(ctx, mail.SendOptions{
+			To:       share.Email,
+			Subject:  title,
+			Template: "notification",
+			Data:     data,
+		})
+	}
+}
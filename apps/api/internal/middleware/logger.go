@@ -13,6 +13,11 @@ func Logger() gin.HandlerFunc {
 		start := time.Now()
 		path := c.Request.URL.Path
 		query := c.Request.URL.RawQuery
+		// WebSocket handshakes may carry the access token in the query.
+		if q := c.Request.URL.Query(); q.Has("token") {
+			q.Set("token", "REDACTED")
+			query = q.Encode()
+		}
 
 		c.Next()
 
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// AIUsage records the tokens spent by one AI call. Endpoint is the API path
+// for user requests, or "job:<task>" for background work done on a user's behalf.
+// IsError marks calls that failed, such as streams cut off by the provider.
+type AIUsage struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	UserID       uint      `gorm:"not null;index:idx_ai_usage_user_created" json:"user_id"`
+	Provider     string    `gorm:"size:50;not null" json:"provider"`
+	Model        string    `gorm:"size:100" json:"model"`
+	Endpoint     string    `gorm:"size:100;not null" json:"endpoint"`
+	InputTokens  int       `gorm:"not null;default:0" json:"input_tokens"`
+	OutputTokens int       `gorm:"not null;default:0" json:"output_tokens"`
+	IsError      bool      `gorm:"not null;default:false" json:"is_error"`
+	CreatedAt    time.Time `gorm:"index:idx_ai_usage_user_created" json:"created_at"`
+}
+
+// TableName keeps usage records in a single "ai_usage" table.
+func (AIUsage) TableName() string {
+	return "ai_usage"
+}
+
+// AIQuota overrides the default monthly token limit for one user.
+// A limit of 0 means unlimited.
+type AIQuota struct {
+	ID                uint      `gorm:"primarykey" json:"id"`
+	UserID            uint      `gorm:"not null;uniqueIndex" json:"user_id"`
+	MonthlyTokenLimit int64     `gorm:"not null;default:0" json:"monthly_token_limit"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
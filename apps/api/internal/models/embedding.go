@@ -0,0 +1,75 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EmbeddedResourceTypes lists the resource types indexed for semantic search.
+var EmbeddedResourceTypes = []string{ResourceTypeNote, ResourceTypeLink, ResourceTypeFile}
+
+// Embedding stores the semantic vector for a resource.
+// Vectors are unit length, so cosine similarity is a plain dot product in SQL.
+type Embedding struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	ResourceType string    `gorm:"size:20;not null;uniqueIndex:idx_embedding_resource" json:"resource_type"`
+	ResourceID   uint      `gorm:"not null;uniqueIndex:idx_embedding_resource" json:"resource_id"`
+	UserID       uint      `gorm:"not null;index" json:"user_id"`
+	Model        string    `gorm:"size:100;not null;index" json:"model"`
+	Dimensions   int       `gorm:"not null" json:"dimensions"`
+	ContentHash  string    `gorm:"size:64;not null" json:"content_hash"`
+	Vector       Vector    `gorm:"type:real[];not null" json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Vector is a float32 slice stored as a Postgres real[] column.
+type Vector []float32
+
+// Value encodes the vector as a Postgres array literal.
+func (v Vector) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	parts := make([]string, len(v))
+	for i, x := range v {
+		parts[i] = strconv.FormatFloat(float64(x), 'g', -1, 32)
+	}
+	return "{" + strings.Join(parts, ",") + "}", nil
+}
+
+// Scan decodes a Postgres array literal into the vector.
+func (v *Vector) Scan(src interface{}) error {
+	var s string
+	switch src := src.(type) {
+	case nil:
+		*v = nil
+		return nil
+	case string:
+		s = src
+	case []byte:
+		s = string(src)
+	default:
+		return fmt.Errorf("cannot scan %T into Vector", src)
+	}
+
+	s = strings.Trim(s, "{}")
+	if s == "" {
+		*v = Vector{}
+		return nil
+	}
+	parts := strings.Split(s, ",")
+	out := make(Vector, len(parts))
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 32)
+		if err != nil {
+			return fmt.Errorf("parsing vector element %d: %w", i, err)
+		}
+		out[i] = float32(f)
+	}
+	*v = out
+	return nil
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Export job statuses. An export expires once its archive has been deleted.
+const (
+	ExportPending   = "pending"
+	ExportRunning   = "running"
+	ExportCompleted = "completed"
+	ExportFailed    = "failed"
+	ExportExpired   = "expired"
+)
+
+// ExportJob tracks a background export of a whole account into a zip archive
+// kept in object storage until ExpiresAt.
+type ExportJob struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	Status      string     `gorm:"size:20;not null;default:pending;index" json:"status"`
+	StorageKey  string     `gorm:"size:1024" json:"-"`
+	SizeBytes   int64      `gorm:"default:0" json:"size_bytes"`
+	Labels      int        `gorm:"default:0" json:"labels"`
+	Notes       int        `gorm:"default:0" json:"notes"`
+	Links       int        `gorm:"default:0" json:"links"`
+	Images      int        `gorm:"default:0" json:"images"`
+	Files       int        `gorm:"default:0" json:"files"`
+	Error       string     `gorm:"type:text" json:"error,omitempty"`
+	StartedAt   *time.Time `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	ExpiresAt   *time.Time `gorm:"index" json:"expires_at"`
+	DownloadURL string     `gorm:"-" json:"download_url,omitempty"` // signed, filled in when the archive is available
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
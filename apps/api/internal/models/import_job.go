@@ -0,0 +1,118 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Import job statuses.
+const (
+	ImportPending   = "pending"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// Import sources.
+const (
+	ImportKindBookmarks  = "bookmarks"
+	ImportKindGoogleKeep = "google_keep"
+	ImportKindAccount    = "account"
+	ImportKindEvernote   = "evernote"
+)
+
+// Account import modes. Merge skips archive entries that match a resource
+// the user already has; copy imports them alongside it.
+const (
+	ImportModeMerge = "merge"
+	ImportModeCopy  = "copy"
+)
+
+// Resolutions of an archive entry that matched an existing resource or could
+// not be imported as is.
+const (
+	ImportSkipped    = "skipped"
+	ImportMerged     = "merged"
+	ImportDuplicated = "duplicated"
+)
+
+// ImportJob tracks a background import of an uploaded file. The file is kept
+// in object storage until the import completes.
+type ImportJob struct {
+	ID         uint          `gorm:"primarykey" json:"id"`
+	UserID     uint          `gorm:"not null;index" json:"user_id"`
+	Kind       string        `gorm:"size:30;not null" json:"kind"`
+	Status     string        `gorm:"size:20;not null;default:pending" json:"status"`
+	Filename   string        `gorm:"size:255" json:"filename"`
+	SourceKey  string        `gorm:"size:1024" json:"-"`
+	Mode       string        `gorm:"size:20;default:''" json:"mode,omitempty"`
+	DryRun     bool          `gorm:"default:false" json:"dry_run"` // report what would happen without changing anything
+	Total      int           `gorm:"default:0" json:"total"`       // entries found in the file
+	Processed  int           `gorm:"default:0" json:"processed"`   // entries handled so far
+	Created    int           `gorm:"default:0" json:"created"`
+	Merged     int           `gorm:"default:0" json:"merged"` // matched an existing resource
+	Skipped    int           `gorm:"default:0" json:"skipped"`
+	Error      string        `gorm:"type:text" json:"error,omitempty"`
+	Report     *ImportReport `gorm:"type:text" json:"report,omitempty"`
+	StartedAt  *time.Time    `json:"started_at"`
+	FinishedAt *time.Time    `json:"finished_at"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+}
+
+// ImportReport details an account import: how many resources of each type it
+// created, or would create in a dry run, and the entries that conflicted.
+type ImportReport struct {
+	Create    map[string]int `json:"create"`
+	Conflicts []ImportIssue  `json:"conflicts"`
+	// MoreConflicts counts conflicts left out once Conflicts is full.
+	MoreConflicts int `json:"more_conflicts,omitempty"`
+}
+
+// ImportIssue is an archive entry that matched an existing resource, or that
+// could not be imported.
+type ImportIssue struct {
+	ResourceType string `json:"resource_type"`
+	SourceID     uint   `json:"source_id"` // the entry's ID in the archive
+	Title        string `json:"title"`
+	Reason       string `json:"reason"`
+	ExistingID   uint   `json:"existing_id,omitempty"`
+	Resolution   string `json:"resolution"`
+}
+
+// Value stores the report as JSON.
+func (r ImportReport) Value() (driver.Value, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads a report stored as JSON.
+func (r *ImportReport) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	default:
+		return fmt.Errorf("unsupported import report type %T", value)
+	}
+}
+
+// ImportRecord remembers which resource an imported item became, so running
+// the same import again skips the item instead of duplicating it.
+type ImportRecord struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	UserID       uint      `gorm:"not null;uniqueIndex:idx_import_record" json:"user_id"`
+	Source       string    `gorm:"size:30;not null;uniqueIndex:idx_import_record" json:"source"`
+	ExternalID   string    `gorm:"size:255;not null;uniqueIndex:idx_import_record" json:"external_id"`
+	ResourceType string    `gorm:"size:20;not null" json:"resource_type"`
+	ResourceID   uint      `gorm:"not null" json:"resource_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// Label suggestion statuses.
+const (
+	SuggestionPending  = "pending"
+	SuggestionAccepted = "accepted"
+	SuggestionRejected = "rejected"
+)
+
+// LabelSuggestion is an AI-proposed label for a resource, applied only once the user accepts it.
+// LabelID is set when the suggestion matches an existing label; otherwise Name
+// is a new label created on acceptance.
+type LabelSuggestion struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	UserID       uint      `gorm:"not null;index" json:"user_id"`
+	ResourceType string    `gorm:"size:20;not null;index:idx_suggestion_resource" json:"resource_type"`
+	ResourceID   uint      `gorm:"not null;index:idx_suggestion_resource" json:"resource_id"`
+	LabelID      *uint     `gorm:"index" json:"label_id"`
+	Label        *Label    `gorm:"foreignKey:LabelID" json:"label,omitempty"`
+	Name         string    `gorm:"size:255;not null" json:"name"`
+	Status       string    `gorm:"size:20;not null;default:pending;index" json:"status"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
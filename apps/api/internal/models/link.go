@@ -1,26 +1,46 @@
-package models
-
-import (
-	"time"
-
-	"gorm.io/gorm"
-)
-
-// Link represents a saved bookmark/link.
-type Link struct {
-	ID           uint           `gorm:"primarykey" json:"id"`
-	URL          string         `gorm:"size:2048;not null" json:"url" binding:"required,url"`
-	Title        string         `gorm:"size:500" json:"title"`
-	Description  string         `gorm:"type:text" json:"description"`
-	ThumbnailURL string         `gorm:"size:2048" json:"thumbnail_url"`
-	FaviconURL   string         `gorm:"size:2048" json:"favicon_url"`
-	IsPinned     bool           `gorm:"default:false" json:"is_pinned"`
-	IsArchived   bool           `gorm:"default:false" json:"is_archived"`
-	IsTrashed    bool           `gorm:"default:false" json:"is_trashed"`
-	UserID       uint           `gorm:"not null;index" json:"user_id"`
-	User         User           `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	Labels       []Label        `gorm:"many2many:link_labels;" json:"labels,omitempty"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
-}
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Link health statuses. An empty status means the link has not been checked yet.
+const (
+	LinkHealthOK         = "ok"
+	LinkHealthRedirected = "redirected"
+	LinkHealthBroken     = "broken"
+)
+
+// Link represents a saved bookmark/link.
+// CanonicalURL is the normalized URL used to detect duplicates (see urlcanon).
+// The health fields are maintained by the periodic link checker.
+type Link struct {
+	ID            uint           `gorm:"primarykey" json:"id"`
+	URL           string         `gorm:"size:2048;not null" json:"url" binding:"required,url"`
+	CanonicalURL  string         `gorm:"size:2048;index:idx_links_user_canonical,priority:2" json:"canonical_url"`
+	Title         string         `gorm:"size:500" json:"title"`
+	Description   string         `gorm:"type:text" json:"description"`
+	Summary       string         `gorm:"type:text" json:"summary"`
+	SummaryHash   string         `gorm:"size:64" json:"-"` // SHA-256 of the text the summary was made from
+	ThumbnailURL  string         `gorm:"size:2048" json:"thumbnail_url"`
+	FaviconURL    string         `gorm:"size:2048" json:"favicon_url"`
+	IsPinned      bool           `gorm:"default:false" json:"is_pinned"`
+	IsArchived    bool           `gorm:"default:false" json:"is_archived"`
+	IsTrashed     bool           `gorm:"default:false" json:"is_trashed"`
+	HealthStatus  string         `gorm:"size:20;default:'';index" json:"health_status"`
+	StatusCode    int            `gorm:"default:0" json:"status_code"`
+	FinalURL      string         `gorm:"size:2048" json:"final_url"`
+	CheckError    string         `gorm:"size:500" json:"check_error,omitempty"`
+	LastCheckedAt *time.Time     `json:"last_checked_at"`
+	NextCheckAt   *time.Time     `gorm:"index" json:"-"`
+	CheckFailures int            `gorm:"default:0" json:"-"`
+	UserID        uint           `gorm:"not null;index;index:idx_links_user_canonical,priority:1" json:"user_id"`
+	User          User           `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Labels        []Label        `gorm:"many2many:link_labels;" json:"labels,omitempty"`
+	Version       uint           `gorm:"not null;default:1" json:"version"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
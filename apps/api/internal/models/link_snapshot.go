@@ -0,0 +1,50 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Link snapshot statuses.
+const (
+	SnapshotPending = "pending"
+	SnapshotReady   = "ready"
+	SnapshotFailed  = "failed"
+)
+
+// LinkSnapshot is an archived, readable copy of a link's page. The sanitized
+// article HTML and its images live in object storage so the snapshot outlives
+// the original site; the plain text is kept here for search.
+type LinkSnapshot struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	LinkID      uint       `gorm:"not null;uniqueIndex" json:"link_id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	Status      string     `gorm:"size:20;not null;default:pending" json:"status"`
+	SourceURL   string     `gorm:"size:2048" json:"source_url"`
+	Title       string     `gorm:"size:500" json:"title"`
+	Byline      string     `gorm:"size:255" json:"byline"`
+	Excerpt     string     `gorm:"type:text" json:"excerpt"`
+	TextContent string     `gorm:"type:text" json:"-"`
+	ContentKey  string     `gorm:"size:1024" json:"-"`
+	AssetKeys   string     `gorm:"type:text" json:"-"` // newline-separated storage keys of archived images
+	ImageCount  int        `gorm:"default:0" json:"image_count"`
+	SizeBytes   int64      `gorm:"default:0" json:"size_bytes"`
+	Error       string     `gorm:"type:text" json:"error,omitempty"`
+	CapturedAt  *time.Time `json:"captured_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Keys returns every storage key belonging to the snapshot.
+func (s *LinkSnapshot) Keys() []string {
+	var keys []string
+	if s.ContentKey != "" {
+		keys = append(keys, s.ContentKey)
+	}
+	for _, k := range strings.Split(s.AssetKeys, "\n") {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
@@ -1,24 +1,38 @@
-package models
-
-import (
-	"time"
-
-	"gorm.io/gorm"
-)
-
-// Note represents a text note.
-type Note struct {
-	ID         uint           `gorm:"primarykey" json:"id"`
-	Title      string         `gorm:"size:500" json:"title"`
-	Body       string         `gorm:"type:text" json:"body"`
-	Color      string         `gorm:"size:7;default:#ffffff" json:"color"`
-	IsPinned   bool           `gorm:"default:false" json:"is_pinned"`
-	IsArchived bool           `gorm:"default:false" json:"is_archived"`
-	IsTrashed  bool           `gorm:"default:false" json:"is_trashed"`
-	UserID     uint           `gorm:"not null;index" json:"user_id"`
-	User       User           `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	Labels     []Label        `gorm:"many2many:note_labels;" json:"labels,omitempty"`
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
-}
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Note types.
+const (
+	NoteTypeText      = "text"
+	NoteTypeChecklist = "checklist"
+)
+
+// Note represents a text note, or a checklist note whose lines are Items.
+// The body of a checklist note is kept as its items rendered as a Markdown
+// task list.
+type Note struct {
+	ID                  uint           `gorm:"primarykey" json:"id"`
+	Type                string         `gorm:"size:20;not null;default:text" json:"type"`
+	Title               string         `gorm:"size:500" json:"title"`
+	Body                string         `gorm:"type:text" json:"body"`
+	Summary             string         `gorm:"type:text" json:"summary"`
+	SummaryHash         string         `gorm:"size:64" json:"-"` // SHA-256 of the text the summary was made from
+	Color               string         `gorm:"size:7;default:#ffffff" json:"color"`
+	IsPinned            bool           `gorm:"default:false" json:"is_pinned"`
+	IsArchived          bool           `gorm:"default:false" json:"is_archived"`
+	IsTrashed           bool           `gorm:"default:false" json:"is_trashed"`
+	MoveCheckedToBottom bool           `gorm:"default:false" json:"move_checked_to_bottom"`
+	UserID              uint           `gorm:"not null;index" json:"user_id"`
+	User                User           `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Labels              []Label        `gorm:"many2many:note_labels;" json:"labels,omitempty"`
+	Items               []NoteItem     `gorm:"constraint:OnDelete:CASCADE" json:"items,omitempty"`
+	Version             uint           `gorm:"not null;default:1" json:"version"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt `gorm:"index" json:"-"`
+}
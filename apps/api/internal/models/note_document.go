@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// NoteDocument is the collaborative editing state of a text note's body: the
+// runs of its replicated text (see package crdt) as JSON, deleted characters
+// included. The note's Body is the text of this state, unless the note was
+// edited outside a collaborative session since the state was saved.
+type NoteDocument struct {
+	NoteID    uint      `gorm:"primarykey;autoIncrement:false" json:"note_id"`
+	Note      *Note     `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	State     string    `gorm:"type:text;not null" json:"-"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
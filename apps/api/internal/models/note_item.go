@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// NoteItem is one line of a checklist note. Items are shown by Position,
+// unless the note moves checked items to the bottom.
+type NoteItem struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	NoteID    uint      `gorm:"not null;index" json:"note_id"`
+	Text      string    `gorm:"type:text" json:"text"`
+	Checked   bool      `gorm:"default:false" json:"checked"`
+	Position  int       `gorm:"not null;default:0" json:"position"`
+	Indent    int       `gorm:"default:0" json:"indent"` // nesting level, 0 to checklist.MaxIndent
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// NoteRevision is a saved state of a note's title and body. A note gets a
+// revision for every edit, except that quick successive saves by the same
+// author update their latest revision instead of adding one. The first
+// revision of a note that predates its history is the note as it was before
+// that edit.
+type NoteRevision struct {
+	ID             uint      `gorm:"primarykey" json:"id"`
+	NoteID         uint      `gorm:"not null;index" json:"note_id"`
+	Note           *Note     `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	AuthorID       uint      `gorm:"not null;index" json:"author_id"`
+	Title          string    `gorm:"size:500" json:"title"`
+	Body           string    `gorm:"type:text" json:"body,omitempty"`
+	RestoredFromID *uint     `json:"restored_from_id,omitempty"` // revision this one restored
+	CreatedAt      time.Time `gorm:"index" json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"` // last save folded into this revision
+}
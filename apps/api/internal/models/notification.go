@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Notification types.
+const (
+	NotificationReminder = "reminder"
+	NotificationShare    = "share"
+)
+
+// Notification is an in-app notification. Key identifies what it is about,
+// such as one occurrence of a reminder, so delivering it twice creates it once.
+type Notification struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	UserID       uint       `gorm:"not null;uniqueIndex:idx_notification_key;index" json:"user_id"`
+	Key          string     `gorm:"size:191;not null;uniqueIndex:idx_notification_key" json:"-"`
+	Type         string     `gorm:"size:30;not null" json:"type"`
+	Title        string     `gorm:"size:500" json:"title"`
+	Message      string     `gorm:"type:text" json:"message"`
+	ResourceType string     `gorm:"size:20" json:"resource_type,omitempty"`
+	ResourceID   uint       `json:"resource_id,omitempty"`
+	ReadAt       *time.Time `json:"read_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// PublicLink publishes one note, link, image or file to anyone who has its
+// Token, until it expires or is revoked. A link with a Password asks for it
+// before showing the resource.
+type PublicLink struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	UserID       uint       `gorm:"not null;index" json:"user_id"`
+	ResourceType string     `gorm:"size:20;not null;index:idx_public_link_resource,priority:1" json:"resource_type"`
+	ResourceID   uint       `gorm:"not null;index:idx_public_link_resource,priority:2" json:"resource_id"`
+	Token        string     `gorm:"size:64;not null;uniqueIndex" json:"token"`
+	Password     string     `gorm:"size:255" json:"-"`
+	HasPassword  bool       `gorm:"-" json:"has_password"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	ViewCount    int64      `gorm:"default:0" json:"view_count"`
+	LastViewedAt *time.Time `json:"last_viewed_at"`
+	RevokedAt    *time.Time `gorm:"index" json:"revoked_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// AfterFind tells readers whether the link asks for a password.
+func (p *PublicLink) AfterFind(tx *gorm.DB) error {
+	p.HasPassword = p.Password != ""
+	return nil
+}
+
+// SetPassword hashes and sets the link's password. An empty password removes it.
+func (p *PublicLink) SetPassword(password string) error {
+	p.Password, p.HasPassword = "", false
+	if password == "" {
+		return nil
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	p.Password, p.HasPassword = string(hashedPassword), true
+	return nil
+}
+
+// CheckPassword compares the given password with the stored hash.
+func (p *PublicLink) CheckPassword(password string) bool {
+	err := bcrypt.CompareHashAndPassword([]byte(p.Password), []byte(password))
+	return err == nil
+}
+
+// Expired reports whether the link stopped working before now.
+func (p *PublicLink) Expired(now time.Time) bool {
+	return p.ExpiresAt != nil && !now.Before(*p.ExpiresAt)
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Reminder statuses. A one-off reminder is done once it fired; a recurring
+// one is done after its last occurrence.
+const (
+	ReminderScheduled = "scheduled"
+	ReminderDone      = "done"
+)
+
+// Reminder notifies a user about a note at a due time, optionally repeating
+// by an RFC 5545 recurrence rule. NotifyAt is when it fires next: DueAt, or
+// SnoozedUntil while snoozed.
+type Reminder struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	UserID         uint       `gorm:"not null;index" json:"user_id"`
+	NoteID         uint       `gorm:"not null;uniqueIndex" json:"note_id"`
+	Note           *Note      `gorm:"constraint:OnDelete:CASCADE" json:"note,omitempty"`
+	Status         string     `gorm:"size:20;not null;default:scheduled" json:"status"`
+	StartsAt       time.Time  `gorm:"not null" json:"starts_at"` // first occurrence; recurrence counts from here
+	DueAt          time.Time  `gorm:"not null" json:"due_at"`    // current occurrence
+	RRule          string     `gorm:"size:500" json:"rrule,omitempty"`
+	Timezone       string     `gorm:"size:64;not null;default:UTC" json:"timezone"`
+	SnoozedUntil   *time.Time `json:"snoozed_until"`
+	NotifyAt       time.Time  `gorm:"not null;index" json:"notify_at"`
+	LastNotifiedAt *time.Time `json:"last_notified_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Resource link kinds.
+const (
+	ResourceLinkWiki = "wiki" // [[Note title]]
+	ResourceLinkURI  = "uri"  // keep://link/42
+)
+
+// ResourceLink is a reference from a note's body to another resource, an
+// edge of the user's resource graph. A wiki link whose title matches none of
+// the user's notes has no TargetID until a note gets that title.
+type ResourceLink struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	UserID      uint      `gorm:"not null;index" json:"user_id"`
+	SourceType  string    `gorm:"size:20;not null;index:idx_resource_link_source,priority:1" json:"source_type"`
+	SourceID    uint      `gorm:"not null;index:idx_resource_link_source,priority:2" json:"source_id"`
+	TargetType  string    `gorm:"size:20;not null;index:idx_resource_link_target,priority:1" json:"target_type"`
+	TargetID    *uint     `gorm:"index:idx_resource_link_target,priority:2" json:"target_id"`
+	TargetTitle string    `gorm:"size:500" json:"target_title,omitempty"` // title a wiki link names
+	Kind        string    `gorm:"size:10;not null" json:"kind"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+)
+
+// Resource type constants shared by cross-resource features.
+const (
+	ResourceTypeNote  = "note"
+	ResourceTypeLink  = "link"
+	ResourceTypeImage = "image"
+	ResourceTypeFile  = "file"
+)
+
+// ResourceTypes lists every searchable resource type.
+var ResourceTypes = []string{ResourceTypeNote, ResourceTypeLink, ResourceTypeImage, ResourceTypeFile}
+
+// SearchDocument is the denormalized full-text index entry for a resource.
+// Rows are maintained by services.SearchService; Document is computed in SQL.
+type SearchDocument struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	ResourceType string    `gorm:"size:20;not null;uniqueIndex:idx_search_resource" json:"resource_type"`
+	ResourceID   uint      `gorm:"not null;uniqueIndex:idx_search_resource" json:"resource_id"`
+	UserID       uint      `gorm:"not null;index" json:"user_id"`
+	Title        string    `gorm:"size:500" json:"title"`
+	Body         string    `gorm:"type:text" json:"body"`
+	Labels       string    `gorm:"type:text" json:"labels"`
+	URL          string    `gorm:"size:2048" json:"url"`
+	Color        string    `gorm:"size:7" json:"color"`
+	IsPinned     bool      `gorm:"default:false" json:"is_pinned"`
+	IsArchived   bool      `gorm:"default:false" json:"is_archived"`
+	IsTrashed    bool      `gorm:"default:false" json:"is_trashed"`
+	Document     string    `gorm:"type:tsvector;index:idx_search_document,type:gin;<-:false;->:false" json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
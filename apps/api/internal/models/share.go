@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Share permissions.
+const (
+	SharePermissionView = "view"
+	SharePermissionEdit = "edit"
+)
+
+// ShareResourceLabel is the resource type of a share of a label, which
+// shares every resource carrying it.
+const ShareResourceLabel = "label"
+
+// Share grants another user view or edit access to a note, link, image,
+// file or label. A share made to an email address without an account gets
+// its UserID when someone signs up with that address.
+type Share struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	OwnerID      uint      `gorm:"not null;index" json:"owner_id"`
+	ResourceType string    `gorm:"size:20;not null;uniqueIndex:idx_share_resource_email,priority:1;index:idx_share_grantee,priority:2" json:"resource_type"`
+	ResourceID   uint      `gorm:"not null;uniqueIndex:idx_share_resource_email,priority:2" json:"resource_id"`
+	Email        string    `gorm:"size:255;not null;uniqueIndex:idx_share_resource_email,priority:3" json:"email"`
+	UserID       *uint     `gorm:"index:idx_share_grantee,priority:1" json:"user_id"`
+	Permission   string    `gorm:"size:10;not null;default:view" json:"permission"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -33,6 +34,8 @@ type User struct {
 	Bio             string         `gorm:"type:text" json:"bio"`
 	Active          bool           `gorm:"default:true" json:"active"`
 	EmailVerifiedAt *time.Time     `json:"email_verified_at"`
+	VerifyToken     string         `gorm:"size:64;index" json:"-"` // SHA-256 of the emailed token
+	VerifySentAt    *time.Time     `json:"-"`
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
 	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
@@ -50,6 +53,18 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// DisplayName returns the name to show for the user: their name, or their
+// first and last name, or their email address.
+func (u *User) DisplayName() string {
+	if u.Name != "" {
+		return u.Name
+	}
+	if name := strings.TrimSpace(u.FirstName + " " + u.LastName); name != "" {
+		return name
+	}
+	return u.Email
+}
+
 // CheckPassword compares the given password with the stored hash.
 func (u *User) CheckPassword(password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
@@ -68,19 +83,47 @@ func Models() []interface{} {
 		&Link{},
 		&Image{},
 		&File{},
+		&SearchDocument{},
+		&Embedding{},
+		&LabelSuggestion{},
+		&AIUsage{},
+		&AIQuota{},
+		&LinkSnapshot{},
+		&ImportJob{},
+		&ImportRecord{},
+		&ExportJob{},
+		&NoteItem{},
+		&Reminder{},
+		&Notification{},
+		&NoteRevision{},
+		&ResourceLink{},
+		&Share{},
+		&PublicLink{},
+		&NoteDocument{},
 		// grit:models
 	}
 }
 
-// Migrate runs database migrations only for tables that don't exist yet.
-// It prints which tables were created and which were skipped.
+// Migrate creates tables that don't exist yet. Existing tables only get
+// newly declared columns and indexes added; existing columns are never altered,
+// so new fields on existing models must be nullable or have a default.
+// It prints which tables were created, extended or skipped.
 func Migrate(db *gorm.DB) error {
 	models := Models()
 	migrated := 0
 
 	for _, model := range models {
 		if db.Migrator().HasTable(model) {
-			log.Printf("  ✓ %T — already exists, skipping", model)
+			added, err := addMissingColumns(db, model)
+			if err != nil {
+				return fmt.Errorf("migrating %T: %w", model, err)
+			}
+			if added == 0 {
+				log.Printf("  ✓ %T — already exists, skipping", model)
+				continue
+			}
+			log.Printf("  ✓ %T — added %d column(s)/index(es)", model, added)
+			migrated++
 			continue
 		}
 
@@ -99,3 +142,36 @@ func Migrate(db *gorm.DB) error {
 
 	return nil
 }
+
+// addMissingColumns adds the columns and indexes declared on a model that
+// its existing table does not have yet. Returns how many were added.
+func addMissingColumns(db *gorm.DB, model interface{}) (int, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return 0, fmt.Errorf("parsing schema: %w", err)
+	}
+
+	added := 0
+	for _, dbName := range stmt.Schema.DBNames {
+		field := stmt.Schema.FieldsByDBName[dbName]
+		if field.IgnoreMigration || db.Migrator().HasColumn(model, dbName) {
+			continue
+		}
+		if err := db.Migrator().AddColumn(model, field.Name); err != nil {
+			return added, fmt.Errorf("adding column %s: %w", dbName, err)
+		}
+		added++
+	}
+
+	for _, idx := range stmt.Schema.ParseIndexes() {
+		if db.Migrator().HasIndex(model, idx.Name) {
+			continue
+		}
+		if err := db.Migrator().CreateIndex(model, idx.Name); err != nil {
+			return added, fmt.Errorf("creating index %s: %w", idx.Name, err)
+		}
+		added++
+	}
+
+	return added, nil
+}
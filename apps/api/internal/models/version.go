@@ -0,0 +1,45 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// RegisterVersioning makes every update of a resource with a Version column
+// (notes, links, images, files and labels) bump its version, so that clients
+// can tell whether the copy they edit is still current. Like UpdatedAt, the
+// version is left alone by UpdateColumn and UpdateColumns, which the services
+// use for changes that are not user edits, such as fetched link metadata.
+func RegisterVersioning(db *gorm.DB) error {
+	return db.Callback().Update().Before("gorm:update").Register("models:version", bumpVersion)
+}
+
+// bumpVersion adds "version = version + 1" to updates of map values, which is
+// how the services update resources.
+func bumpVersion(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || stmt.SkipHooks {
+		return
+	}
+	field := stmt.Schema.LookUpField("version")
+	if field == nil {
+		return
+	}
+	values, ok := stmt.Dest.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if _, set := values[field.DBName]; set {
+		return
+	}
+	if _, set := values[field.Name]; set {
+		return
+	}
+
+	// Copy the values, which belong to the caller.
+	dest := make(map[string]interface{}, len(values)+1)
+	for k, v := range values {
+		dest[k] = v
+	}
+	dest[field.DBName] = gorm.Expr(stmt.Quote(field.DBName) + " + 1")
+	stmt.Dest = dest
+}
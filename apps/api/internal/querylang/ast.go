@@ -0,0 +1,138 @@
+// Package querylang parses the search query language used by /api/search and
+// the resource list endpoints, and compiles it to GORM scopes.
+//
+// Grammar (terms are ANDed, a leading "-" negates any term):
+//
+//	word                 free-text term
+//	"exact phrase"       free-text phrase
+//	type:link            resource type (note, link, image, file); repeated types are ORed
+//	label:work           label slug or name, label:"two words" for names with spaces
+//	is:pinned            is:pinned, is:archived, is:trashed
+//	before:2026-01-01    created before the date
+//	after:2026-01-01     created on or after the date
+//	color:#fff           note color (3 or 6 digit hex)
+package querylang
+
+import (
+	"strings"
+	"time"
+)
+
+// Flag is a boolean resource state matched by the is: operator.
+type Flag string
+
+// Supported is: flags.
+const (
+	FlagPinned   Flag = "pinned"
+	FlagArchived Flag = "archived"
+	FlagTrashed  Flag = "trashed"
+)
+
+// Node is a single term of a parsed query.
+type Node interface {
+	isNode()
+}
+
+// Text matches free text, either a single word or a quoted phrase.
+type Text struct {
+	Value   string
+	Phrase  bool
+	Negated bool
+}
+
+// TypeFilter matches the resource type.
+type TypeFilter struct {
+	Type    string
+	Negated bool
+}
+
+// LabelFilter matches resources carrying a label, by slug or name.
+type LabelFilter struct {
+	Label   string
+	Negated bool
+}
+
+// FlagFilter matches a boolean resource state.
+type FlagFilter struct {
+	Flag    Flag
+	Negated bool
+}
+
+// DateFilter matches the creation date. Before is exclusive, after is inclusive.
+type DateFilter struct {
+	Before  bool
+	Date    time.Time
+	Negated bool
+}
+
+// ColorFilter matches the note color as a normalized 7-character hex string.
+type ColorFilter struct {
+	Color   string
+	Negated bool
+}
+
+func (Text) isNode()        {}
+func (TypeFilter) isNode()  {}
+func (LabelFilter) isNode() {}
+func (FlagFilter) isNode()  {}
+func (DateFilter) isNode()  {}
+func (ColorFilter) isNode() {}
+
+// Query is a parsed query: a list of terms that must all match.
+type Query struct {
+	Nodes []Node
+}
+
+// Empty reports whether the query has no terms.
+func (q *Query) Empty() bool {
+	return q == nil || len(q.Nodes) == 0
+}
+
+// HasText reports whether the query contains any free-text terms.
+func (q *Query) HasText() bool {
+	if q == nil {
+		return false
+	}
+	for _, n := range q.Nodes {
+		if _, ok := n.(Text); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// HasFlag reports whether the query filters on the given flag, negated or not.
+func (q *Query) HasFlag(flag Flag) bool {
+	if q == nil {
+		return false
+	}
+	for _, n := range q.Nodes {
+		if f, ok := n.(FlagFilter); ok && f.Flag == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// Text renders the free-text terms in websearch_to_tsquery syntax.
+func (q *Query) Text() string {
+	if q == nil {
+		return ""
+	}
+	parts := []string{}
+	for _, n := range q.Nodes {
+		t, ok := n.(Text)
+		if !ok {
+			continue
+		}
+		part := t.Value
+		if t.Phrase {
+			part = `"` + part + `"`
+		}
+		if t.Negated {
+			part = "-" + part
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
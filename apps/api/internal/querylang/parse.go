@@ -0,0 +1,171 @@
+package querylang
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"desis-keep/apps/api/internal/models"
+)
+
+// dateLayout is the accepted format for before: and after: values.
+const dateLayout = "2006-01-02"
+
+var (
+	operatorPattern = regexp.MustCompile(`^([A-Za-z_]+):(?:"([^"]*)"|(\S+))`)
+	hexColorPattern = regexp.MustCompile(`^#?([0-9a-f]{3}|[0-9a-f]{6})$`)
+)
+
+// operators lists every recognized operator name.
+var operators = map[string]bool{
+	"type":   true,
+	"label":  true,
+	"is":     true,
+	"before": true,
+	"after":  true,
+	"color":  true,
+}
+
+// Error is a validation error for a malformed query.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid query at position %d: %s", e.Pos+1, e.Msg)
+}
+
+// Parse parses a query string into a Query. An empty string yields an empty query.
+func Parse(input string) (*Query, error) {
+	q := &Query{}
+	i := 0
+	for i < len(input) {
+		r, size := utf8.DecodeRuneInString(input[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+
+		start := i
+		negated := false
+		if r == '-' && wordEnd(input, i+1) > i+1 {
+			negated = true
+			i++
+		}
+
+		// Quoted phrase
+		if input[i] == '"' {
+			end := strings.IndexByte(input[i+1:], '"')
+			if end < 0 {
+				return nil, &Error{Pos: i, Msg: "unterminated quote"}
+			}
+			phrase := strings.TrimSpace(input[i+1 : i+1+end])
+			i += end + 2
+			if phrase != "" {
+				q.Nodes = append(q.Nodes, Text{Value: phrase, Phrase: true, Negated: negated})
+			}
+			continue
+		}
+
+		// Operator (key:value or key:"quoted value")
+		if m := operatorPattern.FindStringSubmatchIndex(input[i:]); m != nil {
+			key := strings.ToLower(input[i+m[2] : i+m[3]])
+			value := ""
+			next := i + m[1]
+			if m[4] >= 0 {
+				value = input[i+m[4] : i+m[5]]
+			} else {
+				// \S only excludes ASCII spaces; a value also ends at others
+				next = wordEnd(input, i+m[6])
+				value = input[i+m[6] : next]
+			}
+			// URLs such as https://example.com are plain text, not operators
+			if !strings.HasPrefix(value, "//") {
+				node, err := parseOperator(key, value, negated, start)
+				if err != nil {
+					return nil, err
+				}
+				q.Nodes = append(q.Nodes, node)
+				i = next
+				continue
+			}
+		}
+
+		// Plain word
+		end := wordEnd(input, i)
+		word := input[i:end]
+		i = end
+		if key, ok := strings.CutSuffix(word, ":"); ok && operators[strings.ToLower(key)] {
+			return nil, &Error{Pos: start, Msg: fmt.Sprintf("operator %q needs a value", strings.ToLower(key)+":")}
+		}
+		q.Nodes = append(q.Nodes, Text{Value: word, Negated: negated})
+	}
+	return q, nil
+}
+
+// wordEnd returns where the word starting at i ends: at the next space,
+// Unicode spaces included, or at the end of the input.
+func wordEnd(input string, i int) int {
+	for i < len(input) {
+		r, size := utf8.DecodeRuneInString(input[i:])
+		if unicode.IsSpace(r) {
+			return i
+		}
+		i += size
+	}
+	return len(input)
+}
+
+// parseOperator validates an operator term and builds its node.
+func parseOperator(key, value string, negated bool, pos int) (Node, error) {
+	if !operators[key] {
+		return nil, &Error{Pos: pos, Msg: fmt.Sprintf("unknown operator %q (supported: type, label, is, before, after, color)", key+":")}
+	}
+	if strings.TrimSpace(value) == "" {
+		return nil, &Error{Pos: pos, Msg: fmt.Sprintf("operator %q needs a value", key+":")}
+	}
+
+	switch key {
+	case "type":
+		t := strings.ToLower(value)
+		if slices.Contains(models.ResourceTypes, t) {
+			return TypeFilter{Type: t, Negated: negated}, nil
+		}
+		return nil, &Error{Pos: pos, Msg: fmt.Sprintf("unknown type %q (supported: %s)", value, strings.Join(models.ResourceTypes, ", "))}
+
+	case "label":
+		return LabelFilter{Label: value, Negated: negated}, nil
+
+	case "is":
+		switch Flag(strings.ToLower(value)) {
+		case FlagPinned, FlagArchived, FlagTrashed:
+			return FlagFilter{Flag: Flag(strings.ToLower(value)), Negated: negated}, nil
+		}
+		return nil, &Error{Pos: pos, Msg: fmt.Sprintf("unknown flag %q for is: (supported: pinned, archived, trashed)", value)}
+
+	case "before", "after":
+		date, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return nil, &Error{Pos: pos, Msg: fmt.Sprintf("invalid date %q for %s: (use YYYY-MM-DD)", value, key)}
+		}
+		return DateFilter{Before: key == "before", Date: date, Negated: negated}, nil
+
+	case "color":
+		m := hexColorPattern.FindStringSubmatch(strings.ToLower(value))
+		if m == nil {
+			return nil, &Error{Pos: pos, Msg: fmt.Sprintf("invalid color %q (use hex such as #fff or #ffffff)", value)}
+		}
+		hex := m[1]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		return ColorFilter{Color: "#" + hex, Negated: negated}, nil
+	}
+
+	return nil, &Error{Pos: pos, Msg: fmt.Sprintf("unknown operator %q", key+":")}
+}
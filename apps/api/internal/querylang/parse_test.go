@@ -0,0 +1,85 @@
+package querylang
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Node
+	}{
+		{"empty", "", nil},
+		{"spaces only", " \t\u00a0\u3000 ", nil},
+		{"words", "foo bar", []Node{Text{Value: "foo"}, Text{Value: "bar"}}},
+		{"no-break space", "foo\u00a0bar", []Node{Text{Value: "foo"}, Text{Value: "bar"}}},
+		{"ideographic space", "foo\u3000bar", []Node{Text{Value: "foo"}, Text{Value: "bar"}}},
+		{"line separator", "foo\u2028bar", []Node{Text{Value: "foo"}, Text{Value: "bar"}}},
+		{"non-ASCII word", "café naïve", []Node{Text{Value: "café"}, Text{Value: "naïve"}}},
+		{"invalid UTF-8", "a\xffb c", []Node{Text{Value: "a\xffb"}, Text{Value: "c"}}},
+		{"phrase", `"exact phrase" x`, []Node{Text{Value: "exact phrase", Phrase: true}, Text{Value: "x"}}},
+		{"negated word", "-foo", []Node{Text{Value: "foo", Negated: true}}},
+		{"negated phrase", `-"a b"`, []Node{Text{Value: "a b", Phrase: true, Negated: true}}},
+		{"trailing minus", "foo -", []Node{Text{Value: "foo"}, Text{Value: "-"}}},
+		{"minus before no-break space", "-\u00a0foo", []Node{Text{Value: "-"}, Text{Value: "foo"}}},
+		{"trailing OR", "foo OR", []Node{Text{Value: "foo"}, Text{Value: "OR"}}},
+		{"unbalanced open parenthesis", "(foo bar", []Node{Text{Value: "(foo"}, Text{Value: "bar"}}},
+		{"unbalanced close parenthesis", "foo)", []Node{Text{Value: "foo)"}}},
+		{"URL", "https://example.com", []Node{Text{Value: "https://example.com"}}},
+		{"operators", "type:LINK -is:pinned label:work", []Node{
+			TypeFilter{Type: "link"},
+			FlagFilter{Flag: FlagPinned, Negated: true},
+			LabelFilter{Label: "work"},
+		}},
+		{"quoted operator value", `label:"two words"`, []Node{LabelFilter{Label: "two words"}}},
+		{"operator value ends at no-break space", "label:work\u00a0foo", []Node{LabelFilter{Label: "work"}, Text{Value: "foo"}}},
+		{"color", "color:FFF", []Node{ColorFilter{Color: "#ffffff"}}},
+		{"date", "after:2026-01-02", []Node{DateFilter{Date: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(q.Nodes, tt.want) {
+				t.Errorf("Parse(%q) = %#v, want %#v", tt.input, q.Nodes, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		pos   int
+	}{
+		{"unterminated quote", `foo "bar`, 4},
+		{"unterminated negated quote", `-"bar`, 1},
+		{"lone quote", `"`, 0},
+		{"unknown operator", "foo:bar", 0},
+		{"operator without value", "x label:", 2},
+		{"operator with no-break space value", "label:\u00a0work", 0},
+		{"bad type", "type:video", 0},
+		{"bad flag", "is:done", 0},
+		{"bad date", "before:yesterday", 0},
+		{"bad color", "color:blue", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var qerr *Error
+			if !errors.As(err, &qerr) {
+				t.Fatalf("Parse(%q) error = %v, want *Error", tt.input, err)
+			}
+			if qerr.Pos != tt.pos {
+				t.Errorf("Parse(%q) error at %d, want %d", tt.input, qerr.Pos, tt.pos)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package querylang
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"desis-keep/apps/api/internal/models"
+)
+
+// SearchConfig is the Postgres text search configuration for full-text terms.
+const SearchConfig = "english"
+
+// Target describes the table a query is compiled against.
+type Target struct {
+	Table        string   // table name, used to qualify columns
+	ResourceType string   // fixed resource type of every row; empty when TypeColumn is set
+	TypeColumn   string   // column holding the resource type (search index)
+	IDColumn     string   // column holding the resource ID, joined against label tables
+	TextColumns  []string // columns matched with ILIKE by free-text terms
+	FullText     string   // tsvector column; when set, free text uses websearch_to_tsquery instead
+	ColorColumn  string   // empty when the resource has no color
+}
+
+// Compile targets for each resource table and the search index.
+var (
+	NoteTarget   = Target{Table: "notes", ResourceType: models.ResourceTypeNote, IDColumn: "id", TextColumns: []string{"title", "body"}, ColorColumn: "color"}
+	LinkTarget   = Target{Table: "links", ResourceType: models.ResourceTypeLink, IDColumn: "id", TextColumns: []string{"title", "url", "description"}}
+	ImageTarget  = Target{Table: "images", ResourceType: models.ResourceTypeImage, IDColumn: "id", TextColumns: []string{"title"}}
+	FileTarget   = Target{Table: "files", ResourceType: models.ResourceTypeFile, IDColumn: "id", TextColumns: []string{"title", "original_name"}}
+	SearchTarget = Target{Table: "search_documents", TypeColumn: "resource_type", IDColumn: "resource_id", FullText: "document", ColorColumn: "color"}
+)
+
+// labelJoins maps each resource type to its many2many label table and foreign key.
+var labelJoins = map[string][2]string{
+	models.ResourceTypeNote:  {"note_labels", "note_id"},
+	models.ResourceTypeLink:  {"link_labels", "link_id"},
+	models.ResourceTypeImage: {"image_labels", "image_id"},
+	models.ResourceTypeFile:  {"file_labels", "file_id"},
+}
+
+// Scope compiles the query into a GORM scope for the given target.
+// A nil or empty query compiles to a no-op scope.
+func (q *Query) Scope(t Target) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if q.Empty() {
+			return db
+		}
+
+		var includeTypes, excludeTypes []string
+		for _, n := range q.Nodes {
+			switch n := n.(type) {
+			case Text:
+				if t.FullText == "" {
+					db = db.Where(t.textCondition(n))
+				}
+			case TypeFilter:
+				if n.Negated {
+					excludeTypes = append(excludeTypes, n.Type)
+				} else {
+					includeTypes = append(includeTypes, n.Type)
+				}
+			case LabelFilter:
+				db = db.Where(negate(t.labelCondition(n.Label), n.Negated))
+			case FlagFilter:
+				db = db.Where(t.column("is_"+string(n.Flag))+" = ?", !n.Negated)
+			case DateFilter:
+				op := ">="
+				if n.Before != n.Negated {
+					op = "<"
+				}
+				db = db.Where(t.column("created_at")+" "+op+" ?", n.Date)
+			case ColorFilter:
+				if t.ColorColumn == "" {
+					db = db.Where(negate(gorm.Expr("1 = 0"), n.Negated))
+				} else {
+					db = db.Where(negate(gorm.Expr("LOWER("+t.column(t.ColorColumn)+") = ?", n.Color), n.Negated))
+				}
+			}
+		}
+
+		if t.FullText != "" && q.HasText() {
+			db = db.Where(t.column(t.FullText)+" @@ websearch_to_tsquery(?, ?)", SearchConfig, q.Text())
+		}
+
+		if t.TypeColumn != "" {
+			if len(includeTypes) > 0 {
+				db = db.Where(t.column(t.TypeColumn)+" IN ?", includeTypes)
+			}
+			if len(excludeTypes) > 0 {
+				db = db.Where(t.column(t.TypeColumn)+" NOT IN ?", excludeTypes)
+			}
+		} else if (len(includeTypes) > 0 && !slices.Contains(includeTypes, t.ResourceType)) || slices.Contains(excludeTypes, t.ResourceType) {
+			db = db.Where("1 = 0")
+		}
+
+		return db
+	}
+}
+
+// column qualifies a column name with the target table.
+func (t Target) column(name string) string {
+	return t.Table + "." + name
+}
+
+// textCondition matches a word or phrase against the target's text columns.
+func (t Target) textCondition(n Text) clause.Expr {
+	pattern := "%" + escapeLike(n.Value) + "%"
+	conds := make([]string, len(t.TextColumns))
+	args := make([]interface{}, len(t.TextColumns))
+	for i, col := range t.TextColumns {
+		conds[i] = "COALESCE(" + t.column(col) + ", '') ILIKE ?"
+		args[i] = pattern
+	}
+	return negate(gorm.Expr("("+strings.Join(conds, " OR ")+")", args...), n.Negated)
+}
+
+// labelCondition matches rows carrying a label with the given slug or name.
+func (t Target) labelCondition(label string) clause.Expr {
+	exists := func(resourceType string) (string, []interface{}) {
+		join := labelJoins[resourceType]
+		sql := fmt.Sprintf(`EXISTS (SELECT 1 FROM %[1]s JOIN labels ON labels.id = %[1]s.label_id
+			WHERE %[1]s.%[2]s = %[3]s AND labels.deleted_at IS NULL
+			AND (labels.slug = LOWER(?) OR LOWER(labels.name) = LOWER(?)))`, join[0], join[1], t.column(t.IDColumn))
+		return sql, []interface{}{label, label}
+	}
+
+	if t.TypeColumn == "" {
+		sql, args := exists(t.ResourceType)
+		return gorm.Expr(sql, args...)
+	}
+
+	conds := make([]string, 0, len(models.ResourceTypes))
+	args := []interface{}{}
+	for _, rt := range models.ResourceTypes {
+		sql, a := exists(rt)
+		conds = append(conds, "("+t.column(t.TypeColumn)+" = ? AND "+sql+")")
+		args = append(args, rt)
+		args = append(args, a...)
+	}
+	return gorm.Expr("("+strings.Join(conds, " OR ")+")", args...)
+}
+
+// negate wraps a condition in NOT when negated is true.
+func negate(expr clause.Expr, negated bool) clause.Expr {
+	if !negated {
+		return expr
+	}
+	return gorm.Expr("NOT (?)", expr)
+}
+
+// escapeLike escapes LIKE wildcards in user input.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,520 @@
+// Package readability extracts the main article from a web page and returns it
+// as sanitized HTML plus plain text, in the spirit of Mozilla's Readability.
+//
+// Paragraph-like elements are scored by length and punctuation, scores flow
+// up to their parent and grandparent, and the best scoring container, with
+// related siblings, becomes the article. Navigation, ads and other boilerplate
+// are pruned by tag, class/id hints and link density.
+package readability
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// ErrNoContent is returned when a page has no readable text.
+var ErrNoContent = errors.New("no readable content found")
+
+// minTextLength is the shortest text worth scoring as a paragraph.
+const minTextLength = 25
+
+var (
+	unlikelyPattern = regexp.MustCompile(`(?i)banner|breadcrumb|combx|comment|community|cookie|disqus|extra|footer|gdpr|header|legends|menu|modal|nav|newsletter|pager|pagination|popup|promo|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|tags|tool|widget|\bad-|\bads\b|advert`)
+	maybePattern    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow|story|entry|post|text|blog`)
+	positivePattern = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|pagination|post|text|blog|story`)
+	negativePattern = regexp.MustCompile(`(?i)-ad-|hidden|^hid$|\bhid\b|banner|combx|comment|com-|contact|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+)
+
+// Article is the readable content of a page.
+type Article struct {
+	Title   string
+	Byline  string
+	Excerpt string
+	Text    string // plain text with paragraphs separated by blank lines
+
+	content *html.Node // sanitized <div> holding the article
+}
+
+// HTML renders the sanitized article.
+func (a *Article) HTML() string {
+	var buf bytes.Buffer
+	for c := a.content.FirstChild; c != nil; c = c.NextSibling {
+		_ = html.Render(&buf, c)
+	}
+	return buf.String()
+}
+
+// ImageURLs returns the absolute URLs of the article's images, in document order, without duplicates.
+func (a *Article) ImageURLs() []string {
+	var urls []string
+	seen := map[string]bool{}
+	walk(a.content, func(n *html.Node) {
+		if n.DataAtom == atom.Img {
+			if src := attr(n, "src"); src != "" && !seen[src] {
+				seen[src] = true
+				urls = append(urls, src)
+			}
+		}
+	})
+	return urls
+}
+
+// ReplaceImages rewrites image sources, e.g. to point at archived copies.
+// Images mapped to "" are removed.
+func (a *Article) ReplaceImages(sources map[string]string) {
+	var remove []*html.Node
+	walk(a.content, func(n *html.Node) {
+		if n.DataAtom != atom.Img {
+			return
+		}
+		replacement, ok := sources[attr(n, "src")]
+		if !ok {
+			return
+		}
+		if replacement == "" {
+			remove = append(remove, n)
+			return
+		}
+		setAttr(n, "src", replacement)
+	})
+	for _, n := range remove {
+		n.Parent.RemoveChild(n)
+	}
+}
+
+// Extract finds the main content of an HTML document. Relative links and
+// image sources are resolved against base, which should be the page URL.
+func Extract(body []byte, contentType string, base *url.URL) (*Article, error) {
+	reader, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		reader = bytes.NewReader(body)
+	}
+	doc, err := html.Parse(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	article := &Article{}
+	readMeta(doc, article, &base)
+
+	root := find(doc, atom.Body)
+	if root == nil {
+		root = doc
+	}
+	prune(root)
+
+	content := grabArticle(root)
+	sanitize(content, base)
+	clean(content)
+
+	article.content = content
+	article.Text = blockText(content)
+	if strings.TrimSpace(article.Text) == "" {
+		return nil, ErrNoContent
+	}
+	if article.Excerpt == "" {
+		article.Excerpt = firstParagraph(content)
+	}
+	return article, nil
+}
+
+// readMeta fills the title, byline and excerpt from <head>, and moves base
+// to the document's <base href> when present.
+func readMeta(doc *html.Node, article *Article, base **url.URL) {
+	meta := map[string]string{}
+	var title string
+	walk(doc, func(n *html.Node) {
+		switch n.DataAtom {
+		case atom.Title:
+			if title == "" {
+				title = textContent(n)
+			}
+		case atom.Meta:
+			key := strings.ToLower(attr(n, "property"))
+			if key == "" {
+				key = strings.ToLower(attr(n, "name"))
+			}
+			if _, seen := meta[key]; key != "" && !seen {
+				meta[key] = strings.TrimSpace(attr(n, "content"))
+			}
+		case atom.Base:
+			if *base == nil {
+				return
+			}
+			if u, err := (*base).Parse(attr(n, "href")); err == nil {
+				*base = u
+			}
+		}
+	})
+
+	article.Title = collapse(firstNonEmpty(meta["og:title"], meta["twitter:title"], title))
+	article.Byline = collapse(firstNonEmpty(meta["author"], meta["article:author"], meta["dc.creator"]))
+	article.Excerpt = collapse(firstNonEmpty(meta["og:description"], meta["twitter:description"], meta["description"]))
+}
+
+// prune removes elements that never hold article content, and elements whose
+// class or id marks them as boilerplate.
+func prune(root *html.Node) {
+	var remove []*html.Node
+	walk(root, func(n *html.Node) {
+		if n.Type == html.CommentNode {
+			remove = append(remove, n)
+			return
+		}
+		if n.Type != html.ElementNode {
+			return
+		}
+		switch n.DataAtom {
+		case atom.Script, atom.Style, atom.Noscript, atom.Iframe, atom.Form, atom.Nav,
+			atom.Footer, atom.Aside, atom.Button, atom.Input, atom.Select, atom.Textarea,
+			atom.Svg, atom.Canvas, atom.Object, atom.Embed, atom.Link, atom.Meta, atom.Template:
+			remove = append(remove, n)
+			return
+		case atom.Body, atom.Article, atom.Main, atom.A:
+			return
+		}
+		if attr(n, "hidden") != "" || attr(n, "aria-hidden") == "true" || attr(n, "role") == "navigation" {
+			remove = append(remove, n)
+			return
+		}
+		hint := attr(n, "class") + " " + attr(n, "id")
+		if unlikelyPattern.MatchString(hint) && !maybePattern.MatchString(hint) {
+			remove = append(remove, n)
+		}
+	})
+	for _, n := range remove {
+		if n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+	}
+}
+
+// grabArticle scores the document and returns a new <div> holding the best
+// candidate and any siblings that look like part of the same article.
+func grabArticle(root *html.Node) *html.Node {
+	scores := map[*html.Node]float64{}
+	var candidates []*html.Node
+
+	initialize := func(n *html.Node) {
+		if _, ok := scores[n]; ok {
+			return
+		}
+		scores[n] = tagWeight(n) + classWeight(n)
+		candidates = append(candidates, n)
+	}
+
+	walk(root, func(n *html.Node) {
+		if !isParagraphLike(n) {
+			return
+		}
+		text := textContent(n)
+		if len(text) < minTextLength {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")) + float64(min(len(text)/100, 3))
+
+		parent := n.Parent
+		if parent == nil || parent.Type != html.ElementNode {
+			return
+		}
+		initialize(parent)
+		scores[parent] += score
+		if grand := parent.Parent; parent != root && grand != nil && grand.Type == html.ElementNode {
+			initialize(grand)
+			scores[grand] += score / 2
+		}
+	})
+
+	var top *html.Node
+	for _, c := range candidates {
+		scores[c] *= 1 - linkDensity(c)
+		if top == nil || scores[c] > scores[top] {
+			top = c
+		}
+	}
+
+	container := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	if top == nil {
+		// No paragraphs: keep the whole body
+		for c := root.FirstChild; c != nil; {
+			next := c.NextSibling
+			root.RemoveChild(c)
+			container.AppendChild(c)
+			c = next
+		}
+		return container
+	}
+
+	threshold := max(10, scores[top]*0.2)
+	parent := top.Parent
+	if parent == nil {
+		top.Parent = nil
+		container.AppendChild(top)
+		return container
+	}
+	for s := parent.FirstChild; s != nil; {
+		next := s.NextSibling
+		include := s == top
+		if !include && s.Type == html.ElementNode {
+			if score, ok := scores[s]; ok && score >= threshold {
+				include = true
+			} else if s.DataAtom == atom.P {
+				text := textContent(s)
+				density := linkDensity(s)
+				include = (len(text) > 80 && density < 0.25) ||
+					(len(text) > 0 && density == 0 && strings.ContainsAny(text, ".!?"))
+			}
+		}
+		if include {
+			parent.RemoveChild(s)
+			container.AppendChild(s)
+		}
+		s = next
+	}
+	return container
+}
+
+// clean drops leftover boilerplate inside the article: link-heavy blocks,
+// negatively weighted blocks with little text, and empty paragraphs.
+func clean(content *html.Node) {
+	var remove []*html.Node
+	walk(content, func(n *html.Node) {
+		if n == content || n.Type != html.ElementNode {
+			return
+		}
+		switch n.DataAtom {
+		case atom.Div, atom.Section, atom.Ul, atom.Ol, atom.Table, atom.P:
+		default:
+			return
+		}
+		text := textContent(n)
+		images := countTag(n, atom.Img)
+		switch {
+		case text == "" && images == 0:
+			remove = append(remove, n)
+		case n.DataAtom != atom.P && linkDensity(n) > 0.5 && images == 0:
+			remove = append(remove, n)
+		case n.DataAtom != atom.P && len(text) < 200 && images == 0 && countTag(n, atom.P) == 0 && strings.Count(text, ",") < 2 && linkDensity(n) > 0.2:
+			remove = append(remove, n)
+		}
+	})
+	for _, n := range remove {
+		if n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+	}
+}
+
+// isParagraphLike reports whether n holds running text: a paragraph, or a
+// div used as one (no block-level children).
+func isParagraphLike(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	switch n.DataAtom {
+	case atom.P, atom.Pre, atom.Td, atom.Blockquote:
+		return true
+	case atom.Div, atom.Section:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && isBlock(c) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// tagWeight is the starting score for a candidate container.
+func tagWeight(n *html.Node) float64 {
+	switch n.DataAtom {
+	case atom.Article:
+		return 10
+	case atom.Div, atom.Main, atom.Section:
+		return 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		return 3
+	case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		return -3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		return -5
+	}
+	return 0
+}
+
+// classWeight scores class and id hints.
+func classWeight(n *html.Node) float64 {
+	weight := 0.0
+	for _, hint := range []string{attr(n, "class"), attr(n, "id")} {
+		if hint == "" {
+			continue
+		}
+		if negativePattern.MatchString(hint) {
+			weight -= 25
+		}
+		if positivePattern.MatchString(hint) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+// linkDensity is the share of n's text that sits inside links.
+func linkDensity(n *html.Node) float64 {
+	total := len(textContent(n))
+	if total == 0 {
+		return 0
+	}
+	linked := 0
+	walk(n, func(c *html.Node) {
+		if c.DataAtom == atom.A {
+			linked += len(textContent(c))
+		}
+	})
+	return float64(linked) / float64(total)
+}
+
+// isBlock reports whether n is a block-level element.
+func isBlock(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Address, atom.Article, atom.Aside, atom.Blockquote, atom.Dd, atom.Div, atom.Dl, atom.Dt,
+		atom.Figcaption, atom.Figure, atom.Footer, atom.Form, atom.H1, atom.H2, atom.H3, atom.H4,
+		atom.H5, atom.H6, atom.Header, atom.Hr, atom.Li, atom.Main, atom.Nav, atom.Ol, atom.P,
+		atom.Pre, atom.Section, atom.Table, atom.Tr, atom.Td, atom.Th, atom.Ul, atom.Br:
+		return true
+	}
+	return false
+}
+
+// textContent returns n's text with whitespace collapsed.
+func textContent(n *html.Node) string {
+	var buf strings.Builder
+	walk(n, func(c *html.Node) {
+		if c.Type == html.TextNode {
+			buf.WriteString(c.Data)
+			buf.WriteByte(' ')
+		}
+	})
+	return collapse(buf.String())
+}
+
+// blockText returns n's text with a blank line between block-level elements.
+func blockText(n *html.Node) string {
+	var buf strings.Builder
+	var visit func(*html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			buf.WriteString(n.Data)
+			return
+		}
+		block := n.Type == html.ElementNode && isBlock(n)
+		if block {
+			buf.WriteString("\n\n")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+		if block {
+			buf.WriteString("\n\n")
+		}
+	}
+	visit(n)
+
+	var paragraphs []string
+	for _, p := range strings.Split(buf.String(), "\n\n") {
+		if p = collapse(p); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// firstParagraph returns the text of the first non-empty paragraph, capped at 300 characters.
+func firstParagraph(content *html.Node) string {
+	var excerpt string
+	walk(content, func(n *html.Node) {
+		if excerpt == "" && n.DataAtom == atom.P {
+			excerpt = textContent(n)
+		}
+	})
+	if runes := []rune(excerpt); len(runes) > 300 {
+		excerpt = strings.TrimSpace(string(runes[:300])) + "…"
+	}
+	return excerpt
+}
+
+// countTag counts descendants of n with the given tag.
+func countTag(n *html.Node, tag atom.Atom) int {
+	count := 0
+	walk(n, func(c *html.Node) {
+		if c != n && c.DataAtom == tag {
+			count++
+		}
+	})
+	return count
+}
+
+// walk calls fn for n and all its descendants in document order.
+// fn may not remove nodes; collect them and remove after walking.
+func walk(n *html.Node, fn func(*html.Node)) {
+	fn(n)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
+
+// find returns the first element with the given tag.
+func find(n *html.Node, tag atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == tag {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := find(c, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// attr returns the value of an attribute, or "".
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// setAttr sets or adds an attribute.
+func setAttr(n *html.Node, key, val string) {
+	for i, a := range n.Attr {
+		if a.Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
+
+// firstNonEmpty returns the first non-blank value.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// collapse trims s and folds runs of whitespace into single spaces.
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,152 @@
+package readability
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedTags are kept in sanitized output; other elements are unwrapped,
+// keeping their children.
+var allowedTags = map[atom.Atom]bool{
+	atom.A: true, atom.Abbr: true, atom.B: true, atom.Blockquote: true, atom.Br: true,
+	atom.Caption: true, atom.Code: true, atom.Dd: true, atom.Del: true, atom.Div: true,
+	atom.Dl: true, atom.Dt: true, atom.Em: true, atom.Figcaption: true, atom.Figure: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Hr: true, atom.I: true, atom.Img: true, atom.Ins: true, atom.Kbd: true, atom.Li: true,
+	atom.Mark: true, atom.Ol: true, atom.P: true, atom.Pre: true, atom.Q: true, atom.S: true,
+	atom.Samp: true, atom.Small: true, atom.Strong: true, atom.Sub: true, atom.Sup: true,
+	atom.Table: true, atom.Tbody: true, atom.Td: true, atom.Tfoot: true, atom.Th: true,
+	atom.Thead: true, atom.Tr: true, atom.U: true, atom.Ul: true,
+}
+
+// droppedTags are removed together with their content.
+var droppedTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Iframe: true,
+	atom.Object: true, atom.Embed: true, atom.Svg: true, atom.Math: true,
+	atom.Form: true, atom.Input: true, atom.Button: true, atom.Select: true,
+	atom.Textarea: true, atom.Template: true, atom.Frame: true, atom.Frameset: true,
+	atom.Link: true, atom.Meta: true, atom.Base: true, atom.Head: true, atom.Title: true,
+	atom.Audio: true, atom.Video: true, atom.Source: true, atom.Track: true,
+}
+
+// allowedAttrs lists the attributes kept per tag. Everything else, including
+// every style, class, id and event handler, is stripped.
+var allowedAttrs = map[atom.Atom][]string{
+	atom.A:   {"href", "title"},
+	atom.Img: {"src", "alt", "title"},
+	atom.Td:  {"colspan", "rowspan"},
+	atom.Th:  {"colspan", "rowspan"},
+}
+
+// sanitize rewrites the tree under root to the allowlist, in place. Links and
+// image sources are made absolute against base; anything that is not an
+// http(s) URL (javascript:, data:, ...) is dropped.
+func sanitize(root *html.Node, base *url.URL) {
+	for c := root.FirstChild; c != nil; {
+		next := c.NextSibling
+		sanitizeNode(c, base)
+		c = next
+	}
+}
+
+func sanitizeNode(n *html.Node, base *url.URL) {
+	switch n.Type {
+	case html.TextNode:
+		return
+	case html.ElementNode:
+	default:
+		n.Parent.RemoveChild(n)
+		return
+	}
+
+	if droppedTags[n.DataAtom] {
+		n.Parent.RemoveChild(n)
+		return
+	}
+
+	// Sanitize children first so unwrapping moves clean nodes
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		sanitizeNode(c, base)
+		c = next
+	}
+
+	if !allowedTags[n.DataAtom] {
+		unwrap(n)
+		return
+	}
+
+	if n.DataAtom == atom.Img && attr(n, "src") == "" {
+		// Lazy-loading pages keep the real source in a data attribute
+		for _, key := range []string{"data-src", "data-original", "data-lazy-src"} {
+			if v := attr(n, key); v != "" {
+				setAttr(n, "src", v)
+				break
+			}
+		}
+	}
+
+	var attrs []html.Attribute
+	for _, a := range n.Attr {
+		if a.Namespace != "" || !slices.Contains(allowedAttrs[n.DataAtom], a.Key) {
+			continue
+		}
+		if a.Key == "href" || a.Key == "src" {
+			abs := absoluteURL(base, a.Val)
+			if abs == "" {
+				continue
+			}
+			a.Val = abs
+		}
+		attrs = append(attrs, a)
+	}
+	n.Attr = attrs
+
+	switch n.DataAtom {
+	case atom.Img:
+		if attr(n, "src") == "" {
+			n.Parent.RemoveChild(n)
+		}
+	case atom.A:
+		if attr(n, "href") == "" {
+			unwrap(n)
+			return
+		}
+		n.Attr = append(n.Attr, html.Attribute{Key: "rel", Val: "noopener noreferrer nofollow"})
+	}
+}
+
+// unwrap replaces n with its children.
+func unwrap(n *html.Node) {
+	parent := n.Parent
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		n.RemoveChild(c)
+		parent.InsertBefore(c, n)
+		c = next
+	}
+	parent.RemoveChild(n)
+}
+
+// absoluteURL resolves ref against base and returns it only when it is an http(s) URL.
+func absoluteURL(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	return u.String()
+}
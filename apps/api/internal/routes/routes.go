@@ -11,6 +11,7 @@ import (
 
 	"desis-keep/apps/api/internal/ai"
 	"desis-keep/apps/api/internal/cache"
+	"desis-keep/apps/api/internal/collab"
 	"desis-keep/apps/api/internal/config"
 	"desis-keep/apps/api/internal/handlers"
 	"desis-keep/apps/api/internal/jobs"
@@ -28,6 +29,7 @@ type Services struct {
 	Mailer  *mail.Mailer
 	AI      *ai.AI
 	Jobs    *jobs.Client
+	Collab  *collab.Hub
 }
 
 // Setup configures all routes and returns the Gin engine.
@@ -47,6 +49,9 @@ func Setup(db *gorm.DB, cfg *config.Config, svc *Services) *gin.Engine {
 	authHandler := &handlers.AuthHandler{
 		DB:          db,
 		AuthService: authService,
+		Jobs:        svc.Jobs,
+		AppName:     cfg.AppName,
+		FrontendURL: cfg.FrontendURL,
 	}
 	userHandler := &handlers.UserHandler{
 		DB: db,
@@ -56,8 +61,13 @@ func Setup(db *gorm.DB, cfg *config.Config, svc *Services) *gin.Engine {
 		Storage: svc.Storage,
 		Jobs:    svc.Jobs,
 	}
+	usageService := services.NewUsageService(db, cfg.AIMonthlyTokenLimit)
 	aiHandler := &handlers.AIHandler{
-		AI: svc.AI,
+		AI:    svc.AI,
+		Usage: usageService,
+	}
+	if svc.AI.CanComplete() {
+		aiHandler.AskService = services.NewAskService(db, svc.AI)
 	}
 	jobsHandler := &handlers.JobsHandler{
 		RedisURL: cfg.RedisURL,
@@ -65,14 +75,27 @@ func Setup(db *gorm.DB, cfg *config.Config, svc *Services) *gin.Engine {
 	cronHandler := &handlers.CronHandler{}
 	blogHandler := handlers.NewBlogHandler(db)
 	labelHandler := handlers.NewLabelHandler(db)
-	noteHandler := handlers.NewNoteHandler(db)
-	linkHandler := handlers.NewLinkHandler(db)
+	noteHandler := handlers.NewNoteHandler(db, svc.Jobs)
+	linkHandler := handlers.NewLinkHandler(db, svc.Jobs, svc.Storage)
 	imageHandler := handlers.NewImageHandler(db)
-	fileHandler := handlers.NewFileHandler(db)
-	searchHandler := handlers.NewSearchHandler(db)
+	fileHandler := handlers.NewFileHandler(db, svc.Jobs)
+	searchHandler := handlers.NewSearchHandler(db, svc.AI)
+	suggestionHandler := handlers.NewSuggestionHandler(db, svc.AI)
+	aiUsageHandler := handlers.NewAIUsageHandler(db, usageService)
+	importHandler := handlers.NewImportHandler(db, svc.Jobs, svc.Storage)
+	exportHandler := handlers.NewExportHandler(db, svc.Jobs, svc.Storage)
+	reminderHandler := handlers.NewReminderHandler(db)
+	notificationHandler := handlers.NewNotificationHandler(db)
+	referenceHandler := handlers.NewReferenceHandler(db)
+	shareHandler := handlers.NewShareHandler(db, svc.Jobs)
+	publicLinkHandler := handlers.NewPublicLinkHandler(db, svc.Storage, svc.Cache)
+	collabHandler := handlers.NewCollabHandler(db, svc.Collab, cfg.CORSOrigins)
 	oauthHandler := handlers.NewOAuthHandler(db, cfg, authService)
 
 	r := gin.New()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Printf("Warning: invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	// Set max multipart memory for file uploads (50MB)
 	r.MaxMultipartMemory = 50 << 20 // 50 MB
@@ -199,6 +222,13 @@ func Setup(db *gorm.DB, cfg *config.Config, svc *Services) *gin.Engine {
 		blogs.GET("/:slug", blogHandler.GetBySlug)
 	}
 
+	// Public share links (no auth required)
+	public := r.Group("/api/public")
+	{
+		public.GET("/:token", publicLinkHandler.Open)
+		public.POST("/:token", publicLinkHandler.Open)
+	}
+
 	// Public auth routes
 	auth := r.Group("/api/auth")
 	{
@@ -207,6 +237,7 @@ func Setup(db *gorm.DB, cfg *config.Config, svc *Services) *gin.Engine {
 		auth.POST("/refresh", authHandler.Refresh)
 		auth.POST("/forgot-password", authHandler.ForgotPassword)
 		auth.POST("/reset-password", authHandler.ResetPassword)
+		auth.POST("/verify-email", authHandler.VerifyEmail)
 
 		// Google OAuth routes
 		auth.GET("/google", oauthHandler.GoogleLogin)
@@ -219,6 +250,7 @@ func Setup(db *gorm.DB, cfg *config.Config, svc *Services) *gin.Engine {
 	{
 		protected.GET("/auth/me", authHandler.Me)
 		protected.POST("/auth/logout", authHandler.Logout)
+		protected.POST("/auth/verify-email/resend", authHandler.ResendVerification)
 
 		// User routes (authenticated)
 		protected.GET("/users/:id", userHandler.GetByID)
@@ -233,6 +265,8 @@ func Setup(db *gorm.DB, cfg *config.Config, svc *Services) *gin.Engine {
 		protected.POST("/ai/complete", aiHandler.Complete)
 		protected.POST("/ai/chat", aiHandler.Chat)
 		protected.POST("/ai/stream", aiHandler.Stream)
+		protected.POST("/ai/ask", aiHandler.Ask)
+		protected.GET("/ai/usage", aiHandler.GetUsage)
 
 		// Labels
 		protected.GET("/labels", labelHandler.List)
@@ -247,14 +281,33 @@ func Setup(db *gorm.DB, cfg *config.Config, svc *Services) *gin.Engine {
 		protected.DELETE("/notes/:id", noteHandler.Delete)
 		protected.PUT("/notes/:id/restore", noteHandler.Restore)
 		protected.DELETE("/notes/:id/permanent", noteHandler.PermanentDelete)
+		protected.POST("/notes/:id/convert", noteHandler.Convert)
+		protected.GET("/notes/:id/revisions", noteHandler.Revisions)
+		protected.GET("/notes/:id/revisions/diff", noteHandler.DiffRevisions)
+		protected.GET("/notes/:id/revisions/:revision_id", noteHandler.Revision)
+		protected.POST("/notes/:id/revisions/:revision_id/restore", noteHandler.RestoreRevision)
+		protected.GET("/notes/:id/backlinks", referenceHandler.Backlinks)
+		protected.POST("/notes/:id/backlinks/rewrite", noteHandler.RewriteReferences)
+		protected.GET("/notes/:id/collab", collabHandler.Connect)
+		protected.GET("/graph", referenceHandler.Graph)
+		protected.POST("/notes/:id/items", noteHandler.AddItem)
+		protected.PUT("/notes/:id/items/order", noteHandler.ReorderItems)
+		protected.PUT("/notes/:id/items/:item_id", noteHandler.UpdateItem)
+		protected.PUT("/notes/:id/items/:item_id/toggle", noteHandler.ToggleItem)
+		protected.DELETE("/notes/:id/items/:item_id", noteHandler.DeleteItem)
 
 		// Links
 		protected.GET("/links", linkHandler.List)
 		protected.POST("/links", linkHandler.Create)
+		protected.GET("/links/health", linkHandler.HealthReport)
+		protected.POST("/links/health/apply-redirects", linkHandler.ApplyRedirects)
 		protected.PUT("/links/:id", linkHandler.Update)
 		protected.DELETE("/links/:id", linkHandler.Delete)
 		protected.PUT("/links/:id/restore", linkHandler.Restore)
 		protected.DELETE("/links/:id/permanent", linkHandler.PermanentDelete)
+		protected.GET("/links/:id/snapshot", linkHandler.GetSnapshot)
+		protected.POST("/links/:id/snapshot", linkHandler.CreateSnapshot)
+		protected.DELETE("/links/:id/snapshot", linkHandler.DeleteSnapshot)
 
 		// Images
 		protected.GET("/images", imageHandler.List)
@@ -274,6 +327,41 @@ func Setup(db *gorm.DB, cfg *config.Config, svc *Services) *gin.Engine {
 
 		// Search
 		protected.GET("/search", searchHandler.Search)
+		protected.GET("/search/semantic", searchHandler.Semantic)
+
+		// AI label suggestions
+		protected.GET("/suggestions", suggestionHandler.List)
+		protected.PUT("/suggestions/:id/accept", suggestionHandler.Accept)
+		protected.PUT("/suggestions/:id/reject", suggestionHandler.Reject)
+
+		// Import and export
+		protected.POST("/import/bookmarks", importHandler.Bookmarks)
+		protected.POST("/import/google-keep", importHandler.GoogleKeep)
+		protected.POST("/import/evernote", importHandler.Evernote)
+		protected.GET("/import/jobs", importHandler.List)
+		protected.GET("/import/jobs/:id", importHandler.GetByID)
+		protected.GET("/export/bookmarks", exportHandler.Bookmarks)
+		protected.GET("/export/obsidian", exportHandler.Obsidian)
+
+		// Reminders and notifications
+		protected.GET("/reminders", reminderHandler.List)
+		protected.POST("/reminders/:id/snooze", reminderHandler.Snooze)
+		protected.PUT("/notes/:id/reminder", reminderHandler.Set)
+		protected.DELETE("/notes/:id/reminder", reminderHandler.Delete)
+		protected.GET("/notifications", notificationHandler.List)
+		protected.PUT("/notifications/read-all", notificationHandler.MarkAllRead)
+		protected.PUT("/notifications/:id/read", notificationHandler.MarkRead)
+
+		// Sharing
+		protected.GET("/shares", shareHandler.List)
+		protected.GET("/shares/received", shareHandler.Received)
+		protected.POST("/shares", shareHandler.Create)
+		protected.PUT("/shares/:id", shareHandler.Update)
+		protected.DELETE("/shares/:id", shareHandler.Delete)
+		protected.GET("/public-links", publicLinkHandler.List)
+		protected.POST("/public-links", publicLinkHandler.Create)
+		protected.PUT("/public-links/:id", publicLinkHandler.Update)
+		protected.DELETE("/public-links/:id", publicLinkHandler.Delete)
 
 		// grit:routes:protected
 	}
@@ -284,6 +372,10 @@ func Setup(db *gorm.DB, cfg *config.Config, svc *Services) *gin.Engine {
 		profile.GET("", userHandler.GetProfile)
 		profile.PUT("", userHandler.UpdateProfile)
 		profile.DELETE("", userHandler.DeleteProfile)
+		profile.POST("/export", exportHandler.StartAccount)
+		profile.GET("/export", exportHandler.ListAccount)
+		profile.GET("/export/:id", exportHandler.GetAccount)
+		profile.POST("/import", importHandler.Account)
 	}
 
 	// Admin routes
@@ -303,6 +395,14 @@ func Setup(db *gorm.DB, cfg *config.Config, svc *Services) *gin.Engine {
 		admin.DELETE("/admin/jobs/queue/:queue", jobsHandler.ClearQueue)
 		admin.GET("/admin/cron/tasks", cronHandler.ListTasks)
 
+		// AI usage and quotas
+		admin.GET("/admin/ai/usage", aiUsageHandler.Summary)
+		admin.GET("/admin/ai/usage/:user_id", aiUsageHandler.UserUsage)
+		admin.PUT("/admin/ai/quotas/:user_id", aiUsageHandler.SetQuota)
+
+		// Link maintenance
+		admin.POST("/admin/links/dedupe", linkHandler.Dedupe)
+
 		// Blog management (admin)
 		admin.GET("/admin/blogs", blogHandler.List)
 		admin.POST("/admin/blogs", blogHandler.Create)
@@ -0,0 +1,484 @@
+// Package rrule parses and expands the recurrence rules of RFC 5545
+// (iCalendar), such as "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10". It supports the
+// parts reminders need: FREQ (DAILY, WEEKLY, MONTHLY or YEARLY), INTERVAL,
+// COUNT, UNTIL, BYDAY (with ordinals such as 2TU or -1FR for monthly and
+// yearly rules), BYMONTHDAY and BYMONTH. Rules that use anything else are
+// rejected rather than expanded wrongly.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequencies.
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+	Yearly  = "YEARLY"
+)
+
+// ErrInvalid is wrapped by every error Parse returns.
+var ErrInvalid = errors.New("invalid recurrence rule")
+
+// maxSearchDays bounds the days expansion looks at for one occurrence: 400
+// years, after which the Gregorian calendar repeats.
+const maxSearchDays = 146097
+
+// periodDays is the most days a period of each frequency has.
+var periodDays = map[string]int{Daily: 1, Weekly: 7, Monthly: 31, Yearly: 366}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// WeekdayNum is a BYDAY entry: a weekday, and for monthly and yearly rules
+// optionally which one of the month (1 is the first, -1 the last; 0 is every).
+type WeekdayNum struct {
+	Day time.Weekday
+	N   int
+}
+
+// Rule is a parsed recurrence rule.
+type Rule struct {
+	Freq       string
+	Interval   int
+	Count      int       // 0 is unlimited
+	Until      time.Time // zero is unlimited
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+}
+
+// Parse reads a rule, with or without an "RRULE:" prefix. An UNTIL without a
+// time zone is in UTC.
+func Parse(s string) (*Rule, error) {
+	return ParseIn(s, time.UTC)
+}
+
+// ParseIn reads a rule like Parse, taking an UNTIL without a time zone (a
+// floating date or date-time) to be in loc.
+func ParseIn(s string, loc *time.Location) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	if len(s) >= 6 && strings.EqualFold(s[:6], "RRULE:") {
+		s = s[6:]
+	}
+	r := &Rule{Interval: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		key = strings.ToUpper(strings.TrimSpace(key))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%w: %q is not NAME=VALUE", ErrInvalid, part)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%w: %s given twice", ErrInvalid, key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			switch value {
+			case Daily, Weekly, Monthly, Yearly:
+				r.Freq = value
+			default:
+				err = fmt.Errorf("FREQ %s is not supported", value)
+			}
+		case "INTERVAL":
+			r.Interval, err = number(value, 1, 1000)
+		case "COUNT":
+			r.Count, err = number(value, 1, 10000)
+		case "UNTIL":
+			r.Until, err = parseUntil(value, loc)
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				var day WeekdayNum
+				if day, err = parseWeekday(v); err != nil {
+					break
+				}
+				r.ByDay = append(r.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			for _, v := range strings.Split(value, ",") {
+				var day int
+				if day, err = number(v, -31, 31); err != nil || day == 0 {
+					err = fmt.Errorf("BYMONTHDAY %s is out of range", v)
+					break
+				}
+				r.ByMonthDay = append(r.ByMonthDay, day)
+			}
+		case "BYMONTH":
+			for _, v := range strings.Split(value, ",") {
+				var month int
+				if month, err = number(v, 1, 12); err != nil {
+					break
+				}
+				r.ByMonth = append(r.ByMonth, time.Month(month))
+			}
+		case "WKST":
+			// Weeks always start on Monday here
+			if value != "MO" {
+				err = fmt.Errorf("WKST %s is not supported", value)
+			}
+		default:
+			err = fmt.Errorf("%s is not supported", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalid)
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, fmt.Errorf("%w: COUNT and UNTIL cannot be combined", ErrInvalid)
+	}
+	if r.Freq == Weekly && len(r.ByMonthDay) > 0 {
+		return nil, fmt.Errorf("%w: BYMONTHDAY cannot be used with WEEKLY", ErrInvalid)
+	}
+	for _, d := range r.ByDay {
+		if d.N != 0 && (r.Freq == Daily || r.Freq == Weekly || (r.Freq == Yearly && len(r.ByMonth) == 0)) {
+			return nil, fmt.Errorf("%w: numbered BYDAY needs MONTHLY, or YEARLY with BYMONTH", ErrInvalid)
+		}
+	}
+	if !r.possible() {
+		return nil, fmt.Errorf("%w: BYMONTH, BYMONTHDAY and BYDAY never match the same day", ErrInvalid)
+	}
+	return r, nil
+}
+
+// possible reports whether some day of the calendar matches BYMONTH,
+// BYMONTHDAY and BYDAY together, trying every length of the allowed months
+// with every weekday they may start on.
+func (r *Rule) possible() bool {
+	for month := time.January; month <= time.December; month++ {
+		if !r.matchesMonth(month) {
+			continue
+		}
+		lengths := []int{daysIn(2001, month)}
+		if month == time.February {
+			lengths = append(lengths, 29)
+		}
+		for _, length := range lengths {
+			for first := time.Sunday; first <= time.Saturday; first++ {
+				for day := 1; day <= length; day++ {
+					if r.matchesDay(day, (first+time.Weekday(day-1))%7, length) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// String returns the rule in canonical form, without the "RRULE:" prefix.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			days[i] = strings.ToUpper(d.Day.String()[:2])
+			if d.N != 0 {
+				days[i] = strconv.Itoa(d.N) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, d := range r.ByMonthDay {
+			days[i] = strconv.Itoa(d)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]string, len(r.ByMonth))
+		for i, m := range r.ByMonth {
+			months[i] = strconv.Itoa(int(m))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// After returns the first occurrence later than t of the series that starts
+// at start, and false when the series ends before then. start is always the
+// first occurrence. Occurrences keep start's wall-clock time in start's
+// location, so a daily 9:00 reminder stays at 9:00 across DST changes.
+// The search looks at no more days than 400 years hold, from t's period on,
+// or from start for a rule with COUNT.
+func (r *Rule) After(start, t time.Time) (time.Time, bool) {
+	if start.After(t) {
+		return start, true
+	}
+	count := 1
+	first := 0
+	if r.Count == 0 {
+		// Without COUNT the occurrences before t need not be counted, so the
+		// search starts at the period before the one holding t
+		first = max(r.periodsBefore(start, t)-1, 0)
+	}
+	for period, days := first, 0; days < maxSearchDays; period++ {
+		days += periodDays[r.Freq]
+		for _, occ := range r.period(start, period) {
+			if !occ.After(start) {
+				continue
+			}
+			if !r.Until.IsZero() && occ.After(r.Until) {
+				return time.Time{}, false
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return time.Time{}, false
+			}
+			if occ.After(t) {
+				return occ, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// periodsBefore returns how many whole periods from start come before t's
+// period, counting in steps of Interval.
+func (r *Rule) periodsBefore(start, t time.Time) int {
+	sy, sm, sd := start.Date()
+	ty, tm, td := t.In(start.Location()).Date()
+	days := int((time.Date(ty, tm, td, 0, 0, 0, 0, time.UTC).Unix() - time.Date(sy, sm, sd, 0, 0, 0, 0, time.UTC).Unix()) / 86400)
+	var n int
+	switch r.Freq {
+	case Daily:
+		n = days
+	case Weekly:
+		n = days / 7
+	case Monthly:
+		n = (ty-sy)*12 + int(tm-sm)
+	case Yearly:
+		n = ty - sy
+	}
+	return n / r.Interval
+}
+
+// period returns the occurrences of the n-th period (day, week, month or
+// year, counting in steps of Interval from start), in order.
+func (r *Rule) period(start time.Time, n int) []time.Time {
+	y, m, d := start.Date()
+	at := func(year int, month time.Month, day int) time.Time {
+		return wallTime(time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), 0, time.UTC), start.Location())
+	}
+	// Days are matched as UTC dates, and only those that match get a time
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	step := n * r.Interval
+
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		day := date(y, m, d+step)
+		if r.matchesMonth(day.Month()) && r.matchesDay(day.Day(), day.Weekday(), daysIn(day.Year(), day.Month())) {
+			days = append(days, at(day.Date()))
+		}
+	case Weekly:
+		monday := d - (int(start.Weekday())+6)%7 + 7*step
+		wanted := r.ByDay
+		if len(wanted) == 0 {
+			wanted = []WeekdayNum{{Day: start.Weekday()}}
+		}
+		for _, wd := range wanted {
+			day := date(y, m, monday+(int(wd.Day)+6)%7)
+			if r.matchesMonth(day.Month()) {
+				days = append(days, at(day.Date()))
+			}
+		}
+	case Monthly:
+		first := date(y, m+time.Month(step), 1)
+		if r.matchesMonth(first.Month()) {
+			days = r.monthDays(first, d, at)
+		}
+	case Yearly:
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{m}
+			if len(r.ByDay) > 0 || len(r.ByMonthDay) > 0 {
+				months = []time.Month{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+			}
+		}
+		for _, month := range months {
+			days = append(days, r.monthDays(date(y+step, month, 1), d, at)...)
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return dedupe(days)
+}
+
+// monthDays returns the days of first's month (a UTC date) selected by
+// BYMONTHDAY and BYDAY (both must match when both are given), or day itself
+// when neither is, skipping months too short to have it.
+func (r *Rule) monthDays(first time.Time, day int, at func(int, time.Month, int) time.Time) []time.Time {
+	year, month := first.Year(), first.Month()
+	length := daysIn(year, month)
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		if day > length {
+			return nil
+		}
+		return []time.Time{at(year, month, day)}
+	}
+
+	var days []time.Time
+	weekday := first.Weekday()
+	for d := 1; d <= length; d++ {
+		if r.matchesDay(d, weekday, length) {
+			days = append(days, at(year, month, d))
+		}
+		weekday = (weekday + 1) % 7
+	}
+	return days
+}
+
+func (r *Rule) matchesMonth(m time.Month) bool {
+	return len(r.ByMonth) == 0 || slices.Contains(r.ByMonth, m)
+}
+
+// matchesDay checks BYMONTHDAY and BYDAY (both must match when both are
+// given) for a day of a month of length days.
+func (r *Rule) matchesDay(day int, weekday time.Weekday, length int) bool {
+	return r.matchesMonthDay(day, length) && r.matchesNthWeekday(day, weekday, length)
+}
+
+func (r *Rule) matchesMonthDay(day, length int) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	for _, d := range r.ByMonthDay {
+		if d == day || (d < 0 && length+d+1 == day) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesNthWeekday checks BYDAY within a month of length days.
+func (r *Rule) matchesNthWeekday(day int, weekday time.Weekday, length int) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Day != weekday {
+			continue
+		}
+		switch {
+		case wd.N == 0:
+			return true
+		case wd.N > 0 && (day-1)/7+1 == wd.N:
+			return true
+		case wd.N < 0 && (length-day)/7+1 == -wd.N:
+			return true
+		}
+	}
+	return false
+}
+
+// wallTime returns the time in loc whose wall clock reads like wall does in
+// UTC. As RFC 5545 asks, a wall-clock time that a DST change repeats is the
+// first of the two, and one it skips is read with the offset from before the
+// change, landing after the gap.
+func wallTime(wall time.Time, loc *time.Location) time.Time {
+	_, before := wall.Add(-24 * time.Hour).In(loc).Zone()
+	_, after := wall.Add(24 * time.Hour).In(loc).Zone()
+	for _, offset := range []int{before, after} {
+		t := wall.Add(-time.Duration(offset) * time.Second).In(loc)
+		if sameWallClock(t, wall) {
+			return t
+		}
+	}
+	return wall.Add(-time.Duration(before) * time.Second).In(loc)
+}
+
+func sameWallClock(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd && a.Hour() == b.Hour() && a.Minute() == b.Minute() && a.Second() == b.Second()
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func dedupe(days []time.Time) []time.Time {
+	out := days[:0]
+	for i, d := range days {
+		if i == 0 || !d.Equal(days[i-1]) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func number(s string, min, max int) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%s is not a number from %d to %d", s, min, max)
+	}
+	return n, nil
+}
+
+func parseWeekday(s string) (WeekdayNum, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 {
+		return WeekdayNum{}, fmt.Errorf("BYDAY %q is not a weekday", s)
+	}
+	day, ok := weekdays[s[len(s)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("BYDAY %q is not a weekday", s)
+	}
+	wd := WeekdayNum{Day: day}
+	if prefix := s[:len(s)-2]; prefix != "" {
+		n, err := number(strings.TrimPrefix(prefix, "+"), -5, 5)
+		if err != nil || n == 0 {
+			return WeekdayNum{}, fmt.Errorf("BYDAY %q has an invalid position", s)
+		}
+		wd.N = n
+	}
+	return wd, nil
+}
+
+// parseUntil reads a UTC date-time, or a date or date-time in loc.
+func parseUntil(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("20060102T150405", s, loc); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("20060102", s, loc); err == nil {
+		// A date covers the whole day
+		y, m, d := t.Date()
+		return time.Date(y, m, d+1, 0, 0, 0, 0, loc).Add(-time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("UNTIL %s is not a date or date-time", s)
+}
@@ -0,0 +1,240 @@
+package rrule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// occurrences returns up to n occurrences of a rule from start, start
+// included.
+func occurrences(t *testing.T, rule string, start time.Time, n int) []string {
+	t.Helper()
+	r, err := ParseIn(rule, start.Location())
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", rule, err)
+	}
+	var out []string
+	at := start.Add(-time.Second)
+	for len(out) < n {
+		next, ok := r.After(start, at)
+		if !ok {
+			break
+		}
+		out = append(out, next.Format("2006-01-02 15:04 MST"))
+		at = next
+	}
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAfter(t *testing.T) {
+	jan := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		ends  bool
+		want  []string
+	}{
+		{"daily", "FREQ=DAILY;INTERVAL=2", jan, false, []string{
+			"2026-01-01 09:00 UTC", "2026-01-03 09:00 UTC", "2026-01-05 09:00 UTC",
+		}},
+		{"weekly by day", "FREQ=WEEKLY;BYDAY=MO,FR", jan, false, []string{
+			"2026-01-01 09:00 UTC", "2026-01-02 09:00 UTC", "2026-01-05 09:00 UTC", "2026-01-09 09:00 UTC",
+		}},
+		{"second Tuesday", "FREQ=MONTHLY;BYDAY=2TU", jan, false, []string{
+			"2026-01-01 09:00 UTC", "2026-01-13 09:00 UTC", "2026-02-10 09:00 UTC", "2026-03-10 09:00 UTC",
+		}},
+		{"last Friday", "FREQ=MONTHLY;BYDAY=-1FR", jan, false, []string{
+			"2026-01-01 09:00 UTC", "2026-01-30 09:00 UTC", "2026-02-27 09:00 UTC", "2026-03-27 09:00 UTC",
+		}},
+		{"fourth Thursday of November", "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", jan, false, []string{
+			"2026-01-01 09:00 UTC", "2026-11-26 09:00 UTC", "2027-11-25 09:00 UTC",
+		}},
+		{"last day of the month", "FREQ=MONTHLY;BYMONTHDAY=-1", jan, false, []string{
+			"2026-01-01 09:00 UTC", "2026-01-31 09:00 UTC", "2026-02-28 09:00 UTC", "2026-03-31 09:00 UTC", "2026-04-30 09:00 UTC",
+		}},
+		{"second to last day", "FREQ=MONTHLY;BYMONTHDAY=-2;BYMONTH=2", jan, false, []string{
+			"2026-01-01 09:00 UTC", "2026-02-27 09:00 UTC", "2027-02-27 09:00 UTC", "2028-02-28 09:00 UTC",
+		}},
+		{"31st skips short months", "FREQ=MONTHLY", time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC), false, []string{
+			"2026-01-31 09:00 UTC", "2026-03-31 09:00 UTC", "2026-05-31 09:00 UTC",
+		}},
+		{"leap day", "FREQ=YEARLY", time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC), false, []string{
+			"2024-02-29 09:00 UTC", "2028-02-29 09:00 UTC",
+		}},
+		{"Friday the 13th", "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13", jan, false, []string{
+			"2026-01-01 09:00 UTC", "2026-02-13 09:00 UTC", "2026-03-13 09:00 UTC", "2026-11-13 09:00 UTC",
+		}},
+		{"count", "FREQ=DAILY;COUNT=3", jan, true, []string{
+			"2026-01-01 09:00 UTC", "2026-01-02 09:00 UTC", "2026-01-03 09:00 UTC",
+		}},
+		{"until includes its time", "FREQ=DAILY;UNTIL=20260103T090000Z", jan, true, []string{
+			"2026-01-01 09:00 UTC", "2026-01-02 09:00 UTC", "2026-01-03 09:00 UTC",
+		}},
+		{"until a date covers the day", "FREQ=WEEKLY;UNTIL=20260115", jan, true, []string{
+			"2026-01-01 09:00 UTC", "2026-01-08 09:00 UTC", "2026-01-15 09:00 UTC",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := len(tt.want)
+			if tt.ends {
+				n++
+			}
+			if got := occurrences(t, tt.rule, tt.start, n); !equal(got, tt.want) {
+				t.Errorf("occurrences = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAfterDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone data is not available")
+	}
+
+	// Clocks go forward on 2026-03-08 and back on 2026-11-01
+	start := time.Date(2026, 3, 7, 9, 0, 0, 0, ny)
+	want := []string{"2026-03-07 09:00 EST", "2026-03-08 09:00 EDT", "2026-03-09 09:00 EDT"}
+	if got := occurrences(t, "FREQ=DAILY", start, 3); !equal(got, want) {
+		t.Errorf("spring forward: %q, want %q", got, want)
+	}
+	start = time.Date(2026, 10, 31, 9, 0, 0, 0, ny)
+	want = []string{"2026-10-31 09:00 EDT", "2026-11-01 09:00 EST", "2026-11-02 09:00 EST"}
+	if got := occurrences(t, "FREQ=DAILY", start, 3); !equal(got, want) {
+		t.Errorf("fall back: %q, want %q", got, want)
+	}
+
+	// A time skipped by the change moves past it, only on that day, and a
+	// repeated one is the first of the two
+	start = time.Date(2026, 3, 7, 2, 30, 0, 0, ny)
+	want = []string{"2026-03-07 02:30 EST", "2026-03-08 03:30 EDT", "2026-03-09 02:30 EDT"}
+	if got := occurrences(t, "FREQ=DAILY", start, 3); !equal(got, want) {
+		t.Errorf("skipped time: %q, want %q", got, want)
+	}
+	start = time.Date(2026, 10, 31, 1, 30, 0, 0, ny)
+	want = []string{"2026-10-31 01:30 EDT", "2026-11-01 01:30 EDT", "2026-11-02 01:30 EST"}
+	if got := occurrences(t, "FREQ=DAILY", start, 3); !equal(got, want) {
+		t.Errorf("repeated time: %q, want %q", got, want)
+	}
+	if berlin, err := time.LoadLocation("Europe/Berlin"); err == nil {
+		start = time.Date(2026, 3, 28, 2, 30, 0, 0, berlin)
+		want = []string{"2026-03-28 02:30 CET", "2026-03-29 03:30 CEST"}
+		if got := occurrences(t, "FREQ=DAILY", start, 2); !equal(got, want) {
+			t.Errorf("skipped time in Berlin: %q, want %q", got, want)
+		}
+		start = time.Date(2026, 10, 24, 2, 30, 0, 0, berlin)
+		want = []string{"2026-10-24 02:30 CEST", "2026-10-25 02:30 CEST", "2026-10-26 02:30 CET"}
+		if got := occurrences(t, "FREQ=DAILY", start, 3); !equal(got, want) {
+			t.Errorf("repeated time in Berlin: %q, want %q", got, want)
+		}
+	}
+
+	// A floating UNTIL is in the reminder's time zone: 9:00 in New York is
+	// 14:00 UTC, after 12:00
+	want = []string{"2026-01-01 09:00 EST", "2026-01-02 09:00 EST"}
+	start = time.Date(2026, 1, 1, 9, 0, 0, 0, ny)
+	if got := occurrences(t, "FREQ=DAILY;UNTIL=20260102T090000", start, 5); !equal(got, want) {
+		t.Errorf("floating UNTIL: %q, want %q", got, want)
+	}
+	if got := occurrences(t, "FREQ=DAILY;UNTIL=20260102", start, 5); !equal(got, want) {
+		t.Errorf("UNTIL date: %q, want %q", got, want)
+	}
+	r, _ := Parse("FREQ=DAILY;UNTIL=20260102T120000")
+	if !r.Until.Equal(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Parse reads a floating UNTIL as %v, want UTC", r.Until)
+	}
+}
+
+func TestAfterFarFromStart(t *testing.T) {
+	// A series that started long ago skips ahead to now
+	r, _ := Parse("FREQ=DAILY")
+	start := time.Date(1900, 1, 1, 9, 0, 0, 0, time.UTC)
+	now := time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC)
+	next, ok := r.After(start, now)
+	if want := time.Date(2026, 6, 16, 9, 0, 0, 0, time.UTC); !ok || !next.Equal(want) {
+		t.Errorf("After = %v, %v; want %v", next, ok, want)
+	}
+
+	// Leap days that an INTERVAL never reaches end the search instead of
+	// running on
+	r, _ = Parse("FREQ=YEARLY;INTERVAL=4;BYMONTH=2;BYMONTHDAY=29")
+	start = time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	began := time.Now()
+	if next, ok := r.After(start, start); ok {
+		t.Errorf("After = %v, want no occurrence", next)
+	}
+	if elapsed := time.Since(began); elapsed > time.Second {
+		t.Errorf("After took %v", elapsed)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=abc",
+		"FREQ=DAILY;COUNT=2;UNTIL=20260101",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=2MO",
+		"FREQ=YEARLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=MONTHLY;BYDAY=0MO",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=YEARLY;BYMONTH=13",
+		"FREQ=DAILY;WKST=SU",
+		"FREQ=DAILY;BYHOUR=9",
+		"FREQ=DAILY;COUNT",
+		// Never match
+		"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30",
+		"FREQ=MONTHLY;BYMONTH=4,6,9,11;BYMONTHDAY=31",
+		"FREQ=DAILY;BYMONTH=2;BYMONTHDAY=-30",
+		"FREQ=MONTHLY;BYMONTHDAY=1;BYDAY=2MO",
+		"FREQ=YEARLY;BYMONTH=2;BYDAY=5MO;BYMONTHDAY=1",
+	} {
+		if _, err := Parse(rule); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalid", rule, err)
+		}
+	}
+}
+
+func TestParseString(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"RRULE:freq=weekly;byday=mo,we", "FREQ=WEEKLY;BYDAY=MO,WE"},
+		{"FREQ=MONTHLY;BYDAY=+2TU,-1FR;INTERVAL=1", "FREQ=MONTHLY;BYDAY=2TU,-1FR"},
+		{"FREQ=DAILY;UNTIL=20260102", "FREQ=DAILY;UNTIL=20260102T235959Z"},
+		{"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29;COUNT=5;WKST=MO", "FREQ=YEARLY;COUNT=5;BYMONTHDAY=29;BYMONTH=2"},
+		// A fifth Monday of February happens in some leap years
+		{"FREQ=YEARLY;BYMONTH=2;BYDAY=5MO", "FREQ=YEARLY;BYDAY=5MO;BYMONTH=2"},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got := r.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
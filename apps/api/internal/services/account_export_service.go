@@ -0,0 +1,428 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/archive"
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/storage"
+)
+
+const (
+	// exportRetention is how long a finished account export stays downloadable.
+	exportRetention = 7 * 24 * time.Hour
+	// exportStaleAfter is how long an unfinished export blocks starting another.
+	exportStaleAfter = 2 * time.Hour
+)
+
+// AccountExportService packs everything an account holds into a portable zip
+// archive (see the archive package) and keeps it in object storage for a while.
+type AccountExportService struct {
+	DB      *gorm.DB
+	Storage *storage.Storage
+}
+
+// NewAccountExportService creates a new AccountExportService instance.
+// store may be nil, in which case exports cannot be started or run.
+func NewAccountExportService(db *gorm.DB, store *storage.Storage) *AccountExportService {
+	return &AccountExportService{DB: db, Storage: store}
+}
+
+// Start records a pending export for the user. When an export of theirs is
+// already pending or running, that one is returned instead and started is false.
+// The caller enqueues the job that runs it.
+func (s *AccountExportService) Start(userID uint) (job *models.ExportJob, started bool, err error) {
+	if s.Storage == nil {
+		return nil, false, ErrStorageUnavailable
+	}
+
+	var active models.ExportJob
+	err = s.DB.Where("user_id = ? AND status IN ? AND created_at > ?",
+		userID, []string{models.ExportPending, models.ExportRunning}, time.Now().Add(-exportStaleAfter)).
+		Order("created_at DESC").
+		First(&active).Error
+	if err == nil {
+		return &active, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, fmt.Errorf("checking exports: %w", err)
+	}
+
+	job = &models.ExportJob{UserID: userID, Status: models.ExportPending}
+	if err := s.DB.Create(job).Error; err != nil {
+		return nil, false, fmt.Errorf("creating export: %w", err)
+	}
+	return job, true, nil
+}
+
+// Get returns one of the user's exports.
+func (s *AccountExportService) Get(id, userID uint) (*models.ExportJob, error) {
+	var job models.ExportJob
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&job).Error; err != nil {
+		return nil, fmt.Errorf("export not found: %w", err)
+	}
+	return &job, nil
+}
+
+// List returns the user's most recent exports, newest first.
+func (s *AccountExportService) List(userID uint, limit int) ([]models.ExportJob, error) {
+	var jobs []models.ExportJob
+	if err := s.DB.Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Find(&jobs).Error; err != nil {
+		return nil, fmt.Errorf("fetching exports: %w", err)
+	}
+	return jobs, nil
+}
+
+// Run builds and uploads the archive of a pending export. It returns the
+// finished export, or nil when there is nothing to do. An export that already
+// completed is returned as is, so a retried job does not build it twice.
+func (s *AccountExportService) Run(ctx context.Context, id uint) (*models.ExportJob, error) {
+	if s.Storage == nil {
+		return nil, ErrStorageUnavailable
+	}
+
+	var job models.ExportJob
+	err := s.DB.First(&job, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading export %d: %w", id, err)
+	}
+	switch job.Status {
+	case models.ExportCompleted:
+		return &job, nil
+	case models.ExportExpired:
+		return nil, nil
+	}
+
+	now := time.Now()
+	job.Status = models.ExportRunning
+	job.StartedAt = &now
+	job.Error = ""
+	if err := s.save(&job); err != nil {
+		return nil, err
+	}
+
+	if err := s.run(ctx, &job); err != nil {
+		job.Status = models.ExportFailed
+		job.Error = err.Error()
+		if saveErr := s.save(&job); saveErr != nil {
+			log.Printf("Warning: %v", saveErr)
+		}
+		return nil, err
+	}
+
+	finished := time.Now()
+	expires := finished.Add(exportRetention)
+	job.Status = models.ExportCompleted
+	job.FinishedAt = &finished
+	job.ExpiresAt = &expires
+	if err := s.save(&job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *AccountExportService) run(ctx context.Context, job *models.ExportJob) error {
+	// The archive can be large, so it is built on disk rather than in memory
+	tmp, err := os.CreateTemp("", "account-export-*.zip")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := s.write(ctx, job, tmp); err != nil {
+		return err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("sizing archive: %w", err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("rewinding archive: %w", err)
+	}
+
+	key := fmt.Sprintf("exports/%d/%d/%s", job.UserID, job.ID, exportFilename(job))
+	if err := s.Storage.Upload(ctx, key, tmp, "application/zip"); err != nil {
+		return err
+	}
+	job.StorageKey = key
+	job.SizeBytes = size
+	return nil
+}
+
+// write streams the user's archive to w, blobs first and the manifest last,
+// since the manifest records which blobs made it in.
+func (s *AccountExportService) write(ctx context.Context, job *models.ExportJob, w io.Writer) error {
+	var user models.User
+	if err := s.DB.First(&user, job.UserID).Error; err != nil {
+		return fmt.Errorf("loading user %d: %w", job.UserID, err)
+	}
+
+	manifest := archive.Manifest{
+		Version:    archive.FormatVersion,
+		App:        archive.App,
+		ExportedAt: time.Now().UTC(),
+		Account:    archive.Account{Name: user.Name, Email: user.Email},
+		Labels:     []archive.Label{},
+		Notes:      []archive.Note{},
+		Links:      []archive.Link{},
+		Images:     []archive.Image{},
+		Files:      []archive.File{},
+	}
+
+	var labels []models.Label
+	if err := s.DB.Where("user_id = ?", job.UserID).Order("id ASC").Find(&labels).Error; err != nil {
+		return fmt.Errorf("fetching labels: %w", err)
+	}
+	for _, l := range labels {
+		manifest.Labels = append(manifest.Labels, archive.Label{
+			ID: l.ID, Name: l.Name, Slug: l.Slug, Color: l.Color, CreatedAt: l.CreatedAt,
+		})
+	}
+
+	zw := zip.NewWriter(w)
+
+	var notes []models.Note
+	if err := s.DB.Where("user_id = ?", job.UserID).Preload("Labels").Order("id ASC").Find(&notes).Error; err != nil {
+		return fmt.Errorf("fetching notes: %w", err)
+	}
+	for _, n := range notes {
+		entry := archive.Note{
+			ID:                  n.ID,
+			Type:                n.Type,
+			Title:               n.Title,
+			Color:               n.Color,
+			MoveCheckedToBottom: n.MoveCheckedToBottom,
+			State:               archive.State{IsPinned: n.IsPinned, IsArchived: n.IsArchived, IsTrashed: n.IsTrashed},
+			Labels:              labelSlugs(n.Labels),
+			Path:                archive.EntryName("notes", n.ID, n.Title, ".md"),
+			CreatedAt:           n.CreatedAt,
+			UpdatedAt:           n.UpdatedAt,
+		}
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: entry.Path, Method: zip.Deflate, Modified: n.UpdatedAt})
+		if err != nil {
+			return fmt.Errorf("adding note %d: %w", n.ID, err)
+		}
+		if err := archive.WriteNote(fw, entry, labelNameList(n.Labels), n.Body); err != nil {
+			return fmt.Errorf("writing note %d: %w", n.ID, err)
+		}
+		manifest.Notes = append(manifest.Notes, entry)
+	}
+
+	var links []models.Link
+	if err := s.DB.Where("user_id = ?", job.UserID).Preload("Labels").Order("id ASC").Find(&links).Error; err != nil {
+		return fmt.Errorf("fetching links: %w", err)
+	}
+	for _, l := range links {
+		manifest.Links = append(manifest.Links, archive.Link{
+			ID:           l.ID,
+			URL:          l.URL,
+			Title:        l.Title,
+			Description:  l.Description,
+			ThumbnailURL: l.ThumbnailURL,
+			FaviconURL:   l.FaviconURL,
+			State:        archive.State{IsPinned: l.IsPinned, IsArchived: l.IsArchived, IsTrashed: l.IsTrashed},
+			Labels:       labelSlugs(l.Labels),
+			CreatedAt:    l.CreatedAt,
+			UpdatedAt:    l.UpdatedAt,
+		})
+	}
+
+	var images []models.Image
+	if err := s.DB.Where("user_id = ?", job.UserID).Preload("Labels").Order("id ASC").Find(&images).Error; err != nil {
+		return fmt.Errorf("fetching images: %w", err)
+	}
+	for _, img := range images {
+		entry := archive.Image{
+			ID:        img.ID,
+			Title:     img.Title,
+			MimeType:  img.MimeType,
+			SizeBytes: img.SizeBytes,
+			Width:     img.Width,
+			Height:    img.Height,
+			Folder:    img.Folder,
+			State:     archive.State{IsPinned: img.IsPinned, IsArchived: img.IsArchived, IsTrashed: img.IsTrashed},
+			Labels:    labelSlugs(img.Labels),
+			CreatedAt: img.CreatedAt,
+			UpdatedAt: img.UpdatedAt,
+		}
+		path := archive.EntryName("images", img.ID, blobName("", img.StorageKey), "")
+		// Image formats are compressed already
+		ok, err := s.copyBlob(ctx, zw, path, img.StorageKey, zip.Store, img.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		if ok {
+			entry.Path = path
+		}
+		manifest.Images = append(manifest.Images, entry)
+	}
+
+	var files []models.File
+	if err := s.DB.Where("user_id = ?", job.UserID).Preload("Labels").Order("id ASC").Find(&files).Error; err != nil {
+		return fmt.Errorf("fetching files: %w", err)
+	}
+	for _, f := range files {
+		entry := archive.File{
+			ID:           f.ID,
+			Title:        f.Title,
+			OriginalName: f.OriginalName,
+			MimeType:     f.MimeType,
+			SizeBytes:    f.SizeBytes,
+			Extension:    f.Extension,
+			Folder:       f.Folder,
+			State:        archive.State{IsPinned: f.IsPinned, IsArchived: f.IsArchived, IsTrashed: f.IsTrashed},
+			Labels:       labelSlugs(f.Labels),
+			CreatedAt:    f.CreatedAt,
+			UpdatedAt:    f.UpdatedAt,
+		}
+		path := archive.EntryName("files", f.ID, blobName(f.OriginalName, f.StorageKey), "")
+		ok, err := s.copyBlob(ctx, zw, path, f.StorageKey, zip.Deflate, f.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		if ok {
+			entry.Path = path
+		}
+		manifest.Files = append(manifest.Files, entry)
+	}
+
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: archive.ManifestName, Method: zip.Deflate, Modified: manifest.ExportedAt})
+	if err != nil {
+		return fmt.Errorf("adding manifest: %w", err)
+	}
+	enc := json.NewEncoder(mw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("finishing archive: %w", err)
+	}
+
+	job.Labels, job.Notes, job.Links = len(manifest.Labels), len(manifest.Notes), len(manifest.Links)
+	job.Images, job.Files = len(manifest.Images), len(manifest.Files)
+	return nil
+}
+
+// copyBlob adds a stored object to the archive. A missing object is logged
+// and reported as not copied rather than failing the whole export.
+func (s *AccountExportService) copyBlob(ctx context.Context, zw *zip.Writer, path, key string, method uint16, modified time.Time) (bool, error) {
+	reader, err := s.Storage.Download(ctx, key)
+	if err != nil {
+		log.Printf("Warning: exporting %s: %v", key, err)
+		return false, nil
+	}
+	defer reader.Close()
+
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: path, Method: method, Modified: modified})
+	if err != nil {
+		return false, fmt.Errorf("adding %s: %w", path, err)
+	}
+	if _, err := io.Copy(fw, reader); err != nil {
+		return false, fmt.Errorf("copying %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// DownloadURL returns a signed URL for a completed export's archive, valid
+// until the export expires, or "" when the archive is not available.
+func (s *AccountExportService) DownloadURL(ctx context.Context, job *models.ExportJob) (string, error) {
+	if s.Storage == nil || job.Status != models.ExportCompleted || job.ExpiresAt == nil {
+		return "", nil
+	}
+	ttl := time.Until(*job.ExpiresAt)
+	if ttl <= 0 {
+		return "", nil
+	}
+	return s.Storage.GetSignedURL(ctx, job.StorageKey, ttl)
+}
+
+// CleanupExpired deletes the archives of exports past their expiry and marks
+// them expired. It returns how many exports expired.
+func (s *AccountExportService) CleanupExpired(ctx context.Context) (int, error) {
+	if s.Storage == nil {
+		return 0, ErrStorageUnavailable
+	}
+
+	var jobs []models.ExportJob
+	err := s.DB.Where("status = ? AND expires_at < ?", models.ExportCompleted, time.Now()).Find(&jobs).Error
+	if err != nil {
+		return 0, fmt.Errorf("fetching expired exports: %w", err)
+	}
+	expired := 0
+	for _, job := range jobs {
+		if err := s.Storage.Delete(ctx, job.StorageKey); err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		err := s.DB.Model(&job).Updates(map[string]interface{}{
+			"status":      models.ExportExpired,
+			"storage_key": "",
+		}).Error
+		if err != nil {
+			return expired, fmt.Errorf("expiring export %d: %w", job.ID, err)
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// save writes the export's status, results and timestamps.
+func (s *AccountExportService) save(job *models.ExportJob) error {
+	err := s.DB.Model(job).Select(
+		"status", "storage_key", "size_bytes", "labels", "notes", "links", "images", "files",
+		"error", "started_at", "finished_at", "expires_at",
+	).Updates(job).Error
+	if err != nil {
+		return fmt.Errorf("saving export %d: %w", job.ID, err)
+	}
+	return nil
+}
+
+// exportFilename names an export's archive after the day it was requested.
+func exportFilename(job *models.ExportJob) string {
+	return fmt.Sprintf("%s-export-%s.zip", archive.App, job.CreatedAt.Format("2006-01-02"))
+}
+
+// blobName picks the name an image or file is stored under in the archive:
+// name when set, otherwise the last part of its storage key, which keeps the
+// uploaded file's extension.
+func blobName(name, key string) string {
+	if strings.TrimSpace(name) != "" {
+		return name
+	}
+	return key[strings.LastIndex(key, "/")+1:]
+}
+
+// labelSlugs returns the slugs of the given labels.
+func labelSlugs(labels []models.Label) []string {
+	slugs := make([]string, len(labels))
+	for i, l := range labels {
+		slugs[i] = l.Slug
+	}
+	return slugs
+}
+
+// labelNameList returns the names of the given labels.
+func labelNameList(labels []models.Label) []string {
+	names := make([]string, len(labels))
+	for i, l := range labels {
+		names[i] = l.Name
+	}
+	return names
+}
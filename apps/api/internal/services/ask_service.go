@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/ai"
+	"desis-keep/apps/api/internal/models"
+)
+
+const (
+	// askCandidates is how many hits each retriever contributes before fusion.
+	askCandidates = 8
+	// askSourceChars caps the text of a single source injected into the prompt.
+	askSourceChars = 2000
+	// rrfK dampens reciprocal rank fusion so lower ranks still contribute.
+	rrfK = 60
+)
+
+var citationPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// AskService answers questions over a user's resources (retrieval-augmented generation).
+type AskService struct {
+	DB         *gorm.DB
+	AI         *ai.AI
+	Embeddings *EmbeddingService
+}
+
+// NewAskService creates a new AskService instance.
+func NewAskService(db *gorm.DB, aiService *ai.AI) *AskService {
+	return &AskService{
+		DB:         db,
+		AI:         aiService,
+		Embeddings: NewEmbeddingService(db, aiService),
+	}
+}
+
+// AskSource is a resource injected into the prompt as numbered context.
+type AskSource struct {
+	Index int    `json:"index"`
+	Type  string `json:"type"`
+	ID    uint   `json:"id"`
+	Title string `json:"title"`
+	URL   string `json:"url,omitempty"`
+	Text  string `json:"-"`
+}
+
+// sourceKey identifies a resource across retrievers.
+type sourceKey struct {
+	resourceType string
+	id           uint
+}
+
+// Retrieve returns the resources the user can open, their own and those
+// shared with them, most relevant to the question, fusing semantic and
+// keyword rankings. Trashed resources are never returned.
+func (s *AskService) Retrieve(ctx context.Context, userID uint, question string, limit int) ([]AskSource, error) {
+	scores := map[sourceKey]float64{}
+
+	semantic, err := s.Embeddings.Search(ctx, userID, SemanticParams{
+		Query:           question,
+		Types:           models.EmbeddedResourceTypes,
+		IncludeArchived: true,
+		Limit:           askCandidates,
+	})
+	if err != nil {
+		// Keyword retrieval still works without embeddings
+		log.Printf("Warning: semantic retrieval failed: %v", err)
+	}
+	for rank, r := range semantic {
+		scores[sourceKey{r.Type, r.ID}] += 1.0 / float64(rrfK+rank+1)
+	}
+
+	keyword, err := s.keywordSearch(userID, question)
+	if err != nil {
+		return nil, err
+	}
+	for rank, k := range keyword {
+		scores[k] += 1.0 / float64(rrfK+rank+1)
+	}
+
+	keys := make([]sourceKey, 0, len(scores))
+	for k := range scores {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if scores[keys[i]] != scores[keys[j]] {
+			return scores[keys[i]] > scores[keys[j]]
+		}
+		if keys[i].resourceType != keys[j].resourceType {
+			return keys[i].resourceType < keys[j].resourceType
+		}
+		return keys[i].id < keys[j].id
+	})
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	sources := make([]AskSource, 0, len(keys))
+	for _, k := range keys {
+		var doc models.SearchDocument
+		err := s.DB.Where("resource_type = ? AND resource_id = ?", k.resourceType, k.id).
+			Scopes(accessibleDocuments(userID)).
+			First(&doc).Error
+		if err != nil {
+			if err := ignoreNotFound(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		text := joinText(doc.Labels, doc.Body)
+		if runes := []rune(text); len(runes) > askSourceChars {
+			text = string(runes[:askSourceChars]) + "…"
+		}
+		sources = append(sources, AskSource{
+			Index: len(sources) + 1,
+			Type:  doc.ResourceType,
+			ID:    doc.ResourceID,
+			Title: doc.Title,
+			URL:   doc.URL,
+			Text:  text,
+		})
+	}
+	return sources, nil
+}
+
+// keywordSearch ranks the notes, links and files the user can open by
+// full-text match on any word of the question.
+func (s *AskService) keywordSearch(userID uint, question string) ([]sourceKey, error) {
+	words := strings.FieldsFunc(strings.ToLower(question), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return nil, nil
+	}
+	tsquery := strings.Join(words, " | ")
+
+	var rows []struct {
+		ResourceType string
+		ResourceID   uint
+	}
+	err := s.DB.Table("search_documents").
+		Select("resource_type, resource_id").
+		Scopes(accessibleDocuments(userID)).
+		Where("is_trashed = ? AND resource_type IN ?", false, models.EmbeddedResourceTypes).
+		Where("document @@ to_tsquery(?::regconfig, ?)", searchConfig, tsquery).
+		Order(gorm.Expr("ts_rank(document, to_tsquery(?::regconfig, ?)) DESC, updated_at DESC", searchConfig, tsquery)).
+		Limit(askCandidates).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("keyword retrieval: %w", err)
+	}
+
+	keys := make([]sourceKey, len(rows))
+	for i, r := range rows {
+		keys[i] = sourceKey{r.ResourceType, r.ResourceID}
+	}
+	return keys, nil
+}
+
+// BuildMessages appends the question, wrapped with numbered source context, to the history.
+// The context goes in the user turn because not every provider accepts a system role.
+func (s *AskService) BuildMessages(question string, history []ai.Message, sources []AskSource) []ai.Message {
+	var b strings.Builder
+	b.WriteString("You are answering a question using the notes, links and files the user saved or that were shared with them.\n")
+	b.WriteString("Use only the sources below. Cite every fact with the source number in square brackets, like [1]. ")
+	b.WriteString("If the sources do not contain the answer, say so instead of guessing.\n\n")
+
+	if len(sources) == 0 {
+		b.WriteString("Sources: none found.\n")
+	}
+	for _, src := range sources {
+		fmt.Fprintf(&b, "[%d] %s: %s\n", src.Index, src.Type, src.Title)
+		if src.URL != "" {
+			fmt.Fprintf(&b, "URL: %s\n", src.URL)
+		}
+		if src.Text != "" {
+			b.WriteString(src.Text)
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "Question: %s", question)
+
+	messages := make([]ai.Message, 0, len(history)+1)
+	messages = append(messages, history...)
+	return append(messages, ai.Message{Role: "user", Content: b.String()})
+}
+
+// Cited returns the sources referenced by [n] markers in the answer, in source order.
+func (s *AskService) Cited(answer string, sources []AskSource) []AskSource {
+	seen := map[int]bool{}
+	for _, m := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			seen[n] = true
+		}
+	}
+
+	cited := []AskSource{}
+	for _, src := range sources {
+		if seen[src.Index] {
+			cited = append(cited, src)
+		}
+	}
+	return cited
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"desis-keep/apps/api/internal/crdt"
+	"desis-keep/apps/api/internal/models"
+)
+
+// collabSite is the site of the edits that bring a note's collaborative state
+// in line with a body changed outside a session.
+const collabSite = "note"
+
+// ErrNotTextNote is returned when opening a checklist note for collaborative
+// editing; its items are edited through the item endpoints.
+var ErrNotTextNote = errors.New("only text notes can be edited together")
+
+// CollabService keeps the collaborative editing state of note bodies and
+// saves it back to the notes.
+type CollabService struct {
+	DB    *gorm.DB
+	Notes *NoteService
+}
+
+// NewCollabService creates a new CollabService instance.
+func NewCollabService(db *gorm.DB) *CollabService {
+	return &CollabService{DB: db, Notes: NewNoteService(db)}
+}
+
+// Access reports whether a user may view a text note in a session, and
+// whether they may edit it.
+func (s *CollabService) Access(noteID, userID uint) (canEdit bool, err error) {
+	var note models.Note
+	err = s.DB.Select("id", "type").Where("id = ?", noteID).
+		Scopes(accessible(models.ResourceTypeNote, userID, models.SharePermissionView)).
+		First(&note).Error
+	if err != nil {
+		return false, fmt.Errorf("note not found: %w", err)
+	}
+	if note.Type != models.NoteTypeText {
+		return false, ErrNotTextNote
+	}
+	var count int64
+	err = s.DB.Model(&models.Note{}).Where("id = ?", noteID).
+		Scopes(accessible(models.ResourceTypeNote, userID, models.SharePermissionEdit)).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("checking edit access: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Load returns the collaborative state of a note's body, first taking in any
+// edits made to the body outside a session.
+func (s *CollabService) Load(noteID uint) (*crdt.Doc, error) {
+	var doc *crdt.Doc
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		note, stored, err := s.lockDocument(tx, noteID)
+		if err != nil {
+			return err
+		}
+		doc = stored
+		if doc.Text() == note.Body {
+			return nil
+		}
+		if _, err := doc.Replace(note.Body, collabSite); err != nil {
+			return fmt.Errorf("taking in body of note %d: %w", noteID, err)
+		}
+		return saveDocument(tx, noteID, doc)
+	})
+	return doc, err
+}
+
+// Save writes a session's state back to its note, merged with the state other
+// sessions saved and with edits made to the body outside a session. The new
+// body gets a revision by editorID, or by the note's owner when 0. It returns
+// the operations the session has not seen yet, and whether the body changed.
+func (s *CollabService) Save(noteID, editorID uint, runs []crdt.Run) ([]crdt.Op, bool, error) {
+	var ops []crdt.Op
+	changed := false
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		note, stored, err := s.lockDocument(tx, noteID)
+		if err != nil {
+			return err
+		}
+		if stored.Text() != note.Body {
+			if _, err := stored.Replace(note.Body, collabSite); err != nil {
+				return fmt.Errorf("taking in body of note %d: %w", noteID, err)
+			}
+		}
+
+		doc := crdt.New()
+		if _, err := doc.Merge(runs); err != nil {
+			return fmt.Errorf("reading session state: %w", err)
+		}
+		if ops, err = doc.Merge(stored.Runs()); err != nil {
+			return fmt.Errorf("merging saved state: %w", err)
+		}
+		if err := saveDocument(tx, noteID, doc); err != nil {
+			return err
+		}
+
+		body := doc.Text()
+		if body == note.Body {
+			return nil
+		}
+		changed = true
+		if editorID == 0 {
+			editorID = note.UserID
+		}
+		before := *note
+		if err := tx.Model(note).Omit(clause.Associations).Update("body", body).Error; err != nil {
+			return fmt.Errorf("updating note: %w", err)
+		}
+		return recordRevision(tx, &before, note.Title, body, editorID, nil)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if changed {
+		s.Notes.Search.sync(models.ResourceTypeNote, noteID)
+		s.Notes.References.sync(noteID)
+	}
+	return ops, changed, nil
+}
+
+// lockDocument locks a text note and loads its saved collaborative state, or
+// an empty state when it has none.
+func (s *CollabService) lockDocument(tx *gorm.DB, noteID uint) (*models.Note, *crdt.Doc, error) {
+	var note models.Note
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", noteID).First(&note).Error
+	if err != nil {
+		return nil, nil, fmt.Errorf("note not found: %w", err)
+	}
+	if note.Type != models.NoteTypeText {
+		return nil, nil, ErrNotTextNote
+	}
+
+	var document models.NoteDocument
+	if err := tx.Where("note_id = ?", noteID).Limit(1).Find(&document).Error; err != nil {
+		return nil, nil, fmt.Errorf("loading state of note %d: %w", noteID, err)
+	}
+	doc := crdt.New()
+	if document.State != "" {
+		var runs []crdt.Run
+		if err := json.Unmarshal([]byte(document.State), &runs); err != nil {
+			return nil, nil, fmt.Errorf("decoding state of note %d: %w", noteID, err)
+		}
+		if _, err := doc.Merge(runs); err != nil {
+			return nil, nil, fmt.Errorf("reading state of note %d: %w", noteID, err)
+		}
+	}
+	return &note, doc, nil
+}
+
+// saveDocument stores the collaborative state of a note.
+func saveDocument(tx *gorm.DB, noteID uint, doc *crdt.Doc) error {
+	state, err := json.Marshal(doc.Runs())
+	if err != nil {
+		return fmt.Errorf("encoding state of note %d: %w", noteID, err)
+	}
+	document := models.NoteDocument{NoteID: noteID, State: string(state)}
+	err = tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "note_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"state", "updated_at"}),
+	}).Create(&document).Error
+	if err != nil {
+		return fmt.Errorf("saving state of note %d: %w", noteID, err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/models"
+)
+
+// EmailVerificationTTL is how long an email verification link works.
+const EmailVerificationTTL = 48 * time.Hour
+
+// ErrInvalidVerificationToken is returned for an email verification token that
+// is unknown, already used or expired.
+var ErrInvalidVerificationToken = errors.New("verification link is invalid or has expired")
+
+// EmailVerificationService confirms that users own the email address of their
+// account. Shares sent to an address wait until it is verified.
+type EmailVerificationService struct {
+	DB *gorm.DB
+}
+
+// NewEmailVerificationService creates a new EmailVerificationService instance.
+func NewEmailVerificationService(db *gorm.DB) *EmailVerificationService {
+	return &EmailVerificationService{DB: db}
+}
+
+// Issue makes a token for verifying a user's email address, replacing any
+// earlier one. Only a hash of the token is stored.
+func (s *EmailVerificationService) Issue(user *models.User) (string, error) {
+	token, err := GenerateResetToken()
+	if err != nil {
+		return "", err
+	}
+	err = s.DB.Model(user).UpdateColumns(map[string]interface{}{
+		"verify_token":   hashVerifyToken(token),
+		"verify_sent_at": time.Now(),
+	}).Error
+	if err != nil {
+		return "", fmt.Errorf("storing verification token: %w", err)
+	}
+	return token, nil
+}
+
+// Verify marks the email address a token was sent to as verified, and gives
+// its user the shares waiting for it.
+func (s *EmailVerificationService) Verify(token string) (*models.User, error) {
+	if token == "" {
+		return nil, ErrInvalidVerificationToken
+	}
+	var user models.User
+	err := s.DB.Where("verify_token = ?", hashVerifyToken(token)).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("finding verification token: %w", err)
+	}
+	if user.VerifySentAt == nil || time.Since(*user.VerifySentAt) > EmailVerificationTTL {
+		return nil, ErrInvalidVerificationToken
+	}
+	if err := s.MarkVerified(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// MarkVerified records that a user owns their email address, as shown by a
+// verification link or by Google, and gives them the shares waiting for it.
+func (s *EmailVerificationService) MarkVerified(user *models.User) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if user.EmailVerifiedAt != nil {
+			now = *user.EmailVerifiedAt
+		}
+		err := tx.Model(user).UpdateColumns(map[string]interface{}{
+			"email_verified_at": now,
+			"verify_token":      "",
+			"verify_sent_at":    nil,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("marking email verified: %w", err)
+		}
+		user.EmailVerifiedAt = &now
+		user.VerifyToken = ""
+		user.VerifySentAt = nil
+		return NewShareService(tx).Claim(user)
+	})
+}
+
+// hashVerifyToken returns the stored form of a verification token.
+func hashVerifyToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"desis-keep/apps/api/internal/ai"
+	"desis-keep/apps/api/internal/models"
+)
+
+// maxEmbeddingChars caps the text sent to the embedding provider per resource.
+const maxEmbeddingChars = 8000
+
+// EmbeddingService maintains resource embeddings and answers semantic queries.
+// Embeddings are built from the search index rows, so Index must run first.
+type EmbeddingService struct {
+	DB *gorm.DB
+	AI *ai.AI
+}
+
+// NewEmbeddingService creates a new EmbeddingService instance.
+func NewEmbeddingService(db *gorm.DB, aiService *ai.AI) *EmbeddingService {
+	return &EmbeddingService{DB: db, AI: aiService}
+}
+
+// SemanticParams holds the options for a semantic search.
+type SemanticParams struct {
+	Query           string
+	Types           []string
+	IncludeArchived bool
+	Limit           int
+}
+
+// SemanticResult is a nearest-neighbour hit with its cosine similarity.
+type SemanticResult struct {
+	ID        uint      `json:"id"`
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	Snippet   string    `json:"snippet"`
+	Labels    string    `json:"labels,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	Score     float64   `json:"score"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Embed (re)computes the embedding for a single resource. It is a no-op when
+// the indexed text and model are unchanged, and removes the embedding when the
+// resource no longer exists or has no text.
+func (s *EmbeddingService) Embed(ctx context.Context, resourceType string, id uint) error {
+	if !slices.Contains(models.EmbeddedResourceTypes, resourceType) {
+		return nil
+	}
+
+	var doc models.SearchDocument
+	err := s.DB.Where("resource_type = ? AND resource_id = ?", resourceType, id).First(&doc).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.Remove(resourceType, id)
+	}
+	if err != nil {
+		return fmt.Errorf("loading index entry for %s %d: %w", resourceType, id, err)
+	}
+
+	text := embeddingText(doc)
+	if text == "" {
+		return s.Remove(resourceType, id)
+	}
+
+	model := s.AI.EmbeddingModel()
+	sum := sha256.Sum256([]byte(model + "\x00" + text))
+	hash := hex.EncodeToString(sum[:])
+
+	var existing models.Embedding
+	err = s.DB.Select("content_hash").Where("resource_type = ? AND resource_id = ?", resourceType, id).First(&existing).Error
+	if err == nil && existing.ContentHash == hash {
+		return nil
+	}
+
+	resp, err := s.AI.Embed(ctx, ai.EmbeddingRequest{Input: []string{text}})
+	if err != nil {
+		return fmt.Errorf("embedding %s %d: %w", resourceType, id, err)
+	}
+	if len(resp.Vectors) != 1 {
+		return fmt.Errorf("embedding %s %d: expected 1 vector, got %d", resourceType, id, len(resp.Vectors))
+	}
+
+	embedding := models.Embedding{
+		ResourceType: resourceType,
+		ResourceID:   id,
+		UserID:       doc.UserID,
+		Model:        model,
+		Dimensions:   len(resp.Vectors[0]),
+		ContentHash:  hash,
+		Vector:       resp.Vectors[0],
+	}
+	err = s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "resource_type"}, {Name: "resource_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "model", "dimensions", "content_hash", "vector", "updated_at"}),
+	}).Create(&embedding).Error
+	if err != nil {
+		return fmt.Errorf("saving embedding for %s %d: %w", resourceType, id, err)
+	}
+	return nil
+}
+
+// Remove deletes the embedding for a resource.
+func (s *EmbeddingService) Remove(resourceType string, id uint) error {
+	if err := s.DB.Where("resource_type = ? AND resource_id = ?", resourceType, id).
+		Delete(&models.Embedding{}).Error; err != nil {
+		return fmt.Errorf("removing embedding for %s %d: %w", resourceType, id, err)
+	}
+	return nil
+}
+
+// EmbedAll embeds every indexed resource that supports semantic search.
+// Unchanged resources are skipped, so it is cheap to re-run.
+func (s *EmbeddingService) EmbedAll(ctx context.Context) (int, error) {
+	embedded := 0
+	for _, resourceType := range models.EmbeddedResourceTypes {
+		var ids []uint
+		if err := s.DB.Model(&models.SearchDocument{}).
+			Where("resource_type = ?", resourceType).
+			Pluck("resource_id", &ids).Error; err != nil {
+			return embedded, fmt.Errorf("listing %ss: %w", resourceType, err)
+		}
+		for _, id := range ids {
+			if err := s.Embed(ctx, resourceType, id); err != nil {
+				return embedded, err
+			}
+			embedded++
+		}
+	}
+	return embedded, nil
+}
+
+// Search returns the resources a user can open, their own and those shared
+// with them, nearest to the query text by cosine similarity.
+func (s *EmbeddingService) Search(ctx context.Context, userID uint, params SemanticParams) ([]SemanticResult, error) {
+	if params.Limit < 1 || params.Limit > 50 {
+		params.Limit = 10
+	}
+
+	resp, err := s.AI.Embed(ctx, ai.EmbeddingRequest{Input: []string{params.Query}})
+	if err != nil {
+		return nil, fmt.Errorf("embedding query: %w", err)
+	}
+	if len(resp.Vectors) != 1 {
+		return nil, fmt.Errorf("embedding query: expected 1 vector, got %d", len(resp.Vectors))
+	}
+	vector := models.Vector(resp.Vectors[0])
+
+	query := s.DB.Table("embeddings").
+		Select(`embeddings.resource_id AS id, embeddings.resource_type AS type,
+			search_documents.title, `+escapeHTMLSQL("LEFT(search_documents.body, 200)")+` AS snippet,
+			search_documents.labels, search_documents.url,
+			search_documents.created_at, search_documents.updated_at,
+			(SELECT SUM(a * b) FROM unnest(embeddings.vector, ?::real[]) AS v(a, b)) AS score`, vector).
+		Joins(`JOIN search_documents ON search_documents.resource_type = embeddings.resource_type
+			AND search_documents.resource_id = embeddings.resource_id`).
+		Where("embeddings.model = ? AND embeddings.dimensions = ?", s.AI.EmbeddingModel(), len(vector)).
+		Scopes(accessibleDocuments(userID)).
+		Where("search_documents.is_trashed = ?", false)
+	if !params.IncludeArchived {
+		query = query.Where("search_documents.is_archived = ?", false)
+	}
+	if len(params.Types) > 0 {
+		query = query.Where("embeddings.resource_type IN ?", params.Types)
+	}
+
+	results := []SemanticResult{}
+	if err := query.Order("score DESC").Limit(params.Limit).Scan(&results).Error; err != nil {
+		return nil, fmt.Errorf("semantic search: %w", err)
+	}
+	return results, nil
+}
+
+// embeddingText builds the text embedded for an index entry.
+func embeddingText(doc models.SearchDocument) string {
+	text := joinText(doc.Title, doc.Labels, doc.Body)
+	if runes := []rune(text); len(runes) > maxEmbeddingChars {
+		text = string(runes[:maxEmbeddingChars])
+	}
+	return text
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"desis-keep/apps/api/internal/ai"
+	"desis-keep/apps/api/internal/models"
+)
+
+// testDB opens an empty in-memory database with the tables created by the
+// given statements.
+func testDB(t *testing.T, schema ...string) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	// Every connection to ":memory:" is a database of its own
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	for _, stmt := range schema {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("creating schema: %v", err)
+		}
+	}
+	return db
+}
+
+// The Postgres-only columns (tsvector, real[]) are plain text here.
+const (
+	searchDocumentsTable = `CREATE TABLE search_documents (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		resource_type TEXT NOT NULL, resource_id INTEGER NOT NULL, user_id INTEGER NOT NULL,
+		title TEXT, body TEXT, labels TEXT, url TEXT, color TEXT,
+		is_pinned BOOLEAN DEFAULT false, is_archived BOOLEAN DEFAULT false, is_trashed BOOLEAN DEFAULT false,
+		created_at DATETIME, updated_at DATETIME,
+		UNIQUE (resource_type, resource_id))`
+	embeddingsTable = `CREATE TABLE embeddings (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		resource_type TEXT NOT NULL, resource_id INTEGER NOT NULL, user_id INTEGER NOT NULL,
+		model TEXT NOT NULL, dimensions INTEGER NOT NULL, content_hash TEXT NOT NULL, vector TEXT NOT NULL,
+		created_at DATETIME, updated_at DATETIME,
+		UNIQUE (resource_type, resource_id))`
+)
+
+// countingEmbedder counts the texts it is asked to embed.
+type countingEmbedder struct {
+	ai.Provider
+	mu    sync.Mutex
+	texts []string
+}
+
+func (p *countingEmbedder) Embed(ctx context.Context, req ai.EmbeddingRequest) (*ai.EmbeddingResponse, error) {
+	p.mu.Lock()
+	p.texts = append(p.texts, req.Input...)
+	p.mu.Unlock()
+	return p.Provider.Embed(ctx, req)
+}
+
+func TestEmbedSkipsUnchangedText(t *testing.T) {
+	db := testDB(t, searchDocumentsTable, embeddingsTable)
+	embedder := &countingEmbedder{Provider: ai.NewLocalProvider()}
+	s := NewEmbeddingService(db, ai.New(nil, embedder))
+	ctx := context.Background()
+
+	doc := models.SearchDocument{ResourceType: models.ResourceTypeNote, ResourceID: 1, UserID: 1, Title: "Trip", Body: "Pack light"}
+	if err := db.Create(&doc).Error; err != nil {
+		t.Fatalf("creating index entry: %v", err)
+	}
+	loadEmbedding := func() models.Embedding {
+		t.Helper()
+		var e models.Embedding
+		if err := db.Where("resource_type = ? AND resource_id = ?", doc.ResourceType, doc.ResourceID).First(&e).Error; err != nil {
+			t.Fatalf("loading embedding: %v", err)
+		}
+		return e
+	}
+
+	if err := s.Embed(ctx, doc.ResourceType, doc.ResourceID); err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	first := loadEmbedding()
+	if len(embedder.texts) != 1 || embedder.texts[0] != "Trip\nPack light" {
+		t.Fatalf("embedded %q, want the title and body once", embedder.texts)
+	}
+	if first.Model != "local-hash-256" || first.Dimensions != ai.LocalEmbeddingDimensions || len(first.Vector) != first.Dimensions {
+		t.Errorf("embedding = model %q, %d dimensions, %d values", first.Model, first.Dimensions, len(first.Vector))
+	}
+
+	// Same text: no provider call, nothing rewritten
+	if err := s.Embed(ctx, doc.ResourceType, doc.ResourceID); err != nil {
+		t.Fatalf("Embed again: %v", err)
+	}
+	if len(embedder.texts) != 1 {
+		t.Errorf("re-embedding unchanged text called the provider %d times in all, want once", len(embedder.texts))
+	}
+	if again := loadEmbedding(); again.ContentHash != first.ContentHash || !again.UpdatedAt.Equal(first.UpdatedAt) {
+		t.Error("re-embedding unchanged text rewrote the embedding")
+	}
+
+	// Changed text: embedded again under a new hash
+	db.Model(&doc).Update("body", "Pack light, bring boots")
+	if err := s.Embed(ctx, doc.ResourceType, doc.ResourceID); err != nil {
+		t.Fatalf("Embed after change: %v", err)
+	}
+	changed := loadEmbedding()
+	if len(embedder.texts) != 2 || changed.ContentHash == first.ContentHash {
+		t.Errorf("after a change: %d provider calls, hash changed %v; want 2, true",
+			len(embedder.texts), changed.ContentHash != first.ContentHash)
+	}
+
+	// Removed from the index: the embedding goes too
+	db.Delete(&doc)
+	if err := s.Embed(ctx, doc.ResourceType, doc.ResourceID); err != nil {
+		t.Fatalf("Embed after delete: %v", err)
+	}
+	var count int64
+	db.Model(&models.Embedding{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%d embeddings left after the resource was removed, want 0", count)
+	}
+}
+
+func TestEmbedHashIncludesModel(t *testing.T) {
+	db := testDB(t, searchDocumentsTable, embeddingsTable)
+	ctx := context.Background()
+	db.Create(&models.SearchDocument{ResourceType: models.ResourceTypeLink, ResourceID: 5, UserID: 1, Title: "Docs"})
+
+	local := &countingEmbedder{Provider: ai.NewLocalProvider()}
+	if err := NewEmbeddingService(db, ai.New(nil, local)).Embed(ctx, models.ResourceTypeLink, 5); err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	// The fake provider embeds the same way under another model name, which
+	// must not reuse the stored vector
+	fake := &countingEmbedder{Provider: ai.NewFakeProvider()}
+	if err := NewEmbeddingService(db, ai.New(nil, fake)).Embed(ctx, models.ResourceTypeLink, 5); err != nil {
+		t.Fatalf("Embed with another model: %v", err)
+	}
+	if len(fake.texts) != 1 {
+		t.Errorf("switching models made %d provider calls, want 1", len(fake.texts))
+	}
+	var e models.Embedding
+	db.First(&e)
+	if e.Model != "fake-embedding" {
+		t.Errorf("Model = %q, want fake-embedding", e.Model)
+	}
+}
+
+func TestEmbedIgnoresImages(t *testing.T) {
+	db := testDB(t, searchDocumentsTable, embeddingsTable)
+	embedder := &countingEmbedder{Provider: ai.NewLocalProvider()}
+	db.Create(&models.SearchDocument{ResourceType: models.ResourceTypeImage, ResourceID: 3, UserID: 1, Title: "Photo"})
+
+	if err := NewEmbeddingService(db, ai.New(nil, embedder)).Embed(context.Background(), models.ResourceTypeImage, 3); err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(embedder.texts) != 0 {
+		t.Errorf("embedded an image: %q", embedder.texts)
+	}
+}
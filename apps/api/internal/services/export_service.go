@@ -0,0 +1,76 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/bookmarks"
+	"desis-keep/apps/api/internal/models"
+)
+
+// ExportService writes a user's resources in formats other tools can read.
+type ExportService struct {
+	DB *gorm.DB
+}
+
+// NewExportService creates a new ExportService instance.
+func NewExportService(db *gorm.DB) *ExportService {
+	return &ExportService{DB: db}
+}
+
+// WriteBookmarks writes the user's links outside the trash as a Netscape
+// bookmark file. Each label becomes a folder, so a link with several labels
+// appears in each of their folders; unlabeled links sit at the top level.
+// Every bookmark also lists all its labels in TAGS, which lets a re-import
+// restore them.
+func (s *ExportService) WriteBookmarks(w io.Writer, userID uint) error {
+	var links []models.Link
+	err := s.DB.Where("user_id = ? AND is_trashed = ?", userID, false).
+		Preload("Labels").
+		Order("created_at ASC").
+		Find(&links).Error
+	if err != nil {
+		return fmt.Errorf("fetching links: %w", err)
+	}
+
+	var loose []bookmarks.Bookmark
+	byLabel := map[string]*bookmarks.Folder{}
+	for _, link := range links {
+		b := bookmarks.Bookmark{
+			URL:          link.URL,
+			Title:        link.Title,
+			Description:  plainText(link.Description),
+			AddDate:      link.CreatedAt,
+			LastModified: link.UpdatedAt,
+		}
+		for _, label := range link.Labels {
+			b.Tags = append(b.Tags, label.Name)
+		}
+		if len(link.Labels) == 0 {
+			loose = append(loose, b)
+			continue
+		}
+		for _, label := range link.Labels {
+			folder, ok := byLabel[label.Name]
+			if !ok {
+				folder = &bookmarks.Folder{Name: label.Name}
+				byLabel[label.Name] = folder
+			}
+			folder.Bookmarks = append(folder.Bookmarks, b)
+		}
+	}
+
+	folders := make([]bookmarks.Folder, 0, len(byLabel))
+	for _, folder := range byLabel {
+		folders = append(folders, *folder)
+	}
+	sort.Slice(folders, func(i, j int) bool {
+		return strings.ToLower(folders[i].Name) < strings.ToLower(folders[j].Name)
+	})
+
+	return bookmarks.Write(w, loose, folders)
+}
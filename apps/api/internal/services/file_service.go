@@ -1,133 +1,167 @@
-package services
-
-import (
-	"fmt"
-	"math"
-
-	"gorm.io/gorm"
-
-	"desis-keep/apps/api/internal/models"
-)
-
-// FileService handles business logic for files.
-type FileService struct {
-	DB *gorm.DB
-}
-
-// NewFileService creates a new FileService instance.
-func NewFileService(db *gorm.DB) *FileService {
-	return &FileService{DB: db}
-}
-
-// List returns a paginated list of files for a user.
-func (s *FileService) List(userID uint, page, pageSize int, search, sortKey, sortDir string, archived, trashed *bool) ([]models.File, int64, int, error) {
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 20
-	}
-	if sortDir != "asc" && sortDir != "desc" {
-		sortDir = "desc"
-	}
-	if sortKey == "" {
-		sortKey = "created_at"
-	}
-
-	query := s.DB.Model(&models.File{}).Where("user_id = ?", userID).Preload("Labels")
-
-	if archived == nil && trashed == nil {
-		query = query.Where("is_archived = ? AND is_trashed = ?", false, false)
-	} else {
-		if archived != nil {
-			query = query.Where("is_archived = ?", *archived)
-		}
-		if trashed != nil {
-			query = query.Where("is_trashed = ?", *trashed)
-		}
-	}
-
-	if search != "" {
-		query = query.Where("title ILIKE ? OR original_name ILIKE ?", "%"+search+"%", "%"+search+"%")
-	}
-
-	var total int64
-	query.Count(&total)
-
-	var files []models.File
-	offset := (page - 1) * pageSize
-	if err := query.Order(sortKey + " " + sortDir).Offset(offset).Limit(pageSize).Find(&files).Error; err != nil {
-		return nil, 0, 0, fmt.Errorf("fetching files: %w", err)
-	}
-
-	pages := int(math.Ceil(float64(total) / float64(pageSize)))
-	return files, total, pages, nil
-}
-
-// GetByID returns a single file by ID (scoped to user).
-func (s *FileService) GetByID(id, userID uint) (*models.File, error) {
-	var file models.File
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).Preload("Labels").First(&file).Error; err != nil {
-		return nil, fmt.Errorf("file not found: %w", err)
-	}
-	return &file, nil
-}
-
-// Create creates a new file.
-func (s *FileService) Create(file *models.File) error {
-	if err := s.DB.Create(file).Error; err != nil {
-		return fmt.Errorf("creating file: %w", err)
-	}
-	return nil
-}
-
-// Update modifies an existing file.
-func (s *FileService) Update(id, userID uint, data map[string]interface{}) (*models.File, error) {
-	var file models.File
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&file).Error; err != nil {
-		return nil, fmt.Errorf("file not found: %w", err)
-	}
-
-	if err := s.DB.Model(&file).Updates(data).Error; err != nil {
-		return nil, fmt.Errorf("updating file: %w", err)
-	}
-
-	s.DB.Where("id = ?", id).Preload("Labels").First(&file)
-	return &file, nil
-}
-
-// Delete soft-deletes a file (sets is_trashed = true).
-func (s *FileService) Delete(id, userID uint) error {
-	var file models.File
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&file).Error; err != nil {
-		return fmt.Errorf("file not found: %w", err)
-	}
-	if err := s.DB.Model(&file).Update("is_trashed", true).Error; err != nil {
-		return fmt.Errorf("trashing file: %w", err)
-	}
-	return nil
-}
-
-// Restore restores a trashed file.
-func (s *FileService) Restore(id, userID uint) error {
-	var file models.File
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&file).Error; err != nil {
-		return fmt.Errorf("file not found: %w", err)
-	}
-	if err := s.DB.Model(&file).Update("is_trashed", false).Error; err != nil {
-		return fmt.Errorf("restoring file: %w", err)
-	}
-	return nil
-}
-
-// PermanentDelete hard-deletes a file.
-func (s *FileService) PermanentDelete(id, userID uint) error {
-	var file models.File
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&file).Error; err != nil {
-		return fmt.Errorf("file not found: %w", err)
-	}
-	if err := s.DB.Unscoped().Delete(&file).Error; err != nil {
-		return fmt.Errorf("permanently deleting file: %w", err)
-	}
-	return nil
-}
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/querylang"
+)
+
+// FileService handles business logic for files.
+type FileService struct {
+	DB     *gorm.DB
+	Search *SearchService
+}
+
+// NewFileService creates a new FileService instance.
+func NewFileService(db *gorm.DB) *FileService {
+	return &FileService{DB: db, Search: NewSearchService(db)}
+}
+
+// List returns a paginated list of files for a user, filtered by a parsed search query.
+func (s *FileService) List(userID uint, page, pageSize int, q *querylang.Query, sortKey, sortDir string, archived, trashed *bool) ([]models.File, int64, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	if sortDir != "asc" && sortDir != "desc" {
+		sortDir = "desc"
+	}
+	if sortKey == "" {
+		sortKey = "created_at"
+	}
+
+	query := s.DB.Model(&models.File{}).Scopes(accessible(models.ResourceTypeFile, userID, models.SharePermissionView)).Preload("Labels")
+
+	// Default: exclude archived and trashed unless the query asks for them
+	if archived == nil && trashed == nil {
+		if !q.HasFlag(querylang.FlagArchived) {
+			query = query.Where("is_archived = ?", false)
+		}
+		if !q.HasFlag(querylang.FlagTrashed) {
+			query = query.Where("is_trashed = ?", false)
+		}
+	} else {
+		if archived != nil {
+			query = query.Where("is_archived = ?", *archived)
+		}
+		if trashed != nil {
+			query = query.Where("is_trashed = ?", *trashed)
+		}
+	}
+
+	query = q.Scope(querylang.FileTarget)(query)
+
+	var total int64
+	query.Count(&total)
+
+	var files []models.File
+	offset := (page - 1) * pageSize
+	if err := query.Order(sortKey + " " + sortDir).Offset(offset).Limit(pageSize).Find(&files).Error; err != nil {
+		return nil, 0, 0, fmt.Errorf("fetching files: %w", err)
+	}
+
+	pages := int(math.Ceil(float64(total) / float64(pageSize)))
+	return files, total, pages, nil
+}
+
+// GetByID returns a single file by ID (scoped to user).
+func (s *FileService) GetByID(id, userID uint) (*models.File, error) {
+	var file models.File
+	if err := s.DB.Where("id = ?", id).Scopes(accessible(models.ResourceTypeFile, userID, models.SharePermissionView)).Preload("Labels").First(&file).Error; err != nil {
+		return nil, fmt.Errorf("file not found: %w", err)
+	}
+	return &file, nil
+}
+
+// Create creates a new file.
+func (s *FileService) Create(file *models.File) error {
+	if err := s.DB.Create(file).Error; err != nil {
+		return fmt.Errorf("creating file: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeFile, file.ID)
+	return nil
+}
+
+// Update modifies an existing file. A file that is no longer at version is
+// returned as it is now, with ErrVersionConflict.
+func (s *FileService) Update(id, userID, version uint, data map[string]interface{}) (*models.File, error) {
+	var file models.File
+	if err := s.DB.Where("id = ?", id).Scopes(accessible(models.ResourceTypeFile, userID, models.SharePermissionEdit)).First(&file).Error; err != nil {
+		return nil, fmt.Errorf("file not found: %w", err)
+	}
+
+	err := updateVersioned(s.DB, &file, version, data)
+	if err != nil && !errors.Is(err, ErrVersionConflict) {
+		return nil, fmt.Errorf("updating file: %w", err)
+	}
+
+	s.DB.Where("id = ?", id).Preload("Labels").First(&file)
+	if err != nil {
+		return &file, err
+	}
+	s.Search.sync(models.ResourceTypeFile, file.ID)
+	return &file, nil
+}
+
+// Delete soft-deletes a file (sets is_trashed = true).
+func (s *FileService) Delete(id, userID uint) error {
+	var file models.File
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&file).Error; err != nil {
+		return fmt.Errorf("file not found: %w", err)
+	}
+	if err := s.DB.Model(&file).Update("is_trashed", true).Error; err != nil {
+		return fmt.Errorf("trashing file: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeFile, file.ID)
+	return nil
+}
+
+// Restore restores a trashed file.
+func (s *FileService) Restore(id, userID uint) error {
+	var file models.File
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&file).Error; err != nil {
+		return fmt.Errorf("file not found: %w", err)
+	}
+	if err := s.DB.Model(&file).Update("is_trashed", false).Error; err != nil {
+		return fmt.Errorf("restoring file: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeFile, file.ID)
+	return nil
+}
+
+// PermanentDelete hard-deletes a file.
+func (s *FileService) PermanentDelete(id, userID uint) error {
+	var file models.File
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&file).Error; err != nil {
+		return fmt.Errorf("file not found: %w", err)
+	}
+	if err := s.DB.Unscoped().Delete(&file).Error; err != nil {
+		return fmt.Errorf("permanently deleting file: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeFile, file.ID)
+	forgetReferences(s.DB, models.ResourceTypeFile, file.ID)
+	forgetShares(s.DB, models.ResourceTypeFile, file.ID)
+	forgetPublicLinks(s.DB, models.ResourceTypeFile, file.ID)
+	return nil
+}
+
+// SetLabels replaces the labels on a file with the given labels owned by the user.
+func (s *FileService) SetLabels(file *models.File, userID uint, labelIDs []uint) error {
+	if file.UserID != userID {
+		return ErrNotOwner
+	}
+	var labels []models.Label
+	s.DB.Where("id IN ? AND user_id = ?", labelIDs, userID).Find(&labels)
+	if err := s.DB.Model(file).Association("Labels").Replace(labels); err != nil {
+		return fmt.Errorf("setting file labels: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeFile, file.ID)
+	return nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"path"
+
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/filetext"
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/storage"
+)
+
+// maxFileTextBytes caps the size of files whose text is extracted.
+const maxFileTextBytes = 50 << 20
+
+// FileTextService extracts the text of uploaded files so that search, semantic
+// search and ask match what the files say rather than only their names.
+type FileTextService struct {
+	DB      *gorm.DB
+	Storage *storage.Storage
+	Search  *SearchService
+}
+
+// NewFileTextService creates a new FileTextService instance.
+func NewFileTextService(db *gorm.DB, store *storage.Storage) *FileTextService {
+	return &FileTextService{DB: db, Storage: store, Search: NewSearchService(db)}
+}
+
+// Extract downloads a file, stores the text extracted from it and refreshes
+// its search entry. Files of other types, or larger than maxFileTextBytes,
+// are left without text.
+func (s *FileTextService) Extract(ctx context.Context, id uint) error {
+	var file models.File
+	if err := s.DB.First(&file, id).Error; err != nil {
+		return fmt.Errorf("loading file %d: %w", id, err)
+	}
+	name := file.OriginalName
+	if path.Ext(name) == "" && file.Extension != "" {
+		name += "." + file.Extension
+	}
+	if !filetext.Supported(file.MimeType, name) || file.SizeBytes > maxFileTextBytes {
+		return nil
+	}
+
+	reader, err := s.Storage.Download(ctx, file.StorageKey)
+	if err != nil {
+		return fmt.Errorf("downloading file %d: %w", id, err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, maxFileTextBytes+1))
+	if err != nil {
+		return fmt.Errorf("reading file %d: %w", id, err)
+	}
+	if len(data) > maxFileTextBytes {
+		return nil
+	}
+
+	text, err := filetext.Extract(data, file.MimeType, name)
+	if err != nil {
+		return fmt.Errorf("extracting text of file %d: %w", id, err)
+	}
+	// UpdateColumn keeps updated_at and the version unchanged: extracted text is not a user edit
+	if err := s.DB.Model(&file).UpdateColumn("content", text).Error; err != nil {
+		return fmt.Errorf("saving text of file %d: %w", id, err)
+	}
+	s.Search.sync(models.ResourceTypeFile, id)
+	return nil
+}
+
+// ExtractAll extracts the text of every file that has none yet. Files that
+// fail are logged and skipped.
+func (s *FileTextService) ExtractAll(ctx context.Context) (int, error) {
+	var ids []uint
+	if err := s.DB.Model(&models.File{}).Where("content = '' OR content IS NULL").
+		Order("id").Pluck("id", &ids).Error; err != nil {
+		return 0, fmt.Errorf("listing files: %w", err)
+	}
+	for _, id := range ids {
+		if err := s.Extract(ctx, id); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	return len(ids), nil
+}
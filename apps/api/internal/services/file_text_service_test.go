@@ -0,0 +1,41 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"desis-keep/apps/api/internal/models"
+)
+
+func TestFileIndexEntryIncludesContent(t *testing.T) {
+	db := testDB(t)
+	if err := db.AutoMigrate(&models.User{}, &models.Label{}, &models.File{}); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	file := models.File{
+		OriginalName: "scan-0042.pdf",
+		StorageKey:   "files/scan-0042.pdf",
+		URL:          "https://cdn.example.com/files/scan-0042.pdf",
+		MimeType:     "application/pdf",
+		Extension:    "pdf",
+		Content:      "Lease agreement\nThe deposit is returned within 30 days.",
+		UserID:       1,
+	}
+	if err := db.Create(&file).Error; err != nil {
+		t.Fatalf("creating file: %v", err)
+	}
+
+	entry, err := NewSearchService(db).loadEntry(models.ResourceTypeFile, file.ID)
+	if err != nil {
+		t.Fatalf("loadEntry: %v", err)
+	}
+	if !strings.Contains(entry.Body, "deposit is returned") || !strings.HasPrefix(entry.Body, "scan-0042.pdf") {
+		t.Errorf("indexed body = %q, want the name and the extracted text", entry.Body)
+	}
+
+	// The extracted text stays out of API responses
+	if data, _ := json.Marshal(file); strings.Contains(string(data), "deposit") {
+		t.Error("file JSON includes the extracted text")
+	}
+}
@@ -1,133 +1,167 @@
-package services
-
-import (
-	"fmt"
-	"math"
-
-	"gorm.io/gorm"
-
-	"desis-keep/apps/api/internal/models"
-)
-
-// ImageService handles business logic for images.
-type ImageService struct {
-	DB *gorm.DB
-}
-
-// NewImageService creates a new ImageService instance.
-func NewImageService(db *gorm.DB) *ImageService {
-	return &ImageService{DB: db}
-}
-
-// List returns a paginated list of images for a user.
-func (s *ImageService) List(userID uint, page, pageSize int, search, sortKey, sortDir string, archived, trashed *bool) ([]models.Image, int64, int, error) {
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 20
-	}
-	if sortDir != "asc" && sortDir != "desc" {
-		sortDir = "desc"
-	}
-	if sortKey == "" {
-		sortKey = "created_at"
-	}
-
-	query := s.DB.Model(&models.Image{}).Where("user_id = ?", userID).Preload("Labels")
-
-	if archived == nil && trashed == nil {
-		query = query.Where("is_archived = ? AND is_trashed = ?", false, false)
-	} else {
-		if archived != nil {
-			query = query.Where("is_archived = ?", *archived)
-		}
-		if trashed != nil {
-			query = query.Where("is_trashed = ?", *trashed)
-		}
-	}
-
-	if search != "" {
-		query = query.Where("title ILIKE ?", "%"+search+"%")
-	}
-
-	var total int64
-	query.Count(&total)
-
-	var images []models.Image
-	offset := (page - 1) * pageSize
-	if err := query.Order(sortKey + " " + sortDir).Offset(offset).Limit(pageSize).Find(&images).Error; err != nil {
-		return nil, 0, 0, fmt.Errorf("fetching images: %w", err)
-	}
-
-	pages := int(math.Ceil(float64(total) / float64(pageSize)))
-	return images, total, pages, nil
-}
-
-// GetByID returns a single image by ID (scoped to user).
-func (s *ImageService) GetByID(id, userID uint) (*models.Image, error) {
-	var image models.Image
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).Preload("Labels").First(&image).Error; err != nil {
-		return nil, fmt.Errorf("image not found: %w", err)
-	}
-	return &image, nil
-}
-
-// Create creates a new image.
-func (s *ImageService) Create(image *models.Image) error {
-	if err := s.DB.Create(image).Error; err != nil {
-		return fmt.Errorf("creating image: %w", err)
-	}
-	return nil
-}
-
-// Update modifies an existing image.
-func (s *ImageService) Update(id, userID uint, data map[string]interface{}) (*models.Image, error) {
-	var image models.Image
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&image).Error; err != nil {
-		return nil, fmt.Errorf("image not found: %w", err)
-	}
-
-	if err := s.DB.Model(&image).Updates(data).Error; err != nil {
-		return nil, fmt.Errorf("updating image: %w", err)
-	}
-
-	s.DB.Where("id = ?", id).Preload("Labels").First(&image)
-	return &image, nil
-}
-
-// Delete soft-deletes an image (sets is_trashed = true).
-func (s *ImageService) Delete(id, userID uint) error {
-	var image models.Image
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&image).Error; err != nil {
-		return fmt.Errorf("image not found: %w", err)
-	}
-	if err := s.DB.Model(&image).Update("is_trashed", true).Error; err != nil {
-		return fmt.Errorf("trashing image: %w", err)
-	}
-	return nil
-}
-
-// Restore restores a trashed image.
-func (s *ImageService) Restore(id, userID uint) error {
-	var image models.Image
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&image).Error; err != nil {
-		return fmt.Errorf("image not found: %w", err)
-	}
-	if err := s.DB.Model(&image).Update("is_trashed", false).Error; err != nil {
-		return fmt.Errorf("restoring image: %w", err)
-	}
-	return nil
-}
-
-// PermanentDelete hard-deletes an image.
-func (s *ImageService) PermanentDelete(id, userID uint) error {
-	var image models.Image
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&image).Error; err != nil {
-		return fmt.Errorf("image not found: %w", err)
-	}
-	if err := s.DB.Unscoped().Delete(&image).Error; err != nil {
-		return fmt.Errorf("permanently deleting image: %w", err)
-	}
-	return nil
-}
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/querylang"
+)
+
+// ImageService handles business logic for images.
+type ImageService struct {
+	DB     *gorm.DB
+	Search *SearchService
+}
+
+// NewImageService creates a new ImageService instance.
+func NewImageService(db *gorm.DB) *ImageService {
+	return &ImageService{DB: db, Search: NewSearchService(db)}
+}
+
+// List returns a paginated list of images for a user, filtered by a parsed search query.
+func (s *ImageService) List(userID uint, page, pageSize int, q *querylang.Query, sortKey, sortDir string, archived, trashed *bool) ([]models.Image, int64, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	if sortDir != "asc" && sortDir != "desc" {
+		sortDir = "desc"
+	}
+	if sortKey == "" {
+		sortKey = "created_at"
+	}
+
+	query := s.DB.Model(&models.Image{}).Scopes(accessible(models.ResourceTypeImage, userID, models.SharePermissionView)).Preload("Labels")
+
+	// Default: exclude archived and trashed unless the query asks for them
+	if archived == nil && trashed == nil {
+		if !q.HasFlag(querylang.FlagArchived) {
+			query = query.Where("is_archived = ?", false)
+		}
+		if !q.HasFlag(querylang.FlagTrashed) {
+			query = query.Where("is_trashed = ?", false)
+		}
+	} else {
+		if archived != nil {
+			query = query.Where("is_archived = ?", *archived)
+		}
+		if trashed != nil {
+			query = query.Where("is_trashed = ?", *trashed)
+		}
+	}
+
+	query = q.Scope(querylang.ImageTarget)(query)
+
+	var total int64
+	query.Count(&total)
+
+	var images []models.Image
+	offset := (page - 1) * pageSize
+	if err := query.Order(sortKey + " " + sortDir).Offset(offset).Limit(pageSize).Find(&images).Error; err != nil {
+		return nil, 0, 0, fmt.Errorf("fetching images: %w", err)
+	}
+
+	pages := int(math.Ceil(float64(total) / float64(pageSize)))
+	return images, total, pages, nil
+}
+
+// GetByID returns a single image by ID (scoped to user).
+func (s *ImageService) GetByID(id, userID uint) (*models.Image, error) {
+	var image models.Image
+	if err := s.DB.Where("id = ?", id).Scopes(accessible(models.ResourceTypeImage, userID, models.SharePermissionView)).Preload("Labels").First(&image).Error; err != nil {
+		return nil, fmt.Errorf("image not found: %w", err)
+	}
+	return &image, nil
+}
+
+// Create creates a new image.
+func (s *ImageService) Create(image *models.Image) error {
+	if err := s.DB.Create(image).Error; err != nil {
+		return fmt.Errorf("creating image: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeImage, image.ID)
+	return nil
+}
+
+// Update modifies an existing image. A image that is no longer at version is
+// returned as it is now, with ErrVersionConflict.
+func (s *ImageService) Update(id, userID, version uint, data map[string]interface{}) (*models.Image, error) {
+	var image models.Image
+	if err := s.DB.Where("id = ?", id).Scopes(accessible(models.ResourceTypeImage, userID, models.SharePermissionEdit)).First(&image).Error; err != nil {
+		return nil, fmt.Errorf("image not found: %w", err)
+	}
+
+	err := updateVersioned(s.DB, &image, version, data)
+	if err != nil && !errors.Is(err, ErrVersionConflict) {
+		return nil, fmt.Errorf("updating image: %w", err)
+	}
+
+	s.DB.Where("id = ?", id).Preload("Labels").First(&image)
+	if err != nil {
+		return &image, err
+	}
+	s.Search.sync(models.ResourceTypeImage, image.ID)
+	return &image, nil
+}
+
+// Delete soft-deletes an image (sets is_trashed = true).
+func (s *ImageService) Delete(id, userID uint) error {
+	var image models.Image
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&image).Error; err != nil {
+		return fmt.Errorf("image not found: %w", err)
+	}
+	if err := s.DB.Model(&image).Update("is_trashed", true).Error; err != nil {
+		return fmt.Errorf("trashing image: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeImage, image.ID)
+	return nil
+}
+
+// Restore restores a trashed image.
+func (s *ImageService) Restore(id, userID uint) error {
+	var image models.Image
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&image).Error; err != nil {
+		return fmt.Errorf("image not found: %w", err)
+	}
+	if err := s.DB.Model(&image).Update("is_trashed", false).Error; err != nil {
+		return fmt.Errorf("restoring image: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeImage, image.ID)
+	return nil
+}
+
+// PermanentDelete hard-deletes an image.
+func (s *ImageService) PermanentDelete(id, userID uint) error {
+	var image models.Image
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&image).Error; err != nil {
+		return fmt.Errorf("image not found: %w", err)
+	}
+	if err := s.DB.Unscoped().Delete(&image).Error; err != nil {
+		return fmt.Errorf("permanently deleting image: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeImage, image.ID)
+	forgetReferences(s.DB, models.ResourceTypeImage, image.ID)
+	forgetShares(s.DB, models.ResourceTypeImage, image.ID)
+	forgetPublicLinks(s.DB, models.ResourceTypeImage, image.ID)
+	return nil
+}
+
+// SetLabels replaces the labels on an image with the given labels owned by the user.
+func (s *ImageService) SetLabels(image *models.Image, userID uint, labelIDs []uint) error {
+	if image.UserID != userID {
+		return ErrNotOwner
+	}
+	var labels []models.Label
+	s.DB.Where("id IN ? AND user_id = ?", labelIDs, userID).Find(&labels)
+	if err := s.DB.Model(image).Association("Labels").Replace(labels); err != nil {
+		return fmt.Errorf("setting image labels: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeImage, image.ID)
+	return nil
+}
@@ -0,0 +1,426 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/archive"
+	"desis-keep/apps/api/internal/checklist"
+	"desis-keep/apps/api/internal/models"
+)
+
+// maxReportConflicts caps the conflicts listed in an import report.
+const maxReportConflicts = 500
+
+// Reasons an archive entry is reported as a conflict.
+const (
+	conflictExists         = "exists"          // matches a resource the user already has
+	conflictMissingContent = "missing_content" // the image or file content is not in the archive
+	conflictInvalid        = "invalid"         // the entry cannot be imported, such as a link without a valid URL
+)
+
+// accountImport holds the state of one account import run.
+type accountImport struct {
+	*ImportService
+	job     *models.ImportJob
+	archive *archive.Reader
+	report  *models.ImportReport
+	merge   bool
+	// labels maps archive label slugs to the user's labels. In a dry run,
+	// labels that would be created have no ID.
+	labels map[string]models.Label
+}
+
+// importAccount restores an account archive made by the account export.
+// Labels are matched by slug and reused, or created; every other resource is
+// created with its state, timestamps and labels. Entries that match something
+// the user already has are skipped in merge mode and imported anyway in copy
+// mode, except links, which cannot be saved twice and get their labels merged
+// instead. A dry run changes nothing and only fills in the report.
+func (s *ImportService) importAccount(ctx context.Context, job *models.ImportJob, r io.Reader) error {
+	zr, cleanup, err := spoolZip(r, archive.ErrNotAccountArchive)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	reader, err := archive.Open(zr)
+	if err != nil {
+		return err
+	}
+	m := reader.Manifest
+
+	im := &accountImport{
+		ImportService: s,
+		job:           job,
+		archive:       reader,
+		report:        &models.ImportReport{Create: map[string]int{}, Conflicts: []models.ImportIssue{}},
+		merge:         job.Mode != models.ImportModeCopy,
+		labels:        map[string]models.Label{},
+	}
+	job.Report = im.report
+	job.Total = len(m.Labels) + len(m.Notes) + len(m.Links) + len(m.Images) + len(m.Files)
+	if err := s.saveProgress(job); err != nil {
+		return err
+	}
+
+	steps := []func() error{}
+	for _, l := range m.Labels {
+		l := l
+		steps = append(steps, func() error { return im.label(l) })
+	}
+	for _, n := range m.Notes {
+		n := n
+		steps = append(steps, func() error { return im.note(n) })
+	}
+	for _, l := range m.Links {
+		l := l
+		steps = append(steps, func() error { return im.link(l) })
+	}
+	for _, img := range m.Images {
+		img := img
+		steps = append(steps, func() error { return im.image(ctx, img) })
+	}
+	for _, f := range m.Files {
+		f := f
+		steps = append(steps, func() error { return im.file(ctx, f) })
+	}
+
+	for _, step := range steps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := step(); err != nil {
+			return err
+		}
+		s.step(job)
+	}
+	return nil
+}
+
+// label reuses the user's label with the entry's slug, restoring it if it
+// was deleted, or creates it.
+func (im *accountImport) label(l archive.Label) error {
+	label := models.Label{
+		Name:      truncateRunes(l.Name, 255),
+		Slug:      l.Slug,
+		Color:     l.Color,
+		UserID:    im.job.UserID,
+		CreatedAt: l.CreatedAt,
+	}
+	label.BeforeCreate(im.DB) // fills in a missing slug from the name
+
+	var existing models.Label
+	err := im.DB.Unscoped().Where("user_id = ? AND slug = ?", im.job.UserID, label.Slug).First(&existing).Error
+	switch {
+	case err == nil:
+		if existing.DeletedAt.Valid && !im.job.DryRun {
+			if err := im.DB.Unscoped().Model(&existing).Update("deleted_at", nil).Error; err != nil {
+				return fmt.Errorf("restoring label: %w", err)
+			}
+		}
+		im.labels[l.Slug] = existing
+		im.job.Merged++
+		return nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return fmt.Errorf("loading label: %w", err)
+	}
+
+	if !im.job.DryRun {
+		if err := im.DB.Create(&label).Error; err != nil {
+			return fmt.Errorf("creating label: %w", err)
+		}
+	}
+	im.labels[l.Slug] = label
+	im.created("label")
+	return nil
+}
+
+// resolveLabels returns the labels for an entry's slugs. Slugs the manifest
+// does not define are ignored.
+func (im *accountImport) resolveLabels(slugs []string) []models.Label {
+	var labels []models.Label
+	for _, slug := range slugs {
+		if label, ok := im.labels[slug]; ok && label.ID != 0 {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+func (im *accountImport) note(n archive.Note) error {
+	if n.Path == "" || !im.archive.Has(n.Path) {
+		im.conflict(models.ResourceTypeNote, n.ID, n.Title, conflictMissingContent, 0, models.ImportSkipped)
+		im.job.Skipped++
+		return nil
+	}
+	rc, _, err := im.archive.Open(n.Path)
+	if err != nil {
+		return err
+	}
+	body, err := archive.ReadNoteBody(rc)
+	rc.Close()
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", n.Path, err)
+	}
+
+	var existing models.Note
+	err = im.DB.Select("id").
+		Where("user_id = ? AND title = ? AND body = ?", im.job.UserID, n.Title, body).
+		First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("checking for existing note: %w", err)
+	}
+	if existing.ID != 0 && im.resolveExisting(models.ResourceTypeNote, n.ID, n.Title, existing.ID) {
+		return nil
+	}
+
+	note := models.Note{
+		Title:      truncateRunes(n.Title, 500),
+		Body:       body,
+		Color:      n.Color,
+		IsPinned:   n.IsPinned,
+		IsArchived: n.IsArchived,
+		IsTrashed:  n.IsTrashed,
+		UserID:     im.job.UserID,
+		Labels:     im.resolveLabels(n.Labels),
+		CreatedAt:  n.CreatedAt,
+		UpdatedAt:  n.UpdatedAt,
+	}
+	if note.Color == "" {
+		note.Color = "#ffffff"
+	}
+	if n.Type == models.NoteTypeChecklist {
+		note.Type = models.NoteTypeChecklist
+		note.MoveCheckedToBottom = n.MoveCheckedToBottom
+		for _, item := range checklist.Parse(body) {
+			note.Items = append(note.Items, models.NoteItem{Text: item.Text, Checked: item.Checked, Indent: item.Indent})
+		}
+	}
+	if im.job.DryRun {
+		im.created(models.ResourceTypeNote)
+		return nil
+	}
+	if err := im.Notes.Create(&note); err != nil {
+		return err
+	}
+	im.created(models.ResourceTypeNote)
+	if im.AfterCreate != nil {
+		im.AfterCreate(models.ResourceTypeNote, note.ID, note.Title)
+	}
+	return nil
+}
+
+func (im *accountImport) link(l archive.Link) error {
+	lower := strings.ToLower(l.URL)
+	if (!strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://")) || len(l.URL) > 2048 {
+		im.conflict(models.ResourceTypeLink, l.ID, l.Title, conflictInvalid, 0, models.ImportSkipped)
+		im.job.Skipped++
+		return nil
+	}
+
+	existing, err := im.Links.FindDuplicate(im.job.UserID, CanonicalURL(l.URL))
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		// Links cannot be saved twice, so copy mode merges labels instead
+		resolution := models.ImportMerged
+		if im.merge {
+			resolution = models.ImportSkipped
+		}
+		im.conflict(models.ResourceTypeLink, l.ID, l.Title, conflictExists, existing.ID, resolution)
+		im.job.Merged++
+		labels := im.resolveLabels(l.Labels)
+		if !im.merge && !im.job.DryRun && len(labels) > 0 {
+			return im.Links.AddLabels(existing, im.job.UserID, labelIDs(labels))
+		}
+		return nil
+	}
+
+	link := models.Link{
+		URL:          l.URL,
+		Title:        truncateRunes(l.Title, 500),
+		Description:  l.Description,
+		ThumbnailURL: l.ThumbnailURL,
+		FaviconURL:   l.FaviconURL,
+		IsPinned:     l.IsPinned,
+		IsArchived:   l.IsArchived,
+		IsTrashed:    l.IsTrashed,
+		UserID:       im.job.UserID,
+		Labels:       im.resolveLabels(l.Labels),
+		CreatedAt:    l.CreatedAt,
+		UpdatedAt:    l.UpdatedAt,
+	}
+	if im.job.DryRun {
+		im.created(models.ResourceTypeLink)
+		return nil
+	}
+	if err := im.Links.Create(&link); err != nil {
+		return err
+	}
+	im.created(models.ResourceTypeLink)
+	if im.AfterCreate != nil {
+		im.AfterCreate(models.ResourceTypeLink, link.ID, link.Title)
+	}
+	return nil
+}
+
+func (im *accountImport) image(ctx context.Context, img archive.Image) error {
+	if img.Path == "" || !im.archive.Has(img.Path) {
+		im.conflict(models.ResourceTypeImage, img.ID, img.Title, conflictMissingContent, 0, models.ImportSkipped)
+		im.job.Skipped++
+		return nil
+	}
+
+	var existing models.Image
+	err := im.DB.Select("id").
+		Where("user_id = ? AND title = ? AND size_bytes = ? AND mime_type = ?", im.job.UserID, img.Title, img.SizeBytes, img.MimeType).
+		First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("checking for existing image: %w", err)
+	}
+	if existing.ID != 0 && im.resolveExisting(models.ResourceTypeImage, img.ID, img.Title, existing.ID) {
+		return nil
+	}
+	if im.job.DryRun {
+		im.created(models.ResourceTypeImage)
+		return nil
+	}
+
+	key, size, err := im.uploadEntry(ctx, img.Path, img.MimeType)
+	if err != nil {
+		return err
+	}
+	image := models.Image{
+		Title:      truncateRunes(img.Title, 500),
+		StorageKey: key,
+		URL:        im.Storage.GetURL(key),
+		MimeType:   img.MimeType,
+		SizeBytes:  uint(size),
+		Width:      img.Width,
+		Height:     img.Height,
+		Folder:     truncateRunes(img.Folder, 255),
+		IsPinned:   img.IsPinned,
+		IsArchived: img.IsArchived,
+		IsTrashed:  img.IsTrashed,
+		UserID:     im.job.UserID,
+		Labels:     im.resolveLabels(img.Labels),
+		CreatedAt:  img.CreatedAt,
+		UpdatedAt:  img.UpdatedAt,
+	}
+	if err := im.Images.Create(&image); err != nil {
+		return err
+	}
+	im.created(models.ResourceTypeImage)
+	return nil
+}
+
+func (im *accountImport) file(ctx context.Context, f archive.File) error {
+	if f.Path == "" || !im.archive.Has(f.Path) {
+		im.conflict(models.ResourceTypeFile, f.ID, f.Title, conflictMissingContent, 0, models.ImportSkipped)
+		im.job.Skipped++
+		return nil
+	}
+
+	var existing models.File
+	err := im.DB.Select("id").
+		Where("user_id = ? AND original_name = ? AND size_bytes = ?", im.job.UserID, f.OriginalName, f.SizeBytes).
+		First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("checking for existing file: %w", err)
+	}
+	if existing.ID != 0 && im.resolveExisting(models.ResourceTypeFile, f.ID, f.Title, existing.ID) {
+		return nil
+	}
+	if im.job.DryRun {
+		im.created(models.ResourceTypeFile)
+		return nil
+	}
+
+	key, size, err := im.uploadEntry(ctx, f.Path, f.MimeType)
+	if err != nil {
+		return err
+	}
+	file := models.File{
+		Title:        truncateRunes(f.Title, 500),
+		OriginalName: truncateRunes(f.OriginalName, 500),
+		StorageKey:   key,
+		URL:          im.Storage.GetURL(key),
+		MimeType:     f.MimeType,
+		SizeBytes:    uint(size),
+		Extension:    f.Extension,
+		Folder:       truncateRunes(f.Folder, 255),
+		IsPinned:     f.IsPinned,
+		IsArchived:   f.IsArchived,
+		IsTrashed:    f.IsTrashed,
+		UserID:       im.job.UserID,
+		Labels:       im.resolveLabels(f.Labels),
+		CreatedAt:    f.CreatedAt,
+		UpdatedAt:    f.UpdatedAt,
+	}
+	if file.OriginalName == "" {
+		file.OriginalName = path.Base(f.Path)
+	}
+	if err := im.Files.Create(&file); err != nil {
+		return err
+	}
+	im.created(models.ResourceTypeFile)
+	if im.AfterCreate != nil {
+		im.AfterCreate(models.ResourceTypeFile, file.ID, file.Title)
+	}
+	return nil
+}
+
+// uploadEntry stores an archive entry under a new upload key.
+func (im *accountImport) uploadEntry(ctx context.Context, name, mimeType string) (string, int64, error) {
+	rc, size, err := im.archive.Open(name)
+	if err != nil {
+		return "", 0, err
+	}
+	defer rc.Close()
+
+	key := uploadKey(path.Base(name))
+	if err := im.Storage.Upload(ctx, key, rc, mimeType); err != nil {
+		return "", 0, err
+	}
+	return key, size, nil
+}
+
+// resolveExisting reports an entry that matches an existing resource and
+// tells whether the entry should be skipped, which it is in merge mode.
+func (im *accountImport) resolveExisting(resourceType string, sourceID uint, title string, existingID uint) bool {
+	if im.merge {
+		im.conflict(resourceType, sourceID, title, conflictExists, existingID, models.ImportSkipped)
+		im.job.Merged++
+		return true
+	}
+	im.conflict(resourceType, sourceID, title, conflictExists, existingID, models.ImportDuplicated)
+	return false
+}
+
+func (im *accountImport) created(resourceType string) {
+	im.report.Create[resourceType]++
+	im.job.Created++
+}
+
+func (im *accountImport) conflict(resourceType string, sourceID uint, title, reason string, existingID uint, resolution string) {
+	if len(im.report.Conflicts) >= maxReportConflicts {
+		im.report.MoreConflicts++
+		return
+	}
+	im.report.Conflicts = append(im.report.Conflicts, models.ImportIssue{
+		ResourceType: resourceType,
+		SourceID:     sourceID,
+		Title:        truncateRunes(title, 200),
+		Reason:       reason,
+		ExistingID:   existingID,
+		Resolution:   resolution,
+	})
+}
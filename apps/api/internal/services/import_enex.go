@@ -0,0 +1,252 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"desis-keep/apps/api/internal/enex"
+	"desis-keep/apps/api/internal/models"
+)
+
+const (
+	// enexFolder is the folder imported Evernote attachments are filed under.
+	enexFolder = "Evernote"
+	// maxEnexAttachmentBytes caps a single imported attachment.
+	maxEnexAttachmentBytes = 50 << 20
+)
+
+// enexAttachment is an uploaded attachment as the note body refers to it.
+type enexAttachment struct {
+	name    string
+	url     string
+	isImage bool
+}
+
+// importEnex creates notes from an Evernote export. The file is read one note
+// at a time, so the total grows as the import goes. Note content is converted
+// to Markdown, tags become labels, and, since Evernote exports one notebook
+// per file, the notebook named by the file becomes a label too. Attachments
+// are uploaded as images or files and linked from the note body where they
+// appeared. Notes and attachments imported before are skipped, so the same
+// export can be imported again safely.
+func (s *ImportService) importEnex(ctx context.Context, job *models.ImportJob, r io.Reader) error {
+	labels := newLabelCache(s.DB, job.UserID)
+	var notebook []string
+	if name := strings.TrimSpace(strings.TrimSuffix(job.Filename, path.Ext(job.Filename))); name != "" {
+		notebook = []string{name}
+	}
+
+	reader := enex.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		note, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		job.Total++
+		if err := s.importEnexNote(ctx, job, note, labels, notebook); err != nil {
+			return err
+		}
+		s.step(job)
+	}
+}
+
+func (s *ImportService) importEnexNote(ctx context.Context, job *models.ImportJob, en *enex.Note, labels *labelCache, notebook []string) error {
+	resolved, err := labels.resolve(append(notebook, en.Tags...))
+	if err != nil {
+		return err
+	}
+
+	externalID := enexExternalID(en)
+	existing, err := s.findImported(job.UserID, models.ImportKindEvernote, externalID, models.ResourceTypeNote)
+	if err != nil {
+		return err
+	}
+
+	// Attachments come first so the note body can link to them. They are
+	// tracked on their own so a retry picks up any that failed.
+	attachments := map[string]enexAttachment{}
+	for _, res := range en.Resources {
+		att, err := s.importEnexAttachment(ctx, job, en, externalID, res, resolved)
+		if err != nil {
+			log.Printf("Warning: import %d: %v", job.ID, err)
+			continue
+		}
+		attachments[res.Hash] = att
+	}
+
+	if existing != 0 {
+		job.Merged++
+		return nil
+	}
+
+	body := enex.ToText(en.Content, func(hash, mimeType string) string {
+		att, ok := attachments[hash]
+		if !ok {
+			return ""
+		}
+		if att.isImage {
+			return fmt.Sprintf("![%s](%s)", att.name, att.url)
+		}
+		return fmt.Sprintf("[%s](%s)", att.name, att.url)
+	})
+	if en.SourceURL != "" {
+		body = strings.TrimSpace(body + "\n\nSource: " + en.SourceURL)
+	}
+	if en.Title == "" && body == "" {
+		job.Skipped++
+		return nil
+	}
+
+	note := models.Note{
+		Title:     truncateRunes(en.Title, 500),
+		Body:      body,
+		UserID:    job.UserID,
+		Labels:    resolved,
+		CreatedAt: en.Created,
+		UpdatedAt: en.Updated,
+	}
+	if note.UpdatedAt.IsZero() {
+		note.UpdatedAt = note.CreatedAt
+	}
+	if err := s.Notes.Create(&note); err != nil {
+		return err
+	}
+	if err := s.recordImport(job.UserID, models.ImportKindEvernote, externalID, models.ResourceTypeNote, note.ID); err != nil {
+		return err
+	}
+	job.Created++
+	if s.AfterCreate != nil {
+		s.AfterCreate(models.ResourceTypeNote, note.ID, note.Title)
+	}
+	return nil
+}
+
+// importEnexAttachment uploads one attachment and records it as an image, or
+// as a file when it is not an image. An attachment imported before is looked
+// up instead.
+func (s *ImportService) importEnexAttachment(ctx context.Context, job *models.ImportJob, en *enex.Note, noteID string, res enex.Resource, labels []models.Label) (enexAttachment, error) {
+	externalID := noteID + "/" + res.Hash
+	mimeType := res.Mime
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(path.Ext(res.FileName))
+	}
+	name := path.Base(res.FileName)
+	if res.FileName == "" {
+		name = res.Hash
+		if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+			name += exts[0]
+		}
+	}
+	resourceType := models.ResourceTypeFile
+	if strings.HasPrefix(mimeType, "image/") {
+		resourceType = models.ResourceTypeImage
+	}
+	att := enexAttachment{name: name, isImage: resourceType == models.ResourceTypeImage}
+
+	existing, err := s.findImported(job.UserID, models.ImportKindEvernote, externalID, resourceType)
+	if err != nil {
+		return att, err
+	}
+	if existing != 0 {
+		if att.isImage {
+			var img models.Image
+			err = s.DB.Select("url").First(&img, existing).Error
+			att.url = img.URL
+		} else {
+			var file models.File
+			err = s.DB.Select("url").First(&file, existing).Error
+			att.url = file.URL
+		}
+		return att, err
+	}
+
+	if len(res.Data) > maxEnexAttachmentBytes {
+		return att, fmt.Errorf("attachment %s is larger than %d MB", name, maxEnexAttachmentBytes>>20)
+	}
+
+	key := uploadKey(name)
+	if err := s.Storage.Upload(ctx, key, bytes.NewReader(res.Data), mimeType); err != nil {
+		return att, err
+	}
+	att.url = s.Storage.GetURL(key)
+
+	title := en.Title
+	if title == "" {
+		title = name
+	}
+	var resourceID uint
+	if att.isImage {
+		img := models.Image{
+			Title:      truncateRunes(title, 500),
+			StorageKey: key,
+			URL:        att.url,
+			MimeType:   mimeType,
+			SizeBytes:  uint(len(res.Data)),
+			Width:      res.Width,
+			Height:     res.Height,
+			Folder:     enexFolder,
+			UserID:     job.UserID,
+			Labels:     labels,
+			CreatedAt:  en.Created,
+		}
+		if img.Width == 0 || img.Height == 0 {
+			if cfg, _, err := image.DecodeConfig(bytes.NewReader(res.Data)); err == nil {
+				img.Width, img.Height = cfg.Width, cfg.Height
+			}
+		}
+		if err := s.Images.Create(&img); err != nil {
+			return att, err
+		}
+		resourceID = img.ID
+	} else {
+		file := models.File{
+			Title:        truncateRunes(title, 500),
+			OriginalName: name,
+			StorageKey:   key,
+			URL:          att.url,
+			MimeType:     mimeType,
+			SizeBytes:    uint(len(res.Data)),
+			Extension:    strings.TrimPrefix(path.Ext(name), "."),
+			Folder:       enexFolder,
+			UserID:       job.UserID,
+			Labels:       labels,
+			CreatedAt:    en.Created,
+		}
+		if err := s.Files.Create(&file); err != nil {
+			return att, err
+		}
+		resourceID = file.ID
+		if s.AfterCreate != nil {
+			s.AfterCreate(models.ResourceTypeFile, file.ID, file.Title)
+		}
+	}
+	return att, s.recordImport(job.UserID, models.ImportKindEvernote, externalID, resourceType, resourceID)
+}
+
+// enexExternalID identifies an Evernote note across exports. Exports carry no
+// note IDs, so the creation time and title stand in, with the content added
+// for notes that have no creation time.
+func enexExternalID(en *enex.Note) string {
+	h := md5.New()
+	io.WriteString(h, en.Title)
+	if en.Created.IsZero() {
+		io.WriteString(h, en.Content)
+	}
+	return en.Created.UTC().Format(time.RFC3339) + "/" + hex.EncodeToString(h.Sum(nil))
+}
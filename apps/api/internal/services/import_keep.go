@@ -0,0 +1,206 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"mime"
+	"path"
+	"strings"
+
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/takeout"
+)
+
+const (
+	// keepFolder is the folder imported Keep attachments are filed under.
+	keepFolder = "Google Keep"
+	// maxKeepAttachmentBytes caps a single imported attachment.
+	maxKeepAttachmentBytes = 50 << 20
+)
+
+// importKeep creates notes from a Google Keep Takeout archive. Colors, pin,
+// archive and trash state, labels and timestamps carry over; checklists become
+// Markdown task lists; attachments become images or files. Notes and
+// attachments imported before are skipped, so the same archive can be
+// imported again safely.
+func (s *ImportService) importKeep(ctx context.Context, job *models.ImportJob, r io.Reader) error {
+	zr, cleanup, err := spoolZip(r, takeout.ErrNoKeepNotes)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	archive, err := takeout.OpenKeep(zr)
+	if err != nil {
+		return err
+	}
+
+	job.Total = len(archive.Notes)
+	if err := s.saveProgress(job); err != nil {
+		return err
+	}
+
+	labels := newLabelCache(s.DB, job.UserID)
+	if _, err := labels.resolve(archive.Labels); err != nil {
+		return err
+	}
+	for _, note := range archive.Notes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.importKeepNote(ctx, job, archive, note, labels); err != nil {
+			return err
+		}
+		s.step(job)
+	}
+	return nil
+}
+
+func (s *ImportService) importKeepNote(ctx context.Context, job *models.ImportJob, archive *takeout.KeepArchive, kn takeout.KeepNote, labels *labelCache) error {
+	names := make([]string, 0, len(kn.Labels))
+	for _, l := range kn.Labels {
+		names = append(names, l.Name)
+	}
+	resolved, err := labels.resolve(names)
+	if err != nil {
+		return err
+	}
+
+	externalID := kn.ExternalID()
+	existing, err := s.findImported(job.UserID, models.ImportKindGoogleKeep, externalID, models.ResourceTypeNote)
+	if err != nil {
+		return err
+	}
+	if existing != 0 {
+		job.Merged++
+	} else {
+		note := models.Note{
+			Title:      truncateRunes(kn.Title, 500),
+			Body:       kn.Body(),
+			Color:      kn.ColorHex(),
+			IsPinned:   kn.IsPinned,
+			IsArchived: kn.IsArchived,
+			IsTrashed:  kn.IsTrashed,
+			UserID:     job.UserID,
+			Labels:     resolved,
+			CreatedAt:  kn.CreatedAt(),
+			UpdatedAt:  kn.EditedAt(),
+		}
+		if note.Title == "" && note.Body == "" && len(kn.Attachments) == 0 {
+			job.Skipped++
+			return nil
+		}
+		if err := s.Notes.Create(&note); err != nil {
+			return err
+		}
+		if err := s.recordImport(job.UserID, models.ImportKindGoogleKeep, externalID, models.ResourceTypeNote, note.ID); err != nil {
+			return err
+		}
+		job.Created++
+		if s.AfterCreate != nil {
+			s.AfterCreate(models.ResourceTypeNote, note.ID, note.Title)
+		}
+	}
+
+	// Attachments are tracked on their own so a retry picks up any that failed
+	for _, att := range kn.Attachments {
+		if err := s.importKeepAttachment(ctx, job, archive, kn, att, resolved); err != nil {
+			log.Printf("Warning: import %d: %v", job.ID, err)
+		}
+	}
+	return nil
+}
+
+// importKeepAttachment uploads one attachment and records it as an image, or
+// as a file when it is not an image.
+func (s *ImportService) importKeepAttachment(ctx context.Context, job *models.ImportJob, archive *takeout.KeepArchive, kn takeout.KeepNote, att takeout.KeepAttachment, labels []models.Label) error {
+	externalID := kn.ExternalID() + "/" + att.FilePath
+	mimeType := att.MimeType
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(path.Ext(att.FilePath))
+	}
+	resourceType := models.ResourceTypeFile
+	if strings.HasPrefix(mimeType, "image/") {
+		resourceType = models.ResourceTypeImage
+	}
+
+	existing, err := s.findImported(job.UserID, models.ImportKindGoogleKeep, externalID, resourceType)
+	if err != nil || existing != 0 {
+		return err
+	}
+
+	rc, size, err := archive.Attachment(kn, att)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if size > maxKeepAttachmentBytes {
+		return fmt.Errorf("attachment %s is larger than %d MB", att.FilePath, maxKeepAttachmentBytes>>20)
+	}
+	data, err := io.ReadAll(io.LimitReader(rc, maxKeepAttachmentBytes))
+	if err != nil {
+		return fmt.Errorf("reading attachment %s: %w", att.FilePath, err)
+	}
+
+	name := path.Base(att.FilePath)
+	key := uploadKey(name)
+	if err := s.Storage.Upload(ctx, key, bytes.NewReader(data), mimeType); err != nil {
+		return err
+	}
+
+	title := kn.Title
+	if title == "" {
+		title = name
+	}
+	var resourceID uint
+	if resourceType == models.ResourceTypeImage {
+		img := models.Image{
+			Title:      truncateRunes(title, 500),
+			StorageKey: key,
+			URL:        s.Storage.GetURL(key),
+			MimeType:   mimeType,
+			SizeBytes:  uint(len(data)),
+			Folder:     keepFolder,
+			IsArchived: kn.IsArchived,
+			IsTrashed:  kn.IsTrashed,
+			UserID:     job.UserID,
+			Labels:     labels,
+			CreatedAt:  kn.CreatedAt(),
+		}
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+			img.Width, img.Height = cfg.Width, cfg.Height
+		}
+		if err := s.Images.Create(&img); err != nil {
+			return err
+		}
+		resourceID = img.ID
+	} else {
+		file := models.File{
+			Title:        truncateRunes(title, 500),
+			OriginalName: name,
+			StorageKey:   key,
+			URL:          s.Storage.GetURL(key),
+			MimeType:     mimeType,
+			SizeBytes:    uint(len(data)),
+			Extension:    strings.TrimPrefix(path.Ext(name), "."),
+			Folder:       keepFolder,
+			IsArchived:   kn.IsArchived,
+			IsTrashed:    kn.IsTrashed,
+			UserID:       job.UserID,
+			Labels:       labels,
+			CreatedAt:    kn.CreatedAt(),
+		}
+		if err := s.Files.Create(&file); err != nil {
+			return err
+		}
+		resourceID = file.ID
+		if s.AfterCreate != nil {
+			s.AfterCreate(models.ResourceTypeFile, file.ID, file.Title)
+		}
+	}
+	return s.recordImport(job.UserID, models.ImportKindGoogleKeep, externalID, resourceType, resourceID)
+}
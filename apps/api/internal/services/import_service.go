@@ -0,0 +1,393 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"desis-keep/apps/api/internal/bookmarks"
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/storage"
+)
+
+// importProgressEvery is how many entries an import handles between progress saves.
+const importProgressEvery = 100
+
+// ErrUnknownImportKind is returned when an import job names a source no importer handles.
+var ErrUnknownImportKind = errors.New("unknown import kind")
+
+// ImportOptions tune how an import treats what the user already has. Only
+// account imports use them.
+type ImportOptions struct {
+	Mode   string // models.ImportModeMerge (the default) or models.ImportModeCopy
+	DryRun bool
+}
+
+// ImportService runs imports of uploaded files in the background and reports their progress.
+type ImportService struct {
+	DB      *gorm.DB
+	Storage *storage.Storage
+	Links   *LinkService
+	Notes   *NoteService
+	Images  *ImageService
+	Files   *FileService
+	// AfterCreate, when set, is called for every resource an import creates,
+	// so the caller can queue follow-up work such as embeddings.
+	AfterCreate func(resourceType string, id uint, title string)
+}
+
+// NewImportService creates a new ImportService instance.
+// store may be nil, in which case imports cannot be started or run.
+func NewImportService(db *gorm.DB, store *storage.Storage) *ImportService {
+	return &ImportService{
+		DB:      db,
+		Storage: store,
+		Links:   NewLinkService(db),
+		Notes:   NewNoteService(db),
+		Images:  NewImageService(db),
+		Files:   NewFileService(db),
+	}
+}
+
+// Start stores an uploaded file and records a pending import for it.
+// The caller enqueues the job that runs it.
+func (s *ImportService) Start(ctx context.Context, userID uint, kind, filename string, file io.Reader, opts ImportOptions) (*models.ImportJob, error) {
+	if s.Storage == nil {
+		return nil, ErrStorageUnavailable
+	}
+
+	job := models.ImportJob{
+		UserID:   userID,
+		Kind:     kind,
+		Status:   models.ImportPending,
+		Filename: truncateRunes(filename, 255),
+		Mode:     opts.Mode,
+		DryRun:   opts.DryRun,
+	}
+	if err := s.DB.Create(&job).Error; err != nil {
+		return nil, fmt.Errorf("creating import: %w", err)
+	}
+
+	key := fmt.Sprintf("imports/%d/%d/source", userID, job.ID)
+	if err := s.Storage.Upload(ctx, key, file, "application/octet-stream"); err != nil {
+		s.DB.Delete(&job)
+		return nil, err
+	}
+	if err := s.DB.Model(&job).Update("source_key", key).Error; err != nil {
+		return nil, fmt.Errorf("creating import: %w", err)
+	}
+	return &job, nil
+}
+
+// Get returns one of the user's imports.
+func (s *ImportService) Get(id, userID uint) (*models.ImportJob, error) {
+	var job models.ImportJob
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&job).Error; err != nil {
+		return nil, fmt.Errorf("import not found: %w", err)
+	}
+	return &job, nil
+}
+
+// List returns the user's most recent imports, newest first.
+func (s *ImportService) List(userID uint, limit int) ([]models.ImportJob, error) {
+	var jobs []models.ImportJob
+	if err := s.DB.Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Find(&jobs).Error; err != nil {
+		return nil, fmt.Errorf("fetching imports: %w", err)
+	}
+	return jobs, nil
+}
+
+// Run performs a pending import. Counters start over when a failed or
+// interrupted import is retried; entries it already imported are matched
+// rather than duplicated. The uploaded file is deleted once the import completes.
+func (s *ImportService) Run(ctx context.Context, id uint) error {
+	if s.Storage == nil {
+		return ErrStorageUnavailable
+	}
+
+	var job models.ImportJob
+	err := s.DB.First(&job, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("loading import %d: %w", id, err)
+	}
+	if job.Status == models.ImportCompleted {
+		return nil
+	}
+
+	now := time.Now()
+	job.Status = models.ImportRunning
+	job.StartedAt = &now
+	job.Total, job.Processed, job.Created, job.Merged, job.Skipped = 0, 0, 0, 0, 0
+	job.Error = ""
+	job.Report = nil
+	if err := s.saveProgress(&job); err != nil {
+		return err
+	}
+
+	if err := s.run(ctx, &job); err != nil {
+		job.Status = models.ImportFailed
+		job.Error = err.Error()
+		if saveErr := s.saveProgress(&job); saveErr != nil {
+			log.Printf("Warning: %v", saveErr)
+		}
+		return err
+	}
+
+	finished := time.Now()
+	job.Status = models.ImportCompleted
+	job.FinishedAt = &finished
+	if err := s.saveProgress(&job); err != nil {
+		return err
+	}
+	if err := s.Storage.Delete(ctx, job.SourceKey); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return nil
+}
+
+func (s *ImportService) run(ctx context.Context, job *models.ImportJob) error {
+	reader, err := s.Storage.Download(ctx, job.SourceKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	switch job.Kind {
+	case models.ImportKindBookmarks:
+		return s.importBookmarks(ctx, job, reader)
+	case models.ImportKindGoogleKeep:
+		return s.importKeep(ctx, job, reader)
+	case models.ImportKindAccount:
+		return s.importAccount(ctx, job, reader)
+	case models.ImportKindEvernote:
+		return s.importEnex(ctx, job, reader)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownImportKind, job.Kind)
+	}
+}
+
+// saveProgress writes the job's status and counters.
+func (s *ImportService) saveProgress(job *models.ImportJob) error {
+	err := s.DB.Model(job).Select(
+		"status", "total", "processed", "created", "merged", "skipped", "error", "report", "started_at", "finished_at",
+	).Updates(job).Error
+	if err != nil {
+		return fmt.Errorf("saving progress of import %d: %w", job.ID, err)
+	}
+	return nil
+}
+
+// spoolZip copies an uploaded archive to a temporary file, since zip needs
+// random access, and opens it. Errors opening the zip wrap notZip. The
+// returned cleanup removes the file.
+func spoolZip(r io.Reader, notZip error) (*zip.Reader, func(), error) {
+	tmp, err := os.CreateTemp("", "import-*.zip")
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating temp file: %w", err)
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("downloading archive: %w", err)
+	}
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("%w: %v", notZip, err)
+	}
+	return zr, cleanup, nil
+}
+
+// uploadKey returns a fresh storage key for an imported upload, laid out
+// like the keys of regular uploads.
+func uploadKey(name string) string {
+	now := time.Now()
+	return fmt.Sprintf("uploads/%s/%d-%s", now.Format("2006/01"), now.UnixNano(), name)
+}
+
+// findImported returns the ID of the resource an item was imported as, or 0
+// when it was never imported or that resource has since been deleted.
+func (s *ImportService) findImported(userID uint, source, externalID, resourceType string) (uint, error) {
+	var record models.ImportRecord
+	err := s.DB.Where("user_id = ? AND source = ? AND external_id = ?", userID, source, externalID).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("loading import record: %w", err)
+	}
+	if record.ResourceType != resourceType {
+		return 0, nil
+	}
+	if _, err := loadOwnedResource(s.DB, resourceType, record.ResourceID, userID); err != nil {
+		return 0, nil
+	}
+	return record.ResourceID, nil
+}
+
+// recordImport remembers the resource an item was imported as.
+func (s *ImportService) recordImport(userID uint, source, externalID, resourceType string, resourceID uint) error {
+	record := models.ImportRecord{
+		UserID:       userID,
+		Source:       source,
+		ExternalID:   truncateRunes(externalID, 255),
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+	}
+	err := s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "source"}, {Name: "external_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"resource_type", "resource_id"}),
+	}).Create(&record).Error
+	if err != nil {
+		return fmt.Errorf("recording import of %s: %w", externalID, err)
+	}
+	return nil
+}
+
+// step counts one handled entry and saves progress periodically.
+func (s *ImportService) step(job *models.ImportJob) {
+	job.Processed++
+	if job.Processed%importProgressEvery == 0 {
+		if err := s.saveProgress(job); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+// importBookmarks creates a link for every http(s) bookmark in a Netscape
+// bookmark file. Folder names and tags become labels, ADD_DATE becomes the
+// creation time, and bookmarks the user already saved get the labels merged in.
+func (s *ImportService) importBookmarks(ctx context.Context, job *models.ImportJob, r io.Reader) error {
+	entries, err := bookmarks.Parse(r)
+	if err != nil {
+		return err
+	}
+	job.Total = len(entries)
+	if err := s.saveProgress(job); err != nil {
+		return err
+	}
+
+	labels := newLabelCache(s.DB, job.UserID)
+	for _, b := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.importBookmark(job, b, labels); err != nil {
+			return err
+		}
+		s.step(job)
+	}
+	return nil
+}
+
+func (s *ImportService) importBookmark(job *models.ImportJob, b bookmarks.Bookmark, labels *labelCache) error {
+	lower := strings.ToLower(b.URL)
+	if (!strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://")) || len(b.URL) > 2048 {
+		// javascript:, place:, file: and similar entries only make sense in a browser
+		job.Skipped++
+		return nil
+	}
+
+	resolved, err := labels.resolve(append(append([]string{}, b.Folders...), b.Tags...))
+	if err != nil {
+		return err
+	}
+
+	link := models.Link{
+		URL:         b.URL,
+		Title:       truncateRunes(b.Title, 500),
+		Description: b.Description,
+		UserID:      job.UserID,
+		Labels:      resolved,
+		CreatedAt:   b.AddDate,
+		UpdatedAt:   b.LastModified,
+	}
+	if link.UpdatedAt.IsZero() {
+		link.UpdatedAt = link.CreatedAt
+	}
+
+	err = s.Links.Create(&link)
+	var duplicate *DuplicateLinkError
+	if errors.As(err, &duplicate) {
+		if len(resolved) > 0 {
+			if err := s.Links.AddLabels(duplicate.Existing, job.UserID, labelIDs(resolved)); err != nil {
+				return err
+			}
+		}
+		job.Merged++
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	job.Created++
+	if s.AfterCreate != nil {
+		s.AfterCreate(models.ResourceTypeLink, link.ID, link.Title)
+	}
+	return nil
+}
+
+// labelCache resolves label names for one user, creating missing labels once.
+type labelCache struct {
+	db     *gorm.DB
+	userID uint
+	byName map[string]models.Label
+}
+
+func newLabelCache(db *gorm.DB, userID uint) *labelCache {
+	return &labelCache{db: db, userID: userID, byName: map[string]models.Label{}}
+}
+
+// resolve returns the labels for the given names, skipping blanks and repeats.
+func (c *labelCache) resolve(names []string) ([]models.Label, error) {
+	var labels []models.Label
+	seen := map[uint]bool{}
+	for _, name := range names {
+		name = truncateRunes(strings.TrimSpace(name), 255)
+		if name == "" {
+			continue
+		}
+		key := strings.ToLower(name)
+		label, ok := c.byName[key]
+		if !ok {
+			found, err := findOrCreateLabel(c.db, c.userID, name)
+			if err != nil {
+				return nil, err
+			}
+			label = *found
+			c.byName[key] = label
+		}
+		if !seen[label.ID] {
+			seen[label.ID] = true
+			labels = append(labels, label)
+		}
+	}
+	return labels, nil
+}
+
+// labelIDs returns the IDs of the given labels.
+func labelIDs(labels []models.Label) []uint {
+	ids := make([]uint, len(labels))
+	for i, l := range labels {
+		ids[i] = l.ID
+	}
+	return ids
+}
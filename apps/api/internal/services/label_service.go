@@ -1,98 +1,136 @@
-package services
-
-import (
-	"fmt"
-	"math"
-
-	"gorm.io/gorm"
-
-	"desis-keep/apps/api/internal/models"
-)
-
-// LabelService handles business logic for labels.
-type LabelService struct {
-	DB *gorm.DB
-}
-
-// NewLabelService creates a new LabelService instance.
-func NewLabelService(db *gorm.DB) *LabelService {
-	return &LabelService{DB: db}
-}
-
-// List returns a paginated list of labels for a user.
-func (s *LabelService) List(userID uint, page, pageSize int, search, sortKey, sortDir string) ([]models.Label, int64, int, error) {
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 20
-	}
-	if sortDir != "asc" && sortDir != "desc" {
-		sortDir = "desc"
-	}
-	if sortKey == "" {
-		sortKey = "created_at"
-	}
-
-	query := s.DB.Model(&models.Label{}).Where("user_id = ?", userID)
-
-	if search != "" {
-		query = query.Where("name ILIKE ?", "%"+search+"%")
-	}
-
-	var total int64
-	query.Count(&total)
-
-	var labels []models.Label
-	offset := (page - 1) * pageSize
-	if err := query.Order(sortKey + " " + sortDir).Offset(offset).Limit(pageSize).Find(&labels).Error; err != nil {
-		return nil, 0, 0, fmt.Errorf("fetching labels: %w", err)
-	}
-
-	pages := int(math.Ceil(float64(total) / float64(pageSize)))
-	return labels, total, pages, nil
-}
-
-// GetByID returns a single label by ID (scoped to user).
-func (s *LabelService) GetByID(id, userID uint) (*models.Label, error) {
-	var label models.Label
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&label).Error; err != nil {
-		return nil, fmt.Errorf("label not found: %w", err)
-	}
-	return &label, nil
-}
-
-// Create creates a new label.
-func (s *LabelService) Create(label *models.Label) error {
-	if err := s.DB.Create(label).Error; err != nil {
-		return fmt.Errorf("creating label: %w", err)
-	}
-	return nil
-}
-
-// Update modifies an existing label.
-func (s *LabelService) Update(id, userID uint, data map[string]interface{}) (*models.Label, error) {
-	var label models.Label
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&label).Error; err != nil {
-		return nil, fmt.Errorf("label not found: %w", err)
-	}
-
-	if err := s.DB.Model(&label).Updates(data).Error; err != nil {
-		return nil, fmt.Errorf("updating label: %w", err)
-	}
-
-	s.DB.First(&label, id)
-	return &label, nil
-}
-
-// Delete soft-deletes a label.
-func (s *LabelService) Delete(id, userID uint) error {
-	var label models.Label
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&label).Error; err != nil {
-		return fmt.Errorf("label not found: %w", err)
-	}
-	if err := s.DB.Delete(&label).Error; err != nil {
-		return fmt.Errorf("deleting label: %w", err)
-	}
-	return nil
-}
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/models"
+)
+
+// LabelService handles business logic for labels.
+type LabelService struct {
+	DB     *gorm.DB
+	Search *SearchService
+}
+
+// NewLabelService creates a new LabelService instance.
+func NewLabelService(db *gorm.DB) *LabelService {
+	return &LabelService{DB: db, Search: NewSearchService(db)}
+}
+
+// List returns a paginated list of labels for a user.
+func (s *LabelService) List(userID uint, page, pageSize int, search, sortKey, sortDir string) ([]models.Label, int64, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	if sortDir != "asc" && sortDir != "desc" {
+		sortDir = "desc"
+	}
+	if sortKey == "" {
+		sortKey = "created_at"
+	}
+
+	query := s.DB.Model(&models.Label{}).Where("user_id = ?", userID)
+
+	if search != "" {
+		query = query.Where("name ILIKE ?", "%"+search+"%")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var labels []models.Label
+	offset := (page - 1) * pageSize
+	if err := query.Order(sortKey + " " + sortDir).Offset(offset).Limit(pageSize).Find(&labels).Error; err != nil {
+		return nil, 0, 0, fmt.Errorf("fetching labels: %w", err)
+	}
+
+	pages := int(math.Ceil(float64(total) / float64(pageSize)))
+	return labels, total, pages, nil
+}
+
+// GetByID returns a single label by ID (scoped to user).
+func (s *LabelService) GetByID(id, userID uint) (*models.Label, error) {
+	var label models.Label
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&label).Error; err != nil {
+		return nil, fmt.Errorf("label not found: %w", err)
+	}
+	return &label, nil
+}
+
+// Create creates a new label.
+func (s *LabelService) Create(label *models.Label) error {
+	if err := s.DB.Create(label).Error; err != nil {
+		return fmt.Errorf("creating label: %w", err)
+	}
+	return nil
+}
+
+// Update modifies an existing label. A label that is no longer at version is
+// returned as it is now, with ErrVersionConflict.
+func (s *LabelService) Update(id, userID, version uint, data map[string]interface{}) (*models.Label, error) {
+	var label models.Label
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&label).Error; err != nil {
+		return nil, fmt.Errorf("label not found: %w", err)
+	}
+
+	err := updateVersioned(s.DB, &label, version, data)
+	if err != nil && !errors.Is(err, ErrVersionConflict) {
+		return nil, fmt.Errorf("updating label: %w", err)
+	}
+
+	s.DB.First(&label, id)
+	if err != nil {
+		return &label, err
+	}
+	if err := s.Search.ReindexLabel(label.ID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return &label, nil
+}
+
+// Delete soft-deletes a label.
+func (s *LabelService) Delete(id, userID uint) error {
+	var label models.Label
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&label).Error; err != nil {
+		return fmt.Errorf("label not found: %w", err)
+	}
+	if err := s.DB.Delete(&label).Error; err != nil {
+		return fmt.Errorf("deleting label: %w", err)
+	}
+	forgetShares(s.DB, models.ShareResourceLabel, label.ID)
+	if err := s.Search.ReindexLabel(label.ID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return nil
+}
+
+// findOrCreateLabel returns the user's label with the given name, matched by
+// slug, creating it or restoring a deleted label with the same slug as needed.
+func findOrCreateLabel(tx *gorm.DB, userID uint, name string) (*models.Label, error) {
+	label := models.Label{Name: name, UserID: userID}
+	label.BeforeCreate(tx) // fills in the slug used for the lookup below
+	err := tx.Unscoped().Where("user_id = ? AND slug = ?", label.UserID, label.Slug).First(&label).Error
+	switch {
+	case err == nil:
+		if label.DeletedAt.Valid {
+			if err := tx.Unscoped().Model(&label).Update("deleted_at", nil).Error; err != nil {
+				return nil, fmt.Errorf("restoring label: %w", err)
+			}
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if err := tx.Create(&label).Error; err != nil {
+			return nil, fmt.Errorf("creating label: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("loading label: %w", err)
+	}
+	return &label, nil
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/storage"
+)
+
+// LinkDedupeService merges links that were saved more than once under equivalent URLs.
+type LinkDedupeService struct {
+	DB        *gorm.DB
+	Search    *SearchService
+	Snapshots *LinkSnapshotService
+}
+
+// NewLinkDedupeService creates a new LinkDedupeService instance.
+// store may be nil, in which case archived files of merged snapshots are left in place.
+func NewLinkDedupeService(db *gorm.DB, store *storage.Storage) *LinkDedupeService {
+	return &LinkDedupeService{
+		DB:        db,
+		Search:    NewSearchService(db),
+		Snapshots: NewLinkSnapshotService(db, store),
+	}
+}
+
+// DedupeResult counts the work done by Dedupe.
+type DedupeResult struct {
+	Backfilled int `json:"backfilled"` // links given a canonical URL
+	Groups     int `json:"groups"`     // sets of duplicates found
+	Removed    int `json:"removed"`    // links merged into another and deleted
+}
+
+// Dedupe fills in missing canonical URLs, then merges each set of duplicate
+// links (outside the trash) into its oldest member. userID 0 covers every user.
+func (s *LinkDedupeService) Dedupe(ctx context.Context, userID uint) (*DedupeResult, error) {
+	result := &DedupeResult{}
+
+	backfilled, err := s.backfill(userID)
+	result.Backfilled = backfilled
+	if err != nil {
+		return result, err
+	}
+
+	var groups []struct {
+		UserID       uint
+		CanonicalURL string
+	}
+	query := s.DB.Model(&models.Link{}).
+		Select("user_id, canonical_url").
+		Where("is_trashed = ? AND canonical_url <> ''", false).
+		Group("user_id, canonical_url").
+		Having("COUNT(*) > 1")
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.Scan(&groups).Error; err != nil {
+		return result, fmt.Errorf("finding duplicate links: %w", err)
+	}
+
+	for _, g := range groups {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		var links []models.Link
+		err := s.DB.Where("user_id = ? AND canonical_url = ? AND is_trashed = ?", g.UserID, g.CanonicalURL, false).
+			Preload("Labels").
+			Order("created_at ASC, id ASC").
+			Find(&links).Error
+		if err != nil {
+			return result, fmt.Errorf("loading duplicate links: %w", err)
+		}
+		if len(links) < 2 {
+			continue
+		}
+
+		removed, err := s.merge(ctx, &links[0], links[1:])
+		if err != nil {
+			return result, err
+		}
+		result.Groups++
+		result.Removed += removed
+	}
+	return result, nil
+}
+
+// backfill computes canonical URLs for links saved before they were tracked.
+func (s *LinkDedupeService) backfill(userID uint) (int, error) {
+	query := s.DB.Model(&models.Link{}).Select("id, url").Where("canonical_url = '' OR canonical_url IS NULL")
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	count := 0
+	var links []models.Link
+	err := query.FindInBatches(&links, 500, func(tx *gorm.DB, batch int) error {
+		for _, link := range links {
+			err := s.DB.Model(&models.Link{}).Where("id = ?", link.ID).
+				UpdateColumn("canonical_url", CanonicalURL(link.URL)).Error
+			if err != nil {
+				return fmt.Errorf("backfilling canonical URL of link %d: %w", link.ID, err)
+			}
+			count++
+		}
+		return nil
+	}).Error
+	return count, err
+}
+
+// merge folds duplicates into keeper and deletes them. The keeper gains their
+// labels, stays pinned if any copy was pinned, stays archived only if every
+// copy was, fills blank fields from them and inherits a snapshot if it has none.
+func (s *LinkDedupeService) merge(ctx context.Context, keeper *models.Link, duplicates []models.Link) (int, error) {
+	var orphanSnapshots []models.Link
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{}
+		fill := func(column, current, candidate string) {
+			if _, done := updates[column]; !done && current == "" && candidate != "" {
+				updates[column] = candidate
+			}
+		}
+		pinned, archived := keeper.IsPinned, keeper.IsArchived
+		var labels []models.Label
+		for _, dup := range duplicates {
+			pinned = pinned || dup.IsPinned
+			archived = archived && dup.IsArchived
+			fill("title", keeper.Title, dup.Title)
+			fill("description", keeper.Description, dup.Description)
+			fill("summary", keeper.Summary, dup.Summary)
+			fill("thumbnail_url", keeper.ThumbnailURL, dup.ThumbnailURL)
+			fill("favicon_url", keeper.FaviconURL, dup.FaviconURL)
+			labels = append(labels, dup.Labels...)
+		}
+		updates["is_pinned"] = pinned
+		updates["is_archived"] = archived
+		if err := tx.Model(keeper).Updates(updates).Error; err != nil {
+			return fmt.Errorf("updating link %d: %w", keeper.ID, err)
+		}
+		if len(labels) > 0 {
+			if err := tx.Model(keeper).Association("Labels").Append(labels); err != nil {
+				return fmt.Errorf("merging labels into link %d: %w", keeper.ID, err)
+			}
+		}
+
+		var keeperSnapshots int64
+		tx.Model(&models.LinkSnapshot{}).Where("link_id = ?", keeper.ID).Count(&keeperSnapshots)
+		for i := range duplicates {
+			dup := &duplicates[i]
+			var snapshot models.LinkSnapshot
+			err := tx.Where("link_id = ?", dup.ID).First(&snapshot).Error
+			switch {
+			case err == nil && keeperSnapshots == 0:
+				if err := tx.Model(&snapshot).Update("link_id", keeper.ID).Error; err != nil {
+					return fmt.Errorf("moving snapshot of link %d: %w", dup.ID, err)
+				}
+				keeperSnapshots++
+			case err == nil:
+				orphanSnapshots = append(orphanSnapshots, *dup)
+			case !errors.Is(err, gorm.ErrRecordNotFound):
+				return fmt.Errorf("loading snapshot of link %d: %w", dup.ID, err)
+			}
+
+			if err := tx.Model(dup).Association("Labels").Clear(); err != nil {
+				return fmt.Errorf("clearing labels of link %d: %w", dup.ID, err)
+			}
+			if err := tx.Where("resource_type = ? AND resource_id = ?", models.ResourceTypeLink, dup.ID).
+				Delete(&models.LabelSuggestion{}).Error; err != nil {
+				return fmt.Errorf("deleting suggestions of link %d: %w", dup.ID, err)
+			}
+			if err := tx.Unscoped().Delete(dup).Error; err != nil {
+				return fmt.Errorf("deleting link %d: %w", dup.ID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, dup := range orphanSnapshots {
+		if err := s.Snapshots.Delete(ctx, dup.ID, dup.UserID); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	for _, dup := range duplicates {
+		s.Search.sync(models.ResourceTypeLink, dup.ID)
+	}
+	s.Search.sync(models.ResourceTypeLink, keeper.ID)
+	return len(duplicates), nil
+}
@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/webfetch"
+)
+
+const (
+	// linkCheckInterval is how long a healthy link waits before its next check.
+	linkCheckInterval = 7 * 24 * time.Hour
+	// linkCheckRetryBase is the first recheck delay after a failed check; it doubles per failure.
+	linkCheckRetryBase = time.Hour
+	// linkCheckAttempts is how many times a transient failure is retried within one check.
+	linkCheckAttempts = 3
+	// linkBrokenAfter is how many consecutive transient failures mark a link broken.
+	linkBrokenAfter = 3
+	// linkCheckConcurrency caps parallel requests during a batch.
+	linkCheckConcurrency = 8
+)
+
+// LinkHealthService checks saved links for rot and records the outcome on each link.
+type LinkHealthService struct {
+	DB      *gorm.DB
+	Checker *webfetch.Client
+	Search  *SearchService
+	// UpdateRedirects replaces a link's URL with its destination when every
+	// redirect on the way was permanent (301/308).
+	UpdateRedirects bool
+	// retryDelay is the first in-check retry delay; it doubles per attempt.
+	retryDelay time.Duration
+}
+
+// NewLinkHealthService creates a new LinkHealthService instance.
+func NewLinkHealthService(db *gorm.DB, updateRedirects bool) *LinkHealthService {
+	return &LinkHealthService{
+		DB:              db,
+		Checker:         webfetch.New(webfetch.Options{MaxBytes: 64 << 10}),
+		Search:          NewSearchService(db),
+		UpdateRedirects: updateRedirects,
+		retryDelay:      2 * time.Second,
+	}
+}
+
+// LinkHealthReport summarizes a user's link health.
+type LinkHealthReport struct {
+	Counts     map[string]int64 `json:"counts"` // by status; "unchecked" for links not checked yet
+	Broken     []models.Link    `json:"broken"`
+	Redirected []models.Link    `json:"redirected"`
+}
+
+// linkCheck is the outcome of checking one link.
+type linkCheck struct {
+	statusCode int
+	finalURL   string
+	permanent  bool // reached only through permanent redirects
+	err        error
+	transient  bool // failure may clear up on its own (timeouts, 5xx, 429)
+}
+
+// CheckDue checks up to limit links whose next check is due, oldest first,
+// and returns how many were checked. Trashed links are skipped.
+func (s *LinkHealthService) CheckDue(ctx context.Context, limit int) (int, error) {
+	var links []models.Link
+	err := s.DB.Where("is_trashed = ? AND (next_check_at IS NULL OR next_check_at <= ?)", false, time.Now()).
+		Order("next_check_at ASC NULLS FIRST").
+		Limit(limit).
+		Find(&links).Error
+	if err != nil {
+		return 0, fmt.Errorf("loading links to check: %w", err)
+	}
+
+	sem := make(chan struct{}, linkCheckConcurrency)
+	var wg sync.WaitGroup
+	for i := range links {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(link *models.Link) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.record(link, s.check(ctx, link.URL))
+		}(&links[i])
+	}
+	wg.Wait()
+	return len(links), ctx.Err()
+}
+
+// check requests a URL with HEAD, falling back to GET for servers that reject
+// HEAD, and retries transient failures with exponential backoff.
+func (s *LinkHealthService) check(ctx context.Context, rawURL string) linkCheck {
+	var result linkCheck
+	delay := s.retryDelay
+	for attempt := 0; attempt < linkCheckAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return linkCheck{err: ctx.Err(), transient: true}
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+
+		resp, err := s.Checker.Head(ctx, rawURL)
+		if err == nil && headUnsupported(resp.StatusCode) {
+			resp, err = s.Checker.Get(ctx, rawURL, "")
+		}
+		result = classify(resp, err)
+		if !result.transient || result.err == nil {
+			return result
+		}
+	}
+	return result
+}
+
+// classify turns a response or error into a check outcome.
+func classify(resp *webfetch.Response, err error) linkCheck {
+	if err != nil {
+		var dnsErr *net.DNSError
+		switch {
+		case errors.Is(err, webfetch.ErrBlockedAddress), errors.Is(err, webfetch.ErrInvalidURL):
+			return linkCheck{err: err}
+		case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+			return linkCheck{err: err}
+		default:
+			return linkCheck{err: err, transient: true}
+		}
+	}
+
+	result := linkCheck{statusCode: resp.StatusCode, permanent: resp.PermanentlyMoved()}
+	if len(resp.Redirects) > 0 {
+		result.finalURL = resp.URL.String()
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		result.err = fmt.Errorf("server answered %d", resp.StatusCode)
+		result.transient = true
+	case resp.StatusCode >= 400:
+		result.err = fmt.Errorf("server answered %d", resp.StatusCode)
+	}
+	return result
+}
+
+// headUnsupported reports statuses that servers commonly return for HEAD but not GET.
+func headUnsupported(status int) bool {
+	switch status {
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented, http.StatusForbidden, http.StatusNotFound:
+		return true
+	}
+	return false
+}
+
+// record stores a check outcome on the link and schedules its next check.
+// Permanent failures mark a link broken at once; transient ones only after
+// linkBrokenAfter consecutive failed checks, with the recheck backing off.
+func (s *LinkHealthService) record(link *models.Link, result linkCheck) {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status_code":     result.statusCode,
+		"final_url":       result.finalURL,
+		"last_checked_at": now,
+		"check_error":     "",
+	}
+
+	if result.err == nil {
+		updates["check_failures"] = 0
+		updates["next_check_at"] = now.Add(linkCheckInterval)
+		updates["health_status"] = models.LinkHealthOK
+		if result.finalURL != "" && result.finalURL != link.URL {
+			updates["health_status"] = models.LinkHealthRedirected
+			if s.UpdateRedirects && result.permanent && len(result.finalURL) <= 2048 {
+				updates["url"] = result.finalURL
+				updates["canonical_url"] = CanonicalURL(result.finalURL)
+				updates["final_url"] = ""
+				updates["health_status"] = models.LinkHealthOK
+			}
+		}
+	} else {
+		failures := link.CheckFailures + 1
+		backoff := linkCheckRetryBase << min(failures-1, 7)
+		updates["check_failures"] = failures
+		updates["next_check_at"] = now.Add(min(backoff, linkCheckInterval))
+		updates["check_error"] = truncateRunes(result.err.Error(), 500)
+		if !result.transient || failures >= linkBrokenAfter {
+			updates["health_status"] = models.LinkHealthBroken
+		}
+	}
+
+	// UpdateColumns keeps updated_at unchanged: a health check is not a user edit
+	if err := s.DB.Model(link).UpdateColumns(updates).Error; err != nil {
+		log.Printf("Warning: recording health of link %d: %v", link.ID, err)
+		return
+	}
+	if _, moved := updates["url"]; moved {
+		s.Search.sync(models.ResourceTypeLink, link.ID)
+	}
+}
+
+// Report returns counts per health status and the user's broken and redirected links.
+func (s *LinkHealthService) Report(userID uint) (*LinkHealthReport, error) {
+	var rows []struct {
+		HealthStatus string
+		Count        int64
+	}
+	err := s.DB.Model(&models.Link{}).
+		Select("health_status, COUNT(*) AS count").
+		Where("user_id = ? AND is_trashed = ?", userID, false).
+		Group("health_status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("counting link health: %w", err)
+	}
+
+	report := &LinkHealthReport{Counts: map[string]int64{
+		models.LinkHealthOK:         0,
+		models.LinkHealthRedirected: 0,
+		models.LinkHealthBroken:     0,
+		"unchecked":                 0,
+	}}
+	for _, r := range rows {
+		status := r.HealthStatus
+		if status == "" {
+			status = "unchecked"
+		}
+		report.Counts[status] += r.Count
+	}
+
+	for status, dest := range map[string]*[]models.Link{
+		models.LinkHealthBroken:     &report.Broken,
+		models.LinkHealthRedirected: &report.Redirected,
+	} {
+		err := s.DB.Where("user_id = ? AND is_trashed = ? AND health_status = ?", userID, false, status).
+			Order("last_checked_at DESC").
+			Find(dest).Error
+		if err != nil {
+			return nil, fmt.Errorf("loading %s links: %w", status, err)
+		}
+	}
+	return report, nil
+}
+
+// ApplyRedirects replaces the URL of each of the user's redirected links with
+// its destination and returns how many links changed.
+func (s *LinkHealthService) ApplyRedirects(userID uint) (int, error) {
+	var links []models.Link
+	err := s.DB.Where("user_id = ? AND health_status = ? AND final_url <> ''", userID, models.LinkHealthRedirected).
+		Find(&links).Error
+	if err != nil {
+		return 0, fmt.Errorf("loading redirected links: %w", err)
+	}
+
+	for _, link := range links {
+		err := s.DB.Model(&link).Updates(map[string]interface{}{
+			"url":           link.FinalURL,
+			"canonical_url": CanonicalURL(link.FinalURL),
+			"final_url":     "",
+			"health_status": models.LinkHealthOK,
+		}).Error
+		if err != nil {
+			return 0, fmt.Errorf("updating link %d: %w", link.ID, err)
+		}
+		s.Search.sync(models.ResourceTypeLink, link.ID)
+	}
+	return len(links), nil
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/storage"
+	"desis-keep/apps/api/internal/webfetch"
+)
+
+// maxThumbnailBytes caps preview images copied into storage.
+const maxThumbnailBytes = 5 << 20 // 5 MB
+
+// LinkMetadataService fills in a link's title, description, thumbnail and
+// favicon from the page it points to.
+type LinkMetadataService struct {
+	DB      *gorm.DB
+	Fetcher *webfetch.Client
+	// Storage, when set, receives a copy of the preview image so thumbnails
+	// survive the original disappearing. Nil keeps the remote URL.
+	Storage *storage.Storage
+	// Images downloads preview images; it allows larger bodies than Fetcher.
+	Images *webfetch.Client
+	Search *SearchService
+}
+
+// NewLinkMetadataService creates a new LinkMetadataService instance.
+// store may be nil.
+func NewLinkMetadataService(db *gorm.DB, store *storage.Storage) *LinkMetadataService {
+	return &LinkMetadataService{
+		DB:      db,
+		Fetcher: webfetch.New(webfetch.Options{}),
+		Storage: store,
+		Images:  webfetch.New(webfetch.Options{MaxBytes: maxThumbnailBytes}),
+		Search:  NewSearchService(db),
+	}
+}
+
+// Fetch downloads the link's page and fills in fields that are still empty;
+// anything the user entered is kept. It returns the updated link, or nil when
+// the link no longer exists.
+func (s *LinkMetadataService) Fetch(ctx context.Context, linkID uint) (*models.Link, error) {
+	var link models.Link
+	err := s.DB.First(&link, linkID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading link %d: %w", linkID, err)
+	}
+
+	resp, err := s.Fetcher.Get(ctx, link.URL, "text/html,application/xhtml+xml;q=0.9,*/*;q=0.5")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("fetching link %d: %s answered %d", linkID, resp.URL.Redacted(), resp.StatusCode)
+	}
+	if resp.ContentType != "" && resp.ContentType != "text/html" && resp.ContentType != "application/xhtml+xml" {
+		// Not a page (e.g. a PDF); there is no markup to read
+		return &link, nil
+	}
+
+	meta := webfetch.ParseMetadata(resp.Body, resp.ContentType, resp.URL)
+	updates := map[string]interface{}{}
+	if link.Title == "" && meta.Title != "" {
+		updates["title"] = truncateRunes(meta.Title, 500)
+	}
+	if link.Description == "" && meta.Description != "" {
+		updates["description"] = meta.Description
+	}
+	if link.FaviconURL == "" && meta.FaviconURL != "" && len(meta.FaviconURL) <= 2048 {
+		updates["favicon_url"] = meta.FaviconURL
+	}
+	if link.ThumbnailURL == "" && meta.ImageURL != "" {
+		if thumb := s.thumbnail(ctx, &link, meta.ImageURL); thumb != "" && len(thumb) <= 2048 {
+			updates["thumbnail_url"] = thumb
+		}
+	}
+	if len(updates) == 0 {
+		return &link, nil
+	}
+
+	// UpdateColumns keeps updated_at unchanged: fetched metadata is not a user edit
+	if err := s.DB.Model(&link).UpdateColumns(updates).Error; err != nil {
+		return nil, fmt.Errorf("saving metadata for link %d: %w", linkID, err)
+	}
+	s.Search.sync(models.ResourceTypeLink, link.ID)
+	return &link, nil
+}
+
+// thumbnail returns the URL to store for a preview image: a copy in storage
+// when possible, otherwise the remote URL.
+func (s *LinkMetadataService) thumbnail(ctx context.Context, link *models.Link, imageURL string) string {
+	if s.Storage == nil {
+		return imageURL
+	}
+
+	resp, err := s.Images.GetAll(ctx, imageURL, "image/*")
+	if err != nil || resp.StatusCode >= 400 || !storage.IsImageMimeType(resp.ContentType) {
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		return imageURL
+	}
+
+	// Re-encoding caps the size and drops anything that is not pixel data
+	data, err := storage.ProcessImage(bytes.NewReader(resp.Body), resp.ContentType)
+	if err != nil {
+		log.Printf("Warning: processing thumbnail for link %d: %v", link.ID, err)
+		return imageURL
+	}
+
+	// ProcessImage writes PNGs as PNG and everything else as JPEG
+	contentType, ext := "image/jpeg", ".jpg"
+	if resp.ContentType == "image/png" {
+		contentType, ext = "image/png", ".png"
+	}
+	key := fmt.Sprintf("links/%d/%d/thumbnail%s", link.UserID, link.ID, ext)
+	if err := s.Storage.Upload(ctx, key, bytes.NewReader(data), contentType); err != nil {
+		log.Printf("Warning: %v", err)
+		return imageURL
+	}
+	return s.Storage.GetURL(key)
+}
+
+// truncateRunes cuts s to at most n runes.
+func truncateRunes(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
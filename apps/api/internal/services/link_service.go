@@ -1,133 +1,261 @@
-package services
-
-import (
-	"fmt"
-	"math"
-
-	"gorm.io/gorm"
-
-	"desis-keep/apps/api/internal/models"
-)
-
-// LinkService handles business logic for links.
-type LinkService struct {
-	DB *gorm.DB
-}
-
-// NewLinkService creates a new LinkService instance.
-func NewLinkService(db *gorm.DB) *LinkService {
-	return &LinkService{DB: db}
-}
-
-// List returns a paginated list of links for a user.
-func (s *LinkService) List(userID uint, page, pageSize int, search, sortKey, sortDir string, archived, trashed *bool) ([]models.Link, int64, int, error) {
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 20
-	}
-	if sortDir != "asc" && sortDir != "desc" {
-		sortDir = "desc"
-	}
-	if sortKey == "" {
-		sortKey = "created_at"
-	}
-
-	query := s.DB.Model(&models.Link{}).Where("user_id = ?", userID).Preload("Labels")
-
-	if archived == nil && trashed == nil {
-		query = query.Where("is_archived = ? AND is_trashed = ?", false, false)
-	} else {
-		if archived != nil {
-			query = query.Where("is_archived = ?", *archived)
-		}
-		if trashed != nil {
-			query = query.Where("is_trashed = ?", *trashed)
-		}
-	}
-
-	if search != "" {
-		query = query.Where("title ILIKE ? OR url ILIKE ? OR description ILIKE ?", "%"+search+"%", "%"+search+"%", "%"+search+"%")
-	}
-
-	var total int64
-	query.Count(&total)
-
-	var links []models.Link
-	offset := (page - 1) * pageSize
-	if err := query.Order(sortKey + " " + sortDir).Offset(offset).Limit(pageSize).Find(&links).Error; err != nil {
-		return nil, 0, 0, fmt.Errorf("fetching links: %w", err)
-	}
-
-	pages := int(math.Ceil(float64(total) / float64(pageSize)))
-	return links, total, pages, nil
-}
-
-// GetByID returns a single link by ID (scoped to user).
-func (s *LinkService) GetByID(id, userID uint) (*models.Link, error) {
-	var link models.Link
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).Preload("Labels").First(&link).Error; err != nil {
-		return nil, fmt.Errorf("link not found: %w", err)
-	}
-	return &link, nil
-}
-
-// Create creates a new link.
-func (s *LinkService) Create(link *models.Link) error {
-	if err := s.DB.Create(link).Error; err != nil {
-		return fmt.Errorf("creating link: %w", err)
-	}
-	return nil
-}
-
-// Update modifies an existing link.
-func (s *LinkService) Update(id, userID uint, data map[string]interface{}) (*models.Link, error) {
-	var link models.Link
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&link).Error; err != nil {
-		return nil, fmt.Errorf("link not found: %w", err)
-	}
-
-	if err := s.DB.Model(&link).Updates(data).Error; err != nil {
-		return nil, fmt.Errorf("updating link: %w", err)
-	}
-
-	s.DB.Where("id = ?", id).Preload("Labels").First(&link)
-	return &link, nil
-}
-
-// Delete soft-deletes a link (sets is_trashed = true).
-func (s *LinkService) Delete(id, userID uint) error {
-	var link models.Link
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&link).Error; err != nil {
-		return fmt.Errorf("link not found: %w", err)
-	}
-	if err := s.DB.Model(&link).Update("is_trashed", true).Error; err != nil {
-		return fmt.Errorf("trashing link: %w", err)
-	}
-	return nil
-}
-
-// Restore restores a trashed link.
-func (s *LinkService) Restore(id, userID uint) error {
-	var link models.Link
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&link).Error; err != nil {
-		return fmt.Errorf("link not found: %w", err)
-	}
-	if err := s.DB.Model(&link).Update("is_trashed", false).Error; err != nil {
-		return fmt.Errorf("restoring link: %w", err)
-	}
-	return nil
-}
-
-// PermanentDelete hard-deletes a link.
-func (s *LinkService) PermanentDelete(id, userID uint) error {
-	var link models.Link
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&link).Error; err != nil {
-		return fmt.Errorf("link not found: %w", err)
-	}
-	if err := s.DB.Unscoped().Delete(&link).Error; err != nil {
-		return fmt.Errorf("permanently deleting link: %w", err)
-	}
-	return nil
-}
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/querylang"
+	"desis-keep/apps/api/internal/urlcanon"
+)
+
+// DuplicateLinkError is returned by Create and Update when the user already
+// has another link (outside the trash) with the same canonical URL.
+type DuplicateLinkError struct {
+	Existing *models.Link
+}
+
+func (e *DuplicateLinkError) Error() string {
+	return fmt.Sprintf("link already saved as %d", e.Existing.ID)
+}
+
+// LinkService handles business logic for links.
+type LinkService struct {
+	DB     *gorm.DB
+	Search *SearchService
+}
+
+// NewLinkService creates a new LinkService instance.
+func NewLinkService(db *gorm.DB) *LinkService {
+	return &LinkService{DB: db, Search: NewSearchService(db)}
+}
+
+// List returns a paginated list of links for a user, filtered by a parsed search query.
+// health filters by link health status ("unchecked" matches links not checked yet); empty means any.
+func (s *LinkService) List(userID uint, page, pageSize int, q *querylang.Query, sortKey, sortDir string, archived, trashed *bool, health string) ([]models.Link, int64, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	if sortDir != "asc" && sortDir != "desc" {
+		sortDir = "desc"
+	}
+	if sortKey == "" {
+		sortKey = "created_at"
+	}
+
+	query := s.DB.Model(&models.Link{}).Scopes(accessible(models.ResourceTypeLink, userID, models.SharePermissionView)).Preload("Labels")
+
+	// Default: exclude archived and trashed unless the query asks for them
+	if archived == nil && trashed == nil {
+		if !q.HasFlag(querylang.FlagArchived) {
+			query = query.Where("is_archived = ?", false)
+		}
+		if !q.HasFlag(querylang.FlagTrashed) {
+			query = query.Where("is_trashed = ?", false)
+		}
+	} else {
+		if archived != nil {
+			query = query.Where("is_archived = ?", *archived)
+		}
+		if trashed != nil {
+			query = query.Where("is_trashed = ?", *trashed)
+		}
+	}
+
+	switch health {
+	case "":
+	case "unchecked":
+		query = query.Where("health_status = ?", "")
+	default:
+		query = query.Where("health_status = ?", health)
+	}
+
+	query = q.Scope(querylang.LinkTarget)(query)
+
+	var total int64
+	query.Count(&total)
+
+	var links []models.Link
+	offset := (page - 1) * pageSize
+	if err := query.Order(sortKey + " " + sortDir).Offset(offset).Limit(pageSize).Find(&links).Error; err != nil {
+		return nil, 0, 0, fmt.Errorf("fetching links: %w", err)
+	}
+
+	pages := int(math.Ceil(float64(total) / float64(pageSize)))
+	return links, total, pages, nil
+}
+
+// GetByID returns a single link by ID (scoped to user).
+func (s *LinkService) GetByID(id, userID uint) (*models.Link, error) {
+	var link models.Link
+	if err := s.DB.Where("id = ?", id).Scopes(accessible(models.ResourceTypeLink, userID, models.SharePermissionView)).Preload("Labels").First(&link).Error; err != nil {
+		return nil, fmt.Errorf("link not found: %w", err)
+	}
+	return &link, nil
+}
+
+// Create creates a new link. It returns a *DuplicateLinkError when the user
+// already saved the same page under an equivalent URL.
+func (s *LinkService) Create(link *models.Link) error {
+	link.CanonicalURL = CanonicalURL(link.URL)
+	existing, err := s.FindDuplicate(link.UserID, link.CanonicalURL)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return &DuplicateLinkError{Existing: existing}
+	}
+
+	if err := s.DB.Create(link).Error; err != nil {
+		return fmt.Errorf("creating link: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeLink, link.ID)
+	return nil
+}
+
+// Update modifies an existing link. A link that is no longer at version is
+// returned as it is now, with ErrVersionConflict. Changing the URL to one the
+// owner already saved under another link returns a *DuplicateLinkError.
+func (s *LinkService) Update(id, userID, version uint, data map[string]interface{}) (*models.Link, error) {
+	var link models.Link
+	if err := s.DB.Where("id = ?", id).Scopes(accessible(models.ResourceTypeLink, userID, models.SharePermissionEdit)).First(&link).Error; err != nil {
+		return nil, fmt.Errorf("link not found: %w", err)
+	}
+
+	if url, ok := data["url"].(string); ok {
+		canonical := CanonicalURL(url)
+		if canonical != link.CanonicalURL {
+			existing, err := s.findDuplicate(link.UserID, canonical, link.ID)
+			if err != nil {
+				return nil, err
+			}
+			if existing != nil {
+				return nil, &DuplicateLinkError{Existing: existing}
+			}
+		}
+		data["canonical_url"] = canonical
+	}
+	err := updateVersioned(s.DB, &link, version, data)
+	if err != nil && !errors.Is(err, ErrVersionConflict) {
+		return nil, fmt.Errorf("updating link: %w", err)
+	}
+
+	s.DB.Where("id = ?", id).Preload("Labels").First(&link)
+	if err != nil {
+		return &link, err
+	}
+	s.Search.sync(models.ResourceTypeLink, link.ID)
+	return &link, nil
+}
+
+// Delete soft-deletes a link (sets is_trashed = true).
+func (s *LinkService) Delete(id, userID uint) error {
+	var link models.Link
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&link).Error; err != nil {
+		return fmt.Errorf("link not found: %w", err)
+	}
+	if err := s.DB.Model(&link).Update("is_trashed", true).Error; err != nil {
+		return fmt.Errorf("trashing link: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeLink, link.ID)
+	return nil
+}
+
+// Restore restores a trashed link.
+func (s *LinkService) Restore(id, userID uint) error {
+	var link models.Link
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&link).Error; err != nil {
+		return fmt.Errorf("link not found: %w", err)
+	}
+	if err := s.DB.Model(&link).Update("is_trashed", false).Error; err != nil {
+		return fmt.Errorf("restoring link: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeLink, link.ID)
+	return nil
+}
+
+// PermanentDelete hard-deletes a link.
+func (s *LinkService) PermanentDelete(id, userID uint) error {
+	var link models.Link
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&link).Error; err != nil {
+		return fmt.Errorf("link not found: %w", err)
+	}
+	if err := s.DB.Unscoped().Delete(&link).Error; err != nil {
+		return fmt.Errorf("permanently deleting link: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeLink, link.ID)
+	forgetReferences(s.DB, models.ResourceTypeLink, link.ID)
+	forgetShares(s.DB, models.ResourceTypeLink, link.ID)
+	forgetPublicLinks(s.DB, models.ResourceTypeLink, link.ID)
+	return nil
+}
+
+// SetLabels replaces the labels on a link with the given labels owned by the user.
+func (s *LinkService) SetLabels(link *models.Link, userID uint, labelIDs []uint) error {
+	if link.UserID != userID {
+		return ErrNotOwner
+	}
+	var labels []models.Label
+	s.DB.Where("id IN ? AND user_id = ?", labelIDs, userID).Find(&labels)
+	if err := s.DB.Model(link).Association("Labels").Replace(labels); err != nil {
+		return fmt.Errorf("setting link labels: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeLink, link.ID)
+	return nil
+}
+
+// AddLabels attaches the given labels owned by the user to a link, keeping the ones it has.
+func (s *LinkService) AddLabels(link *models.Link, userID uint, labelIDs []uint) error {
+	if link.UserID != userID {
+		return ErrNotOwner
+	}
+	var labels []models.Label
+	s.DB.Where("id IN ? AND user_id = ?", labelIDs, userID).Find(&labels)
+	if len(labels) == 0 {
+		return nil
+	}
+	if err := s.DB.Model(link).Association("Labels").Append(labels); err != nil {
+		return fmt.Errorf("adding link labels: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeLink, link.ID)
+	return nil
+}
+
+// FindDuplicate returns the user's link outside the trash with the given
+// canonical URL, or nil when there is none.
+func (s *LinkService) FindDuplicate(userID uint, canonicalURL string) (*models.Link, error) {
+	return s.findDuplicate(userID, canonicalURL, 0)
+}
+
+// findDuplicate is FindDuplicate ignoring the link with id excludeID.
+func (s *LinkService) findDuplicate(userID uint, canonicalURL string, excludeID uint) (*models.Link, error) {
+	var link models.Link
+	err := s.DB.Where("user_id = ? AND canonical_url = ? AND is_trashed = ? AND id <> ?", userID, canonicalURL, false, excludeID).
+		Preload("Labels").
+		Order("created_at ASC").
+		First(&link).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("checking for duplicate link: %w", err)
+	}
+	return &link, nil
+}
+
+// CanonicalURL returns the duplicate-detection key for a link URL. URLs the
+// canonicalizer rejects are compared as they are.
+func CanonicalURL(rawURL string) string {
+	canonical, err := urlcanon.Canonicalize(rawURL)
+	if err != nil {
+		return strings.TrimSpace(rawURL)
+	}
+	return canonical
+}
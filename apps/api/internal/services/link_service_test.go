@@ -0,0 +1,51 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"desis-keep/apps/api/internal/models"
+)
+
+func TestUpdateLinkRejectsDuplicateURL(t *testing.T) {
+	db := testDB(t, searchDocumentsTable)
+	if err := db.AutoMigrate(&models.User{}, &models.Label{}, &models.Link{}, &models.Share{}); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	s := NewLinkService(db)
+
+	saved := models.Link{URL: "https://example.com/article", UserID: 1}
+	edited := models.Link{URL: "https://example.com/other", UserID: 1}
+	for _, link := range []*models.Link{&saved, &edited} {
+		if err := s.Create(link); err != nil {
+			t.Fatalf("Create(%s): %v", link.URL, err)
+		}
+	}
+
+	// An equivalent URL of another link is refused and nothing changes
+	_, err := s.Update(edited.ID, 1, edited.Version, map[string]interface{}{"url": "https://EXAMPLE.com/article?utm_source=feed"})
+	var duplicate *DuplicateLinkError
+	if !errors.As(err, &duplicate) || duplicate.Existing.ID != saved.ID {
+		t.Fatalf("Update error = %v, want a DuplicateLinkError for link %d", err, saved.ID)
+	}
+	var current models.Link
+	db.First(&current, edited.ID)
+	if current.URL != edited.URL || current.Version != edited.Version {
+		t.Errorf("refused update changed the link: %+v", current)
+	}
+
+	// The link's own URL, spelled differently, is not a duplicate
+	link, err := s.Update(saved.ID, 1, saved.Version, map[string]interface{}{"url": "https://example.com/article/"})
+	if err != nil {
+		t.Fatalf("Update to an equivalent URL of the same link: %v", err)
+	}
+	if link.URL != "https://example.com/article/" {
+		t.Errorf("URL = %q", link.URL)
+	}
+
+	// Trashed links do not count
+	db.Model(&saved).Update("is_trashed", true)
+	if _, err := s.Update(edited.ID, 1, edited.Version, map[string]interface{}{"url": "https://example.com/article"}); err != nil {
+		t.Errorf("Update to the URL of a trashed link: %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/readability"
+	"desis-keep/apps/api/internal/storage"
+	"desis-keep/apps/api/internal/webfetch"
+)
+
+const (
+	// maxSnapshotPageBytes caps the page downloaded for a snapshot.
+	maxSnapshotPageBytes = 5 << 20 // 5 MB
+	// maxSnapshotImages caps the images archived per snapshot; the rest keep their remote URL.
+	maxSnapshotImages = 30
+	// maxSnapshotTextChars caps the plain text kept for search.
+	maxSnapshotTextChars = 200000
+)
+
+// snapshotImageTypes maps archivable image MIME types to file extensions.
+// SVG is excluded because it can carry scripts.
+var snapshotImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// ErrStorageUnavailable is returned when an operation needs object storage and none is configured.
+var ErrStorageUnavailable = errors.New("storage not configured")
+
+// LinkSnapshotService archives readable copies of linked pages.
+type LinkSnapshotService struct {
+	DB      *gorm.DB
+	Storage *storage.Storage
+	Fetcher *webfetch.Client
+	Images  *webfetch.Client
+	Search  *SearchService
+}
+
+// NewLinkSnapshotService creates a new LinkSnapshotService instance.
+// store may be nil, in which case snapshots cannot be captured or read.
+func NewLinkSnapshotService(db *gorm.DB, store *storage.Storage) *LinkSnapshotService {
+	return &LinkSnapshotService{
+		DB:      db,
+		Storage: store,
+		Fetcher: webfetch.New(webfetch.Options{MaxBytes: maxSnapshotPageBytes, Timeout: 20 * time.Second}),
+		Images:  webfetch.New(webfetch.Options{MaxBytes: maxThumbnailBytes}),
+		Search:  NewSearchService(db),
+	}
+}
+
+// Request marks a link's snapshot as pending, creating the record if needed.
+// The caller enqueues the capture job.
+func (s *LinkSnapshotService) Request(linkID, userID uint) (*models.LinkSnapshot, error) {
+	if s.Storage == nil {
+		return nil, ErrStorageUnavailable
+	}
+
+	var link models.Link
+	if err := s.DB.Where("id = ? AND user_id = ?", linkID, userID).First(&link).Error; err != nil {
+		return nil, fmt.Errorf("link not found: %w", err)
+	}
+
+	snapshot := models.LinkSnapshot{
+		LinkID:    link.ID,
+		UserID:    link.UserID,
+		Status:    models.SnapshotPending,
+		SourceURL: link.URL,
+	}
+	err := s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "link_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "source_url", "updated_at"}),
+	}).Create(&snapshot).Error
+	if err != nil {
+		return nil, fmt.Errorf("requesting snapshot: %w", err)
+	}
+	return s.Get(linkID, userID)
+}
+
+// Get returns a link's snapshot record.
+func (s *LinkSnapshotService) Get(linkID, userID uint) (*models.LinkSnapshot, error) {
+	var snapshot models.LinkSnapshot
+	if err := s.DB.Where("link_id = ? AND user_id = ?", linkID, userID).First(&snapshot).Error; err != nil {
+		return nil, fmt.Errorf("snapshot not found: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// Content reads a ready snapshot's sanitized HTML from storage.
+func (s *LinkSnapshotService) Content(ctx context.Context, snapshot *models.LinkSnapshot) (string, error) {
+	if s.Storage == nil {
+		return "", ErrStorageUnavailable
+	}
+	reader, err := s.Storage.Download(ctx, snapshot.ContentKey)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("reading snapshot %d: %w", snapshot.ID, err)
+	}
+	return string(data), nil
+}
+
+// Capture downloads the link's page, extracts the article, archives it and its
+// images in storage and marks the snapshot ready. Failures are recorded on the
+// snapshot before being returned. It returns nil without doing anything when
+// the link or its snapshot request no longer exist.
+func (s *LinkSnapshotService) Capture(ctx context.Context, linkID uint) error {
+	if s.Storage == nil {
+		return ErrStorageUnavailable
+	}
+
+	var link models.Link
+	err := s.DB.First(&link, linkID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("loading link %d: %w", linkID, err)
+	}
+
+	var snapshot models.LinkSnapshot
+	err = s.DB.Where("link_id = ?", linkID).First(&snapshot).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("loading snapshot for link %d: %w", linkID, err)
+	}
+
+	if err := s.capture(ctx, &link, &snapshot); err != nil {
+		s.DB.Model(&snapshot).Updates(map[string]interface{}{
+			"status": models.SnapshotFailed,
+			"error":  err.Error(),
+		})
+		return err
+	}
+	s.Search.sync(models.ResourceTypeLink, link.ID)
+	return nil
+}
+
+func (s *LinkSnapshotService) capture(ctx context.Context, link *models.Link, snapshot *models.LinkSnapshot) error {
+	resp, err := s.Fetcher.Get(ctx, link.URL, "text/html,application/xhtml+xml;q=0.9")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("fetching %s: server answered %d", resp.URL.Redacted(), resp.StatusCode)
+	}
+	if resp.ContentType != "" && resp.ContentType != "text/html" && resp.ContentType != "application/xhtml+xml" {
+		return fmt.Errorf("cannot snapshot %s content: %w", resp.ContentType, readability.ErrNoContent)
+	}
+
+	article, err := readability.Extract(resp.Body, resp.ContentType, resp.URL)
+	if err != nil {
+		return fmt.Errorf("extracting article: %w", err)
+	}
+
+	prefix := fmt.Sprintf("snapshots/%d/%d/", link.UserID, link.ID)
+	sizeBytes := int64(0)
+	sources := map[string]string{}
+	var assetKeys []string
+	for i, src := range article.ImageURLs() {
+		if i >= maxSnapshotImages {
+			break
+		}
+		key, size, ok := s.archiveImage(ctx, prefix, src)
+		if !ok {
+			continue
+		}
+		sources[src] = s.Storage.GetURL(key)
+		assetKeys = append(assetKeys, key)
+		sizeBytes += size
+	}
+	article.ReplaceImages(sources)
+
+	content := article.HTML()
+	contentKey := prefix + "index.html"
+	if err := s.Storage.Upload(ctx, contentKey, strings.NewReader(content), "text/html; charset=utf-8"); err != nil {
+		return err
+	}
+	sizeBytes += int64(len(content))
+
+	// Images referenced by an earlier capture but not this one are orphans now
+	for _, old := range (&models.LinkSnapshot{AssetKeys: snapshot.AssetKeys}).Keys() {
+		if !slices.Contains(assetKeys, old) {
+			if err := s.Storage.Delete(ctx, old); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+	}
+
+	text := article.Text
+	if runes := []rune(text); len(runes) > maxSnapshotTextChars {
+		text = string(runes[:maxSnapshotTextChars])
+	}
+	now := time.Now()
+	return s.DB.Model(snapshot).Updates(map[string]interface{}{
+		"status":       models.SnapshotReady,
+		"source_url":   resp.URL.String(),
+		"title":        truncateRunes(article.Title, 500),
+		"byline":       truncateRunes(article.Byline, 255),
+		"excerpt":      article.Excerpt,
+		"text_content": text,
+		"content_key":  contentKey,
+		"asset_keys":   strings.Join(assetKeys, "\n"),
+		"image_count":  len(assetKeys),
+		"size_bytes":   sizeBytes,
+		"error":        "",
+		"captured_at":  &now,
+	}).Error
+}
+
+// archiveImage copies one image into storage. Keys are derived from the source
+// URL so recapturing a page overwrites rather than duplicates its images.
+func (s *LinkSnapshotService) archiveImage(ctx context.Context, prefix, src string) (string, int64, bool) {
+	resp, err := s.Images.GetAll(ctx, src, "image/*")
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return "", 0, false
+	}
+	ext, ok := snapshotImageTypes[resp.ContentType]
+	if resp.StatusCode >= 400 || !ok {
+		return "", 0, false
+	}
+
+	sum := sha256.Sum256([]byte(src))
+	key := prefix + hex.EncodeToString(sum[:8]) + ext
+	if err := s.Storage.Upload(ctx, key, bytes.NewReader(resp.Body), resp.ContentType); err != nil {
+		log.Printf("Warning: %v", err)
+		return "", 0, false
+	}
+	return key, int64(len(resp.Body)), true
+}
+
+// Delete removes a link's snapshot and its stored files.
+func (s *LinkSnapshotService) Delete(ctx context.Context, linkID, userID uint) error {
+	snapshot, err := s.Get(linkID, userID)
+	if err != nil {
+		return err
+	}
+	if s.Storage != nil {
+		for _, key := range snapshot.Keys() {
+			if err := s.Storage.Delete(ctx, key); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+	}
+	if err := s.DB.Delete(snapshot).Error; err != nil {
+		return fmt.Errorf("deleting snapshot: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeLink, linkID)
+	return nil
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"desis-keep/apps/api/internal/checklist"
+	"desis-keep/apps/api/internal/models"
+)
+
+// MaxNoteItems is the most items a checklist note can have.
+const MaxNoteItems = 1000
+
+var (
+	// ErrNotChecklist is returned when changing the items of a note that is not a checklist.
+	ErrNotChecklist = errors.New("note is not a checklist")
+	// ErrItemNotFound is returned when a checklist note has no item with the given ID.
+	ErrItemNotFound = errors.New("item not found")
+	// ErrTooManyItems is returned when a checklist would exceed MaxNoteItems.
+	ErrTooManyItems = fmt.Errorf("a checklist can have at most %d items", MaxNoteItems)
+	// ErrInvalidItemOrder is returned when a new order does not list every item of the note exactly once.
+	ErrInvalidItemOrder = errors.New("item order must list every item of the note exactly once")
+	// ErrUnknownNoteType is returned when converting a note to a type that does not exist.
+	ErrUnknownNoteType = errors.New("unknown note type")
+)
+
+// AddItem adds an item to a checklist note at position, shifting the items
+// from there down, or at the end when position is nil or past the end.
+func (s *NoteService) AddItem(noteID, userID uint, item models.NoteItem, position *int) (*models.Note, error) {
+	return s.changeItems(noteID, userID, func(tx *gorm.DB, note *models.Note) error {
+		var count int64
+		if err := tx.Model(&models.NoteItem{}).Where("note_id = ?", note.ID).Count(&count).Error; err != nil {
+			return fmt.Errorf("counting items: %w", err)
+		}
+		if count >= MaxNoteItems {
+			return ErrTooManyItems
+		}
+
+		item.ID = 0
+		item.NoteID = note.ID
+		item.Text = checklist.CleanText(item.Text)
+		item.Indent = checklist.ClampIndent(item.Indent)
+		item.Position = int(count)
+		if position != nil && *position >= 0 && *position < int(count) {
+			item.Position = *position
+			err := tx.Model(&models.NoteItem{}).
+				Where("note_id = ? AND position >= ?", note.ID, item.Position).
+				Update("position", gorm.Expr("position + 1")).Error
+			if err != nil {
+				return fmt.Errorf("making room for item: %w", err)
+			}
+		}
+		if err := tx.Create(&item).Error; err != nil {
+			return fmt.Errorf("adding item: %w", err)
+		}
+		return nil
+	})
+}
+
+// UpdateItem changes the text, checked state or indent of an item.
+func (s *NoteService) UpdateItem(noteID, itemID, userID uint, data map[string]interface{}) (*models.Note, error) {
+	if text, ok := data["text"].(string); ok {
+		data["text"] = checklist.CleanText(text)
+	}
+	if indent, ok := data["indent"].(int); ok {
+		data["indent"] = checklist.ClampIndent(indent)
+	}
+	return s.changeItems(noteID, userID, func(tx *gorm.DB, note *models.Note) error {
+		result := tx.Model(&models.NoteItem{}).Where("id = ? AND note_id = ?", itemID, note.ID).Updates(data)
+		if result.Error != nil {
+			return fmt.Errorf("updating item: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrItemNotFound
+		}
+		return nil
+	})
+}
+
+// ToggleItem flips whether an item is checked.
+func (s *NoteService) ToggleItem(noteID, itemID, userID uint) (*models.Note, error) {
+	return s.changeItems(noteID, userID, func(tx *gorm.DB, note *models.Note) error {
+		result := tx.Model(&models.NoteItem{}).
+			Where("id = ? AND note_id = ?", itemID, note.ID).
+			Update("checked", gorm.Expr("NOT checked"))
+		if result.Error != nil {
+			return fmt.Errorf("toggling item: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrItemNotFound
+		}
+		return nil
+	})
+}
+
+// DeleteItem removes an item from a checklist note.
+func (s *NoteService) DeleteItem(noteID, itemID, userID uint) (*models.Note, error) {
+	return s.changeItems(noteID, userID, func(tx *gorm.DB, note *models.Note) error {
+		result := tx.Where("id = ? AND note_id = ?", itemID, note.ID).Delete(&models.NoteItem{})
+		if result.Error != nil {
+			return fmt.Errorf("deleting item: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrItemNotFound
+		}
+		return nil
+	})
+}
+
+// ReorderItems puts the items of a checklist note in the order of itemIDs,
+// which must list each of them once. With checked items moved to the bottom,
+// the order within the unchecked and checked groups is kept.
+func (s *NoteService) ReorderItems(noteID, userID uint, itemIDs []uint) (*models.Note, error) {
+	return s.changeItems(noteID, userID, func(tx *gorm.DB, note *models.Note) error {
+		var ids []uint
+		if err := tx.Model(&models.NoteItem{}).Where("note_id = ?", note.ID).Pluck("id", &ids).Error; err != nil {
+			return fmt.Errorf("listing items: %w", err)
+		}
+		if len(ids) != len(itemIDs) {
+			return ErrInvalidItemOrder
+		}
+		remaining := make(map[uint]bool, len(ids))
+		for _, id := range ids {
+			remaining[id] = true
+		}
+		for _, id := range itemIDs {
+			if !remaining[id] {
+				return ErrInvalidItemOrder
+			}
+			delete(remaining, id)
+		}
+
+		for position, id := range itemIDs {
+			if err := tx.Model(&models.NoteItem{}).Where("id = ?", id).Update("position", position).Error; err != nil {
+				return fmt.Errorf("reordering items: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Convert turns a text note into a checklist, with one item per non-blank
+// line of the body, or a checklist into a text note whose body is the items
+// as a Markdown task list. Converting a note to its own type does nothing.
+func (s *NoteService) Convert(noteID, userID uint, noteType string) (*models.Note, error) {
+	if noteType != models.NoteTypeText && noteType != models.NoteTypeChecklist {
+		return nil, ErrUnknownNoteType
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		note, err := lockNote(tx, noteID, userID)
+		if err != nil || note.Type == noteType {
+			return err
+		}
+
+		if noteType == models.NoteTypeText {
+			// The body already holds the items
+			if err := tx.Where("note_id = ?", note.ID).Delete(&models.NoteItem{}).Error; err != nil {
+				return fmt.Errorf("removing items: %w", err)
+			}
+			if err := tx.Model(note).Update("type", noteType).Error; err != nil {
+				return fmt.Errorf("converting note: %w", err)
+			}
+			return nil
+		}
+
+		before := *note
+		note.Type = noteType
+		body, err := replaceItems(tx, note, note.Body)
+		if err != nil {
+			return err
+		}
+		err = tx.Model(note).Omit(clause.Associations).Updates(map[string]interface{}{"type": noteType, "body": body}).Error
+		if err != nil {
+			return fmt.Errorf("converting note: %w", err)
+		}
+		return recordRevision(tx, &before, note.Title, body, userID, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.Search.sync(models.ResourceTypeNote, noteID)
+	s.References.sync(noteID)
+	return s.GetByID(noteID, userID)
+}
+
+// changeItems runs change on a checklist note's items in a transaction that
+// holds the note's row lock, so concurrent changes to the same list apply one
+// after another. Afterwards positions are renumbered from zero and the body
+// is rendered again.
+func (s *NoteService) changeItems(noteID, userID uint, change func(tx *gorm.DB, note *models.Note) error) (*models.Note, error) {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		note, err := lockNote(tx, noteID, userID)
+		if err != nil {
+			return err
+		}
+		if note.Type != models.NoteTypeChecklist {
+			return ErrNotChecklist
+		}
+		before := *note
+		if err := change(tx, note); err != nil {
+			return err
+		}
+
+		if err := orderItems(tx).Where("note_id = ?", note.ID).Find(&note.Items).Error; err != nil {
+			return fmt.Errorf("loading items: %w", err)
+		}
+		for i := range note.Items {
+			if note.Items[i].Position == i {
+				continue
+			}
+			note.Items[i].Position = i
+			if err := tx.Model(&note.Items[i]).UpdateColumn("position", i).Error; err != nil {
+				return fmt.Errorf("renumbering items: %w", err)
+			}
+		}
+		sortItems(note)
+		body := renderItems(note.Items)
+		if err := tx.Model(note).Omit(clause.Associations).Update("body", body).Error; err != nil {
+			return fmt.Errorf("updating note: %w", err)
+		}
+		return recordRevision(tx, &before, note.Title, body, userID, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.Search.sync(models.ResourceTypeNote, noteID)
+	s.References.sync(noteID)
+	return s.GetByID(noteID, userID)
+}
+
+// lockNote loads a user's note and locks its row for the rest of the transaction.
+func lockNote(tx *gorm.DB, noteID, userID uint) (*models.Note, error) {
+	var note models.Note
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", noteID).
+		Scopes(accessible(models.ResourceTypeNote, userID, models.SharePermissionEdit)).
+		First(&note).Error
+	if err != nil {
+		return nil, fmt.Errorf("note not found: %w", err)
+	}
+	return &note, nil
+}
+
+// orderItems orders items by their position, for Preload and queries.
+func orderItems(db *gorm.DB) *gorm.DB {
+	return db.Order("position ASC, id ASC")
+}
+
+// sortItems puts a checklist's unchecked items before its checked ones when
+// the note moves checked items to the bottom. Items must be in position order.
+func sortItems(note *models.Note) {
+	if !note.MoveCheckedToBottom {
+		return
+	}
+	sort.SliceStable(note.Items, func(i, j int) bool {
+		return !note.Items[i].Checked && note.Items[j].Checked
+	})
+}
+
+// renderItems returns the body of a checklist with these items.
+func renderItems(items []models.NoteItem) string {
+	lines := make([]checklist.Item, len(items))
+	for i, item := range items {
+		lines[i] = checklist.Item{Text: item.Text, Checked: item.Checked, Indent: item.Indent}
+	}
+	return checklist.Render(lines)
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/wikilink"
+)
+
+// RewriteReferences makes wiki links to a note that still name it by an
+// earlier title name its current title, in every note of the user. Each note
+// it changes gets a revision. Returns how many links were rewritten.
+func (s *NoteService) RewriteReferences(noteID, userID uint) (int, error) {
+	var note models.Note
+	if err := s.DB.Select("id", "title").Where("id = ? AND user_id = ?", noteID, userID).First(&note).Error; err != nil {
+		return 0, fmt.Errorf("note not found: %w", err)
+	}
+	title := strings.TrimSpace(note.Title)
+
+	var links []models.ResourceLink
+	err := s.DB.Where("user_id = ? AND kind = ? AND source_type = ? AND target_type = ? AND target_id = ? AND target_title <> ?",
+		userID, models.ResourceLinkWiki, models.ResourceTypeNote, models.ResourceTypeNote, noteID, title).
+		Order("source_id ASC").
+		Find(&links).Error
+	if err != nil {
+		return 0, fmt.Errorf("fetching references: %w", err)
+	}
+
+	oldTitles := make(map[uint][]string)
+	var sources []uint
+	for _, link := range links {
+		if _, ok := oldTitles[link.SourceID]; !ok {
+			sources = append(sources, link.SourceID)
+		}
+		oldTitles[link.SourceID] = append(oldTitles[link.SourceID], link.TargetTitle)
+	}
+
+	rewritten := 0
+	for _, sourceID := range sources {
+		changed, err := s.rewriteNote(sourceID, userID, oldTitles[sourceID], title)
+		if err != nil {
+			return rewritten, err
+		}
+		if changed > 0 {
+			rewritten += changed
+			s.Search.sync(models.ResourceTypeNote, sourceID)
+			s.References.sync(sourceID)
+		}
+	}
+	return rewritten, nil
+}
+
+// rewriteNote changes the wiki links naming any of oldTitles in a note's
+// body, or in its items for a checklist, to name newTitle.
+func (s *NoteService) rewriteNote(noteID, userID uint, oldTitles []string, newTitle string) (int, error) {
+	changed := 0
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		note, err := lockNote(tx, noteID, userID)
+		if err != nil {
+			return err
+		}
+		before := *note
+		rewrite := func(text string) string {
+			for _, old := range oldTitles {
+				var n int
+				text, n = wikilink.Rewrite(text, old, newTitle)
+				changed += n
+			}
+			return text
+		}
+
+		body := note.Body
+		if note.Type == models.NoteTypeChecklist {
+			if err := orderItems(tx).Where("note_id = ?", note.ID).Find(&note.Items).Error; err != nil {
+				return fmt.Errorf("loading items: %w", err)
+			}
+			for i := range note.Items {
+				text := rewrite(note.Items[i].Text)
+				if text == note.Items[i].Text {
+					continue
+				}
+				note.Items[i].Text = text
+				if err := tx.Model(&note.Items[i]).Update("text", text).Error; err != nil {
+					return fmt.Errorf("updating item: %w", err)
+				}
+			}
+			sortItems(note)
+			body = renderItems(note.Items)
+		} else {
+			body = rewrite(body)
+		}
+		if changed == 0 {
+			return nil
+		}
+
+		if err := tx.Model(note).Omit(clause.Associations).Update("body", body).Error; err != nil {
+			return fmt.Errorf("updating note: %w", err)
+		}
+		return recordRevision(tx, &before, note.Title, body, userID, nil)
+	})
+	return changed, err
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"desis-keep/apps/api/internal/checklist"
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/textdiff"
+)
+
+const (
+	// revisionCoalesceWindow is how soon after an author's last save another
+	// save by them updates the same revision, so autosaves don't each add one.
+	revisionCoalesceWindow = 5 * time.Minute
+	// revisionCoalesceLimit is how long a revision keeps absorbing saves, so
+	// a long editing session still leaves a revision every so often.
+	revisionCoalesceLimit = 30 * time.Minute
+	// revisionRetention is how long revisions are kept. A note's latest
+	// revision is kept however old it is.
+	revisionRetention = 90 * 24 * time.Hour
+	// maxNoteRevisions is how many revisions a note keeps at most.
+	maxNoteRevisions = 100
+	// revisionPruneBatch is how many revisions PruneRevisions deletes per statement.
+	revisionPruneBatch = 5000
+	// revisionDiffContext is how many unchanged lines surround each change in a diff.
+	revisionDiffContext = 3
+)
+
+// RevisionDiff is the difference between two states of a note.
+type RevisionDiff struct {
+	FromID    uint   `json:"from_id"`
+	ToID      *uint  `json:"to_id"` // nil for the note as it is now
+	FromTitle string `json:"from_title"`
+	ToTitle   string `json:"to_title"`
+	Diff      string `json:"diff"` // unified diff of the bodies
+	Added     int    `json:"added"`
+	Removed   int    `json:"removed"`
+}
+
+// ListRevisions returns a page of a note's revisions, newest first, without
+// their bodies.
+func (s *NoteService) ListRevisions(noteID, userID uint, page, pageSize int) ([]models.NoteRevision, int64, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	if err := s.DB.Select("id").Where("id = ?", noteID).Scopes(accessible(models.ResourceTypeNote, userID, models.SharePermissionView)).First(&models.Note{}).Error; err != nil {
+		return nil, 0, 0, fmt.Errorf("note not found: %w", err)
+	}
+
+	query := s.DB.Model(&models.NoteRevision{}).Where("note_id = ?", noteID)
+	var total int64
+	query.Count(&total)
+
+	var revisions []models.NoteRevision
+	err := query.Omit("body").Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&revisions).Error
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("fetching revisions: %w", err)
+	}
+
+	pages := int(math.Ceil(float64(total) / float64(pageSize)))
+	return revisions, total, pages, nil
+}
+
+// GetRevision returns one revision of a user's note.
+func (s *NoteService) GetRevision(noteID, revisionID, userID uint) (*models.NoteRevision, error) {
+	var revision models.NoteRevision
+	err := s.DB.Joins("JOIN notes ON notes.id = note_revisions.note_id").
+		Where("note_revisions.id = ? AND note_revisions.note_id = ?", revisionID, noteID).
+		Scopes(accessible(models.ResourceTypeNote, userID, models.SharePermissionView)).
+		First(&revision).Error
+	if err != nil {
+		return nil, fmt.Errorf("revision not found: %w", err)
+	}
+	return &revision, nil
+}
+
+// DiffRevisions compares revision fromID of a note with revision toID, or
+// with the note as it is now when toID is 0.
+func (s *NoteService) DiffRevisions(noteID, userID, fromID, toID uint) (*RevisionDiff, error) {
+	from, err := s.GetRevision(noteID, fromID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RevisionDiff{FromID: from.ID, FromTitle: from.Title}
+	var toBody string
+	if toID == 0 {
+		note, err := s.GetByID(noteID, userID)
+		if err != nil {
+			return nil, err
+		}
+		result.ToTitle, toBody = note.Title, note.Body
+	} else {
+		to, err := s.GetRevision(noteID, toID, userID)
+		if err != nil {
+			return nil, err
+		}
+		result.ToID = &to.ID
+		result.ToTitle, toBody = to.Title, to.Body
+	}
+
+	toName := "current"
+	if result.ToID != nil {
+		toName = fmt.Sprintf("revision %d", *result.ToID)
+	}
+	diff := textdiff.Compute(from.Body, toBody)
+	result.Diff = diff.Unified(fmt.Sprintf("revision %d", from.ID), toName, revisionDiffContext)
+	result.Added, result.Removed = diff.Stats()
+	return result, nil
+}
+
+// RestoreRevision sets a note's title and body back to those of one of its
+// revisions, which is recorded as a new revision. A checklist gets its items
+// back from the restored body.
+func (s *NoteService) RestoreRevision(noteID, revisionID, userID uint) (*models.Note, error) {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		note, err := lockNote(tx, noteID, userID)
+		if err != nil {
+			return err
+		}
+		var revision models.NoteRevision
+		if err := tx.Where("id = ? AND note_id = ?", revisionID, noteID).First(&revision).Error; err != nil {
+			return fmt.Errorf("revision not found: %w", err)
+		}
+
+		before := *note
+		body := revision.Body
+		if note.Type == models.NoteTypeChecklist {
+			if body, err = replaceItems(tx, note, body); err != nil {
+				return err
+			}
+		}
+		err = tx.Model(note).Omit(clause.Associations).Updates(map[string]interface{}{"title": revision.Title, "body": body}).Error
+		if err != nil {
+			return fmt.Errorf("restoring revision: %w", err)
+		}
+		return recordRevision(tx, &before, revision.Title, body, userID, &revision.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.Search.sync(models.ResourceTypeNote, noteID)
+	s.References.sync(noteID)
+	return s.GetByID(noteID, userID)
+}
+
+// PruneRevisions applies the retention policy: it deletes revisions beyond
+// the newest maxNoteRevisions of each note and those older than
+// revisionRetention, always keeping a note's latest revision. Returns how
+// many revisions were deleted.
+func (s *NoteService) PruneRevisions() (int64, error) {
+	cutoff := time.Now().Add(-revisionRetention)
+	var deleted int64
+	for {
+		result := s.DB.Exec(`
+			DELETE FROM note_revisions WHERE id IN (
+				SELECT id FROM (
+					SELECT id, created_at, ROW_NUMBER() OVER (PARTITION BY note_id ORDER BY id DESC) AS recency
+					FROM note_revisions
+				) ranked
+				WHERE recency > 1 AND (recency > ? OR created_at < ?)
+				LIMIT ?
+			)`, maxNoteRevisions, cutoff, revisionPruneBatch)
+		if result.Error != nil {
+			return deleted, fmt.Errorf("pruning revisions: %w", result.Error)
+		}
+		deleted += result.RowsAffected
+		if result.RowsAffected < revisionPruneBatch {
+			return deleted, nil
+		}
+	}
+}
+
+// recordRevision records a note's new title and body, saved by authorID, as
+// a revision. before is the note as it was, which becomes its first
+// revision when it has none yet. A save that changes nothing since the
+// latest revision is not recorded, and one that follows the same author's
+// last save closely updates that revision instead. restoredFrom marks a
+// restore, which always gets a revision of its own.
+func recordRevision(tx *gorm.DB, before *models.Note, title, body string, authorID uint, restoredFrom *uint) error {
+	var latest models.NoteRevision
+	err := tx.Where("note_id = ?", before.ID).Order("id DESC").Limit(1).Find(&latest).Error
+	if err != nil {
+		return fmt.Errorf("loading latest revision: %w", err)
+	}
+
+	now := time.Now()
+	coalesce := restoredFrom == nil && latest.ID != 0 &&
+		latest.AuthorID == authorID && latest.RestoredFromID == nil &&
+		now.Sub(latest.UpdatedAt) < revisionCoalesceWindow &&
+		now.Sub(latest.CreatedAt) < revisionCoalesceLimit
+
+	if latest.ID == 0 {
+		if before.Title == title && before.Body == body {
+			return nil
+		}
+		latest = models.NoteRevision{
+			NoteID:    before.ID,
+			AuthorID:  before.UserID,
+			Title:     before.Title,
+			Body:      before.Body,
+			CreatedAt: before.UpdatedAt,
+			UpdatedAt: before.UpdatedAt,
+		}
+		if err := tx.Create(&latest).Error; err != nil {
+			return fmt.Errorf("recording revision: %w", err)
+		}
+	}
+	if latest.Title == title && latest.Body == body {
+		return nil
+	}
+
+	if coalesce {
+		err := tx.Model(&latest).Updates(map[string]interface{}{"title": title, "body": body}).Error
+		if err != nil {
+			return fmt.Errorf("recording revision: %w", err)
+		}
+		return nil
+	}
+	revision := models.NoteRevision{
+		NoteID:         before.ID,
+		AuthorID:       authorID,
+		Title:          title,
+		Body:           body,
+		RestoredFromID: restoredFrom,
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		return fmt.Errorf("recording revision: %w", err)
+	}
+	return nil
+}
+
+// replaceItems replaces a checklist's items with those of body and returns
+// the body rendered from them.
+func replaceItems(tx *gorm.DB, note *models.Note, body string) (string, error) {
+	parsed := checklist.Parse(body)
+	if len(parsed) > MaxNoteItems {
+		return "", ErrTooManyItems
+	}
+	if err := tx.Where("note_id = ?", note.ID).Delete(&models.NoteItem{}).Error; err != nil {
+		return "", fmt.Errorf("removing items: %w", err)
+	}
+	items := make([]models.NoteItem, len(parsed))
+	for i, p := range parsed {
+		items[i] = models.NoteItem{NoteID: note.ID, Text: p.Text, Checked: p.Checked, Indent: p.Indent, Position: i}
+	}
+	if len(items) > 0 {
+		if err := tx.Create(&items).Error; err != nil {
+			return "", fmt.Errorf("creating items: %w", err)
+		}
+	}
+	note.Items = items
+	sortItems(note)
+	return renderItems(note.Items), nil
+}
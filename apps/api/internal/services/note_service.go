@@ -1,134 +1,225 @@
-package services
-
-import (
-	"fmt"
-	"math"
-
-	"gorm.io/gorm"
-
-	"desis-keep/apps/api/internal/models"
-)
-
-// NoteService handles business logic for notes.
-type NoteService struct {
-	DB *gorm.DB
-}
-
-// NewNoteService creates a new NoteService instance.
-func NewNoteService(db *gorm.DB) *NoteService {
-	return &NoteService{DB: db}
-}
-
-// List returns a paginated list of notes for a user.
-func (s *NoteService) List(userID uint, page, pageSize int, search, sortKey, sortDir string, archived, trashed *bool) ([]models.Note, int64, int, error) {
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 20
-	}
-	if sortDir != "asc" && sortDir != "desc" {
-		sortDir = "desc"
-	}
-	if sortKey == "" {
-		sortKey = "created_at"
-	}
-
-	query := s.DB.Model(&models.Note{}).Where("user_id = ?", userID).Preload("Labels")
-
-	// Default: exclude archived and trashed
-	if archived == nil && trashed == nil {
-		query = query.Where("is_archived = ? AND is_trashed = ?", false, false)
-	} else {
-		if archived != nil {
-			query = query.Where("is_archived = ?", *archived)
-		}
-		if trashed != nil {
-			query = query.Where("is_trashed = ?", *trashed)
-		}
-	}
-
-	if search != "" {
-		query = query.Where("title ILIKE ? OR body ILIKE ?", "%"+search+"%", "%"+search+"%")
-	}
-
-	var total int64
-	query.Count(&total)
-
-	var notes []models.Note
-	offset := (page - 1) * pageSize
-	if err := query.Order(sortKey + " " + sortDir).Offset(offset).Limit(pageSize).Find(&notes).Error; err != nil {
-		return nil, 0, 0, fmt.Errorf("fetching notes: %w", err)
-	}
-
-	pages := int(math.Ceil(float64(total) / float64(pageSize)))
-	return notes, total, pages, nil
-}
-
-// GetByID returns a single note by ID (scoped to user).
-func (s *NoteService) GetByID(id, userID uint) (*models.Note, error) {
-	var note models.Note
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).Preload("Labels").First(&note).Error; err != nil {
-		return nil, fmt.Errorf("note not found: %w", err)
-	}
-	return &note, nil
-}
-
-// Create creates a new note.
-func (s *NoteService) Create(note *models.Note) error {
-	if err := s.DB.Create(note).Error; err != nil {
-		return fmt.Errorf("creating note: %w", err)
-	}
-	return nil
-}
-
-// Update modifies an existing note.
-func (s *NoteService) Update(id, userID uint, data map[string]interface{}) (*models.Note, error) {
-	var note models.Note
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&note).Error; err != nil {
-		return nil, fmt.Errorf("note not found: %w", err)
-	}
-
-	if err := s.DB.Model(&note).Updates(data).Error; err != nil {
-		return nil, fmt.Errorf("updating note: %w", err)
-	}
-
-	s.DB.Where("id = ?", id).Preload("Labels").First(&note)
-	return &note, nil
-}
-
-// Delete soft-deletes a note (sets is_trashed = true).
-func (s *NoteService) Delete(id, userID uint) error {
-	var note models.Note
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&note).Error; err != nil {
-		return fmt.Errorf("note not found: %w", err)
-	}
-	if err := s.DB.Model(&note).Update("is_trashed", true).Error; err != nil {
-		return fmt.Errorf("trashing note: %w", err)
-	}
-	return nil
-}
-
-// Restore restores a trashed note.
-func (s *NoteService) Restore(id, userID uint) error {
-	var note models.Note
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&note).Error; err != nil {
-		return fmt.Errorf("note not found: %w", err)
-	}
-	if err := s.DB.Model(&note).Update("is_trashed", false).Error; err != nil {
-		return fmt.Errorf("restoring note: %w", err)
-	}
-	return nil
-}
-
-// PermanentDelete hard-deletes a note.
-func (s *NoteService) PermanentDelete(id, userID uint) error {
-	var note models.Note
-	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&note).Error; err != nil {
-		return fmt.Errorf("note not found: %w", err)
-	}
-	if err := s.DB.Unscoped().Delete(&note).Error; err != nil {
-		return fmt.Errorf("permanently deleting note: %w", err)
-	}
-	return nil
-}
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/checklist"
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/querylang"
+)
+
+// NoteService handles business logic for notes.
+type NoteService struct {
+	DB         *gorm.DB
+	Search     *SearchService
+	References *ReferenceService
+}
+
+// NewNoteService creates a new NoteService instance.
+func NewNoteService(db *gorm.DB) *NoteService {
+	return &NoteService{DB: db, Search: NewSearchService(db), References: NewReferenceService(db)}
+}
+
+// List returns a paginated list of notes for a user, filtered by a parsed search query.
+func (s *NoteService) List(userID uint, page, pageSize int, q *querylang.Query, sortKey, sortDir string, archived, trashed *bool) ([]models.Note, int64, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	if sortDir != "asc" && sortDir != "desc" {
+		sortDir = "desc"
+	}
+	if sortKey == "" {
+		sortKey = "created_at"
+	}
+
+	query := s.DB.Model(&models.Note{}).Scopes(accessible(models.ResourceTypeNote, userID, models.SharePermissionView)).Preload("Labels").Preload("Items", orderItems)
+
+	// Default: exclude archived and trashed unless the query asks for them
+	if archived == nil && trashed == nil {
+		if !q.HasFlag(querylang.FlagArchived) {
+			query = query.Where("is_archived = ?", false)
+		}
+		if !q.HasFlag(querylang.FlagTrashed) {
+			query = query.Where("is_trashed = ?", false)
+		}
+	} else {
+		if archived != nil {
+			query = query.Where("is_archived = ?", *archived)
+		}
+		if trashed != nil {
+			query = query.Where("is_trashed = ?", *trashed)
+		}
+	}
+
+	query = q.Scope(querylang.NoteTarget)(query)
+
+	var total int64
+	query.Count(&total)
+
+	var notes []models.Note
+	offset := (page - 1) * pageSize
+	if err := query.Order(sortKey + " " + sortDir).Offset(offset).Limit(pageSize).Find(&notes).Error; err != nil {
+		return nil, 0, 0, fmt.Errorf("fetching notes: %w", err)
+	}
+	for i := range notes {
+		sortItems(&notes[i])
+	}
+
+	pages := int(math.Ceil(float64(total) / float64(pageSize)))
+	return notes, total, pages, nil
+}
+
+// GetByID returns a single note by ID (scoped to user).
+func (s *NoteService) GetByID(id, userID uint) (*models.Note, error) {
+	var note models.Note
+	if err := s.DB.Where("id = ?", id).Scopes(accessible(models.ResourceTypeNote, userID, models.SharePermissionView)).Preload("Labels").Preload("Items", orderItems).First(&note).Error; err != nil {
+		return nil, fmt.Errorf("note not found: %w", err)
+	}
+	sortItems(&note)
+	return &note, nil
+}
+
+// Create creates a new note. A checklist note is created with its Items,
+// numbered in order, and its body rendered from them.
+func (s *NoteService) Create(note *models.Note) error {
+	if note.Type == "" {
+		note.Type = models.NoteTypeText
+	}
+	if note.Type == models.NoteTypeChecklist {
+		if len(note.Items) > MaxNoteItems {
+			return ErrTooManyItems
+		}
+		for i := range note.Items {
+			note.Items[i].ID = 0
+			note.Items[i].Position = i
+			note.Items[i].Text = checklist.CleanText(note.Items[i].Text)
+			note.Items[i].Indent = checklist.ClampIndent(note.Items[i].Indent)
+		}
+		sortItems(note)
+		note.Body = renderItems(note.Items)
+	} else {
+		note.Items = nil
+	}
+	if err := s.DB.Create(note).Error; err != nil {
+		return fmt.Errorf("creating note: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeNote, note.ID)
+	s.References.sync(note.ID)
+	return nil
+}
+
+// Update modifies an existing note, recording the edit as a revision when
+// it changes the title or body. The body of a checklist note follows its
+// items, so a body in data is ignored for checklists. A note that is no
+// longer at version is returned as it is now, with ErrVersionConflict.
+func (s *NoteService) Update(id, userID, version uint, data map[string]interface{}) (*models.Note, error) {
+	var note models.Note
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		locked, err := lockNote(tx, id, userID)
+		if err != nil {
+			return err
+		}
+		note = *locked
+		if err := orderItems(tx).Where("note_id = ?", note.ID).Find(&note.Items).Error; err != nil {
+			return fmt.Errorf("loading items: %w", err)
+		}
+		before := note
+
+		if note.Type == models.NoteTypeChecklist {
+			delete(data, "body")
+			if moveChecked, ok := data["move_checked_to_bottom"].(bool); ok && moveChecked != note.MoveCheckedToBottom {
+				note.MoveCheckedToBottom = moveChecked
+				sortItems(&note)
+				data["body"] = renderItems(note.Items)
+			}
+		}
+
+		if err := updateVersioned(tx, &note, version, data); err != nil {
+			return fmt.Errorf("updating note: %w", err)
+		}
+
+		title, body := before.Title, before.Body
+		if v, ok := data["title"].(string); ok {
+			title = v
+		}
+		if v, ok := data["body"].(string); ok {
+			body = v
+		}
+		return recordRevision(tx, &before, title, body, userID, nil)
+	})
+	if err != nil && !errors.Is(err, ErrVersionConflict) {
+		return nil, err
+	}
+
+	s.DB.Where("id = ?", id).Preload("Labels").Preload("Items", orderItems).First(&note)
+	sortItems(&note)
+	if err != nil {
+		return &note, err
+	}
+	s.Search.sync(models.ResourceTypeNote, note.ID)
+	s.References.sync(note.ID)
+	return &note, nil
+}
+
+// Delete soft-deletes a note (sets is_trashed = true).
+func (s *NoteService) Delete(id, userID uint) error {
+	var note models.Note
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&note).Error; err != nil {
+		return fmt.Errorf("note not found: %w", err)
+	}
+	if err := s.DB.Model(&note).Update("is_trashed", true).Error; err != nil {
+		return fmt.Errorf("trashing note: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeNote, note.ID)
+	return nil
+}
+
+// Restore restores a trashed note.
+func (s *NoteService) Restore(id, userID uint) error {
+	var note models.Note
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&note).Error; err != nil {
+		return fmt.Errorf("note not found: %w", err)
+	}
+	if err := s.DB.Model(&note).Update("is_trashed", false).Error; err != nil {
+		return fmt.Errorf("restoring note: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeNote, note.ID)
+	return nil
+}
+
+// PermanentDelete hard-deletes a note.
+func (s *NoteService) PermanentDelete(id, userID uint) error {
+	var note models.Note
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&note).Error; err != nil {
+		return fmt.Errorf("note not found: %w", err)
+	}
+	if err := s.DB.Unscoped().Delete(&note).Error; err != nil {
+		return fmt.Errorf("permanently deleting note: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeNote, note.ID)
+	s.References.sync(note.ID)
+	forgetShares(s.DB, models.ResourceTypeNote, note.ID)
+	forgetPublicLinks(s.DB, models.ResourceTypeNote, note.ID)
+	return nil
+}
+
+// SetLabels replaces the labels on a note with the given labels owned by the user.
+func (s *NoteService) SetLabels(note *models.Note, userID uint, labelIDs []uint) error {
+	if note.UserID != userID {
+		return ErrNotOwner
+	}
+	var labels []models.Label
+	s.DB.Where("id IN ? AND user_id = ?", labelIDs, userID).Find(&labels)
+	if err := s.DB.Model(note).Association("Labels").Replace(labels); err != nil {
+		return fmt.Errorf("setting note labels: %w", err)
+	}
+	s.Search.sync(models.ResourceTypeNote, note.ID)
+	return nil
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"desis-keep/apps/api/internal/models"
+)
+
+// NotificationService stores and reads in-app notifications.
+type NotificationService struct {
+	DB *gorm.DB
+}
+
+// NewNotificationService creates a new NotificationService instance.
+func NewNotificationService(db *gorm.DB) *NotificationService {
+	return &NotificationService{DB: db}
+}
+
+// Create stores a notification unless the user already has one with the same
+// Key, reporting whether it was created.
+func (s *NotificationService) Create(n *models.Notification) (bool, error) {
+	result := s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(n)
+	if result.Error != nil {
+		return false, fmt.Errorf("creating notification: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// List returns a user's notifications, newest first, with the number unread.
+func (s *NotificationService) List(userID uint, unreadOnly bool, limit int) ([]models.Notification, int64, error) {
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	var unread int64
+	if err := s.DB.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&unread).Error; err != nil {
+		return nil, 0, fmt.Errorf("counting notifications: %w", err)
+	}
+
+	query := s.DB.Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+	var notifications []models.Notification
+	if err := query.Order("created_at DESC").Limit(limit).Find(&notifications).Error; err != nil {
+		return nil, 0, fmt.Errorf("fetching notifications: %w", err)
+	}
+	return notifications, unread, nil
+}
+
+// MarkRead marks one of a user's notifications as read.
+func (s *NotificationService) MarkRead(id, userID uint) error {
+	var n models.Notification
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&n).Error; err != nil {
+		return fmt.Errorf("notification not found: %w", err)
+	}
+	if n.ReadAt != nil {
+		return nil
+	}
+	if err := s.DB.Model(&n).Update("read_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("marking notification read: %w", err)
+	}
+	return nil
+}
+
+// MarkAllRead marks every unread notification of a user as read.
+func (s *NotificationService) MarkAllRead(userID uint) error {
+	err := s.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("marking notifications read: %w", err)
+	}
+	return nil
+}
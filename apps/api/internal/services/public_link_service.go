@@ -0,0 +1,373 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/cache"
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/storage"
+)
+
+const (
+	// publicLinkURLTTL is how long the signed URL of a published image or file works.
+	publicLinkURLTTL = 15 * time.Minute
+	// maxPublicLinkPassword is the longest password bcrypt hashes, in bytes.
+	maxPublicLinkPassword = 72
+	// publicLinkTokenAttempts is how many wrong passwords one client may send
+	// for one link before it is locked out of the link. The count is kept per
+	// client so that guessing cannot lock the link's real recipients out.
+	publicLinkTokenAttempts = 10
+	// publicLinkClientAttempts is how many wrong passwords one client may send,
+	// over all links, before it is locked out.
+	publicLinkClientAttempts = 20
+	// publicLinkLockout is how long failed attempts are remembered: a lockout
+	// ends this long after the last wrong password.
+	publicLinkLockout = 15 * time.Minute
+)
+
+var (
+	// ErrUnpublishableType is returned for a resource type that cannot be published.
+	ErrUnpublishableType = errors.New("only notes, links, images and files can be published")
+	// ErrExpiryInPast is returned when a public link would expire before it is made.
+	ErrExpiryInPast = errors.New("expires_at must be in the future")
+	// ErrPublicLinkExpired is returned when opening a public link past its expiry.
+	ErrPublicLinkExpired = errors.New("this link has expired")
+	// ErrPasswordRequired is returned when opening a protected public link
+	// without its password, or with a wrong one.
+	ErrPasswordRequired = errors.New("this link needs a password")
+	// ErrPasswordTooLong is returned for a password bcrypt cannot hash.
+	ErrPasswordTooLong = errors.New("password must be at most 72 bytes")
+)
+
+// TooManyAttemptsError is returned when opening a public link after too many
+// wrong passwords from that client, for that link or over all links.
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	return "too many wrong passwords; try again later"
+}
+
+// PublicLinkService publishes single resources through unguessable links.
+type PublicLinkService struct {
+	DB      *gorm.DB
+	Storage *storage.Storage
+	// Cache counts wrong passwords to throttle guessing; nil disables the limit.
+	Cache *cache.Cache
+}
+
+// NewPublicLinkService creates a new PublicLinkService instance.
+func NewPublicLinkService(db *gorm.DB, store *storage.Storage, cacheService *cache.Cache) *PublicLinkService {
+	return &PublicLinkService{DB: db, Storage: store, Cache: cacheService}
+}
+
+// PublicLinkInput is what to publish and how.
+type PublicLinkInput struct {
+	ResourceType string
+	ResourceID   uint
+	ExpiresAt    *time.Time
+	Password     string
+}
+
+// PublicResource is what a public link shows: the resource's content, and
+// nothing about its owner or where it is stored. The URL of an image or file
+// is signed and stops working at URLExpiresAt.
+type PublicResource struct {
+	Type         string            `json:"type"`
+	Title        string            `json:"title"`
+	Body         string            `json:"body,omitempty"`
+	NoteType     string            `json:"note_type,omitempty"`
+	Items        []models.NoteItem `json:"items,omitempty"`
+	URL          string            `json:"url,omitempty"`
+	URLExpiresAt *time.Time        `json:"url_expires_at,omitempty"`
+	Description  string            `json:"description,omitempty"`
+	ThumbnailURL string            `json:"thumbnail_url,omitempty"`
+	FaviconURL   string            `json:"favicon_url,omitempty"`
+	OriginalName string            `json:"original_name,omitempty"`
+	MimeType     string            `json:"mime_type,omitempty"`
+	SizeBytes    uint              `json:"size_bytes,omitempty"`
+	Width        int               `json:"width,omitempty"`
+	Height       int               `json:"height,omitempty"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// Create publishes one of the user's resources outside the trash.
+func (s *PublicLinkService) Create(userID uint, in PublicLinkInput) (*models.PublicLink, error) {
+	model := resourceModel(in.ResourceType)
+	if model == nil {
+		return nil, ErrUnpublishableType
+	}
+	if in.ExpiresAt != nil && !in.ExpiresAt.After(time.Now()) {
+		return nil, ErrExpiryInPast
+	}
+	if len(in.Password) > maxPublicLinkPassword {
+		return nil, ErrPasswordTooLong
+	}
+	var count int64
+	err := s.DB.Model(model).Where("id = ? AND user_id = ? AND is_trashed = ?", in.ResourceID, userID, false).Count(&count).Error
+	if err != nil {
+		return nil, fmt.Errorf("checking %s %d: %w", in.ResourceType, in.ResourceID, err)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("%s not found: %w", in.ResourceType, gorm.ErrRecordNotFound)
+	}
+
+	token, err := generatePublicToken()
+	if err != nil {
+		return nil, err
+	}
+	link := models.PublicLink{
+		UserID:       userID,
+		ResourceType: in.ResourceType,
+		ResourceID:   in.ResourceID,
+		Token:        token,
+		ExpiresAt:    in.ExpiresAt,
+	}
+	if err := link.SetPassword(in.Password); err != nil {
+		return nil, fmt.Errorf("hashing password: %w", err)
+	}
+	if err := s.DB.Create(&link).Error; err != nil {
+		return nil, fmt.Errorf("creating public link: %w", err)
+	}
+	return &link, nil
+}
+
+// List returns the user's public links, revoked ones included, newest
+// first, optionally only those of one resource.
+func (s *PublicLinkService) List(userID uint, resourceType string, resourceID uint) ([]models.PublicLink, error) {
+	query := s.DB.Where("user_id = ?", userID)
+	if resourceType != "" {
+		query = query.Where("resource_type = ?", resourceType)
+	}
+	if resourceID != 0 {
+		query = query.Where("resource_id = ?", resourceID)
+	}
+	var links []models.PublicLink
+	if err := query.Order("id DESC").Find(&links).Error; err != nil {
+		return nil, fmt.Errorf("fetching public links: %w", err)
+	}
+	return links, nil
+}
+
+// Update replaces when one of the user's public links expires (nil for
+// never) and, unless password is nil, its password ("" for none).
+func (s *PublicLinkService) Update(id, userID uint, expiresAt *time.Time, password *string) (*models.PublicLink, error) {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, ErrExpiryInPast
+	}
+	if password != nil && len(*password) > maxPublicLinkPassword {
+		return nil, ErrPasswordTooLong
+	}
+	var link models.PublicLink
+	if err := s.DB.Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).First(&link).Error; err != nil {
+		return nil, fmt.Errorf("public link not found: %w", err)
+	}
+
+	link.ExpiresAt = expiresAt
+	if password != nil {
+		if err := link.SetPassword(*password); err != nil {
+			return nil, fmt.Errorf("hashing password: %w", err)
+		}
+	}
+	err := s.DB.Model(&link).Updates(map[string]interface{}{
+		"expires_at": link.ExpiresAt,
+		"password":   link.Password,
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("updating public link: %w", err)
+	}
+	return &link, nil
+}
+
+// Revoke stops one of the user's public links from working. The link is
+// kept, with its view count, for the user's records.
+func (s *PublicLinkService) Revoke(id, userID uint) error {
+	result := s.DB.Model(&models.PublicLink{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("revoking public link: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("public link not found: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// Open returns the resource a public link publishes and counts the view.
+// A revoked link, or one whose resource was trashed or deleted, is not found.
+func (s *PublicLinkService) Open(ctx context.Context, token, password, clientIP string) (*PublicResource, error) {
+	var link models.PublicLink
+	if err := s.DB.Where("token = ? AND revoked_at IS NULL", token).First(&link).Error; err != nil {
+		return nil, fmt.Errorf("public link not found: %w", err)
+	}
+	now := time.Now()
+	if link.Expired(now) {
+		return nil, ErrPublicLinkExpired
+	}
+	if link.HasPassword {
+		keys := []string{
+			fmt.Sprintf("public-link:attempts:link:%d:ip:%s", link.ID, clientIP),
+			"public-link:attempts:ip:" + clientIP,
+		}
+		limits := []int64{publicLinkTokenAttempts, publicLinkClientAttempts}
+		if err := s.checkAttempts(ctx, keys, limits); err != nil {
+			return nil, err
+		}
+		if !link.CheckPassword(password) {
+			if password != "" {
+				s.countAttempt(ctx, keys)
+			}
+			return nil, ErrPasswordRequired
+		}
+	}
+
+	resource, err := s.resource(ctx, &link)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.DB.Model(&link).UpdateColumns(map[string]interface{}{
+		"view_count":     gorm.Expr("view_count + 1"),
+		"last_viewed_at": now,
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("counting view: %w", err)
+	}
+	return resource, nil
+}
+
+// checkAttempts returns a *TooManyAttemptsError when any of the failed
+// password counters has reached its limit. It lets the attempt through when
+// the counters cannot be read.
+func (s *PublicLinkService) checkAttempts(ctx context.Context, keys []string, limits []int64) error {
+	if s.Cache == nil {
+		return nil
+	}
+	for i, key := range keys {
+		count, err := s.Cache.Client().Get(ctx, key).Int64()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Warning: reading %s: %v", key, err)
+			continue
+		}
+		if count >= limits[i] {
+			ttl, err := s.Cache.Client().TTL(ctx, key).Result()
+			if err != nil || ttl <= 0 {
+				ttl = publicLinkLockout
+			}
+			return &TooManyAttemptsError{RetryAfter: ttl}
+		}
+	}
+	return nil
+}
+
+// countAttempt records a wrong password against each counter, restarting
+// their lockout window.
+func (s *PublicLinkService) countAttempt(ctx context.Context, keys []string) {
+	if s.Cache == nil {
+		return
+	}
+	pipe := s.Cache.Client().TxPipeline()
+	for _, key := range keys {
+		pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, publicLinkLockout)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Warning: counting public link password attempt: %v", err)
+	}
+}
+
+// resource loads what a public link shows, signing the URL of an image or file.
+func (s *PublicLinkService) resource(ctx context.Context, link *models.PublicLink) (*PublicResource, error) {
+	scope := s.DB.Where("id = ? AND user_id = ? AND is_trashed = ?", link.ResourceID, link.UserID, false)
+	resource := &PublicResource{Type: link.ResourceType}
+
+	switch link.ResourceType {
+	case models.ResourceTypeNote:
+		var note models.Note
+		if err := scope.Preload("Items", orderItems).First(&note).Error; err != nil {
+			return nil, fmt.Errorf("note not found: %w", err)
+		}
+		sortItems(&note)
+		resource.Title, resource.Body, resource.NoteType = note.Title, note.Body, note.Type
+		resource.Items, resource.UpdatedAt = note.Items, note.UpdatedAt
+
+	case models.ResourceTypeLink:
+		var l models.Link
+		if err := scope.First(&l).Error; err != nil {
+			return nil, fmt.Errorf("link not found: %w", err)
+		}
+		resource.Title, resource.URL, resource.Description = l.Title, l.URL, l.Description
+		resource.ThumbnailURL, resource.FaviconURL, resource.UpdatedAt = l.ThumbnailURL, l.FaviconURL, l.UpdatedAt
+
+	case models.ResourceTypeImage:
+		var image models.Image
+		if err := scope.First(&image).Error; err != nil {
+			return nil, fmt.Errorf("image not found: %w", err)
+		}
+		if err := s.sign(ctx, resource, image.StorageKey); err != nil {
+			return nil, err
+		}
+		resource.Title, resource.MimeType, resource.SizeBytes = image.Title, image.MimeType, image.SizeBytes
+		resource.Width, resource.Height, resource.UpdatedAt = image.Width, image.Height, image.UpdatedAt
+
+	case models.ResourceTypeFile:
+		var file models.File
+		if err := scope.First(&file).Error; err != nil {
+			return nil, fmt.Errorf("file not found: %w", err)
+		}
+		if err := s.sign(ctx, resource, file.StorageKey); err != nil {
+			return nil, err
+		}
+		resource.Title, resource.OriginalName, resource.MimeType = file.Title, file.OriginalName, file.MimeType
+		resource.SizeBytes, resource.UpdatedAt = file.SizeBytes, file.UpdatedAt
+
+	default:
+		return nil, fmt.Errorf("%s not found: %w", link.ResourceType, gorm.ErrRecordNotFound)
+	}
+	return resource, nil
+}
+
+// sign sets a resource's URL to a short-lived signed URL of a stored object.
+func (s *PublicLinkService) sign(ctx context.Context, resource *PublicResource, key string) error {
+	if s.Storage == nil {
+		return ErrStorageUnavailable
+	}
+	url, err := s.Storage.GetSignedURL(ctx, key, publicLinkURLTTL)
+	if err != nil {
+		return fmt.Errorf("signing %s: %w", key, err)
+	}
+	expiresAt := time.Now().Add(publicLinkURLTTL)
+	resource.URL, resource.URLExpiresAt = url, &expiresAt
+	return nil
+}
+
+// generatePublicToken returns a random URL-safe token for a public link.
+func generatePublicToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating public link token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// forgetPublicLinks deletes the public links of a deleted resource and logs
+// failures instead of returning them.
+func forgetPublicLinks(db *gorm.DB, resourceType string, id uint) {
+	if err := db.Where("resource_type = ? AND resource_id = ?", resourceType, id).Delete(&models.PublicLink{}).Error; err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
@@ -0,0 +1,353 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/wikilink"
+)
+
+// maxNoteReferences is how many references of one note are recorded.
+const maxNoteReferences = 500
+
+// ReferenceService keeps the graph of references that note bodies make to
+// other resources with wiki links and keep:// URIs.
+type ReferenceService struct {
+	DB *gorm.DB
+}
+
+// NewReferenceService creates a new ReferenceService instance.
+func NewReferenceService(db *gorm.DB) *ReferenceService {
+	return &ReferenceService{DB: db}
+}
+
+// GraphNode is a resource in a user's graph. ID is "<type>:<resource id>".
+type GraphNode struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	ResourceID uint   `json:"resource_id"`
+	Title      string `json:"title"`
+}
+
+// GraphEdge is a reference from one graph node to another.
+type GraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Kind   string `json:"kind"`
+}
+
+// Graph is a user's resources and the references between them.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// Index records the references in a note's body, replacing those recorded
+// before, and points wiki links waiting for the note's title at it. A wiki
+// link names the oldest note with its title, preferring notes outside the
+// trash; a URI only counts when it names one of the user's resources. A note
+// that no longer exists loses its references and the references to it.
+func (s *ReferenceService) Index(noteID uint) error {
+	var note models.Note
+	if err := s.DB.Select("id", "user_id", "title", "body").Where("id = ?", noteID).Limit(1).Find(&note).Error; err != nil {
+		return fmt.Errorf("loading note %d: %w", noteID, err)
+	}
+	if note.ID == 0 {
+		return dropReferences(s.DB, models.ResourceTypeNote, noteID)
+	}
+	title := strings.TrimSpace(note.Title)
+
+	refs := wikilink.Parse(note.Body)
+	if len(refs) > maxNoteReferences {
+		refs = refs[:maxNoteReferences]
+	}
+	links := make([]models.ResourceLink, 0, len(refs))
+	for _, ref := range refs {
+		link := models.ResourceLink{
+			UserID:     note.UserID,
+			SourceType: models.ResourceTypeNote,
+			SourceID:   note.ID,
+		}
+		if ref.IsWiki() {
+			if strings.EqualFold(ref.Title, title) {
+				continue
+			}
+			targetID, err := s.noteByTitle(note.UserID, ref.Title)
+			if err != nil {
+				return err
+			}
+			link.Kind = models.ResourceLinkWiki
+			link.TargetType = models.ResourceTypeNote
+			link.TargetTitle = ref.Title
+			if targetID != 0 {
+				link.TargetID = &targetID
+			}
+		} else {
+			if ref.Type == models.ResourceTypeNote && ref.ID == note.ID {
+				continue
+			}
+			owned, err := s.owns(note.UserID, ref.Type, ref.ID)
+			if err != nil {
+				return err
+			}
+			if !owned {
+				continue
+			}
+			targetID := ref.ID
+			link.Kind = models.ResourceLinkURI
+			link.TargetType = ref.Type
+			link.TargetID = &targetID
+		}
+		links = append(links, link)
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("source_type = ? AND source_id = ?", models.ResourceTypeNote, note.ID).Delete(&models.ResourceLink{}).Error
+		if err != nil {
+			return fmt.Errorf("clearing references of note %d: %w", note.ID, err)
+		}
+		if len(links) > 0 {
+			if err := tx.Create(&links).Error; err != nil {
+				return fmt.Errorf("recording references of note %d: %w", note.ID, err)
+			}
+		}
+		if title == "" {
+			return nil
+		}
+		err = tx.Model(&models.ResourceLink{}).
+			Where("user_id = ? AND kind = ? AND target_id IS NULL AND LOWER(target_title) = LOWER(?)", note.UserID, models.ResourceLinkWiki, title).
+			Update("target_id", note.ID).Error
+		if err != nil {
+			return fmt.Errorf("resolving wiki links to note %d: %w", note.ID, err)
+		}
+		return nil
+	})
+}
+
+// Backlinks returns the user's notes outside the trash that reference a
+// note, most recently updated first.
+func (s *ReferenceService) Backlinks(noteID, userID uint) ([]models.Note, error) {
+	if err := s.DB.Select("id").Where("id = ? AND user_id = ?", noteID, userID).First(&models.Note{}).Error; err != nil {
+		return nil, fmt.Errorf("note not found: %w", err)
+	}
+
+	sources := s.DB.Model(&models.ResourceLink{}).Select("source_id").
+		Where("source_type = ? AND target_type = ? AND target_id = ?", models.ResourceTypeNote, models.ResourceTypeNote, noteID)
+	var notes []models.Note
+	err := s.DB.Where("id IN (?) AND user_id = ? AND is_trashed = ?", sources, userID, false).
+		Preload("Labels").
+		Order("updated_at DESC").
+		Find(&notes).Error
+	if err != nil {
+		return nil, fmt.Errorf("fetching backlinks: %w", err)
+	}
+	return notes, nil
+}
+
+// Graph returns a user's notes outside the trash, the resources they
+// reference and the references between them. Without orphans, notes that
+// neither reference nor are referenced are left out.
+func (s *ReferenceService) Graph(userID uint, orphans bool) (*Graph, error) {
+	var notes []models.Note
+	if err := s.DB.Select("id", "title").Where("user_id = ? AND is_trashed = ?", userID, false).Find(&notes).Error; err != nil {
+		return nil, fmt.Errorf("fetching notes: %w", err)
+	}
+	var links []models.ResourceLink
+	err := s.DB.Where("user_id = ? AND source_type = ? AND target_id IS NOT NULL", userID, models.ResourceTypeNote).
+		Order("id ASC").
+		Find(&links).Error
+	if err != nil {
+		return nil, fmt.Errorf("fetching references: %w", err)
+	}
+
+	nodes := make(map[string]GraphNode, len(notes))
+	for _, note := range notes {
+		nodes[nodeID(models.ResourceTypeNote, note.ID)] = GraphNode{
+			ID:         nodeID(models.ResourceTypeNote, note.ID),
+			Type:       models.ResourceTypeNote,
+			ResourceID: note.ID,
+			Title:      note.Title,
+		}
+	}
+	targets := make(map[string][]uint)
+	for _, link := range links {
+		if link.TargetType != models.ResourceTypeNote {
+			targets[link.TargetType] = append(targets[link.TargetType], *link.TargetID)
+		}
+	}
+	for resourceType, ids := range targets {
+		found, err := s.titles(userID, resourceType, ids)
+		if err != nil {
+			return nil, err
+		}
+		for id, title := range found {
+			nodes[nodeID(resourceType, id)] = GraphNode{
+				ID:         nodeID(resourceType, id),
+				Type:       resourceType,
+				ResourceID: id,
+				Title:      title,
+			}
+		}
+	}
+
+	graph := &Graph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	connected := make(map[string]bool)
+	seen := make(map[GraphEdge]bool)
+	for _, link := range links {
+		edge := GraphEdge{
+			Source: nodeID(link.SourceType, link.SourceID),
+			Target: nodeID(link.TargetType, *link.TargetID),
+			Kind:   link.Kind,
+		}
+		_, hasSource := nodes[edge.Source]
+		_, hasTarget := nodes[edge.Target]
+		if !hasSource || !hasTarget || seen[edge] {
+			continue
+		}
+		seen[edge] = true
+		connected[edge.Source], connected[edge.Target] = true, true
+		graph.Edges = append(graph.Edges, edge)
+	}
+	for id, node := range nodes {
+		if orphans || connected[id] {
+			graph.Nodes = append(graph.Nodes, node)
+		}
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		if graph.Nodes[i].Type != graph.Nodes[j].Type {
+			return graph.Nodes[i].Type < graph.Nodes[j].Type
+		}
+		return graph.Nodes[i].ResourceID < graph.Nodes[j].ResourceID
+	})
+	return graph, nil
+}
+
+// sync indexes a note's references and logs failures instead of returning
+// them, so a reference problem never fails the write that triggered it.
+func (s *ReferenceService) sync(noteID uint) {
+	if err := s.Index(noteID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// noteByTitle returns the ID of the note a wiki link to title names, or 0.
+func (s *ReferenceService) noteByTitle(userID uint, title string) (uint, error) {
+	var note models.Note
+	err := s.DB.Select("id").
+		Where("user_id = ? AND LOWER(TRIM(title)) = LOWER(?)", userID, title).
+		Order("is_trashed ASC, id ASC").
+		Limit(1).
+		Find(&note).Error
+	if err != nil {
+		return 0, fmt.Errorf("finding note %q: %w", title, err)
+	}
+	return note.ID, nil
+}
+
+// owns reports whether one of the user's resources has the type and ID.
+func (s *ReferenceService) owns(userID uint, resourceType string, id uint) (bool, error) {
+	model := resourceModel(resourceType)
+	if model == nil {
+		return false, nil
+	}
+	var count int64
+	if err := s.DB.Model(model).Where("id = ? AND user_id = ?", id, userID).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("checking %s %d: %w", resourceType, id, err)
+	}
+	return count > 0, nil
+}
+
+// titles returns display titles of the user's resources of a type outside
+// the trash, by ID. Untitled links and images show their URL, and untitled
+// files their file name.
+func (s *ReferenceService) titles(userID uint, resourceType string, ids []uint) (map[uint]string, error) {
+	var rows []struct {
+		ID       uint
+		Title    string
+		Fallback string
+	}
+	var fallback string
+	switch resourceType {
+	case models.ResourceTypeLink, models.ResourceTypeImage:
+		fallback = "url"
+	case models.ResourceTypeFile:
+		fallback = "original_name"
+	default:
+		return nil, nil
+	}
+	err := s.DB.Model(resourceModel(resourceType)).
+		Select("id", "title", fallback+" AS fallback").
+		Where("id IN ? AND user_id = ? AND is_trashed = ?", ids, userID, false).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s titles: %w", resourceType, err)
+	}
+
+	titles := make(map[uint]string, len(rows))
+	for _, row := range rows {
+		titles[row.ID] = row.Title
+		if row.Title == "" {
+			titles[row.ID] = row.Fallback
+		}
+	}
+	return titles, nil
+}
+
+// resourceModel returns the model of a resource type, or nil.
+func resourceModel(resourceType string) interface{} {
+	switch resourceType {
+	case models.ResourceTypeNote:
+		return &models.Note{}
+	case models.ResourceTypeLink:
+		return &models.Link{}
+	case models.ResourceTypeImage:
+		return &models.Image{}
+	case models.ResourceTypeFile:
+		return &models.File{}
+	}
+	return nil
+}
+
+// nodeID names a resource in a graph.
+func nodeID(resourceType string, id uint) string {
+	return fmt.Sprintf("%s:%d", resourceType, id)
+}
+
+// dropReferences removes a deleted resource from the graph: the references
+// a note made, and the URI references to it. Wiki links to a deleted note
+// wait for another note with its title.
+func dropReferences(db *gorm.DB, resourceType string, id uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if resourceType == models.ResourceTypeNote {
+			err := tx.Where("source_type = ? AND source_id = ?", resourceType, id).Delete(&models.ResourceLink{}).Error
+			if err != nil {
+				return fmt.Errorf("clearing references of %s %d: %w", resourceType, id, err)
+			}
+		}
+		err := tx.Where("target_type = ? AND target_id = ? AND kind = ?", resourceType, id, models.ResourceLinkURI).
+			Delete(&models.ResourceLink{}).Error
+		if err != nil {
+			return fmt.Errorf("clearing references to %s %d: %w", resourceType, id, err)
+		}
+		err = tx.Model(&models.ResourceLink{}).
+			Where("target_type = ? AND target_id = ? AND kind = ?", resourceType, id, models.ResourceLinkWiki).
+			Update("target_id", nil).Error
+		if err != nil {
+			return fmt.Errorf("detaching wiki links to %s %d: %w", resourceType, id, err)
+		}
+		return nil
+	})
+}
+
+// forgetReferences drops a permanently deleted resource from the graph and
+// logs failures instead of returning them.
+func forgetReferences(db *gorm.DB, resourceType string, id uint) {
+	if err := dropReferences(db, resourceType, id); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/rrule"
+)
+
+const (
+	// reminderBatch is how many due reminders Dispatch handles per transaction.
+	reminderBatch = 100
+	// maxSnooze is how far ahead a reminder can be snoozed.
+	maxSnooze = 365 * 24 * time.Hour
+)
+
+var (
+	// ErrInvalidTimezone is returned for a timezone that is not an IANA name.
+	ErrInvalidTimezone = errors.New("unknown timezone")
+	// ErrReminderInPast is returned when a one-off reminder or a snooze is not in the future.
+	ErrReminderInPast = errors.New("reminder time must be in the future")
+	// ErrSnoozeTooLong is returned when a snooze is further ahead than maxSnooze.
+	ErrSnoozeTooLong = errors.New("reminders can be snoozed for up to a year")
+	// ErrRecurrenceEnded is returned when a recurrence rule has no occurrence left.
+	ErrRecurrenceEnded = errors.New("recurrence has no future occurrences")
+)
+
+// ReminderService schedules note reminders and finds the ones that are due.
+type ReminderService struct {
+	DB *gorm.DB
+}
+
+// NewReminderService creates a new ReminderService instance.
+func NewReminderService(db *gorm.DB) *ReminderService {
+	return &ReminderService{DB: db}
+}
+
+// ReminderInput is a reminder as a user sets it.
+type ReminderInput struct {
+	DueAt    time.Time
+	RRule    string // empty for a one-off reminder
+	Timezone string // IANA name the recurrence follows; empty is UTC
+}
+
+// Set gives a note its reminder, replacing any it had. A recurring reminder
+// whose start is past begins at its next occurrence.
+func (s *ReminderService) Set(noteID, userID uint, in ReminderInput) (*models.Reminder, error) {
+	if err := s.DB.Select("id").Where("id = ? AND user_id = ?", noteID, userID).First(&models.Note{}).Error; err != nil {
+		return nil, fmt.Errorf("note not found: %w", err)
+	}
+
+	if in.Timezone == "" {
+		in.Timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(in.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTimezone, in.Timezone)
+	}
+
+	now := time.Now()
+	reminder := models.Reminder{
+		UserID:   userID,
+		NoteID:   noteID,
+		Status:   models.ReminderScheduled,
+		StartsAt: in.DueAt.In(loc),
+		DueAt:    in.DueAt.In(loc),
+		Timezone: loc.String(),
+	}
+	if in.RRule != "" {
+		rule, err := rrule.ParseIn(in.RRule, loc)
+		if err != nil {
+			return nil, err
+		}
+		reminder.RRule = rule.String()
+		next, ok := rule.After(reminder.StartsAt, now)
+		if !ok {
+			return nil, ErrRecurrenceEnded
+		}
+		reminder.DueAt = next
+	} else if !reminder.DueAt.After(now) {
+		return nil, ErrReminderInPast
+	}
+	reminder.NotifyAt = reminder.DueAt
+
+	err = s.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "note_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"status", "starts_at", "due_at", "rrule", "timezone", "snoozed_until", "notify_at", "updated_at",
+		}),
+	}).Create(&reminder).Error
+	if err != nil {
+		return nil, fmt.Errorf("saving reminder: %w", err)
+	}
+	return s.get(noteID, userID)
+}
+
+// Remove deletes a note's reminder.
+func (s *ReminderService) Remove(noteID, userID uint) error {
+	result := s.DB.Where("note_id = ? AND user_id = ?", noteID, userID).Delete(&models.Reminder{})
+	if result.Error != nil {
+		return fmt.Errorf("deleting reminder: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("reminder not found: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// Snooze fires a reminder again at until. Snoozing a recurring reminder from
+// an earlier occurrence's notification does not skip the next occurrence,
+// and a one-off reminder that already fired is scheduled again.
+func (s *ReminderService) Snooze(id, userID uint, until time.Time) (*models.Reminder, error) {
+	now := time.Now()
+	if !until.After(now) {
+		return nil, ErrReminderInPast
+	}
+	if until.Sub(now) > maxSnooze {
+		return nil, ErrSnoozeTooLong
+	}
+
+	var reminder models.Reminder
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&reminder).Error; err != nil {
+		return nil, fmt.Errorf("reminder not found: %w", err)
+	}
+	err := s.DB.Model(&reminder).Updates(map[string]interface{}{
+		"status":        models.ReminderScheduled,
+		"snoozed_until": until,
+		"notify_at":     until,
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("snoozing reminder: %w", err)
+	}
+	return s.get(reminder.NoteID, userID)
+}
+
+// Upcoming returns a user's scheduled reminders on notes outside the trash,
+// soonest first, up to before when it is set.
+func (s *ReminderService) Upcoming(userID uint, before *time.Time, limit int) ([]models.Reminder, error) {
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	query := s.DB.Joins("JOIN notes ON notes.id = reminders.note_id AND notes.deleted_at IS NULL").
+		Where("reminders.user_id = ? AND reminders.status = ? AND notes.is_trashed = ?", userID, models.ReminderScheduled, false).
+		Preload("Note").
+		Order("reminders.notify_at ASC").
+		Limit(limit)
+	if before != nil {
+		query = query.Where("reminders.notify_at <= ?", *before)
+	}
+
+	var reminders []models.Reminder
+	if err := query.Find(&reminders).Error; err != nil {
+		return nil, fmt.Errorf("fetching reminders: %w", err)
+	}
+	return reminders, nil
+}
+
+// Dispatch hands every reminder due by now to notify and moves it on: a
+// snoozed reminder back to its current occurrence if that is still ahead,
+// a recurring one to its next occurrence after now, and anything else to
+// done. Occurrences missed while nothing ran are notified once. Reminders of
+// trashed notes move on without a notification. Returns how many reminders
+// were handed to notify; when notify fails, the reminder stays due.
+func (s *ReminderService) Dispatch(now time.Time, notify func(r *models.Reminder) error) (int, error) {
+	sent := 0
+	for {
+		handled := 0
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			var due []models.Reminder
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+				Where("status = ? AND notify_at <= ?", models.ReminderScheduled, now).
+				Order("notify_at ASC").
+				Limit(reminderBatch).
+				Find(&due).Error
+			if err != nil {
+				return fmt.Errorf("fetching due reminders: %w", err)
+			}
+
+			for i := range due {
+				r := &due[i]
+				var note models.Note
+				err := tx.Select("id", "is_trashed").Where("id = ?", r.NoteID).Limit(1).Find(&note).Error
+				if err != nil {
+					return fmt.Errorf("loading note of reminder %d: %w", r.ID, err)
+				}
+				notified := false
+				if note.ID != 0 && !note.IsTrashed {
+					if err := notify(r); err != nil {
+						return err
+					}
+					notified = true
+					sent++
+				}
+				if err := s.advance(tx, r, now, notified); err != nil {
+					return err
+				}
+				handled++
+			}
+			return nil
+		})
+		if err != nil || handled < reminderBatch {
+			return sent, err
+		}
+	}
+}
+
+// advance schedules a reminder that just fired.
+func (s *ReminderService) advance(tx *gorm.DB, r *models.Reminder, now time.Time, notified bool) error {
+	updates := map[string]interface{}{"snoozed_until": nil}
+	if notified {
+		updates["last_notified_at"] = now
+	}
+
+	switch {
+	case r.SnoozedUntil != nil && r.DueAt.After(now):
+		updates["notify_at"] = r.DueAt
+	case r.RRule != "":
+		next, ok := nextOccurrence(r, now)
+		if ok {
+			updates["due_at"] = next
+			updates["notify_at"] = next
+		} else {
+			updates["status"] = models.ReminderDone
+		}
+	default:
+		updates["status"] = models.ReminderDone
+	}
+
+	if err := tx.Model(r).Updates(updates).Error; err != nil {
+		return fmt.Errorf("advancing reminder %d: %w", r.ID, err)
+	}
+	return nil
+}
+
+// get loads a note's reminder with its note.
+func (s *ReminderService) get(noteID, userID uint) (*models.Reminder, error) {
+	var reminder models.Reminder
+	if err := s.DB.Where("note_id = ? AND user_id = ?", noteID, userID).Preload("Note").First(&reminder).Error; err != nil {
+		return nil, fmt.Errorf("reminder not found: %w", err)
+	}
+	return &reminder, nil
+}
+
+// nextOccurrence returns a recurring reminder's first occurrence after both
+// its current one and now, in the reminder's timezone.
+func nextOccurrence(r *models.Reminder, now time.Time) (time.Time, bool) {
+	loc, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	rule, err := rrule.ParseIn(r.RRule, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	after := r.DueAt
+	if now.After(after) {
+		after = now
+	}
+	return rule.After(r.StartsAt.In(loc), after)
+}
@@ -0,0 +1,389 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/querylang"
+)
+
+// searchConfig is the Postgres text search configuration used for indexing and querying.
+const searchConfig = querylang.SearchConfig
+
+// headlineOptions controls how ts_headline builds highlighted snippets.
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter= … "
+
+// escapeHTMLSQL wraps a SQL text expression so that it is escaped for HTML.
+// Snippets are built from escaped text so that the <mark> tags ts_headline
+// adds are their only markup; ts_headline keeps tags found in its input.
+func escapeHTMLSQL(expr string) string {
+	return `replace(replace(replace(replace(replace(` + expr +
+		`, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+}
+
+var (
+	htmlTagPattern    = regexp.MustCompile(`<[^>]*>`)
+	whitespacePattern = regexp.MustCompile(`\s+`)
+)
+
+// SearchService maintains the full-text search index and queries it.
+type SearchService struct {
+	DB *gorm.DB
+}
+
+// NewSearchService creates a new SearchService instance.
+func NewSearchService(db *gorm.DB) *SearchService {
+	return &SearchService{DB: db}
+}
+
+// SearchParams holds the options for a search query.
+type SearchParams struct {
+	Query           *querylang.Query
+	Types           []string
+	IncludeArchived bool
+	Page            int
+	PageSize        int
+}
+
+// SearchResult represents a single ranked search hit.
+type SearchResult struct {
+	ID        uint      `json:"id"`
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	Snippet   string    `json:"snippet"`
+	Labels    string    `json:"labels,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	Rank      float64   `json:"rank"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SearchPage is a page of search results with totals.
+type SearchPage struct {
+	Results []SearchResult   `json:"results"`
+	Total   int64            `json:"total"`
+	Pages   int              `json:"pages"`
+	Counts  map[string]int64 `json:"counts"`
+}
+
+// searchEntry is the source data for one index row.
+type searchEntry struct {
+	UserID     uint
+	Title      string
+	Body       string
+	Labels     string
+	URL        string
+	Color      string
+	IsPinned   bool
+	IsArchived bool
+	IsTrashed  bool
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Search runs a ranked full-text query over the resources a user can open:
+// their own and those shared with them.
+func (s *SearchService) Search(userID uint, params SearchParams) (*SearchPage, error) {
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.PageSize < 1 || params.PageSize > 100 {
+		params.PageSize = 20
+	}
+
+	q := params.Query
+	base := s.DB.Table("search_documents").Scopes(accessibleDocuments(userID))
+	if !q.HasFlag(querylang.FlagTrashed) {
+		base = base.Where("is_trashed = ?", false)
+	}
+	if !params.IncludeArchived && !q.HasFlag(querylang.FlagArchived) {
+		base = base.Where("is_archived = ?", false)
+	}
+	if len(params.Types) > 0 {
+		base = base.Where("resource_type IN ?", params.Types)
+	}
+	base = q.Scope(querylang.SearchTarget)(base)
+
+	var counts []struct {
+		ResourceType string
+		Count        int64
+	}
+	if err := base.Session(&gorm.Session{}).
+		Select("resource_type, COUNT(*) AS count").
+		Group("resource_type").
+		Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("counting search results: %w", err)
+	}
+
+	page := &SearchPage{Counts: map[string]int64{}}
+	for _, c := range counts {
+		page.Counts[c.ResourceType] = c.Count
+		page.Total += c.Count
+	}
+
+	page.Results = []SearchResult{}
+	if page.Total > 0 {
+		offset := (params.Page - 1) * params.PageSize
+		ranked := base.Session(&gorm.Session{})
+		if q.HasText() {
+			text := q.Text()
+			ranked = ranked.Select(`resource_id AS id, resource_type AS type, title, labels, url, created_at, updated_at,
+				ts_rank(document, websearch_to_tsquery(?, ?)) AS rank,
+				ts_headline(?, `+escapeHTMLSQL("COALESCE(NULLIF(body, ''), title)")+`, websearch_to_tsquery(?, ?), ?) AS snippet`,
+				searchConfig, text, searchConfig, searchConfig, text, headlineOptions)
+		} else {
+			ranked = ranked.Select(`resource_id AS id, resource_type AS type, title, labels, url, created_at, updated_at,
+				0 AS rank, ` + escapeHTMLSQL("LEFT(body, 200)") + ` AS snippet`)
+		}
+		err := ranked.
+			Order("rank DESC, updated_at DESC").
+			Offset(offset).
+			Limit(params.PageSize).
+			Scan(&page.Results).Error
+		if err != nil {
+			return nil, fmt.Errorf("searching: %w", err)
+		}
+	}
+
+	page.Pages = int(math.Ceil(float64(page.Total) / float64(params.PageSize)))
+	return page, nil
+}
+
+// Index (re)builds the search entry for a single resource.
+// Resources that no longer exist are removed from the index.
+func (s *SearchService) Index(resourceType string, id uint) error {
+	entry, err := s.loadEntry(resourceType, id)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return s.Remove(resourceType, id)
+	}
+
+	err = s.DB.Exec(`INSERT INTO search_documents
+			(resource_type, resource_id, user_id, title, body, labels, url, color, is_pinned, is_archived, is_trashed, created_at, updated_at, document)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
+			setweight(to_tsvector(?::regconfig, ?), 'A') ||
+			setweight(to_tsvector(?::regconfig, ?), 'B') ||
+			setweight(to_tsvector(?::regconfig, ?), 'C'))
+		ON CONFLICT (resource_type, resource_id) DO UPDATE SET
+			user_id = EXCLUDED.user_id,
+			title = EXCLUDED.title,
+			body = EXCLUDED.body,
+			labels = EXCLUDED.labels,
+			url = EXCLUDED.url,
+			color = EXCLUDED.color,
+			is_pinned = EXCLUDED.is_pinned,
+			is_archived = EXCLUDED.is_archived,
+			is_trashed = EXCLUDED.is_trashed,
+			created_at = EXCLUDED.created_at,
+			updated_at = EXCLUDED.updated_at,
+			document = EXCLUDED.document`,
+		resourceType, id, entry.UserID, entry.Title, entry.Body, entry.Labels, entry.URL,
+		entry.Color, entry.IsPinned, entry.IsArchived, entry.IsTrashed, entry.CreatedAt, entry.UpdatedAt,
+		searchConfig, entry.Title,
+		searchConfig, entry.Labels,
+		searchConfig, entry.Body,
+	).Error
+	if err != nil {
+		return fmt.Errorf("indexing %s %d: %w", resourceType, id, err)
+	}
+	return nil
+}
+
+// Remove deletes a resource from the search index, including its embedding.
+func (s *SearchService) Remove(resourceType string, id uint) error {
+	if err := s.DB.Where("resource_type = ? AND resource_id = ?", resourceType, id).
+		Delete(&models.SearchDocument{}).Error; err != nil {
+		return fmt.Errorf("removing %s %d from index: %w", resourceType, id, err)
+	}
+	if err := s.DB.Where("resource_type = ? AND resource_id = ?", resourceType, id).
+		Delete(&models.Embedding{}).Error; err != nil {
+		return fmt.Errorf("removing %s %d embedding: %w", resourceType, id, err)
+	}
+	return nil
+}
+
+// ReindexLabel refreshes every resource tagged with the given label.
+func (s *SearchService) ReindexLabel(labelID uint) error {
+	joins := []struct {
+		resourceType string
+		table        string
+		column       string
+	}{
+		{models.ResourceTypeNote, "note_labels", "note_id"},
+		{models.ResourceTypeLink, "link_labels", "link_id"},
+		{models.ResourceTypeImage, "image_labels", "image_id"},
+		{models.ResourceTypeFile, "file_labels", "file_id"},
+	}
+
+	for _, j := range joins {
+		var ids []uint
+		if err := s.DB.Table(j.table).Where("label_id = ?", labelID).Pluck(j.column, &ids).Error; err != nil {
+			return fmt.Errorf("listing %ss for label %d: %w", j.resourceType, labelID, err)
+		}
+		for _, id := range ids {
+			if err := s.Index(j.resourceType, id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReindexAll rebuilds the index for every resource in the database.
+func (s *SearchService) ReindexAll() (int, error) {
+	tables := map[string]interface{}{
+		models.ResourceTypeNote:  &models.Note{},
+		models.ResourceTypeLink:  &models.Link{},
+		models.ResourceTypeImage: &models.Image{},
+		models.ResourceTypeFile:  &models.File{},
+	}
+
+	indexed := 0
+	for _, resourceType := range models.ResourceTypes {
+		var ids []uint
+		if err := s.DB.Model(tables[resourceType]).Pluck("id", &ids).Error; err != nil {
+			return indexed, fmt.Errorf("listing %ss: %w", resourceType, err)
+		}
+		for _, id := range ids {
+			if err := s.Index(resourceType, id); err != nil {
+				return indexed, err
+			}
+			indexed++
+		}
+	}
+	return indexed, nil
+}
+
+// sync indexes a resource and logs failures instead of returning them,
+// so a search index problem never fails the write that triggered it.
+func (s *SearchService) sync(resourceType string, id uint) {
+	if err := s.Index(resourceType, id); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// loadEntry reads a resource and converts it to an index entry.
+// Returns nil when the resource does not exist.
+func (s *SearchService) loadEntry(resourceType string, id uint) (*searchEntry, error) {
+	switch resourceType {
+	case models.ResourceTypeNote:
+		var note models.Note
+		if err := s.DB.Preload("Labels").First(&note, id).Error; err != nil {
+			return nil, ignoreNotFound(err)
+		}
+		return &searchEntry{
+			UserID:     note.UserID,
+			Title:      note.Title,
+			Body:       plainText(note.Body),
+			Labels:     labelNames(note.Labels),
+			Color:      note.Color,
+			IsPinned:   note.IsPinned,
+			IsArchived: note.IsArchived,
+			IsTrashed:  note.IsTrashed,
+			CreatedAt:  note.CreatedAt,
+			UpdatedAt:  note.UpdatedAt,
+		}, nil
+	case models.ResourceTypeLink:
+		var link models.Link
+		if err := s.DB.Preload("Labels").First(&link, id).Error; err != nil {
+			return nil, ignoreNotFound(err)
+		}
+		// Archived page text makes the link findable by its content
+		var snapshot models.LinkSnapshot
+		s.DB.Select("text_content").Where("link_id = ? AND status = ?", link.ID, models.SnapshotReady).Limit(1).Find(&snapshot)
+		return &searchEntry{
+			UserID:     link.UserID,
+			Title:      link.Title,
+			Body:       joinText(plainText(link.Description), snapshot.TextContent, link.URL),
+			Labels:     labelNames(link.Labels),
+			URL:        link.URL,
+			IsPinned:   link.IsPinned,
+			IsArchived: link.IsArchived,
+			IsTrashed:  link.IsTrashed,
+			CreatedAt:  link.CreatedAt,
+			UpdatedAt:  link.UpdatedAt,
+		}, nil
+	case models.ResourceTypeImage:
+		var image models.Image
+		if err := s.DB.Preload("Labels").First(&image, id).Error; err != nil {
+			return nil, ignoreNotFound(err)
+		}
+		return &searchEntry{
+			UserID:     image.UserID,
+			Title:      image.Title,
+			Body:       joinText(image.Folder, image.MimeType),
+			Labels:     labelNames(image.Labels),
+			URL:        image.URL,
+			IsPinned:   image.IsPinned,
+			IsArchived: image.IsArchived,
+			IsTrashed:  image.IsTrashed,
+			CreatedAt:  image.CreatedAt,
+			UpdatedAt:  image.UpdatedAt,
+		}, nil
+	case models.ResourceTypeFile:
+		var file models.File
+		if err := s.DB.Preload("Labels").First(&file, id).Error; err != nil {
+			return nil, ignoreNotFound(err)
+		}
+		return &searchEntry{
+			UserID:     file.UserID,
+			Title:      file.Title,
+			Body:       joinText(file.OriginalName, file.Folder, file.Extension, file.Content),
+			Labels:     labelNames(file.Labels),
+			URL:        file.URL,
+			IsPinned:   file.IsPinned,
+			IsArchived: file.IsArchived,
+			IsTrashed:  file.IsTrashed,
+			CreatedAt:  file.CreatedAt,
+			UpdatedAt:  file.UpdatedAt,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown resource type %q", resourceType)
+	}
+}
+
+// ignoreNotFound maps gorm.ErrRecordNotFound to a nil error.
+func ignoreNotFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	return fmt.Errorf("loading resource for index: %w", err)
+}
+
+// labelNames joins label names into a single indexable string.
+func labelNames(labels []models.Label) string {
+	names := make([]string, 0, len(labels))
+	for _, l := range labels {
+		names = append(names, l.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+// joinText concatenates non-empty parts with newlines.
+func joinText(parts ...string) string {
+	kept := parts[:0]
+	for _, p := range parts {
+		if strings.TrimSpace(p) != "" {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// plainText strips HTML markup from rich text content.
+func plainText(s string) string {
+	s = htmlTagPattern.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	return strings.TrimSpace(whitespacePattern.ReplaceAllString(s, " "))
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/models"
+)
+
+var (
+	// ErrShareSelf is returned when inviting the owner's own email address.
+	ErrShareSelf = errors.New("you cannot share with yourself")
+	// ErrInvalidPermission is returned for a permission other than view or edit.
+	ErrInvalidPermission = errors.New("permission must be view or edit")
+	// ErrUnshareableType is returned for a resource type that cannot be shared.
+	ErrUnshareableType = errors.New("only notes, links, images, files and labels can be shared")
+	// ErrNotOwner is returned when someone a resource is shared with tries
+	// something only its owner may do.
+	ErrNotOwner = errors.New("only the owner can do this")
+)
+
+// ShareService manages who else can see and edit a user's resources.
+type ShareService struct {
+	DB *gorm.DB
+}
+
+// NewShareService creates a new ShareService instance.
+func NewShareService(db *gorm.DB) *ShareService {
+	return &ShareService{DB: db}
+}
+
+// ShareInput is an invitation to a resource.
+type ShareInput struct {
+	ResourceType string
+	ResourceID   uint
+	Email        string
+	Permission   string
+}
+
+// ShareDetails is a share with what a reader needs to show it: who shared
+// it, with whom, and the title of the shared resource.
+type ShareDetails struct {
+	models.Share
+	OwnerName  string `json:"owner_name"`
+	OwnerEmail string `json:"owner_email"`
+	Title      string `json:"title"`
+}
+
+// Invite shares one of the owner's resources with an email address, or
+// changes the permission of an existing share. Returns the share and
+// whether it is new, in which case the invitee should be told.
+func (s *ShareService) Invite(ownerID uint, in ShareInput) (*models.Share, bool, error) {
+	if in.Permission == "" {
+		in.Permission = models.SharePermissionView
+	}
+	if in.Permission != models.SharePermissionView && in.Permission != models.SharePermissionEdit {
+		return nil, false, ErrInvalidPermission
+	}
+	model := shareableModel(in.ResourceType)
+	if model == nil {
+		return nil, false, ErrUnshareableType
+	}
+	var count int64
+	if err := s.DB.Model(model).Where("id = ? AND user_id = ?", in.ResourceID, ownerID).Count(&count).Error; err != nil {
+		return nil, false, fmt.Errorf("checking %s %d: %w", in.ResourceType, in.ResourceID, err)
+	}
+	if count == 0 {
+		return nil, false, fmt.Errorf("%s not found: %w", in.ResourceType, gorm.ErrRecordNotFound)
+	}
+
+	email := strings.ToLower(strings.TrimSpace(in.Email))
+	var owner models.User
+	if err := s.DB.Select("id", "email").First(&owner, ownerID).Error; err != nil {
+		return nil, false, fmt.Errorf("loading owner: %w", err)
+	}
+	if strings.EqualFold(owner.Email, email) {
+		return nil, false, ErrShareSelf
+	}
+
+	var share models.Share
+	err := s.DB.Where("resource_type = ? AND resource_id = ? AND email = ?", in.ResourceType, in.ResourceID, email).
+		Limit(1).Find(&share).Error
+	if err != nil {
+		return nil, false, fmt.Errorf("loading share: %w", err)
+	}
+	if share.ID != 0 {
+		if err := s.DB.Model(&share).Update("permission", in.Permission).Error; err != nil {
+			return nil, false, fmt.Errorf("updating share: %w", err)
+		}
+		return &share, false, nil
+	}
+
+	share = models.Share{
+		OwnerID:      ownerID,
+		ResourceType: in.ResourceType,
+		ResourceID:   in.ResourceID,
+		Email:        email,
+		Permission:   in.Permission,
+	}
+	// Someone may sign up with an address they do not own, so the share waits
+	// for the address to be verified; see Claim.
+	var invitee models.User
+	err = s.DB.Select("id").Where("LOWER(email) = ? AND email_verified_at IS NOT NULL", email).
+		Limit(1).Find(&invitee).Error
+	if err != nil {
+		return nil, false, fmt.Errorf("finding invitee: %w", err)
+	}
+	if invitee.ID != 0 {
+		share.UserID = &invitee.ID
+	}
+	if err := s.DB.Create(&share).Error; err != nil {
+		return nil, false, fmt.Errorf("creating share: %w", err)
+	}
+	return &share, true, nil
+}
+
+// Claim gives a user the shares waiting for their email address, once they
+// have shown they own it. It does nothing for an unverified address.
+func (s *ShareService) Claim(user *models.User) error {
+	if user.EmailVerifiedAt == nil {
+		return nil
+	}
+	err := s.DB.Model(&models.Share{}).
+		Where("user_id IS NULL AND email = ?", strings.ToLower(strings.TrimSpace(user.Email))).
+		Update("user_id", user.ID).Error
+	if err != nil {
+		return fmt.Errorf("claiming shares: %w", err)
+	}
+	return nil
+}
+
+// List returns the shares an owner made, newest first, optionally only
+// those of one resource.
+func (s *ShareService) List(ownerID uint, resourceType string, resourceID uint) ([]models.Share, error) {
+	query := s.DB.Where("owner_id = ?", ownerID)
+	if resourceType != "" {
+		query = query.Where("resource_type = ?", resourceType)
+	}
+	if resourceID != 0 {
+		query = query.Where("resource_id = ?", resourceID)
+	}
+	var shares []models.Share
+	if err := query.Order("id DESC").Find(&shares).Error; err != nil {
+		return nil, fmt.Errorf("fetching shares: %w", err)
+	}
+	return shares, nil
+}
+
+// Received returns what other users shared with a user, newest first.
+func (s *ShareService) Received(userID uint) ([]ShareDetails, error) {
+	var shares []models.Share
+	if err := s.DB.Where("user_id = ?", userID).Order("id DESC").Find(&shares).Error; err != nil {
+		return nil, fmt.Errorf("fetching shares: %w", err)
+	}
+	details := make([]ShareDetails, 0, len(shares))
+	for i := range shares {
+		d, err := s.Details(&shares[i])
+		if err != nil {
+			return nil, err
+		}
+		details = append(details, *d)
+	}
+	return details, nil
+}
+
+// Details loads the owner and resource title of a share.
+func (s *ShareService) Details(share *models.Share) (*ShareDetails, error) {
+	var owner models.User
+	if err := s.DB.Select("id", "name", "first_name", "last_name", "email").First(&owner, share.OwnerID).Error; err != nil {
+		return nil, fmt.Errorf("loading owner of share %d: %w", share.ID, err)
+	}
+	var row struct {
+		Title    string
+		Fallback string
+	}
+	var columns []string
+	switch share.ResourceType {
+	case models.ResourceTypeNote:
+		columns = []string{"title", "'' AS fallback"}
+	case models.ResourceTypeLink, models.ResourceTypeImage:
+		columns = []string{"title", "url AS fallback"}
+	case models.ResourceTypeFile:
+		columns = []string{"title", "original_name AS fallback"}
+	case models.ShareResourceLabel:
+		columns = []string{"name AS title", "'' AS fallback"}
+	}
+	if model := shareableModel(share.ResourceType); model != nil {
+		if err := s.DB.Model(model).Select(columns).Where("id = ?", share.ResourceID).Scan(&row).Error; err != nil {
+			return nil, fmt.Errorf("loading %s %d: %w", share.ResourceType, share.ResourceID, err)
+		}
+	}
+	title := row.Title
+	if title == "" {
+		title = row.Fallback
+	}
+
+	return &ShareDetails{Share: *share, OwnerName: owner.DisplayName(), OwnerEmail: owner.Email, Title: title}, nil
+}
+
+// Get returns a share by ID.
+func (s *ShareService) Get(id uint) (*models.Share, error) {
+	var share models.Share
+	if err := s.DB.First(&share, id).Error; err != nil {
+		return nil, fmt.Errorf("share not found: %w", err)
+	}
+	return &share, nil
+}
+
+// SetPermission changes the permission of one of the owner's shares.
+func (s *ShareService) SetPermission(id, ownerID uint, permission string) (*models.Share, error) {
+	if permission != models.SharePermissionView && permission != models.SharePermissionEdit {
+		return nil, ErrInvalidPermission
+	}
+	var share models.Share
+	if err := s.DB.Where("id = ? AND owner_id = ?", id, ownerID).First(&share).Error; err != nil {
+		return nil, fmt.Errorf("share not found: %w", err)
+	}
+	if err := s.DB.Model(&share).Update("permission", permission).Error; err != nil {
+		return nil, fmt.Errorf("updating share: %w", err)
+	}
+	return &share, nil
+}
+
+// Revoke deletes a share. The owner revokes it; the user it was shared
+// with leaves it.
+func (s *ShareService) Revoke(id, userID uint) error {
+	result := s.DB.Where("id = ? AND (owner_id = ? OR user_id = ?)", id, userID, userID).Delete(&models.Share{})
+	if result.Error != nil {
+		return fmt.Errorf("deleting share: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("share not found: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// shareableModel returns the model of a resource type that can be shared, or nil.
+func shareableModel(resourceType string) interface{} {
+	if resourceType == models.ShareResourceLabel {
+		return &models.Label{}
+	}
+	return resourceModel(resourceType)
+}
+
+// accessible scopes a query on a resource table to the resources a user
+// may open with permission: their own, and those outside the trash shared
+// with them, directly or through a shared label. Edit access includes view.
+func accessible(resourceType string, userID uint, permission string) func(*gorm.DB) *gorm.DB {
+	var table, labelTable, labelColumn string
+	switch resourceType {
+	case models.ResourceTypeNote:
+		table, labelTable, labelColumn = "notes", "note_labels", "note_id"
+	case models.ResourceTypeLink:
+		table, labelTable, labelColumn = "links", "link_labels", "link_id"
+	case models.ResourceTypeImage:
+		table, labelTable, labelColumn = "images", "image_labels", "image_id"
+	case models.ResourceTypeFile:
+		table, labelTable, labelColumn = "files", "file_labels", "file_id"
+	}
+	permissions := []string{models.SharePermissionView, models.SharePermissionEdit}
+	if permission == models.SharePermissionEdit {
+		permissions = []string{models.SharePermissionEdit}
+	}
+
+	sql := fmt.Sprintf(`(%[1]s.user_id = ? OR (%[1]s.is_trashed = ? AND (
+		%[1]s.id IN (SELECT resource_id FROM shares WHERE resource_type = ? AND user_id = ? AND permission IN ?)
+		OR %[1]s.id IN (SELECT %[3]s FROM %[2]s WHERE label_id IN (
+			SELECT resource_id FROM shares WHERE resource_type = ? AND user_id = ? AND permission IN ?)))))`,
+		table, labelTable, labelColumn)
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(sql, userID, false,
+			resourceType, userID, permissions,
+			models.ShareResourceLabel, userID, permissions)
+	}
+}
+
+// accessibleDocuments scopes a query on search_documents to the entries of
+// the resources a user may view, as accessible does for the resource tables:
+// their own, and those outside the trash shared with them, directly or
+// through a shared label. Index entries carry their resource's owner and
+// trash state, so no resource table is joined.
+func accessibleDocuments(userID uint) func(*gorm.DB) *gorm.DB {
+	sql := `(search_documents.user_id = ? OR (search_documents.is_trashed = ? AND (
+		EXISTS (SELECT 1 FROM shares WHERE shares.resource_type = search_documents.resource_type
+			AND shares.resource_id = search_documents.resource_id AND shares.user_id = ?)`
+	args := []interface{}{userID, false, userID}
+	for _, labels := range []struct{ resourceType, table, column string }{
+		{models.ResourceTypeNote, "note_labels", "note_id"},
+		{models.ResourceTypeLink, "link_labels", "link_id"},
+		{models.ResourceTypeImage, "image_labels", "image_id"},
+		{models.ResourceTypeFile, "file_labels", "file_id"},
+	} {
+		sql += fmt.Sprintf(`
+		OR (search_documents.resource_type = ? AND search_documents.resource_id IN (SELECT %[2]s FROM %[1]s WHERE label_id IN (
+			SELECT resource_id FROM shares WHERE resource_type = ? AND user_id = ?)))`, labels.table, labels.column)
+		args = append(args, labels.resourceType, models.ShareResourceLabel, userID)
+	}
+	sql += `)))`
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(sql, args...)
+	}
+}
+
+// forgetShares deletes the shares of a deleted resource and logs failures
+// instead of returning them.
+func forgetShares(db *gorm.DB, resourceType string, id uint) {
+	if err := db.Where("resource_type = ? AND resource_id = ?", resourceType, id).Delete(&models.Share{}).Error; err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
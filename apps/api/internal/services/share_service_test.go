@@ -0,0 +1,75 @@
+package services
+
+import (
+	"sort"
+	"testing"
+
+	"desis-keep/apps/api/internal/models"
+)
+
+const (
+	sharesTable = `CREATE TABLE shares (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		owner_id INTEGER NOT NULL, resource_type TEXT NOT NULL, resource_id INTEGER NOT NULL,
+		email TEXT NOT NULL, user_id INTEGER, permission TEXT NOT NULL DEFAULT 'view',
+		created_at DATETIME, updated_at DATETIME)`
+	noteLabelsTable  = `CREATE TABLE note_labels (note_id INTEGER, label_id INTEGER)`
+	linkLabelsTable  = `CREATE TABLE link_labels (link_id INTEGER, label_id INTEGER)`
+	imageLabelsTable = `CREATE TABLE image_labels (image_id INTEGER, label_id INTEGER)`
+	fileLabelsTable  = `CREATE TABLE file_labels (file_id INTEGER, label_id INTEGER)`
+)
+
+func TestAccessibleDocuments(t *testing.T) {
+	db := testDB(t, searchDocumentsTable, sharesTable, noteLabelsTable, linkLabelsTable, imageLabelsTable, fileLabelsTable)
+	const owner, sharee = 1, 2
+
+	docs := []models.SearchDocument{
+		{ResourceType: models.ResourceTypeNote, ResourceID: 1, UserID: sharee, Title: "own note"},
+		{ResourceType: models.ResourceTypeNote, ResourceID: 2, UserID: owner, Title: "shared note"},
+		{ResourceType: models.ResourceTypeLink, ResourceID: 2, UserID: owner, Title: "link with the note's id"},
+		{ResourceType: models.ResourceTypeFile, ResourceID: 3, UserID: owner, Title: "file with a shared label"},
+		{ResourceType: models.ResourceTypeNote, ResourceID: 3, UserID: owner, Title: "note with the file's id"},
+		{ResourceType: models.ResourceTypeLink, ResourceID: 4, UserID: owner, Title: "trashed shared link", IsTrashed: true},
+		{ResourceType: models.ResourceTypeImage, ResourceID: 5, UserID: owner, Title: "private image"},
+		{ResourceType: models.ResourceTypeImage, ResourceID: 6, UserID: 3, Title: "image shared with someone else"},
+	}
+	if err := db.Create(&docs).Error; err != nil {
+		t.Fatalf("creating index entries: %v", err)
+	}
+	grantee, other := uint(sharee), uint(3)
+	shares := []models.Share{
+		{OwnerID: owner, ResourceType: models.ResourceTypeNote, ResourceID: 2, Email: "b@example.com", UserID: &grantee, Permission: models.SharePermissionView},
+		{OwnerID: owner, ResourceType: models.ShareResourceLabel, ResourceID: 10, Email: "b@example.com", UserID: &grantee, Permission: models.SharePermissionEdit},
+		{OwnerID: owner, ResourceType: models.ResourceTypeLink, ResourceID: 4, Email: "b@example.com", UserID: &grantee, Permission: models.SharePermissionView},
+		{OwnerID: 3, ResourceType: models.ResourceTypeImage, ResourceID: 6, Email: "c@example.com", UserID: &other, Permission: models.SharePermissionView},
+	}
+	if err := db.Create(&shares).Error; err != nil {
+		t.Fatalf("creating shares: %v", err)
+	}
+	if err := db.Exec("INSERT INTO file_labels (file_id, label_id) VALUES (3, 10)").Error; err != nil {
+		t.Fatalf("labelling file: %v", err)
+	}
+
+	var titles []string
+	if err := db.Model(&models.SearchDocument{}).Scopes(accessibleDocuments(sharee)).Pluck("title", &titles).Error; err != nil {
+		t.Fatalf("querying: %v", err)
+	}
+	sort.Strings(titles)
+	want := []string{"file with a shared label", "own note", "shared note"}
+	if len(titles) != len(want) {
+		t.Fatalf("sharee sees %q, want %q", titles, want)
+	}
+	for i := range want {
+		if titles[i] != want[i] {
+			t.Fatalf("sharee sees %q, want %q", titles, want)
+		}
+	}
+
+	var count int64
+	if err := db.Model(&models.SearchDocument{}).Scopes(accessibleDocuments(owner)).Count(&count).Error; err != nil {
+		t.Fatalf("counting: %v", err)
+	}
+	if count != 6 {
+		t.Errorf("owner sees %d entries, want their 6, trashed included", count)
+	}
+}
@@ -0,0 +1,393 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/ai"
+	"desis-keep/apps/api/internal/models"
+)
+
+const (
+	// enrichAttempts is how many times a malformed AI response is retried.
+	enrichAttempts = 3
+	// enrichInputChars caps the resource text sent for summarization.
+	enrichInputChars = 6000
+	// maxSummaryChars caps the stored summary.
+	maxSummaryChars = 500
+	// maxLabelSuggestions caps the labels suggested per resource.
+	maxLabelSuggestions = 5
+)
+
+// EnrichedResourceTypes lists the resource types that get AI summaries and label suggestions.
+var EnrichedResourceTypes = []string{models.ResourceTypeNote, models.ResourceTypeLink}
+
+// ErrSuggestionResolved is returned when accepting or rejecting a suggestion that is no longer pending.
+var ErrSuggestionResolved = errors.New("suggestion already resolved")
+
+// SuggestionService generates AI summaries and label suggestions and lets users resolve them.
+// When Usage is set, enrichment is metered against the resource owner and
+// skipped once they exceed their monthly quota.
+type SuggestionService struct {
+	DB     *gorm.DB
+	AI     *ai.AI
+	Search *SearchService
+	Usage  *UsageService
+}
+
+// NewSuggestionService creates a new SuggestionService instance.
+func NewSuggestionService(db *gorm.DB, aiService *ai.AI) *SuggestionService {
+	return &SuggestionService{DB: db, AI: aiService, Search: NewSearchService(db)}
+}
+
+// enrichment is the JSON object the model is asked to return.
+type enrichment struct {
+	Summary string   `json:"summary"`
+	Labels  []string `json:"labels"`
+}
+
+// Enrich asks the AI for a summary and label suggestions for a resource.
+// The summary is stored on the resource; labels become pending suggestions,
+// replacing earlier pending ones. Labels the user already has on the resource
+// or has rejected before are not suggested again. It is a no-op when the text
+// is unchanged since the last enrichment.
+func (s *SuggestionService) Enrich(ctx context.Context, resourceType string, id uint) error {
+	if !slices.Contains(EnrichedResourceTypes, resourceType) {
+		return nil
+	}
+
+	var doc models.SearchDocument
+	err := s.DB.Where("resource_type = ? AND resource_id = ?", resourceType, id).First(&doc).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("loading index entry for %s %d: %w", resourceType, id, err)
+	}
+	if strings.TrimSpace(joinText(doc.Title, doc.Body)) == "" {
+		return nil
+	}
+
+	table := map[string]interface{}{
+		models.ResourceTypeNote: &models.Note{},
+		models.ResourceTypeLink: &models.Link{},
+	}[resourceType]
+	sum := sha256.Sum256([]byte(joinText(doc.Title, doc.URL, doc.Body)))
+	hash := hex.EncodeToString(sum[:])
+
+	var enriched []string
+	if err := s.DB.Model(table).Where("id = ?", id).Pluck("summary_hash", &enriched).Error; err != nil {
+		return fmt.Errorf("loading %s %d: %w", resourceType, id, err)
+	}
+	if len(enriched) == 1 && enriched[0] == hash {
+		return nil
+	}
+
+	if s.Usage != nil {
+		if _, err := s.Usage.Check(doc.UserID); errors.Is(err, ErrQuotaExceeded) {
+			// Enrichment is optional; it resumes when the quota resets
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
+	var labels []models.Label
+	if err := s.DB.Where("user_id = ?", doc.UserID).Order("name").Find(&labels).Error; err != nil {
+		return fmt.Errorf("loading labels: %w", err)
+	}
+
+	result, err := s.complete(ctx, resourceType, doc, labels)
+	if err != nil {
+		return err
+	}
+
+	// UpdateColumns keeps updated_at unchanged: a summary is not a user edit
+	if err := s.DB.Model(table).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"summary":      result.Summary,
+		"summary_hash": hash,
+	}).Error; err != nil {
+		return fmt.Errorf("saving summary for %s %d: %w", resourceType, id, err)
+	}
+
+	return s.replaceSuggestions(doc, labels, result.Labels)
+}
+
+// complete prompts the model and parses its JSON answer, retrying with a
+// corrective message when the response is malformed.
+func (s *SuggestionService) complete(ctx context.Context, resourceType string, doc models.SearchDocument, labels []models.Label) (*enrichment, error) {
+	names := make([]string, len(labels))
+	for i, l := range labels {
+		names[i] = l.Name
+	}
+	existing, _ := json.Marshal(names)
+
+	body := doc.Body
+	if runes := []rune(body); len(runes) > enrichInputChars {
+		body = string(runes[:enrichInputChars])
+	}
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Summarize the following %s in one or two sentences (at most 280 characters) and suggest up to 3 labels for it.\n", resourceType)
+	fmt.Fprintf(&prompt, "The user's existing labels are: %s. Prefer these; propose a new short label only when none fit.\n", existing)
+	prompt.WriteString(`Respond with only a JSON object of the form {"summary": "...", "labels": ["..."]} and no other text.` + "\n\n")
+	fmt.Fprintf(&prompt, "Title: %s\n", doc.Title)
+	if doc.URL != "" {
+		fmt.Fprintf(&prompt, "URL: %s\n", doc.URL)
+	}
+	fmt.Fprintf(&prompt, "Content:\n%s", body)
+
+	messages := []ai.Message{{Role: "user", Content: prompt.String()}}
+	var lastErr error
+	for attempt := 0; attempt < enrichAttempts; attempt++ {
+		resp, err := s.AI.Complete(ctx, ai.CompletionRequest{Messages: messages, MaxTokens: 400})
+		if err != nil {
+			return nil, fmt.Errorf("enriching %s %d: %w", resourceType, doc.ResourceID, err)
+		}
+		if s.Usage != nil {
+			if err := s.Usage.Record(doc.UserID, "job:ai:enrich", s.AI.Provider(), resp, nil); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+
+		result, err := parseEnrichment(resp.Content)
+		if err == nil {
+			return result, nil
+		}
+		lastErr = err
+		messages = append(messages,
+			ai.Message{Role: "assistant", Content: resp.Content},
+			ai.Message{Role: "user", Content: fmt.Sprintf(`That response was invalid (%v). Reply with only the JSON object {"summary": "...", "labels": ["..."]}.`, err)},
+		)
+	}
+	return nil, fmt.Errorf("enriching %s %d: malformed AI response after %d attempts: %w", resourceType, doc.ResourceID, enrichAttempts, lastErr)
+}
+
+// parseEnrichment extracts and validates the JSON object in a model response,
+// tolerating code fences and surrounding prose.
+func parseEnrichment(content string) (*enrichment, error) {
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON object found")
+	}
+
+	var result enrichment
+	if err := json.Unmarshal([]byte(content[start:end+1]), &result); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	result.Summary = strings.TrimSpace(result.Summary)
+	if result.Summary == "" {
+		return nil, fmt.Errorf("summary is empty")
+	}
+	if runes := []rune(result.Summary); len(runes) > maxSummaryChars {
+		result.Summary = string(runes[:maxSummaryChars])
+	}
+
+	seen := map[string]bool{}
+	labels := []string{}
+	for _, l := range result.Labels {
+		l = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(l), "#"))
+		if l == "" || len(l) > 50 || seen[strings.ToLower(l)] {
+			continue
+		}
+		seen[strings.ToLower(l)] = true
+		labels = append(labels, l)
+		if len(labels) == maxLabelSuggestions {
+			break
+		}
+	}
+	result.Labels = labels
+	return &result, nil
+}
+
+// replaceSuggestions swaps the resource's pending suggestions for the new names.
+func (s *SuggestionService) replaceSuggestions(doc models.SearchDocument, labels []models.Label, names []string) error {
+	byName := map[string]models.Label{}
+	for _, l := range labels {
+		byName[strings.ToLower(l.Name)] = l
+	}
+
+	resource, err := loadOwnedResource(s.DB, doc.ResourceType, doc.ResourceID, doc.UserID)
+	if err != nil {
+		return err
+	}
+	var current []models.Label
+	if err := s.DB.Model(resource).Association("Labels").Find(&current); err != nil {
+		return fmt.Errorf("loading applied labels: %w", err)
+	}
+	applied := map[string]bool{}
+	for _, l := range current {
+		applied[strings.ToLower(l.Name)] = true
+	}
+
+	var rejected []string
+	if err := s.DB.Model(&models.LabelSuggestion{}).
+		Where("resource_type = ? AND resource_id = ? AND status = ?", doc.ResourceType, doc.ResourceID, models.SuggestionRejected).
+		Pluck("LOWER(name)", &rejected).Error; err != nil {
+		return fmt.Errorf("loading rejected suggestions: %w", err)
+	}
+	skip := map[string]bool{}
+	for _, r := range rejected {
+		skip[r] = true
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("resource_type = ? AND resource_id = ? AND status = ?", doc.ResourceType, doc.ResourceID, models.SuggestionPending).
+			Delete(&models.LabelSuggestion{}).Error; err != nil {
+			return fmt.Errorf("clearing pending suggestions: %w", err)
+		}
+
+		for _, name := range names {
+			key := strings.ToLower(name)
+			if skip[key] || applied[key] {
+				continue
+			}
+			suggestion := models.LabelSuggestion{
+				UserID:       doc.UserID,
+				ResourceType: doc.ResourceType,
+				ResourceID:   doc.ResourceID,
+				Name:         name,
+				Status:       models.SuggestionPending,
+			}
+			if label, ok := byName[key]; ok {
+				suggestion.LabelID = &label.ID
+				suggestion.Name = label.Name
+			}
+			if err := tx.Create(&suggestion).Error; err != nil {
+				return fmt.Errorf("saving suggestion: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// List returns a user's suggestions, optionally filtered by resource and status.
+func (s *SuggestionService) List(userID uint, resourceType string, resourceID uint, status string) ([]models.LabelSuggestion, error) {
+	query := s.DB.Where("user_id = ?", userID).Preload("Label")
+	if resourceType != "" {
+		query = query.Where("resource_type = ?", resourceType)
+	}
+	if resourceID != 0 {
+		query = query.Where("resource_id = ?", resourceID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	suggestions := []models.LabelSuggestion{}
+	if err := query.Order("created_at DESC").Find(&suggestions).Error; err != nil {
+		return nil, fmt.Errorf("fetching suggestions: %w", err)
+	}
+	return suggestions, nil
+}
+
+// Accept applies a pending suggestion, creating the label first when it is new.
+func (s *SuggestionService) Accept(id, userID uint) (*models.LabelSuggestion, error) {
+	suggestion, err := s.pending(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		label, err := s.resolveLabel(tx, suggestion)
+		if err != nil {
+			return err
+		}
+
+		resource, err := loadOwnedResource(tx, suggestion.ResourceType, suggestion.ResourceID, userID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(resource).Association("Labels").Append(label); err != nil {
+			return fmt.Errorf("applying label: %w", err)
+		}
+
+		suggestion.LabelID = &label.ID
+		suggestion.Label = label
+		suggestion.Status = models.SuggestionAccepted
+		return tx.Model(suggestion).Updates(map[string]interface{}{
+			"label_id": label.ID,
+			"status":   models.SuggestionAccepted,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.Search.sync(suggestion.ResourceType, suggestion.ResourceID)
+	return suggestion, nil
+}
+
+// Reject marks a pending suggestion as rejected so it is not suggested again.
+func (s *SuggestionService) Reject(id, userID uint) (*models.LabelSuggestion, error) {
+	suggestion, err := s.pending(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.DB.Model(suggestion).Update("status", models.SuggestionRejected).Error; err != nil {
+		return nil, fmt.Errorf("rejecting suggestion: %w", err)
+	}
+	return suggestion, nil
+}
+
+// pending loads a user's suggestion and checks that it is still pending.
+func (s *SuggestionService) pending(id, userID uint) (*models.LabelSuggestion, error) {
+	var suggestion models.LabelSuggestion
+	if err := s.DB.Where("id = ? AND user_id = ?", id, userID).First(&suggestion).Error; err != nil {
+		return nil, fmt.Errorf("suggestion not found: %w", err)
+	}
+	if suggestion.Status != models.SuggestionPending {
+		return nil, ErrSuggestionResolved
+	}
+	return &suggestion, nil
+}
+
+// resolveLabel returns the suggested label, creating it (or restoring a deleted
+// label with the same slug) when the suggestion proposes a new one.
+func (s *SuggestionService) resolveLabel(tx *gorm.DB, suggestion *models.LabelSuggestion) (*models.Label, error) {
+	var label models.Label
+	if suggestion.LabelID != nil {
+		err := tx.Where("id = ? AND user_id = ?", *suggestion.LabelID, suggestion.UserID).First(&label).Error
+		if err == nil {
+			return &label, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("loading label: %w", err)
+		}
+		// The label was deleted since the suggestion was made; recreate it by name
+	}
+
+	return findOrCreateLabel(tx, suggestion.UserID, suggestion.Name)
+}
+
+// loadOwnedResource loads a labelable resource owned by the user.
+func loadOwnedResource(db *gorm.DB, resourceType string, id, userID uint) (interface{}, error) {
+	var resource interface{}
+	switch resourceType {
+	case models.ResourceTypeNote:
+		resource = &models.Note{}
+	case models.ResourceTypeLink:
+		resource = &models.Link{}
+	case models.ResourceTypeImage:
+		resource = &models.Image{}
+	case models.ResourceTypeFile:
+		resource = &models.File{}
+	default:
+		return nil, fmt.Errorf("unknown resource type %q", resourceType)
+	}
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(resource).Error; err != nil {
+		return nil, fmt.Errorf("%s not found: %w", resourceType, err)
+	}
+	return resource, nil
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"desis-keep/apps/api/internal/ai"
+	"desis-keep/apps/api/internal/models"
+)
+
+func TestEnrichSkipsUnchangedText(t *testing.T) {
+	db := testDB(t, searchDocumentsTable)
+	if err := db.AutoMigrate(&models.User{}, &models.Label{}, &models.Note{}, &models.LabelSuggestion{}); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	provider := ai.NewFakeProvider(
+		`{"summary": "Packing list for the trip.", "labels": ["travel"]}`,
+		`{"summary": "Packing list with boots.", "labels": ["travel", "hiking"]}`,
+	)
+	s := NewSuggestionService(db, ai.New(provider, nil))
+	ctx := context.Background()
+
+	note := models.Note{Title: "Trip", Body: "Pack light", UserID: 1}
+	if err := db.Create(&note).Error; err != nil {
+		t.Fatalf("creating note: %v", err)
+	}
+	doc := models.SearchDocument{ResourceType: models.ResourceTypeNote, ResourceID: note.ID, UserID: 1, Title: note.Title, Body: note.Body}
+	if err := db.Create(&doc).Error; err != nil {
+		t.Fatalf("creating index entry: %v", err)
+	}
+	summary := func() string {
+		t.Helper()
+		var n models.Note
+		if err := db.First(&n, note.ID).Error; err != nil {
+			t.Fatalf("loading note: %v", err)
+		}
+		return n.Summary
+	}
+
+	if err := s.Enrich(ctx, models.ResourceTypeNote, note.ID); err != nil {
+		t.Fatalf("Enrich: %v", err)
+	}
+	if len(provider.Requests) != 1 || summary() != "Packing list for the trip." {
+		t.Fatalf("after the first save: %d AI calls, summary %q", len(provider.Requests), summary())
+	}
+
+	// Same text: no AI call, the summary stays
+	if err := s.Enrich(ctx, models.ResourceTypeNote, note.ID); err != nil {
+		t.Fatalf("Enrich again: %v", err)
+	}
+	if len(provider.Requests) != 1 {
+		t.Errorf("re-enriching unchanged text called the AI %d times in all, want once", len(provider.Requests))
+	}
+
+	// Changed text: enriched again
+	db.Model(&doc).Update("body", "Pack light, bring boots")
+	if err := s.Enrich(ctx, models.ResourceTypeNote, note.ID); err != nil {
+		t.Fatalf("Enrich after change: %v", err)
+	}
+	if len(provider.Requests) != 2 || summary() != "Packing list with boots." {
+		t.Errorf("after a change: %d AI calls, summary %q; want 2 calls and the new summary", len(provider.Requests), summary())
+	}
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"desis-keep/apps/api/internal/ai"
+	"desis-keep/apps/api/internal/models"
+)
+
+// ErrQuotaExceeded is returned when a user has spent their monthly AI token allowance.
+var ErrQuotaExceeded = errors.New("monthly AI token quota exceeded")
+
+// UsageService meters AI token usage and enforces monthly per-user quotas.
+// Months are calendar months in UTC.
+type UsageService struct {
+	DB *gorm.DB
+	// DefaultLimit is the monthly token limit for users without an override. 0 means unlimited.
+	DefaultLimit int64
+}
+
+// NewUsageService creates a new UsageService instance.
+func NewUsageService(db *gorm.DB, defaultLimit int64) *UsageService {
+	return &UsageService{DB: db, DefaultLimit: defaultLimit}
+}
+
+// QuotaStatus describes a user's token usage for the current month.
+type QuotaStatus struct {
+	Used     int64     `json:"used"`
+	Limit    int64     `json:"limit"` // 0 means unlimited
+	ResetsAt time.Time `json:"resets_at"`
+}
+
+// UserUsage is one user's token totals for a month.
+type UserUsage struct {
+	UserID       uint   `json:"user_id"`
+	Email        string `json:"email"`
+	Name         string `json:"name"`
+	Requests     int64  `json:"requests"`
+	InputTokens  int64  `json:"input_tokens"`
+	OutputTokens int64  `json:"output_tokens"`
+	TotalTokens  int64  `json:"total_tokens"`
+	Limit        int64  `json:"limit"`
+}
+
+// UsageBreakdown is a user's token totals for one endpoint, provider and model.
+type UsageBreakdown struct {
+	Endpoint     string `json:"endpoint"`
+	Provider     string `json:"provider"`
+	Model        string `json:"model"`
+	Requests     int64  `json:"requests"`
+	InputTokens  int64  `json:"input_tokens"`
+	OutputTokens int64  `json:"output_tokens"`
+}
+
+// Check returns the user's usage for the current month. The error is
+// ErrQuotaExceeded, alongside the status, when the limit has been reached.
+func (s *UsageService) Check(userID uint) (*QuotaStatus, error) {
+	start, end := MonthBounds(time.Now())
+	limit, err := s.Limit(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var used int64
+	err = s.DB.Model(&models.AIUsage{}).
+		Where("user_id = ? AND created_at >= ? AND created_at < ?", userID, start, end).
+		Select("COALESCE(SUM(input_tokens + output_tokens), 0)").
+		Scan(&used).Error
+	if err != nil {
+		return nil, fmt.Errorf("summing AI usage: %w", err)
+	}
+
+	status := &QuotaStatus{Used: used, Limit: limit, ResetsAt: end}
+	if limit > 0 && used >= limit {
+		return status, ErrQuotaExceeded
+	}
+	return status, nil
+}
+
+// Record stores the tokens spent by one call; callErr is the error the call
+// returned, if any. Calls without a response are ignored.
+func (s *UsageService) Record(userID uint, endpoint, provider string, resp *ai.CompletionResponse, callErr error) error {
+	if resp == nil || resp.Usage == nil {
+		return nil
+	}
+	usage := models.AIUsage{
+		UserID:       userID,
+		Provider:     provider,
+		Model:        resp.Model,
+		Endpoint:     endpoint,
+		InputTokens:  resp.Usage.InputTokens,
+		OutputTokens: resp.Usage.OutputTokens,
+		IsError:      callErr != nil,
+	}
+	if err := s.DB.Create(&usage).Error; err != nil {
+		return fmt.Errorf("recording AI usage: %w", err)
+	}
+	return nil
+}
+
+// Limit returns the user's monthly token limit: their override, or the default.
+func (s *UsageService) Limit(userID uint) (int64, error) {
+	var quota models.AIQuota
+	err := s.DB.Where("user_id = ?", userID).First(&quota).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.DefaultLimit, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("loading AI quota: %w", err)
+	}
+	return quota.MonthlyTokenLimit, nil
+}
+
+// SetLimit overrides a user's monthly token limit. A nil limit removes the
+// override so the default applies again.
+func (s *UsageService) SetLimit(userID uint, limit *int64) error {
+	if limit == nil {
+		return s.DB.Where("user_id = ?", userID).Delete(&models.AIQuota{}).Error
+	}
+	quota := models.AIQuota{UserID: userID, MonthlyTokenLimit: *limit}
+	return s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"monthly_token_limit", "updated_at"}),
+	}).Create(&quota).Error
+}
+
+// Summary returns per-user totals for the month containing t, highest usage first.
+func (s *UsageService) Summary(t time.Time) ([]UserUsage, error) {
+	start, end := MonthBounds(t)
+	var rows []UserUsage
+	err := s.DB.Table("ai_usage").
+		Select(`ai_usage.user_id, users.email, users.name,
+			COUNT(*) AS requests,
+			SUM(ai_usage.input_tokens) AS input_tokens,
+			SUM(ai_usage.output_tokens) AS output_tokens,
+			SUM(ai_usage.input_tokens + ai_usage.output_tokens) AS total_tokens`).
+		Joins("LEFT JOIN users ON users.id = ai_usage.user_id").
+		Where("ai_usage.created_at >= ? AND ai_usage.created_at < ?", start, end).
+		Group("ai_usage.user_id, users.email, users.name").
+		Order("total_tokens DESC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("summarizing AI usage: %w", err)
+	}
+
+	var quotas []models.AIQuota
+	if err := s.DB.Find(&quotas).Error; err != nil {
+		return nil, fmt.Errorf("loading AI quotas: %w", err)
+	}
+	limits := make(map[uint]int64, len(quotas))
+	for _, q := range quotas {
+		limits[q.UserID] = q.MonthlyTokenLimit
+	}
+	for i := range rows {
+		rows[i].Limit = s.DefaultLimit
+		if limit, ok := limits[rows[i].UserID]; ok {
+			rows[i].Limit = limit
+		}
+	}
+	return rows, nil
+}
+
+// Breakdown returns a user's totals per endpoint, provider and model for the month containing t.
+func (s *UsageService) Breakdown(userID uint, t time.Time) ([]UsageBreakdown, error) {
+	start, end := MonthBounds(t)
+	var rows []UsageBreakdown
+	err := s.DB.Model(&models.AIUsage{}).
+		Select(`endpoint, provider, model,
+			COUNT(*) AS requests,
+			SUM(input_tokens) AS input_tokens,
+			SUM(output_tokens) AS output_tokens`).
+		Where("user_id = ? AND created_at >= ? AND created_at < ?", userID, start, end).
+		Group("endpoint, provider, model").
+		Order("endpoint, provider, model").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("breaking down AI usage: %w", err)
+	}
+	return rows, nil
+}
+
+// MonthBounds returns the start of the UTC calendar month containing t and the start of the next.
+func MonthBounds(t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"path"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+
+	"desis-keep/apps/api/internal/models"
+	"desis-keep/apps/api/internal/storage"
+	"desis-keep/apps/api/internal/vault"
+)
+
+// VaultService writes a user's notes, links and images as a Markdown vault
+// (see the vault package), either whole or as an incremental sync.
+type VaultService struct {
+	DB      *gorm.DB
+	Storage *storage.Storage
+}
+
+// NewVaultService creates a new VaultService instance.
+// store may be nil, in which case images are left out.
+func NewVaultService(db *gorm.DB, store *storage.Storage) *VaultService {
+	return &VaultService{DB: db, Storage: store}
+}
+
+// VaultSyncResult counts what a sync did.
+type VaultSyncResult struct {
+	Written     int `json:"written"`
+	Unchanged   int `json:"unchanged"`
+	Removed     int `json:"removed"`
+	Attachments int `json:"attachments"`
+}
+
+// Sync writes the user's vault to target. Notes in the trash are left out.
+// Images are copied first so notes can embed them by relative path. Notes
+// whose UpdatedAt is not newer than recorded in state, and whose file name
+// and embedded attachments did not change, are skipped; files of notes and
+// images that are gone are removed. state is updated to match; pass a new
+// state to write everything.
+func (s *VaultService) Sync(ctx context.Context, userID uint, target vault.Target, state *vault.State) (*VaultSyncResult, error) {
+	result := &VaultSyncResult{}
+
+	var notes []models.Note
+	err := s.DB.Where("user_id = ? AND is_trashed = ?", userID, false).
+		Preload("Labels").
+		Order("id ASC").
+		Find(&notes).Error
+	if err != nil {
+		return nil, fmt.Errorf("fetching notes: %w", err)
+	}
+
+	namer := vault.NewNamer()
+	entries := make([]vault.Note, len(notes))
+	paths := make([]string, len(notes))
+	current := map[string]bool{}
+	for i, n := range notes {
+		entries[i] = vault.Note{
+			ID:         n.ID,
+			Title:      n.Title,
+			Body:       n.Body,
+			Color:      n.Color,
+			Tags:       labelTags(n.Labels),
+			IsPinned:   n.IsPinned,
+			IsArchived: n.IsArchived,
+			CreatedAt:  n.CreatedAt,
+			UpdatedAt:  n.UpdatedAt,
+		}
+		paths[i] = namer.NotePath(entries[i])
+		current[paths[i]] = true
+	}
+
+	// Remove files of notes that are gone or moved first, since another
+	// note may take over a freed name
+	present := map[uint]bool{}
+	for _, n := range notes {
+		present[n.ID] = true
+	}
+	for id, prev := range state.Notes {
+		if present[id] && current[prev.Path] {
+			continue
+		}
+		if !current[prev.Path] {
+			if err := target.Remove(prev.Path); err != nil {
+				return nil, err
+			}
+			result.Removed++
+		}
+		if !present[id] {
+			delete(state.Notes, id)
+		}
+	}
+
+	attachments := vault.NewAttachments(nil)
+	if s.Storage != nil {
+		images, err := s.syncImages(ctx, userID, target, state, result)
+		if err != nil {
+			return nil, err
+		}
+		attachments = vault.NewAttachments(images)
+	}
+
+	for i, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var embeds []string
+		entry.Body, embeds = attachments.Embed(entry.Body, paths[i])
+		prev, ok := state.Notes[entry.ID]
+		if ok && prev.Path == paths[i] && !entry.UpdatedAt.After(prev.UpdatedAt) &&
+			slices.Equal(prev.Attachments, embeds) && target.Exists(paths[i]) {
+			result.Unchanged++
+			continue
+		}
+		var buf bytes.Buffer
+		if err := vault.WriteNote(&buf, entry); err != nil {
+			return nil, err
+		}
+		if err := target.Write(paths[i], entry.UpdatedAt, &buf); err != nil {
+			return nil, err
+		}
+		state.Notes[entry.ID] = vault.NoteState{Path: paths[i], UpdatedAt: entry.UpdatedAt, Attachments: embeds}
+		result.Written++
+	}
+
+	if err := s.syncLinks(userID, target, state); err != nil {
+		return nil, err
+	}
+
+	state.SyncedAt = time.Now().UTC()
+	return result, nil
+}
+
+// syncLinks rewrites the links index when its content changed.
+func (s *VaultService) syncLinks(userID uint, target vault.Target, state *vault.State) error {
+	var links []models.Link
+	err := s.DB.Where("user_id = ? AND is_trashed = ?", userID, false).
+		Preload("Labels").
+		Order("is_pinned DESC, created_at DESC").
+		Find(&links).Error
+	if err != nil {
+		return fmt.Errorf("fetching links: %w", err)
+	}
+
+	entries := make([]vault.Link, len(links))
+	for i, l := range links {
+		entries[i] = vault.Link{
+			URL:         l.URL,
+			Title:       l.Title,
+			Description: plainText(l.Description),
+			Tags:        labelTags(l.Labels),
+			IsArchived:  l.IsArchived,
+		}
+	}
+	var buf bytes.Buffer
+	if err := vault.WriteLinks(&buf, entries); err != nil {
+		return err
+	}
+	return writeIndex(target, state, vault.LinksFile, buf.Bytes())
+}
+
+// syncImages copies new images into the vault's attachments, removes those
+// of deleted images and rewrites the images index. An image that cannot be
+// downloaded is logged, left out and tried again on the next sync. It
+// returns the images that are in the vault.
+func (s *VaultService) syncImages(ctx context.Context, userID uint, target vault.Target, state *vault.State, result *VaultSyncResult) ([]vault.Image, error) {
+	var images []models.Image
+	err := s.DB.Where("user_id = ? AND is_trashed = ?", userID, false).
+		Order("created_at DESC").
+		Find(&images).Error
+	if err != nil {
+		return nil, fmt.Errorf("fetching images: %w", err)
+	}
+
+	present := map[uint]bool{}
+	for _, img := range images {
+		present[img.ID] = true
+	}
+	for id, p := range state.Attachments {
+		if !present[id] {
+			if err := target.Remove(p); err != nil {
+				return nil, err
+			}
+			delete(state.Attachments, id)
+			result.Removed++
+		}
+	}
+
+	entries := make([]vault.Image, 0, len(images))
+	for _, img := range images {
+		p := vault.AttachmentPath(img.ID, path.Base(img.StorageKey))
+		if state.Attachments[img.ID] != p || !target.Exists(p) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if err := s.copyAttachment(ctx, target, img, p); err != nil {
+				log.Printf("Warning: vault sync: %v", err)
+				continue
+			}
+			state.Attachments[img.ID] = p
+			result.Attachments++
+		}
+		entries = append(entries, vault.Image{ID: img.ID, Title: img.Title, URL: img.URL, Path: p})
+	}
+
+	var buf bytes.Buffer
+	if err := vault.WriteImages(&buf, entries); err != nil {
+		return nil, err
+	}
+	if err := writeIndex(target, state, vault.ImagesFile, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *VaultService) copyAttachment(ctx context.Context, target vault.Target, img models.Image, p string) error {
+	reader, err := s.Storage.Download(ctx, img.StorageKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return target.Write(p, img.CreatedAt, reader)
+}
+
+// writeIndex writes an index file unless it is already there with the same content.
+func writeIndex(target vault.Target, state *vault.State, name string, content []byte) error {
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	if state.Indexes[name] == hash && target.Exists(name) {
+		return nil
+	}
+	if err := target.Write(name, time.Now(), bytes.NewReader(content)); err != nil {
+		return err
+	}
+	state.Indexes[name] = hash
+	return nil
+}
+
+// labelTags turns labels into Obsidian tags.
+func labelTags(labels []models.Label) []string {
+	tags := make([]string, 0, len(labels))
+	for _, l := range labels {
+		if tag := vault.Tag(l.Name); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
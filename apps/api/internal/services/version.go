@@ -0,0 +1,30 @@
+package services
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrVersionConflict is returned when updating a resource from a copy that is
+// no longer current: someone changed it since the copy was read.
+var ErrVersionConflict = errors.New("resource was changed since it was read")
+
+// updateVersioned updates a resource loaded in model, provided it is still at
+// version, which bumps the version. A version of 0 updates whatever the
+// current version. It returns ErrVersionConflict when the version differs.
+func updateVersioned(tx *gorm.DB, model interface{}, version uint, data map[string]interface{}) error {
+	query := tx.Model(model).Omit(clause.Associations)
+	if version != 0 {
+		query = query.Where("version = ?", version)
+	}
+	result := query.Updates(data)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+// Package takeout reads data exported from Google services with Google Takeout.
+package takeout
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoKeepNotes is returned when an archive contains no Keep notes.
+var ErrNoKeepNotes = errors.New("no Google Keep notes found in archive")
+
+// keepColors maps Keep's color names to the palette used for notes. Colors
+// outside our palette keep Keep's own shade.
+var keepColors = map[string]string{
+	"DEFAULT":  "#ffffff",
+	"RED":      "#f28b82",
+	"ORANGE":   "#fbbc04",
+	"YELLOW":   "#fff475",
+	"GREEN":    "#ccff90",
+	"TEAL":     "#a7ffeb",
+	"BLUE":     "#cbf0f8",
+	"CERULEAN": "#aecbfa",
+	"PURPLE":   "#d7aefb",
+	"PINK":     "#fdcfe8",
+	"BROWN":    "#e6c9a8",
+	"GRAY":     "#e8eaed",
+}
+
+// KeepNote is one note of a Keep export, as written to its per-note JSON file.
+type KeepNote struct {
+	Title                   string           `json:"title"`
+	TextContent             string           `json:"textContent"`
+	ListContent             []KeepListItem   `json:"listContent"`
+	Color                   string           `json:"color"`
+	IsPinned                bool             `json:"isPinned"`
+	IsArchived              bool             `json:"isArchived"`
+	IsTrashed               bool             `json:"isTrashed"`
+	Labels                  []KeepLabel      `json:"labels"`
+	Attachments             []KeepAttachment `json:"attachments"`
+	Annotations             []KeepAnnotation `json:"annotations"`
+	CreatedTimestampUsec    int64            `json:"createdTimestampUsec"`
+	UserEditedTimestampUsec int64            `json:"userEditedTimestampUsec"`
+
+	// Path is the note's JSON file inside the archive.
+	Path string `json:"-"`
+}
+
+// KeepListItem is one checklist entry.
+type KeepListItem struct {
+	Text      string `json:"text"`
+	IsChecked bool   `json:"isChecked"`
+}
+
+// KeepLabel is a label attached to a note.
+type KeepLabel struct {
+	Name string `json:"name"`
+}
+
+// KeepAttachment is a file attached to a note, stored next to its JSON file.
+type KeepAttachment struct {
+	FilePath string `json:"filePath"`
+	MimeType string `json:"mimetype"`
+}
+
+// KeepAnnotation is a web link Keep detected in a note.
+type KeepAnnotation struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// KeepArchive is an opened Takeout zip containing Keep notes.
+type KeepArchive struct {
+	Notes  []KeepNote
+	Labels []string // from Labels.txt, including labels no note uses
+	files  map[string]*zip.File
+}
+
+// OpenKeep reads every Keep note in a Takeout archive. Notes are JSON files
+// in a "Keep" folder; JSON files that do not decode as notes are ignored.
+// Notes come back oldest first.
+func OpenKeep(r *zip.Reader) (*KeepArchive, error) {
+	archive := &KeepArchive{files: map[string]*zip.File{}}
+	for _, f := range r.File {
+		archive.files[f.Name] = f
+	}
+
+	for _, f := range r.File {
+		if !inKeepFolder(f.Name) {
+			continue
+		}
+		switch {
+		case strings.EqualFold(path.Base(f.Name), "Labels.txt"):
+			labels, err := readLabels(f)
+			if err != nil {
+				return nil, err
+			}
+			archive.Labels = labels
+		case strings.EqualFold(path.Ext(f.Name), ".json"):
+			note, err := readNote(f)
+			if err != nil {
+				return nil, err
+			}
+			if note != nil {
+				archive.Notes = append(archive.Notes, *note)
+			}
+		}
+	}
+
+	if len(archive.Notes) == 0 && len(archive.Labels) == 0 {
+		return nil, ErrNoKeepNotes
+	}
+	sort.SliceStable(archive.Notes, func(i, j int) bool {
+		return archive.Notes[i].CreatedTimestampUsec < archive.Notes[j].CreatedTimestampUsec
+	})
+	return archive, nil
+}
+
+// Attachment opens an attachment of note. Keep sometimes names a file .jpeg
+// in the JSON while storing it as .jpg, so both spellings are tried.
+func (a *KeepArchive) Attachment(note KeepNote, att KeepAttachment) (io.ReadCloser, int64, error) {
+	name := path.Join(path.Dir(note.Path), att.FilePath)
+	candidates := []string{name}
+	switch ext := path.Ext(name); strings.ToLower(ext) {
+	case ".jpeg":
+		candidates = append(candidates, strings.TrimSuffix(name, ext)+".jpg")
+	case ".jpg":
+		candidates = append(candidates, strings.TrimSuffix(name, ext)+".jpeg")
+	}
+	for _, candidate := range candidates {
+		if f, ok := a.files[candidate]; ok {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, 0, fmt.Errorf("opening %s: %w", candidate, err)
+			}
+			return rc, int64(f.UncompressedSize64), nil
+		}
+	}
+	return nil, 0, fmt.Errorf("attachment %s not found in archive", att.FilePath)
+}
+
+// ExternalID identifies the note across exports. Keep assigns no IDs, so the
+// creation time in microseconds stands in, or the file name when it is missing.
+func (n KeepNote) ExternalID() string {
+	if n.CreatedTimestampUsec > 0 {
+		return strconv.FormatInt(n.CreatedTimestampUsec, 10)
+	}
+	return path.Base(n.Path)
+}
+
+// ColorHex returns the note's color as a hex value from the notes palette.
+func (n KeepNote) ColorHex() string {
+	if hex, ok := keepColors[strings.ToUpper(n.Color)]; ok {
+		return hex
+	}
+	return keepColors["DEFAULT"]
+}
+
+// Body returns the note's text. Checklists become Markdown task lists, and
+// links Keep detected that the text does not already contain are appended.
+func (n KeepNote) Body() string {
+	var b strings.Builder
+	b.WriteString(n.TextContent)
+	for _, item := range n.ListContent {
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		if item.IsChecked {
+			b.WriteString("- [x] ")
+		} else {
+			b.WriteString("- [ ] ")
+		}
+		b.WriteString(item.Text)
+	}
+	for _, a := range n.Annotations {
+		if a.URL == "" || strings.Contains(b.String(), a.URL) {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(a.URL)
+	}
+	return b.String()
+}
+
+// CreatedAt returns when the note was created, or the zero time when unknown.
+func (n KeepNote) CreatedAt() time.Time {
+	return usec(n.CreatedTimestampUsec)
+}
+
+// EditedAt returns when the note was last edited, or the zero time when unknown.
+func (n KeepNote) EditedAt() time.Time {
+	return usec(n.UserEditedTimestampUsec)
+}
+
+func usec(v int64) time.Time {
+	if v <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMicro(v)
+}
+
+// inKeepFolder reports whether an archive path sits in a "Keep" folder, as in
+// Takeout/Keep/note.json, or at the top level of an archive of that folder.
+func inKeepFolder(name string) bool {
+	dir := path.Dir(name)
+	return dir == "." || strings.EqualFold(path.Base(dir), "Keep")
+}
+
+// readNote decodes a note file, returning nil for JSON that is not a Keep note.
+func readNote(f *zip.File) (*KeepNote, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	var note KeepNote
+	if err := json.NewDecoder(rc).Decode(&note); err != nil {
+		return nil, nil
+	}
+	if note.CreatedTimestampUsec == 0 && note.UserEditedTimestampUsec == 0 {
+		return nil, nil
+	}
+	note.Path = f.Name
+	return &note, nil
+}
+
+// readLabels reads Labels.txt, one label per line.
+func readLabels(f *zip.File) ([]string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", f.Name, err)
+	}
+	var labels []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(strings.TrimPrefix(line, "\ufeff")); line != "" {
+			labels = append(labels, line)
+		}
+	}
+	return labels, nil
+}
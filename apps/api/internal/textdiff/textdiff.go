@@ -0,0 +1,255 @@
+// Package textdiff compares two texts line by line and renders the result
+// as a unified diff.
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op says what a line of a diff does.
+type Op int
+
+// Line operations.
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// maxEdits bounds the work spent looking for a shortest diff. Texts further
+// apart than this are diffed as their remaining lines removed and added.
+const maxEdits = 2000
+
+// Line is one line of a diff.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Diff is the line-by-line difference between two texts.
+type Diff struct {
+	Lines []Line
+}
+
+// Compute returns the shortest line diff that turns a into b.
+func Compute(a, b string) Diff {
+	return CompareLines(splitLines(a), splitLines(b))
+}
+
+// CompareLines returns the shortest diff that turns lines al into lines bl,
+// for callers that split texts themselves.
+func CompareLines(al, bl []string) Diff {
+	prefix := 0
+	for prefix < len(al) && prefix < len(bl) && al[prefix] == bl[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(al)-prefix && suffix < len(bl)-prefix &&
+		al[len(al)-1-suffix] == bl[len(bl)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]Line, 0, len(al)+len(bl)-prefix-suffix)
+	for _, text := range al[:prefix] {
+		lines = append(lines, Line{Equal, text})
+	}
+	lines = append(lines, myers(al[prefix:len(al)-suffix], bl[prefix:len(bl)-suffix])...)
+	for _, text := range al[len(al)-suffix:] {
+		lines = append(lines, Line{Equal, text})
+	}
+	return Diff{Lines: lines}
+}
+
+// Stats returns how many lines the diff adds and removes.
+func (d Diff) Stats() (added, removed int) {
+	for _, l := range d.Lines {
+		switch l.Op {
+		case Insert:
+			added++
+		case Delete:
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// Unified renders the diff in unified format with context lines around each
+// change, headed by the names of the two texts. It is empty when the texts
+// are equal.
+func (d Diff) Unified(fromName, toName string, context int) string {
+	var changes []int
+	for i, l := range d.Lines {
+		if l.Op != Equal {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+
+	// Line numbers, in each text, of every diff line's position
+	aLine := make([]int, len(d.Lines)+1)
+	bLine := make([]int, len(d.Lines)+1)
+	for i, l := range d.Lines {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if l.Op != Insert {
+			aLine[i+1]++
+		}
+		if l.Op != Delete {
+			bLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(changes); {
+		// Changes closer than twice the context share a hunk
+		j := i
+		for j+1 < len(changes) && changes[j+1]-changes[j] <= 2*context+1 {
+			j++
+		}
+		start := changes[i] - context
+		if start < 0 {
+			start = 0
+		}
+		end := changes[j] + context + 1
+		if end > len(d.Lines) {
+			end = len(d.Lines)
+		}
+
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n",
+			hunkRange(aLine[start], aLine[end]-aLine[start]),
+			hunkRange(bLine[start], bLine[end]-bLine[start]))
+		for _, l := range d.Lines[start:end] {
+			switch l.Op {
+			case Equal:
+				sb.WriteByte(' ')
+			case Delete:
+				sb.WriteByte('-')
+			case Insert:
+				sb.WriteByte('+')
+			}
+			sb.WriteString(l.Text)
+			sb.WriteByte('\n')
+		}
+		i = j + 1
+	}
+	return sb.String()
+}
+
+// hunkRange formats one side of a hunk header. before is the number of
+// lines of that text ahead of the hunk.
+func hunkRange(before, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	default:
+		return fmt.Sprintf("%d,%d", before+1, count)
+	}
+}
+
+// splitLines splits text into lines, ignoring a final newline.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// myers finds a shortest edit script from a to b with Myers' O(ND)
+// algorithm, keeping the furthest-reaching path of every round to trace the
+// script back.
+func myers(a, b []string) []Line {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replace(a, b)
+	}
+
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+	edits := -1
+	for d := 0; d <= n+m && d <= maxEdits; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				edits = d
+				break
+			}
+		}
+		if edits >= 0 {
+			break
+		}
+	}
+	if edits < 0 {
+		return replace(a, b)
+	}
+
+	// Walk back from the end, collecting lines in reverse
+	var rev []Line
+	x, y := n, m
+	for d := edits; d > 0; d-- {
+		prev := trace[d] // v before round d, indexed from -d
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && prev[k-1+d] < prev[k+1+d]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev[prevK+d]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			rev = append(rev, Line{Equal, a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			rev = append(rev, Line{Insert, b[y-1]})
+			y--
+		} else {
+			rev = append(rev, Line{Delete, a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		rev = append(rev, Line{Equal, a[x-1]})
+		x--
+		y--
+	}
+
+	lines := make([]Line, len(rev))
+	for i, l := range rev {
+		lines[len(rev)-1-i] = l
+	}
+	return lines
+}
+
+// replace is the diff that removes every line of a and adds every line of b.
+func replace(a, b []string) []Line {
+	lines := make([]Line, 0, len(a)+len(b))
+	for _, text := range a {
+		lines = append(lines, Line{Delete, text})
+	}
+	for _, text := range b {
+		lines = append(lines, Line{Insert, text})
+	}
+	return lines
+}
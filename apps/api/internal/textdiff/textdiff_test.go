@@ -0,0 +1,134 @@
+package textdiff
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Line
+	}{
+		{"equal", "a\nb\n", "a\nb", []Line{{Equal, "a"}, {Equal, "b"}}},
+		{"both empty", "", "", []Line{}},
+		{"from empty", "", "a\nb", []Line{{Insert, "a"}, {Insert, "b"}}},
+		{"to empty", "a\nb", "", []Line{{Delete, "a"}, {Delete, "b"}}},
+		{"insert in the middle", "a\nc", "a\nb\nc", []Line{{Equal, "a"}, {Insert, "b"}, {Equal, "c"}}},
+		{"delete in the middle", "a\nb\nc", "a\nc", []Line{{Equal, "a"}, {Delete, "b"}, {Equal, "c"}}},
+		{"change", "a\nb\nc", "a\nB\nc", []Line{{Equal, "a"}, {Delete, "b"}, {Insert, "B"}, {Equal, "c"}}},
+		{"CRLF", "a\r\nb\r\n", "a\nb\n", []Line{{Equal, "a"}, {Equal, "b"}}},
+		{"moved line", "a\nb\nc", "b\nc\na", []Line{{Delete, "a"}, {Equal, "b"}, {Equal, "c"}, {Insert, "a"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Compute(tt.a, tt.b).Lines; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compute(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+// sides rebuilds the two texts a diff compares.
+func sides(d Diff) (a, b []string) {
+	for _, l := range d.Lines {
+		if l.Op != Insert {
+			a = append(a, l.Text)
+		}
+		if l.Op != Delete {
+			b = append(b, l.Text)
+		}
+	}
+	return a, b
+}
+
+func TestCompareLinesRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"a", "b", "c", "d"}
+	random := func() []string {
+		lines := make([]string, rng.Intn(12))
+		for i := range lines {
+			lines[i] = words[rng.Intn(len(words))]
+		}
+		return lines
+	}
+	for i := 0; i < 2000; i++ {
+		al, bl := random(), random()
+		d := CompareLines(al, bl)
+		a, b := sides(d)
+		if strings.Join(a, "\n") != strings.Join(al, "\n") || strings.Join(b, "\n") != strings.Join(bl, "\n") {
+			t.Fatalf("CompareLines(%q, %q) = %v does not turn one into the other", al, bl, d.Lines)
+		}
+		// A shortest diff keeps a longest common subsequence
+		added, removed := d.Stats()
+		if lcs := len(al) - removed; lcs != longestCommon(al, bl) || len(bl)-added != lcs {
+			t.Fatalf("CompareLines(%q, %q) = %v is not a shortest diff", al, bl, d.Lines)
+		}
+	}
+}
+
+// longestCommon returns the length of the longest common subsequence of a
+// and b.
+func longestCommon(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] > cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func TestCompareLinesTooFarApart(t *testing.T) {
+	al := make([]string, maxEdits+10)
+	bl := make([]string, maxEdits+10)
+	for i := range al {
+		al[i] = fmt.Sprintf("a%d", i)
+		bl[i] = fmt.Sprintf("b%d", i)
+	}
+	d := CompareLines(al, bl)
+	if added, removed := d.Stats(); added != len(bl) || removed != len(al) {
+		t.Errorf("Stats = +%d -%d, want every line removed and added", added, removed)
+	}
+	if a, b := sides(d); !reflect.DeepEqual(a, al) || !reflect.DeepEqual(b, bl) {
+		t.Error("diff does not turn one text into the other")
+	}
+}
+
+func TestUnified(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n"
+	want := `--- old
++++ new
+@@ -2,3 +2,3 @@
+ 2
+-3
++three
+ 4
+@@ -10 +10,2 @@
+ 10
++11
+`
+	if got := Compute(a, b).Unified("old", "new", 1); got != want {
+		t.Errorf("Unified =\n%s\nwant\n%s", got, want)
+	}
+	if got := Compute(a, a).Unified("old", "new", 3); got != "" {
+		t.Errorf("Unified of equal texts = %q, want empty", got)
+	}
+	if got := Compute("", "x").Unified("old", "new", 3); got != "--- old\n+++ new\n@@ -0,0 +1 @@\n+x\n" {
+		t.Errorf("Unified from empty = %q", got)
+	}
+}
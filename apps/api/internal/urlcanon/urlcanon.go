@@ -0,0 +1,135 @@
+// Package urlcanon normalizes URLs so that addresses of the same page compare equal.
+package urlcanon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// ErrInvalidURL is returned for input that is not an absolute http(s) URL.
+var ErrInvalidURL = errors.New("not an absolute http(s) URL")
+
+// trackingParams are query parameters that identify a campaign or click rather than content.
+var trackingParams = map[string]bool{
+	"fbclid":   true,
+	"gclid":    true,
+	"dclid":    true,
+	"gbraid":   true,
+	"wbraid":   true,
+	"msclkid":  true,
+	"yclid":    true,
+	"twclid":   true,
+	"igshid":   true,
+	"mc_cid":   true,
+	"mc_eid":   true,
+	"_hsenc":   true,
+	"_hsmi":    true,
+	"mkt_tok":  true,
+	"oly_anon": true,
+	"oly_enc":  true,
+	"vero_id":  true,
+	"ref_src":  true,
+	"ref_url":  true,
+}
+
+// trackingPrefixes match families of tracking parameters such as utm_source.
+var trackingPrefixes = []string{"utm_", "pk_", "mtm_"}
+
+// Canonicalize returns a normalized form of rawURL for duplicate detection.
+// The result is a comparison key, not necessarily a fetchable address:
+//
+//   - http and https are treated as the same scheme (https)
+//   - the host is lowercased, converted to ASCII and stripped of "www." and default ports
+//   - dot segments and trailing slashes are removed from the path
+//   - tracking parameters (utm_*, fbclid, gclid, ...) are dropped and the rest sorted
+//   - the fragment is dropped
+//   - userinfo is dropped
+func Canonicalize(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if (scheme != "http" && scheme != "https") || u.Host == "" {
+		return "", ErrInvalidURL
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+		host = ascii
+	}
+	host = strings.TrimPrefix(host, "www.")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6 literal
+	}
+
+	p := u.Path
+	if p != "" {
+		p = path.Clean("/" + p)
+	}
+	p = strings.TrimRight(p, "/")
+
+	out := url.URL{
+		Scheme:   "https",
+		Host:     host,
+		Path:     p,
+		RawQuery: canonicalQuery(u.Query()),
+	}
+	return out.String(), nil
+}
+
+// canonicalQuery drops tracking parameters and encodes the rest sorted by key.
+// Values keep their order within a key since it can be significant.
+func canonicalQuery(query url.Values) string {
+	for key := range query {
+		if isTracking(key) {
+			delete(query, key)
+		}
+	}
+	if len(query) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		for _, value := range query[key] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(key))
+			if value != "" {
+				b.WriteByte('=')
+				b.WriteString(url.QueryEscape(value))
+			}
+		}
+	}
+	return b.String()
+}
+
+func isTracking(key string) bool {
+	key = strings.ToLower(key)
+	if trackingParams[key] {
+		return true
+	}
+	for _, prefix := range trackingPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
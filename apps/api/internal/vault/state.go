@@ -0,0 +1,80 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StateFile is where a synced directory remembers what was written to it.
+// Obsidian ignores folders starting with a dot.
+const StateFile = ".desis-keep/sync.json"
+
+// State records what the last sync wrote, so the next one only rewrites
+// notes that changed and removes files of notes that are gone.
+type State struct {
+	UserID      uint               `json:"user_id"`
+	SyncedAt    time.Time          `json:"synced_at"`
+	Notes       map[uint]NoteState `json:"notes"`
+	Attachments map[uint]string    `json:"attachments"` // image ID to vault path
+	Indexes     map[string]string  `json:"indexes"`     // index file to a hash of its content
+}
+
+// NoteState is where a note was written, the version written and the
+// attachments it embedded, so a note is rewritten when images it refers to
+// come or go.
+type NoteState struct {
+	Path        string    `json:"path"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Attachments []string  `json:"attachments,omitempty"`
+}
+
+// NewState returns an empty state for a user's vault.
+func NewState(userID uint) *State {
+	return &State{
+		UserID:      userID,
+		Notes:       map[uint]NoteState{},
+		Attachments: map[uint]string{},
+		Indexes:     map[string]string{},
+	}
+}
+
+// LoadState reads the state of a synced directory, or returns an empty state
+// for userID when the directory was never synced.
+func LoadState(root string, userID uint) (*State, error) {
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(StateFile)))
+	if errors.Is(err, os.ErrNotExist) {
+		return NewState(userID), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading sync state: %w", err)
+	}
+
+	state := NewState(userID)
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("reading sync state: %w", err)
+	}
+	if state.UserID != userID {
+		return nil, fmt.Errorf("%s holds the vault of another account (user %d)", root, state.UserID)
+	}
+	return state, nil
+}
+
+// Save writes the state into a synced directory.
+func (s *State) Save(root string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding sync state: %w", err)
+	}
+	path := filepath.Join(root, filepath.FromSlash(StateFile))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("saving sync state: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("saving sync state: %w", err)
+	}
+	return nil
+}
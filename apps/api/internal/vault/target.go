@@ -0,0 +1,100 @@
+package vault
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Target is where a vault is written. Names are slash-separated paths
+// relative to the vault root.
+type Target interface {
+	// Exists reports whether name is present, so a sync can restore files
+	// removed by hand.
+	Exists(name string) bool
+	Write(name string, modified time.Time, r io.Reader) error
+	Remove(name string) error
+}
+
+// DirTarget writes a vault to a local directory.
+type DirTarget struct {
+	Root string
+}
+
+// Exists reports whether the file exists in the directory.
+func (t DirTarget) Exists(name string) bool {
+	_, err := os.Stat(t.path(name))
+	return err == nil
+}
+
+// Write replaces a file, going through a temporary file so an interrupted
+// sync never leaves a half-written note, and sets its modification time.
+func (t DirTarget) Write(name string, modified time.Time, r io.Reader) error {
+	dest := t.path(name)
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return fmt.Errorf("creating folder for %s: %w", name, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".sync-*")
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	if !modified.IsZero() {
+		os.Chtimes(dest, modified, modified)
+	}
+	return nil
+}
+
+// Remove deletes a file; a file that is already gone is not an error.
+func (t DirTarget) Remove(name string) error {
+	if err := os.Remove(t.path(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing %s: %w", name, err)
+	}
+	return nil
+}
+
+func (t DirTarget) path(name string) string {
+	return filepath.Join(t.Root, filepath.FromSlash(name))
+}
+
+// ZipTarget writes a vault into a zip archive. Archives start out empty, so
+// nothing exists and nothing is removed.
+type ZipTarget struct {
+	Writer *zip.Writer
+}
+
+// Exists always reports false.
+func (t ZipTarget) Exists(name string) bool { return false }
+
+// Write adds a file to the archive.
+func (t ZipTarget) Write(name string, modified time.Time, r io.Reader) error {
+	w, err := t.Writer.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return fmt.Errorf("adding %s: %w", name, err)
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("adding %s: %w", name, err)
+	}
+	return nil
+}
+
+// Remove does nothing.
+func (t ZipTarget) Remove(name string) error { return nil }
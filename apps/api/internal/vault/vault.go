@@ -0,0 +1,333 @@
+// Package vault renders notes, links and images as a folder of Markdown files
+// that Obsidian and other Markdown editors open as a vault:
+//
+//	<title>.md              a note, with labels as tags in its front matter
+//	Archive/<title>.md      an archived note
+//	attachments/<id>-<name> an image, embedded by relative path in the notes
+//	                        that refer to it
+//	Images.md               an index embedding every image
+//	Links.md                an index of every link
+//	.desis-keep/sync.json   what the last sync wrote, see State
+package vault
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Index files at the top of the vault.
+const (
+	LinksFile  = "Links.md"
+	ImagesFile = "Images.md"
+)
+
+const (
+	archiveFolder    = "Archive"
+	attachmentFolder = "attachments"
+	// maxTitleRunes caps the title part of a note's file name.
+	maxTitleRunes = 100
+)
+
+// Note is a note as written to the vault.
+type Note struct {
+	ID         uint
+	Title      string
+	Body       string
+	Color      string
+	Tags       []string
+	IsPinned   bool
+	IsArchived bool
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Link is an entry of the links index.
+type Link struct {
+	URL         string
+	Title       string
+	Description string
+	Tags        []string
+	IsArchived  bool
+}
+
+// Image is an entry of the images index; Path is its attachment in the vault.
+// Notes refer to an image by its URL or its keep://image/<ID> URI.
+type Image struct {
+	ID    uint
+	Title string
+	URL   string
+	Path  string
+}
+
+var (
+	// markdownLinkPattern matches Markdown links and images, capturing the
+	// text part and the destination, bare or in angle brackets.
+	markdownLinkPattern = regexp.MustCompile(`(!?\[[^\]\n]*\]\()(<[^>\n]*>|[^()\s]+)\)`)
+	imageURIPattern     = regexp.MustCompile(`keep://image/([0-9]+)`)
+)
+
+// Attachments finds the images that note bodies refer to.
+type Attachments struct {
+	byURL map[string]Image
+	byID  map[uint]Image
+}
+
+// NewAttachments indexes the images written to a vault.
+func NewAttachments(images []Image) *Attachments {
+	a := &Attachments{byURL: map[string]Image{}, byID: map[uint]Image{}}
+	for _, img := range images {
+		if img.URL != "" {
+			a.byURL[img.URL] = img
+		}
+		a.byID[img.ID] = img
+	}
+	return a
+}
+
+// Embed points the references a note body makes to images in the vault at
+// their attachments, by paths relative to the note at notePath: Markdown
+// links and images to an image's URL or URI get the path as destination,
+// and bare URIs become embeds. It returns the body and the attachments it
+// refers to, in order.
+func (a *Attachments) Embed(body, notePath string) (string, []string) {
+	var embeds []string
+	seen := map[string]bool{}
+	use := func(img Image) string {
+		if !seen[img.Path] {
+			seen[img.Path] = true
+			embeds = append(embeds, img.Path)
+		}
+		return strings.Repeat("../", strings.Count(notePath, "/")) + img.Path
+	}
+
+	body = markdownLinkPattern.ReplaceAllStringFunc(body, func(m string) string {
+		parts := markdownLinkPattern.FindStringSubmatch(m)
+		img, ok := a.find(strings.TrimSuffix(strings.TrimPrefix(parts[2], "<"), ">"))
+		if !ok {
+			return m
+		}
+		return parts[1] + "<" + use(img) + ">)"
+	})
+	body = imageURIPattern.ReplaceAllStringFunc(body, func(m string) string {
+		img, ok := a.find(m)
+		if !ok {
+			return m
+		}
+		return fmt.Sprintf("![%s](<%s>)", escapeLinkText(imageTitle(img)), use(img))
+	})
+	return body, embeds
+}
+
+// find looks an image up by its URL or URI.
+func (a *Attachments) find(ref string) (Image, bool) {
+	if m := imageURIPattern.FindStringSubmatch(ref); m != nil && m[0] == ref {
+		id, err := strconv.ParseUint(m[1], 10, 64)
+		if err != nil {
+			return Image{}, false
+		}
+		img, ok := a.byID[uint(id)]
+		return img, ok
+	}
+	img, ok := a.byURL[ref]
+	return img, ok
+}
+
+// Namer hands out note file names that are unique within a vault, ignoring
+// case since macOS and Windows file systems do.
+type Namer struct {
+	used map[string]bool
+}
+
+// NewNamer creates a Namer that never hands out the index file names.
+func NewNamer() *Namer {
+	return &Namer{used: map[string]bool{
+		strings.ToLower(LinksFile):  true,
+		strings.ToLower(ImagesFile): true,
+	}}
+}
+
+// NotePath returns the vault path of a note, named after its title. Notes
+// with the same title get " (2)", " (3)" and so on, in the order they are named.
+func (n *Namer) NotePath(note Note) string {
+	base := fileTitle(note.Title)
+	folder := ""
+	if note.IsArchived {
+		folder = archiveFolder + "/"
+	}
+	name := folder + base + ".md"
+	for i := 2; n.used[strings.ToLower(name)]; i++ {
+		name = fmt.Sprintf("%s%s (%d).md", folder, base, i)
+	}
+	n.used[strings.ToLower(name)] = true
+	return name
+}
+
+// AttachmentPath returns the vault path of an image.
+func AttachmentPath(id uint, name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '_' || r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('-')
+		}
+	}
+	clean := strings.Trim(b.String(), "-.")
+	if clean == "" {
+		return fmt.Sprintf("%s/%d", attachmentFolder, id)
+	}
+	return fmt.Sprintf("%s/%d-%s", attachmentFolder, id, clean)
+}
+
+// Tag turns a label name into an Obsidian tag: spaces become dashes and
+// characters tags cannot hold are dropped. Tags made only of digits are not
+// allowed, so those get a "label-" prefix. It returns "" when nothing is left.
+func Tag(name string) string {
+	var b strings.Builder
+	digits := true
+	for _, r := range strings.Join(strings.Fields(name), "-") {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '/' {
+			b.WriteRune(r)
+			if !unicode.IsDigit(r) {
+				digits = false
+			}
+		}
+	}
+	tag := strings.Trim(b.String(), "/")
+	if tag != "" && digits {
+		tag = "label-" + tag
+	}
+	return tag
+}
+
+// WriteNote writes a note with YAML front matter that Obsidian reads as
+// properties, followed by the body as stored.
+func WriteNote(w io.Writer, n Note) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("---\n")
+	fmt.Fprintf(bw, "title: %s\n", yamlString(n.Title))
+	tags := make([]string, len(n.Tags))
+	for i, tag := range n.Tags {
+		tags[i] = yamlString(tag)
+	}
+	fmt.Fprintf(bw, "tags: [%s]\n", strings.Join(tags, ", "))
+	fmt.Fprintf(bw, "color: %s\n", yamlString(n.Color))
+	fmt.Fprintf(bw, "pinned: %t\n", n.IsPinned)
+	fmt.Fprintf(bw, "archived: %t\n", n.IsArchived)
+	fmt.Fprintf(bw, "created: %s\n", n.CreatedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(bw, "updated: %s\n", n.UpdatedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(bw, "desis_keep_id: %d\n", n.ID)
+	bw.WriteString("---\n\n")
+	bw.WriteString(n.Body)
+	return bw.Flush()
+}
+
+// WriteLinks writes the links index: one list item per link with its tags
+// and the first line of its description, archived links in their own section.
+func WriteLinks(w io.Writer, links []Link) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# Links\n")
+	var active, archived []Link
+	for _, l := range links {
+		if l.IsArchived {
+			archived = append(archived, l)
+		} else {
+			active = append(active, l)
+		}
+	}
+	writeLinkList(bw, active)
+	if len(archived) > 0 {
+		bw.WriteString("\n## Archived\n")
+		writeLinkList(bw, archived)
+	}
+	return bw.Flush()
+}
+
+func writeLinkList(w *bufio.Writer, links []Link) {
+	w.WriteByte('\n')
+	for _, l := range links {
+		title := l.Title
+		if title == "" {
+			title = l.URL
+		}
+		fmt.Fprintf(w, "- [%s](<%s>)", escapeLinkText(title), l.URL)
+		for _, tag := range l.Tags {
+			fmt.Fprintf(w, " #%s", tag)
+		}
+		w.WriteByte('\n')
+		if desc := firstLine(l.Description); desc != "" {
+			fmt.Fprintf(w, "  %s\n", desc)
+		}
+	}
+}
+
+// WriteImages writes the images index, embedding each image by its relative path.
+func WriteImages(w io.Writer, images []Image) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# Images\n")
+	for _, img := range images {
+		title := imageTitle(img)
+		fmt.Fprintf(bw, "\n## %s\n\n![%s](<%s>)\n", strings.TrimSpace(firstLine(title)), escapeLinkText(title), img.Path)
+	}
+	return bw.Flush()
+}
+
+// imageTitle returns an image's title, or its file name when it has none.
+func imageTitle(img Image) string {
+	if img.Title != "" {
+		return img.Title
+	}
+	return img.Path[strings.LastIndex(img.Path, "/")+1:]
+}
+
+// fileTitle makes a title safe as a file name on every platform and in
+// Obsidian links, which also reserve # ^ [ ] and |.
+func fileTitle(title string) string {
+	var b strings.Builder
+	count := 0
+	for _, r := range strings.Join(strings.Fields(title), " ") {
+		if count == maxTitleRunes {
+			break
+		}
+		if strings.ContainsRune(`\/:*?"<>|#^[]`, r) || unicode.IsControl(r) {
+			r = ' '
+		}
+		b.WriteRune(r)
+		count++
+	}
+	name := strings.Trim(strings.Join(strings.Fields(b.String()), " "), ". ")
+	if name == "" {
+		return "Untitled"
+	}
+	return name
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+func escapeLinkText(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.NewReplacer(`[`, `\[`, `]`, `\]`).Replace(s)
+}
+
+// yamlString quotes s as a JSON string, which is also a valid YAML scalar.
+func yamlString(s string) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,46 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEmbed(t *testing.T) {
+	a := NewAttachments([]Image{
+		{ID: 7, Title: "Cat", URL: "https://cdn.example.com/u/1/cat.png", Path: "attachments/7-cat.png"},
+		{ID: 9, URL: "https://cdn.example.com/u/1/dog.jpg", Path: "attachments/9-dog.jpg"},
+	})
+
+	tests := []struct {
+		name       string
+		body, path string
+		want       string
+		embeds     []string
+	}{
+		{"image by URL", "see ![cat](https://cdn.example.com/u/1/cat.png)", "Note.md",
+			"see ![cat](<attachments/7-cat.png>)", []string{"attachments/7-cat.png"}},
+		{"archived note", "![cat](https://cdn.example.com/u/1/cat.png)", "Archive/Note.md",
+			"![cat](<../attachments/7-cat.png>)", []string{"attachments/7-cat.png"}},
+		{"URL in angle brackets", "![](<https://cdn.example.com/u/1/dog.jpg>)", "Note.md",
+			"![](<attachments/9-dog.jpg>)", []string{"attachments/9-dog.jpg"}},
+		{"link to a URI", "[the cat](keep://image/7)", "Note.md",
+			"[the cat](<attachments/7-cat.png>)", []string{"attachments/7-cat.png"}},
+		{"bare URI", "look: keep://image/9 and keep://image/7", "Note.md",
+			"look: ![9-dog.jpg](<attachments/9-dog.jpg>) and ![Cat](<attachments/7-cat.png>)",
+			[]string{"attachments/9-dog.jpg", "attachments/7-cat.png"}},
+		{"embedded twice", "keep://image/7 ![x](https://cdn.example.com/u/1/cat.png)", "Note.md",
+			"![Cat](<attachments/7-cat.png>) ![x](<attachments/7-cat.png>)", []string{"attachments/7-cat.png"}},
+		{"unknown image", "keep://image/8 ![x](https://cdn.example.com/u/1/other.png)", "Note.md",
+			"keep://image/8 ![x](https://cdn.example.com/u/1/other.png)", nil},
+		{"other resources", "[n](keep://note/7) keep://file/7", "Note.md",
+			"[n](keep://note/7) keep://file/7", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, embeds := a.Embed(tt.body, tt.path)
+			if got != tt.want || !reflect.DeepEqual(embeds, tt.embeds) {
+				t.Errorf("Embed(%q, %q) = %q, %q; want %q, %q", tt.body, tt.path, got, embeds, tt.want, tt.embeds)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+// Package webfetch downloads user-supplied URLs safely. Requests may only
+// reach public addresses: every connection, including each redirect hop, is
+// checked after DNS resolution so hostnames that resolve to private, loopback
+// or link-local ranges are refused. Response bodies, redirects and total time
+// are all capped.
+package webfetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Defaults applied when Options fields are zero.
+const (
+	DefaultTimeout      = 10 * time.Second
+	DefaultMaxBytes     = 2 << 20 // 2 MB
+	DefaultMaxRedirects = 5
+	DefaultUserAgent    = "Desis-Keep/1.0 (+link preview)"
+)
+
+// ErrBlockedAddress is returned when a URL resolves to a non-public address.
+var ErrBlockedAddress = errors.New("address is not publicly routable")
+
+// ErrInvalidURL is returned for URLs that are not plain http(s) addresses.
+var ErrInvalidURL = errors.New("invalid URL")
+
+// ErrTooLarge is returned by GetAll when the body exceeds the size limit.
+var ErrTooLarge = errors.New("response body too large")
+
+// Options configures a Client.
+type Options struct {
+	Timeout      time.Duration // whole request, including redirects and reading the body
+	MaxBytes     int64         // body size cap
+	MaxRedirects int
+	UserAgent    string
+	// AllowPrivate disables the address checks. Only for tests against httptest servers.
+	AllowPrivate bool
+}
+
+// Client is an HTTP client hardened for fetching arbitrary user-supplied URLs.
+type Client struct {
+	http     *http.Client
+	maxBytes int64
+	agent    string
+}
+
+// Response is a fetched page or file.
+type Response struct {
+	URL         *url.URL // final URL after redirects
+	StatusCode  int
+	ContentType string // media type without parameters, lowercased
+	Body        []byte
+	Truncated   bool       // Body was cut at the size limit
+	Redirects   []Redirect // hops followed to reach URL, in order
+}
+
+// Redirect is one redirect hop: the status that caused it and where it led.
+type Redirect struct {
+	StatusCode int
+	Location   string
+}
+
+// PermanentlyMoved reports whether the response was reached only through
+// permanent redirects (301 or 308), i.e. the original URL should be replaced.
+func (r *Response) PermanentlyMoved() bool {
+	if len(r.Redirects) == 0 {
+		return false
+	}
+	for _, hop := range r.Redirects {
+		if hop.StatusCode != http.StatusMovedPermanently && hop.StatusCode != http.StatusPermanentRedirect {
+			return false
+		}
+	}
+	return true
+}
+
+// redirectsKey carries a request's redirect log through its context.
+type redirectsKey struct{}
+
+// New creates a Client.
+func New(opts Options) *Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	if opts.MaxRedirects <= 0 {
+		opts.MaxRedirects = DefaultMaxRedirects
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultUserAgent
+	}
+
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !opts.AllowPrivate {
+		// Control runs after DNS resolution, on the exact address being dialed,
+		// so DNS rebinding cannot slip a private address past the check.
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if !IsPublicAddr(addr) {
+				return fmt.Errorf("dialing %s: %w", host, ErrBlockedAddress)
+			}
+			return nil
+		}
+	}
+
+	transport := &http.Transport{
+		// Never route through an environment proxy: the proxy would do the
+		// resolving and bypass the address checks.
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: opts.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+
+	maxRedirects := opts.MaxRedirects
+	return &Client{
+		http: &http.Client{
+			Timeout:   opts.Timeout,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				// via holds the requests made so far: the original and each hop
+				if len(via) > maxRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRedirects)
+				}
+				if err := checkURL(req.URL); err != nil {
+					return err
+				}
+				if hops, ok := req.Context().Value(redirectsKey{}).(*[]Redirect); ok && req.Response != nil {
+					*hops = append(*hops, Redirect{StatusCode: req.Response.StatusCode, Location: req.URL.String()})
+				}
+				return nil
+			},
+		},
+		maxBytes: opts.MaxBytes,
+		agent:    opts.UserAgent,
+	}
+}
+
+// Get fetches rawURL. Bodies larger than the size limit are truncated and
+// flagged, which is fine for HTML where the metadata sits in the head.
+// Non-2xx responses are returned without error so callers can inspect the status.
+func (c *Client) Get(ctx context.Context, rawURL string, accept string) (*Response, error) {
+	return c.do(ctx, http.MethodGet, rawURL, accept)
+}
+
+// Head requests rawURL's headers only. The returned Body is empty.
+func (c *Client) Head(ctx context.Context, rawURL string) (*Response, error) {
+	return c.do(ctx, http.MethodHead, rawURL, "")
+}
+
+func (c *Client) do(ctx context.Context, method, rawURL, accept string) (*Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	if err := checkURL(u); err != nil {
+		return nil, err
+	}
+
+	var redirects []Redirect
+	ctx = context.WithValue(ctx, redirectsKey{}, &redirects)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", c.agent)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", u.Redacted(), err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", u.Redacted(), err)
+	}
+
+	result := &Response{
+		URL:         resp.Request.URL,
+		StatusCode:  resp.StatusCode,
+		ContentType: mediaType(resp.Header.Get("Content-Type")),
+		Body:        body,
+		Redirects:   redirects,
+	}
+	if int64(len(body)) > c.maxBytes {
+		result.Body = body[:c.maxBytes]
+		result.Truncated = true
+	}
+	return result, nil
+}
+
+// GetAll is like Get but fails with ErrTooLarge instead of truncating,
+// for binary content such as images that is useless when cut short.
+func (c *Client) GetAll(ctx context.Context, rawURL string, accept string) (*Response, error) {
+	resp, err := c.Get(ctx, rawURL, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Truncated {
+		return nil, fmt.Errorf("fetching %s: %w", rawURL, ErrTooLarge)
+	}
+	return resp, nil
+}
+
+// IsPublicAddr reports whether addr is a globally routable unicast address.
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || !addr.IsGlobalUnicast() || addr.IsPrivate() ||
+		addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// blockedPrefixes are special-purpose ranges not covered by the netip predicates.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // TEST-NET-1
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // TEST-NET-2
+	netip.MustParsePrefix("203.0.113.0/24"),  // TEST-NET-3
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, can embed private IPv4
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+}
+
+// checkURL rejects non-HTTP schemes and embedded credentials before any connection is made.
+func checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: unsupported scheme %q", ErrInvalidURL, u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%w: no host", ErrInvalidURL)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: credentials are not allowed", ErrInvalidURL)
+	}
+	return nil
+}
+
+// mediaType strips parameters from a Content-Type header.
+func mediaType(contentType string) string {
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
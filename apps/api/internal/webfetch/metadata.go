@@ -0,0 +1,139 @@
+package webfetch
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// Metadata is the preview information found in a page's <head>.
+type Metadata struct {
+	Title       string
+	Description string
+	ImageURL    string // absolute
+	FaviconURL  string // absolute; /favicon.ico when the page declares none
+	SiteName    string
+}
+
+// ParseMetadata extracts the title, description, preview image and favicon
+// from an HTML document. OpenGraph tags win over Twitter card tags, which win
+// over plain <title> and <meta name="description">. Relative URLs are
+// resolved against base, honoring <base href>.
+func ParseMetadata(body []byte, contentType string, base *url.URL) Metadata {
+	var (
+		meta     = map[string]string{}
+		title    string
+		icons    = map[string]string{}
+		inTitle  bool
+		titleBuf strings.Builder
+	)
+
+	reader, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	z := html.NewTokenizer(reader)
+loop:
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			break loop
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			attrs := map[string]string{}
+			for hasAttr {
+				var k, v []byte
+				k, v, hasAttr = z.TagAttr()
+				attrs[string(k)] = string(v)
+			}
+			switch string(name) {
+			case "title":
+				if title == "" && tt == html.StartTagToken {
+					inTitle = true
+				}
+			case "meta":
+				key := strings.ToLower(attrs["property"])
+				if key == "" {
+					key = strings.ToLower(attrs["name"])
+				}
+				if key != "" && attrs["content"] != "" {
+					if _, seen := meta[key]; !seen {
+						meta[key] = strings.TrimSpace(attrs["content"])
+					}
+				}
+			case "link":
+				for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+					if _, seen := icons[rel]; !seen && attrs["href"] != "" {
+						icons[rel] = attrs["href"]
+					}
+				}
+			case "base":
+				if href, ok := attrs["href"]; ok && base != nil {
+					if u, err := base.Parse(href); err == nil {
+						base = u
+					}
+				}
+			case "body":
+				// Everything we need lives in <head>
+				break loop
+			}
+		case html.TextToken:
+			if inTitle {
+				titleBuf.Write(z.Text())
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "title" && inTitle {
+				inTitle = false
+				title = titleBuf.String()
+			}
+		}
+	}
+	if title == "" {
+		title = titleBuf.String()
+	}
+
+	m := Metadata{
+		Title:       collapse(first(meta["og:title"], meta["twitter:title"], title)),
+		Description: collapse(first(meta["og:description"], meta["twitter:description"], meta["description"])),
+		ImageURL:    resolve(base, first(meta["og:image:secure_url"], meta["og:image"], meta["og:image:url"], meta["twitter:image"], meta["twitter:image:src"])),
+		FaviconURL:  resolve(base, first(icons["icon"], icons["apple-touch-icon"], icons["apple-touch-icon-precomposed"])),
+		SiteName:    collapse(meta["og:site_name"]),
+	}
+	if m.FaviconURL == "" && base != nil {
+		m.FaviconURL = resolve(base, "/favicon.ico")
+	}
+	return m
+}
+
+// first returns the first non-empty value.
+func first(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// collapse trims a string and folds runs of whitespace into single spaces.
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// resolve makes ref absolute against base, returning "" for unusable references.
+func resolve(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || base == nil {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
@@ -0,0 +1,99 @@
+// Package wikilink finds references to other resources in note bodies:
+// wiki links to notes by title, written [[Note title]], optionally with a
+// heading ([[Note title#Heading]]) or a display text ([[Note title|text]]),
+// and resource URIs such as keep://link/42.
+package wikilink
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxTitleLength is the longest title a wiki link can name, matching the
+// length of note titles.
+const MaxTitleLength = 500
+
+var (
+	wikiPattern = regexp.MustCompile(`\[\[([^\[\]\n]+)\]\]`)
+	uriPattern  = regexp.MustCompile(`keep://(note|link|image|file)/([0-9]+)`)
+)
+
+// Ref is one reference in a body: a wiki link with its Title, or a URI with
+// the Type and ID of the resource it names.
+type Ref struct {
+	Title string // wiki link target; empty for a URI
+	Type  string // resource type of a URI: note, link, image or file
+	ID    uint   // resource ID of a URI
+}
+
+// IsWiki reports whether the reference is a wiki link.
+func (r Ref) IsWiki() bool {
+	return r.Title != ""
+}
+
+// Parse returns the references in body, each once, in order of first
+// appearance. Wiki links that differ only in letter case are the same.
+func Parse(body string) []Ref {
+	var refs []Ref
+	seen := make(map[string]bool)
+
+	for _, m := range wikiPattern.FindAllStringSubmatch(body, -1) {
+		title, _, _ := split(m[1])
+		if title == "" || utf8.RuneCountInString(title) > MaxTitleLength {
+			continue
+		}
+		key := "wiki:" + strings.ToLower(title)
+		if !seen[key] {
+			seen[key] = true
+			refs = append(refs, Ref{Title: title})
+		}
+	}
+
+	for _, m := range uriPattern.FindAllStringSubmatch(body, -1) {
+		id, err := strconv.ParseUint(m[2], 10, 64)
+		if err != nil || id == 0 {
+			continue
+		}
+		key := m[1] + ":" + m[2]
+		if !seen[key] {
+			seen[key] = true
+			refs = append(refs, Ref{Type: m[1], ID: uint(id)})
+		}
+	}
+	return refs
+}
+
+// Rewrite changes the wiki links in body that name oldTitle, in any letter
+// case, to name newTitle, keeping their heading and display text. It returns
+// the new body and how many links it changed. Nothing changes when
+// newTitle cannot be written in a wiki link.
+func Rewrite(body, oldTitle, newTitle string) (string, int) {
+	newTitle = strings.TrimSpace(newTitle)
+	if newTitle == "" || strings.ContainsAny(newTitle, "[]|#\n") {
+		return body, 0
+	}
+	changed := 0
+	out := wikiPattern.ReplaceAllStringFunc(body, func(link string) string {
+		title, heading, text := split(link[2 : len(link)-2])
+		if !strings.EqualFold(title, oldTitle) || title == newTitle {
+			return link
+		}
+		changed++
+		return "[[" + newTitle + heading + text + "]]"
+	})
+	return out, changed
+}
+
+// split separates the inside of a wiki link into its trimmed title and the
+// "#heading" and "|text" parts that follow it.
+func split(inner string) (title, heading, text string) {
+	if i := strings.IndexByte(inner, '|'); i >= 0 {
+		inner, text = inner[:i], inner[i:]
+	}
+	if i := strings.IndexByte(inner, '#'); i >= 0 {
+		inner, heading = inner[:i], inner[i:]
+	}
+	return strings.TrimSpace(inner), heading, text
+}